@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+	"github.com/rinodrops/lingua-cli-go/langid/linguahttp"
+)
+
+// auditTextModes are the values of -audit-text, how much of a text the
+// audit log of serve records.
+var auditTextModes = []string{"hash", "truncate", "full", "none"}
+
+// auditEntry is a line of the audit log of serve.
+type auditEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id"`
+	RemoteAddr string  `json:"remote_addr"`
+	User       string  `json:"user,omitempty"`
+	Chars      int     `json:"chars"`
+	TextSHA256 string  `json:"text_sha256,omitempty"`
+	Text       *string `json:"text,omitempty"`
+	Language   string  `json:"language"`
+	Score      float64 `json:"score"`
+}
+
+// auditLog writes a JSON line for every detection serve answers to a file,
+// which is opened again for every reload, so that it can be rotated.
+type auditLog struct {
+	mu         sync.Mutex
+	f          *os.File
+	text       string
+	chars      int
+	userHeader string
+}
+
+// openAuditLog opens the audit log at path for appending, recording texts
+// as the -audit-text mode text says, truncated to chars characters, and the
+// user of each request from userHeader, if set.
+func openAuditLog(path, text string, chars int, userHeader string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{f: f, text: text, chars: chars, userHeader: userHeader}, nil
+}
+
+// observe records a detection, as a linguahttp.Config.Observe.
+func (a *auditLog) observe(r *http.Request, text string, result langid.Result) {
+	entry := auditEntry{
+		Time:       time.Now().UTC().Format(time.RFC3339Nano),
+		RequestID:  linguahttp.RequestIDFromContext(r.Context()),
+		RemoteAddr: r.RemoteAddr,
+		Chars:      utf8.RuneCountInString(text),
+		Language:   result.Language,
+		Score:      result.Score,
+	}
+	if a.userHeader != "" {
+		entry.User = r.Header.Get(a.userHeader)
+	}
+	switch a.text {
+	case "hash":
+		sum := sha256.Sum256([]byte(text))
+		entry.TextSHA256 = hex.EncodeToString(sum[:])
+	case "truncate":
+		if entry.Chars > a.chars {
+			text = string([]rune(text)[:a.chars])
+		}
+		entry.Text = &text
+	case "full":
+		entry.Text = &text
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(line, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -audit-log: %v\n", err)
+	}
+}
+
+func (a *auditLog) close() error {
+	return a.f.Close()
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"encoding/binary"
+	"math"
+)
+
+// avroSchema is the Avro schema of the records of -format avro, embedded in
+// the header of every container file written.
+//
+//go:embed schema/avro-v1.avsc
+var avroSchema []byte
+
+// avroBlockRecords is the number of records per block of a container file
+// unless results are flushed one by one.
+const avroBlockRecords = 1000
+
+// avroWriter writes json-v1 records as an Avro object container file, in
+// blocks of records without compression.
+type avroWriter struct {
+	w *outputWriter
+	// sync is the marker ending the header and every block.
+	sync    [16]byte
+	started bool
+	block   []byte
+	count   int
+}
+
+func newAvroWriter(w *outputWriter) *avroWriter {
+	a := &avroWriter{w: w}
+	rand.Read(a.sync[:])
+	return a
+}
+
+// add appends the record of a result, writing the block once it is full, or
+// at once with flush.
+func (a *avroWriter) add(r jsonRecord, flush bool) {
+	a.block = appendAvroRecord(a.block, r)
+	a.count++
+	if flush || a.count >= avroBlockRecords {
+		a.writeBlock()
+	}
+}
+
+// writeBlock writes the header if it was not written yet, and the pending
+// records as a block.
+func (a *avroWriter) writeBlock() {
+	if !a.started {
+		header := []byte("Obj\x01")
+		header = appendAvroLong(header, 2)
+		header = appendAvroString(header, "avro.schema")
+		header = appendAvroBytes(header, avroSchema)
+		header = appendAvroString(header, "avro.codec")
+		header = appendAvroString(header, "null")
+		header = appendAvroLong(header, 0)
+		a.w.Write(header)
+		a.w.Write(a.sync[:])
+		a.started = true
+	}
+	if a.count == 0 {
+		return
+	}
+	var prefix []byte
+	prefix = appendAvroLong(prefix, int64(a.count))
+	prefix = appendAvroLong(prefix, int64(len(a.block)))
+	a.w.Write(prefix)
+	a.w.Write(a.block)
+	a.w.Write(a.sync[:])
+	a.block, a.count = a.block[:0], 0
+}
+
+// close writes the remaining records, and the header of a file without
+// records.
+func (a *avroWriter) close() {
+	a.writeBlock()
+}
+
+// appendAvroRecord appends r in the binary encoding of the Result record of
+// avroSchema, whose optional fields are unions of null, branch 0, and their
+// type, branch 1.
+func appendAvroRecord(buf []byte, r jsonRecord) []byte {
+	optionalString := func(buf []byte, s *string) []byte {
+		if s == nil {
+			return appendAvroLong(buf, 0)
+		}
+		return appendAvroString(appendAvroLong(buf, 1), *s)
+	}
+	optionalDouble := func(buf []byte, f *float64) []byte {
+		if f == nil {
+			return appendAvroLong(buf, 0)
+		}
+		return appendAvroDouble(appendAvroLong(buf, 1), *f)
+	}
+	nonEmpty := func(s string) *string {
+		if s == "" {
+			return nil
+		}
+		return &s
+	}
+	buf = optionalString(buf, nonEmpty(r.File))
+	if r.Line > 0 {
+		buf = appendAvroLong(appendAvroLong(buf, 1), int64(r.Line))
+	} else {
+		buf = appendAvroLong(buf, 0)
+	}
+	buf = optionalString(buf, nonEmpty(r.Source))
+	buf = optionalString(buf, r.Language)
+	buf = optionalDouble(buf, r.Score)
+	buf = optionalDouble(buf, r.Entropy)
+	buf = optionalDouble(buf, r.Margin)
+	if r.MixedScript != nil {
+		buf = appendAvroArrayCount(appendAvroLong(buf, 1), len(r.MixedScript))
+		for _, script := range r.MixedScript {
+			buf = appendAvroString(buf, script)
+		}
+		buf = appendAvroLong(buf, 0)
+	} else {
+		buf = appendAvroLong(buf, 0)
+	}
+	buf = optionalString(buf, nonEmpty(r.Encoding))
+	if r.Confidences != nil {
+		buf = appendAvroArrayCount(appendAvroLong(buf, 1), len(r.Confidences))
+		for _, c := range r.Confidences {
+			buf = appendAvroDouble(appendAvroString(buf, c.Language), c.Score)
+		}
+		buf = appendAvroLong(buf, 0)
+	} else {
+		buf = appendAvroLong(buf, 0)
+	}
+	if r.Spans != nil {
+		buf = appendAvroArrayCount(appendAvroLong(buf, 1), len(r.Spans))
+		for _, s := range r.Spans {
+			buf = appendAvroLong(buf, int64(s.Start))
+			buf = appendAvroLong(buf, int64(s.End))
+			buf = appendAvroString(buf, s.Language)
+			buf = appendAvroString(buf, s.Text)
+		}
+		buf = appendAvroLong(buf, 0)
+	} else {
+		buf = appendAvroLong(buf, 0)
+	}
+	return optionalString(buf, r.Text)
+}
+
+// appendAvroLong appends n as a zigzag-encoded variable-length integer.
+func appendAvroLong(buf []byte, n int64) []byte {
+	return binary.AppendUvarint(buf, uint64(n<<1^n>>63))
+}
+
+// appendAvroArrayCount starts an array of n items as a single block, which
+// the caller ends with a block count of 0 after the items. Empty arrays have
+// no block.
+func appendAvroArrayCount(buf []byte, n int) []byte {
+	if n == 0 {
+		return buf
+	}
+	return appendAvroLong(buf, int64(n))
+}
+
+func appendAvroBytes(buf, b []byte) []byte {
+	return append(appendAvroLong(buf, int64(len(b))), b...)
+}
+
+func appendAvroString(buf []byte, s string) []byte {
+	return append(appendAvroLong(buf, int64(len(s))), s...)
+}
+
+func appendAvroDouble(buf []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAvroLong(t *testing.T) {
+	tests := []struct {
+		n       int64
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{-1, []byte{0x01}},
+		{1, []byte{0x02}},
+		{-2, []byte{0x03}},
+		{2, []byte{0x04}},
+		{-64, []byte{0x7f}},
+		{64, []byte{0x80, 0x01}},
+		{-65, []byte{0x81, 0x01}},
+		{8192, []byte{0x80, 0x80, 0x01}},
+		{math.MaxInt64, []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{math.MinInt64, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+	}
+	for _, test := range tests {
+		encoded := appendAvroLong(nil, test.n)
+		if !bytes.Equal(encoded, test.encoded) {
+			t.Errorf("appendAvroLong(%d) = % x, want % x", test.n, encoded, test.encoded)
+		}
+		d := &avroDecoder{data: encoded}
+		if n := d.long(); n != test.n || d.err != nil || len(d.data) != 0 {
+			t.Errorf("% x decoded as %d, %v", encoded, n, d.err)
+		}
+	}
+	if got := appendAvroString(nil, "foo"); !bytes.Equal(got, []byte{0x06, 'f', 'o', 'o'}) {
+		t.Errorf(`appendAvroString("foo") = % x`, got)
+	}
+	if got := appendAvroDouble(nil, 0.5); !bytes.Equal(got, []byte{0, 0, 0, 0, 0, 0, 0xe0, 0x3f}) {
+		t.Errorf("appendAvroDouble(0.5) = % x", got)
+	}
+}
+
+func TestAvroRecord(t *testing.T) {
+	language, score := "en", 0.5
+	r := jsonRecord{Line: 3, Language: &language, Score: &score}
+	want := []byte{
+		0x00,       // file
+		0x02, 0x06, // line 3
+		0x00,                 // source
+		0x02, 0x04, 'e', 'n', // language
+		0x02, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f, // score
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // entropy to text
+	}
+	if got := appendAvroRecord(nil, r); !bytes.Equal(got, want) {
+		t.Errorf("appendAvroRecord = % x, want % x", got, want)
+	}
+}
+
+// avroTestRecords are records with every field of the json-v1 format set in
+// one of them.
+func avroTestRecords() []jsonRecord {
+	fr, en, unknown, text := "fr", "en", "unknown", "Bonjour à tous"
+	score, entropy, margin := 0.97, 0.12, 0.8
+	return []jsonRecord{
+		{File: "a.txt", Line: 1, Source: "a.txt:1", Language: &fr, Score: &score, Entropy: &entropy, Margin: &margin,
+			MixedScript: []string{"Latn", "Cyrl"}, Encoding: "utf-8",
+			Confidences: []jsonConfidence{{"fr", 0.97}, {"en", 0.03}},
+			Spans:       []jsonSpan{{0, 8, "fr", "Bonjour "}, {8, 15, "en", "à tous"}},
+			Text:        &text},
+		{Language: &unknown, MixedScript: []string{}, Confidences: []jsonConfidence{}, Spans: []jsonSpan{}},
+		{Language: &en},
+	}
+}
+
+func TestAvroContainer(t *testing.T) {
+	var buf bytes.Buffer
+	w := &outputWriter{Writer: bufio.NewWriter(&buf)}
+	a := newAvroWriter(w)
+	records := avroTestRecords()
+	for i, r := range records {
+		a.add(r, i == 0)
+	}
+	a.close()
+	w.Flush()
+
+	d := &avroDecoder{data: buf.Bytes()}
+	if magic := d.fixed(4); string(magic) != "Obj\x01" {
+		t.Fatalf("magic % x", magic)
+	}
+	metadata := make(map[string]string)
+	for n := d.long(); n > 0 && d.err == nil; n-- {
+		key := d.string()
+		metadata[key] = d.string()
+	}
+	if d.long() != 0 {
+		t.Error("metadata of more than one block")
+	}
+	if metadata["avro.codec"] != "null" || metadata["avro.schema"] != string(avroSchema) {
+		t.Errorf("metadata %v", metadata)
+	}
+	var schema avroType
+	if err := json.Unmarshal(avroSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	sync := d.fixed(16)
+	var decoded []any
+	for len(d.data) > 0 && d.err == nil {
+		count, size := d.long(), d.long()
+		block := &avroDecoder{data: d.fixed(int(size))}
+		for ; count > 0; count-- {
+			decoded = append(decoded, block.value(schema))
+		}
+		if block.err == nil && len(block.data) != 0 {
+			block.err = fmt.Errorf("%d bytes left in the block", len(block.data))
+		}
+		if block.err != nil {
+			t.Fatal(block.err)
+		}
+		if !bytes.Equal(d.fixed(16), sync) {
+			t.Fatal("block not ended by the sync marker")
+		}
+	}
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	if len(decoded) != len(records) {
+		t.Fatalf("decoded %d records, want %d", len(decoded), len(records))
+	}
+	for i, r := range records {
+		if want := avroExpected(t, schema, r); !reflect.DeepEqual(decoded[i], want) {
+			t.Errorf("record %d decoded as\n%v\nwant\n%v", i, decoded[i], want)
+		}
+	}
+}
+
+// avroExpected returns the json-v1 record r as avroDecoder decodes it from
+// Avro: a map with all fields of the schema, nil for those absent, and
+// numbers as float64.
+func avroExpected(t *testing.T, schema avroType, r jsonRecord) map[string]any {
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+	// json-v1 omits empty arrays, which Avro keeps.
+	for key, set := range map[string]bool{"mixed_script": r.MixedScript != nil, "confidences": r.Confidences != nil, "spans": r.Spans != nil} {
+		if _, ok := record[key]; !ok && set {
+			record[key] = []any{}
+		}
+	}
+	for _, field := range schema.Fields {
+		if _, ok := record[field.Name]; !ok {
+			record[field.Name] = nil
+		}
+	}
+	return record
+}
+
+// avroType is the part of an Avro schema avroDecoder understands: records,
+// arrays, unions and primitive types.
+type avroType struct {
+	Name   string
+	Fields []struct {
+		Name string
+		Type avroType
+	}
+	Items *avroType
+	Union []avroType
+}
+
+func (a *avroType) UnmarshalJSON(data []byte) error {
+	switch data[0] {
+	case '"':
+		return json.Unmarshal(data, &a.Name)
+	case '[':
+		return json.Unmarshal(data, &a.Union)
+	}
+	var object struct {
+		Type   string
+		Name   string
+		Fields []struct {
+			Name string
+			Type avroType
+		}
+		Items *avroType
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	a.Name, a.Fields, a.Items = object.Type, object.Fields, object.Items
+	return nil
+}
+
+// avroDecoder decodes the Avro binary encoding, keeping the first error.
+type avroDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *avroDecoder) long() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 {
+		d.err = errors.New("malformed long")
+		return 0
+	}
+	d.data = d.data[size:]
+	return int64(n>>1) ^ -int64(n&1)
+}
+
+func (d *avroDecoder) fixed(n int) []byte {
+	if d.err != nil || n < 0 || n > len(d.data) {
+		if d.err == nil {
+			d.err = fmt.Errorf("%d bytes wanted, %d left", n, len(d.data))
+		}
+		return nil
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *avroDecoder) string() string {
+	return string(d.fixed(int(d.long())))
+}
+
+func (d *avroDecoder) value(t avroType) any {
+	if t.Union != nil {
+		branch := d.long()
+		if branch < 0 || branch >= int64(len(t.Union)) {
+			d.err = fmt.Errorf("union branch %d", branch)
+			return nil
+		}
+		return d.value(t.Union[branch])
+	}
+	switch t.Name {
+	case "null":
+		return nil
+	case "string":
+		return d.string()
+	case "long":
+		return float64(d.long())
+	case "double":
+		b := d.fixed(8)
+		if b == nil {
+			return nil
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	case "array":
+		items := []any{}
+		for n := d.long(); n != 0 && d.err == nil; n = d.long() {
+			if n < 0 {
+				// A negative count is followed by the size of the block.
+				n = -n
+				d.long()
+			}
+			for ; n > 0; n-- {
+				items = append(items, d.value(*t.Items))
+			}
+		}
+		return items
+	case "record":
+		record := make(map[string]any)
+		for _, field := range t.Fields {
+			record[field.Name] = d.value(field.Type)
+		}
+		return record
+	}
+	d.err = fmt.Errorf("unsupported type %q", t.Name)
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+// azureClient returns the client for az:// inputs, created on first use.
+// AZURE_STORAGE_CONNECTION_STRING selects the account and its key;
+// otherwise AZURE_STORAGE_ACCOUNT names the account, which is accessed with
+// the default Azure credential chain (environment, managed identity, Azure
+// CLI login).
+var azureClient = sync.OnceValues(func() (*azblob.Client, error) {
+	if connection := os.Getenv("AZURE_STORAGE_CONNECTION_STRING"); connection != "" {
+		return azblob.NewClientFromConnectionString(connection, nil)
+	}
+	account := os.Getenv("AZURE_STORAGE_ACCOUNT")
+	if account == "" {
+		return nil, fmt.Errorf("set AZURE_STORAGE_ACCOUNT or AZURE_STORAGE_CONNECTION_STRING to read az:// URIs")
+	}
+	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	return azblob.NewClient(fmt.Sprintf("https://%s.blob.core.windows.net/", account), credential, nil)
+})
+
+// azureStore reads az://container/blob objects from Azure Blob Storage.
+type azureStore struct{}
+
+func (azureStore) exists(ctx context.Context, container, blob string) (bool, error) {
+	client, err := azureClient()
+	if err != nil {
+		return false, err
+	}
+	_, err = client.ServiceClient().NewContainerClient(container).NewBlobClient(blob).GetProperties(ctx, nil)
+	if bloberror.HasCode(err, bloberror.BlobNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (azureStore) list(ctx context.Context, container, prefix string, send func(string)) error {
+	client, err := azureClient()
+	if err != nil {
+		return err
+	}
+	pages := client.NewListBlobsFlatPager(container, &azblob.ListBlobsFlatOptions{Prefix: &prefix})
+	for pages.More() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, item := range page.Segment.BlobItems {
+			send(*item.Name)
+		}
+	}
+	return nil
+}
+
+func (azureStore) open(ctx context.Context, container, blob string) (io.ReadCloser, int64, error) {
+	client, err := azureClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := client.DownloadStream(ctx, container, blob, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	var size int64
+	if resp.ContentLength != nil {
+		size = *resp.ContentLength
+	}
+	return resp.Body, size, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// runCalibrate implements the calibrate subcommand. It runs the detector over
+// a labeled development set and fits a curve mapping the raw top score to the
+// precision observed for it, to be applied in detect mode with -calibration.
+func runCalibrate(args []string) int {
+	fs := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	bins := fs.Int("bins", 10,
+		"Maximum number of score bins (each bin needs at least 20 texts)")
+	output := fs.String("o", "calibration.tsv",
+		"Output file for the calibration curve")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Fit a confidence calibration curve on a labeled development set.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli calibrate [OPTIONS] DEVFILE\n\n")
+		fmt.Fprintf(os.Stderr, "DEVFILE holds one label<TAB>text pair per line ('-' reads stdin).\n")
+		fmt.Fprintf(os.Stderr, "Detection options must match those the curve will be used with.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if cfg.calibrationFile != "" {
+		fmt.Fprintf(os.Stderr, "error: calibrate works on raw scores and can not be combined with -calibration\n")
+		return 2
+	}
+	entries, err := readLabeled(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	scores := make([]float64, len(entries))
+	correct := make([]bool, len(entries))
+	hits := 0
+	for i, entry := range entries {
+		results, err := detector.ConfidenceValues(entry.text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		if len(results) > 0 && results[0].Score > 0 {
+			scores[i] = results[0].Score
+			correct[i] = results[0].Code == entry.label
+		}
+		if correct[i] {
+			hits++
+		}
+	}
+
+	calibration, err := langid.Calibrate(scores, correct, *bins)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := calibration.Save(f); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "error writing %s: %v\n", *output, err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %v\n", *output, err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "%d texts, accuracy %.4f\n", len(entries), float64(hits)/float64(len(entries)))
+	fmt.Fprintf(os.Stderr, "raw score\tprecision\ttexts\n")
+	for _, p := range calibration.Points {
+		fmt.Fprintf(os.Stderr, "%.4f\t\t%.4f\t\t%d\n", p.Raw, p.Precision, p.Samples)
+	}
+	fmt.Fprintf(os.Stderr, "calibration curve written to %s\n", *output)
+	return 0
+}
@@ -0,0 +1,145 @@
+// Command capi builds lingua-cli's detectors as a C shared library, for
+// Python, Ruby, Node and other callers binding them through FFI instead of
+// starting lingua-cli for every text. Build it with
+//
+//	go build -buildmode=c-shared -o liblingua.so ./capi
+//
+// lingua.h declares the exported functions. Results are JSON strings, which
+// the caller releases with LinguaFree.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime/cgo"
+	"strings"
+	"unsafe"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+type confidenceJSON struct {
+	Language string  `json:"language"`
+	Score    float64 `json:"score"`
+}
+
+type spanJSON struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Language string `json:"language"`
+}
+
+// setError stores the message of err in *errOut, if errOut is not NULL.
+func setError(errOut **C.char, err error) {
+	if errOut != nil {
+		*errOut = C.CString(err.Error())
+	}
+}
+
+// detector returns the detector behind a handle from LinguaNew.
+func detector(handle C.uintptr_t) langid.Detector {
+	return cgo.Handle(handle).Value().(langid.Detector)
+}
+
+// LinguaNew builds a detector for the named backend ("lingua" if NULL or
+// empty), restricted to the comma-separated ISO 639-1 codes in languages
+// (all if NULL or empty). It returns a handle for the other functions, or 0
+// with the reason in *err.
+//
+//export LinguaNew
+func LinguaNew(backend, languages *C.char, lowAccuracy C.int, errOut **C.char) C.uintptr_t {
+	name := "lingua"
+	if backend != nil && C.GoString(backend) != "" {
+		name = C.GoString(backend)
+	}
+	var codes []string
+	if languages != nil {
+		for _, code := range strings.Split(C.GoString(languages), ",") {
+			if code = strings.TrimSpace(code); code != "" {
+				codes = append(codes, strings.ToLower(code))
+			}
+		}
+	}
+	d, err := langid.New(name, langid.Options{Languages: codes, LowAccuracy: lowAccuracy != 0})
+	if err != nil {
+		setError(errOut, err)
+		return 0
+	}
+	return C.uintptr_t(cgo.NewHandle(d))
+}
+
+// LinguaDetect returns the confidence values of text as a JSON array of
+// {"language", "score"} objects, best first, or NULL with the reason in
+// *err.
+//
+//export LinguaDetect
+func LinguaDetect(handle C.uintptr_t, text *C.char, errOut **C.char) *C.char {
+	values, err := detector(handle).ConfidenceValues(C.GoString(text))
+	if err != nil {
+		setError(errOut, err)
+		return nil
+	}
+	result := make([]confidenceJSON, len(values))
+	for i, value := range values {
+		result[i] = confidenceJSON{value.Code, value.Score}
+	}
+	return marshal(result, errOut)
+}
+
+// LinguaDetectMulti splits a mixed-language text into single-language
+// sections, returned as a JSON array of {"start", "end", "language"}
+// objects with UTF-8 byte offsets, or NULL with the reason in *err.
+//
+//export LinguaDetectMulti
+func LinguaDetectMulti(handle C.uintptr_t, text *C.char, errOut **C.char) *C.char {
+	md, ok := langid.AsMulti(detector(handle))
+	if !ok {
+		setError(errOut, errNoMulti)
+		return nil
+	}
+	spans, err := md.DetectMultiple(C.GoString(text))
+	if err != nil {
+		setError(errOut, err)
+		return nil
+	}
+	result := make([]spanJSON, len(spans))
+	for i, span := range spans {
+		result[i] = spanJSON{span.Start, span.End, span.Code}
+	}
+	return marshal(result, errOut)
+}
+
+// LinguaClose releases a detector built by LinguaNew.
+//
+//export LinguaClose
+func LinguaClose(handle C.uintptr_t) {
+	h := cgo.Handle(handle)
+	langid.Close(h.Value().(langid.Detector))
+	h.Delete()
+}
+
+// LinguaFree releases a string returned by this library.
+//
+//export LinguaFree
+func LinguaFree(s *C.char) {
+	C.free(unsafe.Pointer(s))
+}
+
+var errNoMulti = errors.New("the backend does not support mixed-language detection")
+
+func marshal(v any, errOut **C.char) *C.char {
+	data, err := json.Marshal(v)
+	if err != nil {
+		setError(errOut, err)
+		return nil
+	}
+	return C.CString(string(data))
+}
+
+func main() {}
@@ -0,0 +1,50 @@
+/*
+ * C API of lingua-cli's language detectors, built with
+ *
+ *     go build -buildmode=c-shared -o liblingua.so ./capi
+ *
+ * Detectors are referred to by handles. Strings returned by the library,
+ * results and error messages alike, must be released with LinguaFree.
+ * Functions may be called from several threads at once.
+ */
+#ifndef LINGUA_H
+#define LINGUA_H
+
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/*
+ * Builds a detector for backend ("lingua", "whatlang", ...; NULL or "" for
+ * lingua), restricted to the comma-separated ISO 639-1 codes in languages
+ * (NULL or "" for all). Returns 0 and sets *err on failure.
+ */
+uintptr_t LinguaNew(char *backend, char *languages, int low_accuracy, char **err);
+
+/*
+ * Returns the confidence values of text as a JSON array of
+ * {"language": "de", "score": 0.93} objects, best first. Returns NULL and
+ * sets *err on failure.
+ */
+char *LinguaDetect(uintptr_t detector, char *text, char **err);
+
+/*
+ * Splits a mixed-language text into single-language sections, returned as
+ * a JSON array of {"start": 0, "end": 15, "language": "en"} objects with
+ * UTF-8 byte offsets. Returns NULL and sets *err on failure.
+ */
+char *LinguaDetectMulti(uintptr_t detector, char *text, char **err);
+
+/* Releases a detector. */
+void LinguaClose(uintptr_t detector);
+
+/* Releases a string returned by the library. */
+void LinguaFree(char *s);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// CBOR major types.
+const (
+	cborUnsigned = 0 << 5
+	cborText     = 3 << 5
+	cborArray    = 4 << 5
+	cborMap      = 5 << 5
+)
+
+// CBOR items of -format cbor, which writes the records of a run as a single
+// indefinite-length array: the start of the array, written before the first
+// record, and the break ending it at the end of the run.
+const (
+	cborArrayStart = 0x9f
+	cborBreak      = 0xff
+)
+
+// printCBOR prints r as a CBOR map with the keys of the json-v1 record, an
+// item of the array of -format cbor or of the sequence of -format cbor-seq.
+func (p *printer) printCBOR(r jsonRecord) {
+	p.w.Write(appendCBORRecord(nil, r))
+	p.endResult()
+}
+
+// appendCBORRecord appends r encoded as a map of the fields present in the
+// json-v1 record.
+func appendCBORRecord(buf []byte, r jsonRecord) []byte {
+	fields := 0
+	for _, present := range []bool{
+		r.File != "", r.Line > 0, r.Source != "", r.Language != nil, r.Score != nil,
+		r.Entropy != nil, r.Margin != nil, r.MixedScript != nil, r.Encoding != "",
+		r.Confidences != nil, r.Spans != nil, r.Text != nil,
+	} {
+		if present {
+			fields++
+		}
+	}
+	buf = appendCBORHead(buf, cborMap, uint64(fields))
+	if r.File != "" {
+		buf = appendCBORString(appendCBORString(buf, "file"), r.File)
+	}
+	if r.Line > 0 {
+		buf = appendCBORHead(appendCBORString(buf, "line"), cborUnsigned, uint64(r.Line))
+	}
+	if r.Source != "" {
+		buf = appendCBORString(appendCBORString(buf, "source"), r.Source)
+	}
+	if r.Language != nil {
+		buf = appendCBORString(appendCBORString(buf, "language"), *r.Language)
+	}
+	if r.Score != nil {
+		buf = appendCBORDouble(appendCBORString(buf, "score"), *r.Score)
+	}
+	if r.Entropy != nil {
+		buf = appendCBORDouble(appendCBORString(buf, "entropy"), *r.Entropy)
+	}
+	if r.Margin != nil {
+		buf = appendCBORDouble(appendCBORString(buf, "margin"), *r.Margin)
+	}
+	if r.MixedScript != nil {
+		buf = appendCBORHead(appendCBORString(buf, "mixed_script"), cborArray, uint64(len(r.MixedScript)))
+		for _, script := range r.MixedScript {
+			buf = appendCBORString(buf, script)
+		}
+	}
+	if r.Encoding != "" {
+		buf = appendCBORString(appendCBORString(buf, "encoding"), r.Encoding)
+	}
+	if r.Confidences != nil {
+		buf = appendCBORHead(appendCBORString(buf, "confidences"), cborArray, uint64(len(r.Confidences)))
+		for _, c := range r.Confidences {
+			buf = appendCBORHead(buf, cborMap, 2)
+			buf = appendCBORString(appendCBORString(buf, "language"), c.Language)
+			buf = appendCBORDouble(appendCBORString(buf, "score"), c.Score)
+		}
+	}
+	if r.Spans != nil {
+		buf = appendCBORHead(appendCBORString(buf, "spans"), cborArray, uint64(len(r.Spans)))
+		for _, s := range r.Spans {
+			buf = appendCBORHead(buf, cborMap, 4)
+			buf = appendCBORHead(appendCBORString(buf, "start"), cborUnsigned, uint64(s.Start))
+			buf = appendCBORHead(appendCBORString(buf, "end"), cborUnsigned, uint64(s.End))
+			buf = appendCBORString(appendCBORString(buf, "language"), s.Language)
+			buf = appendCBORString(appendCBORString(buf, "text"), s.Text)
+		}
+	}
+	if r.Text != nil {
+		buf = appendCBORString(appendCBORString(buf, "text"), *r.Text)
+	}
+	return buf
+}
+
+// appendCBORHead appends the head of an item of the given major type with
+// argument n, in its shortest form.
+func appendCBORHead(buf []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(buf, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, major|27), n)
+}
+
+func appendCBORString(buf []byte, s string) []byte {
+	return append(appendCBORHead(buf, cborText, uint64(len(s))), s...)
+}
+
+func appendCBORDouble(buf []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(buf, 0xfb), math.Float64bits(f))
+}
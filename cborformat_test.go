@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// The examples of RFC 8949, appendix A.
+func TestCBORItems(t *testing.T) {
+	tests := []struct {
+		encoded, want []byte
+	}{
+		{appendCBORHead(nil, cborUnsigned, 0), []byte{0x00}},
+		{appendCBORHead(nil, cborUnsigned, 23), []byte{0x17}},
+		{appendCBORHead(nil, cborUnsigned, 24), []byte{0x18, 0x18}},
+		{appendCBORHead(nil, cborUnsigned, 100), []byte{0x18, 0x64}},
+		{appendCBORHead(nil, cborUnsigned, 256), []byte{0x19, 0x01, 0x00}},
+		{appendCBORHead(nil, cborUnsigned, 1000), []byte{0x19, 0x03, 0xe8}},
+		{appendCBORHead(nil, cborUnsigned, 1000000), []byte{0x1a, 0x00, 0x0f, 0x42, 0x40}},
+		{appendCBORHead(nil, cborUnsigned, 1000000000000), []byte{0x1b, 0x00, 0x00, 0x00, 0xe8, 0xd4, 0xa5, 0x10, 0x00}},
+		{appendCBORHead(nil, cborUnsigned, math.MaxUint64), []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{appendCBORHead(nil, cborArray, 0), []byte{0x80}},
+		{appendCBORHead(nil, cborArray, 25), []byte{0x98, 0x19}},
+		{appendCBORHead(nil, cborMap, 0), []byte{0xa0}},
+		{appendCBORString(nil, ""), []byte{0x60}},
+		{appendCBORString(nil, "IETF"), []byte{0x64, 0x49, 0x45, 0x54, 0x46}},
+		{appendCBORString(nil, "ü"), []byte{0x62, 0xc3, 0xbc}},
+		{appendCBORDouble(nil, 1.1), []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}},
+		{appendCBORDouble(nil, -4.1), []byte{0xfb, 0xc0, 0x10, 0x66, 0x66, 0x66, 0x66, 0x66, 0x66}},
+	}
+	for _, test := range tests {
+		if !bytes.Equal(test.encoded, test.want) {
+			t.Errorf("encoded % x, want % x", test.encoded, test.want)
+		}
+	}
+}
+
+func TestCBORRecord(t *testing.T) {
+	for i, r := range avroTestRecords() {
+		d := &cborDecoder{data: appendCBORRecord(nil, r)}
+		got := d.item()
+		if d.err == nil && len(d.data) != 0 {
+			d.err = fmt.Errorf("%d bytes left", len(d.data))
+		}
+		if d.err != nil {
+			t.Fatalf("record %d: %v", i, d.err)
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want map[string]any
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Fatal(err)
+		}
+		// json-v1 omits empty arrays, which CBOR keeps.
+		for key, set := range map[string]bool{"mixed_script": r.MixedScript != nil, "confidences": r.Confidences != nil, "spans": r.Spans != nil} {
+			if _, ok := want[key]; !ok && set {
+				want[key] = []any{}
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("record %d decoded as\n%v\nwant\n%v", i, got, want)
+		}
+	}
+}
+
+// cborDecoder decodes the CBOR items appendCBORRecord writes, numbers as
+// float64, keeping the first error.
+type cborDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *cborDecoder) next(n int) []byte {
+	if d.err == nil && n > len(d.data) {
+		d.err = errors.New("truncated item")
+	}
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *cborDecoder) item() any {
+	initial := d.next(1)[0]
+	major, info := initial&0xe0, initial&0x1f
+	if initial == 0xfb {
+		return math.Float64frombits(binary.BigEndian.Uint64(d.next(8)))
+	}
+	var n uint64
+	switch {
+	case info < 24:
+		n = uint64(info)
+	case info == 24:
+		n = uint64(d.next(1)[0])
+	case info == 25:
+		n = uint64(binary.BigEndian.Uint16(d.next(2)))
+	case info == 26:
+		n = uint64(binary.BigEndian.Uint32(d.next(4)))
+	case info == 27:
+		n = binary.BigEndian.Uint64(d.next(8))
+	default:
+		d.err = fmt.Errorf("unsupported item 0x%02x", initial)
+		return nil
+	}
+	switch major {
+	case cborUnsigned:
+		return float64(n)
+	case cborText:
+		return string(d.next(int(n)))
+	case cborArray:
+		items := []any{}
+		for ; n > 0 && d.err == nil; n-- {
+			items = append(items, d.item())
+		}
+		return items
+	case cborMap:
+		m := make(map[string]any)
+		for ; n > 0 && d.err == nil; n-- {
+			key, ok := d.item().(string)
+			if !ok {
+				d.err = errors.New("key not a text string")
+			}
+			m[key] = d.item()
+		}
+		return m
+	}
+	d.err = fmt.Errorf("unsupported item 0x%02x", initial)
+	return nil
+}
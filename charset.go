@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// charsetSampleSize is how much of the input auto-detection looks at.
+const charsetSampleSize = 64 * 1024
+
+// decodeInput converts r from the named encoding to UTF-8. The name "auto"
+// detects the encoding from the start of the input. It returns the decoded
+// reader and the name of the encoding used.
+func decodeInput(r io.Reader, name string) (io.Reader, string, error) {
+	if name == "auto" {
+		br := bufio.NewReaderSize(r, charsetSampleSize)
+		sample, err := br.Peek(charsetSampleSize)
+		if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+			return nil, "", err
+		}
+		name = detectCharset(sample)
+		r = br
+	}
+	if isUTF8Name(name) {
+		return r, "UTF-8", nil
+	}
+	enc, err := lookupEncoding(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return transform.NewReader(r, enc.NewDecoder()), name, nil
+}
+
+// detectCharset guesses the encoding of sample, preferring UTF-8 whenever the
+// sample is valid UTF-8.
+func detectCharset(sample []byte) string {
+	// A sample cut off by the size limit may end in a partial character.
+	if len(sample) == charsetSampleSize {
+		for i := 0; i < utf8.UTFMax-1 && len(sample) > 0; i++ {
+			if r, size := utf8.DecodeLastRune(sample); r != utf8.RuneError || size != 1 {
+				break
+			}
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if utf8.Valid(sample) {
+		return "UTF-8"
+	}
+	result, err := chardet.NewTextDetector().DetectBest(sample)
+	if err != nil {
+		return "UTF-8"
+	}
+	return result.Charset
+}
+
+func isUTF8Name(name string) bool {
+	switch strings.ToLower(strings.ReplaceAll(name, "-", "")) {
+	case "utf8", "":
+		return true
+	}
+	return false
+}
+
+// lookupEncoding finds an encoding by its WHATWG or IANA name.
+func lookupEncoding(name string) (encoding.Encoding, error) {
+	for _, candidate := range []string{name, strings.ReplaceAll(name, "-", "")} {
+		if enc, err := htmlindex.Get(candidate); err == nil {
+			return enc, nil
+		}
+		if enc, err := ianaindex.IANA.Encoding(candidate); err == nil && enc != nil {
+			return enc, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported encoding %q", name)
+}
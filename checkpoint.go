@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checkpointInterval is how often a checkpoint records progress.
+const checkpointInterval = 5 * time.Second
+
+// checkpoint records how far a long run has got, so that an interrupted run
+// can resume there. Progress counts input lines in line mode and files in
+// file mode, in input order.
+type checkpoint struct {
+	path string
+	// done is the number of lines or files whose results were written.
+	done int64
+	// offset is the number of input bytes taken up by the lines done.
+	offset int64
+	// last is the name of the last file done.
+	last string
+	// output is the size of the output file after the results of those
+	// lines or files.
+	output int64
+	saved  time.Time
+}
+
+// loadCheckpoint reads the checkpoint at path. A missing file is a fresh
+// checkpoint with nothing done.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "done":
+			c.done, err = strconv.ParseInt(value, 10, 64)
+		case "offset":
+			c.offset, err = strconv.ParseInt(value, 10, 64)
+		case "output":
+			c.output, err = strconv.ParseInt(value, 10, 64)
+		case "last":
+			c.last = value
+		default:
+			err = fmt.Errorf("unknown key %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return c, scanner.Err()
+}
+
+// advance records that the next line or file, of size bytes, is done, and
+// saves the checkpoint if the last save is long enough ago.
+func (c *checkpoint) advance(size int64, name string, w *outputWriter) {
+	c.done++
+	c.offset += size
+	c.last = name
+	if time.Since(c.saved) >= checkpointInterval {
+		if err := c.save(w); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: saving checkpoint: %v\n", err)
+		}
+	}
+}
+
+// save flushes the output and writes the checkpoint, replacing the previous
+// one atomically.
+func (c *checkpoint) save(w *outputWriter) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	c.output = w.size()
+	c.saved = time.Now()
+	tmp := c.path + ".tmp"
+	content := fmt.Sprintf("# lingua-cli checkpoint, delete it to start over\ndone %d\noffset %d\noutput %d\nlast %s\n",
+		c.done, c.offset, c.output, c.last)
+	if err := os.WriteFile(tmp, []byte(content), 0o666); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// finish removes the checkpoint of a completed run.
+func (c *checkpoint) finish() {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+}
+
+// skipLines reads and discards the first n lines of r.
+func skipLines(r *bufio.Reader, n int64) error {
+	for ; n > 0; n-- {
+		for {
+			_, err := r.ReadSlice('\n')
+			if err == bufio.ErrBufferFull {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
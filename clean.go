@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+var (
+	markupPattern  = regexp.MustCompile(`<[^<>]*>`)
+	linkPattern    = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)\S+`)
+	addressPattern = regexp.MustCompile(`\S+@\S+\.\S+`)
+)
+
+// cleanText scrubs a line of a corpus of markup, URLs, e-mail addresses and
+// control characters, and normalizes it to NFC with single spaces between
+// words.
+func cleanText(line string) string {
+	line = markupPattern.ReplaceAllString(line, " ")
+	line = linkPattern.ReplaceAllString(line, " ")
+	line = addressPattern.ReplaceAllString(line, " ")
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || r == utf8.RuneError {
+			return ' '
+		}
+		return r
+	}, line)
+	return strings.Join(strings.Fields(norm.NFC.String(line)), " ")
+}
+
+// cleanResult is the fate of a line in the clean pipeline.
+type cleanResult struct {
+	text string
+	// lang is the language of a kept line; reason is why a line was
+	// dropped otherwise.
+	lang, reason string
+	err          error
+}
+
+// runClean implements the clean subcommand, the usual preparation of a
+// corpus for training: every line is scrubbed and normalized, and kept if it
+// is long enough, in one of the wanted languages with enough confidence, and
+// not a repetition of an earlier line.
+func runClean(args []string) int {
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	keep := fs.String("keep", "",
+		"Comma-separated ISO 639-1 codes of the languages to keep (default: all)")
+	threshold := fs.Float64("c", 0.3,
+		"Minimum confidence of kept lines (0.0-1.0)")
+	minLength := fs.Int("min-length", 20,
+		"Minimum number of characters of kept lines after cleaning")
+	dedupe := fs.Bool("dedupe", true,
+		"Drop repetitions of earlier lines (use -dedupe=false to keep them)")
+	withLang := fs.Bool("with-lang", false,
+		"Print the language of every kept line before it, separated by a tab")
+	workers := fs.Int("j", 1,
+		"Number of lines to classify in parallel (0 for one per thread, see -threads)")
+	threads := fs.Int("threads", 0,
+		"Number of threads running Go code at once, setting GOMAXPROCS (0 for GOMAXPROCS, one per CPU unless the environment sets it)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Clean a corpus for training: scrub markup, URLs and e-mail addresses, normalize,\n")
+		fmt.Fprintf(os.Stderr, "drop short lines, lines in other languages or below the confidence threshold, and\n")
+		fmt.Fprintf(os.Stderr, "repeated lines.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli clean [OPTIONS] [FILE]...\n\n")
+		fmt.Fprintf(os.Stderr, "Reads stdin without FILE arguments or for '-', and prints the kept lines to stdout\n")
+		fmt.Fprintf(os.Stderr, "and a summary to stderr.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if *threshold < 0 || *threshold > 1 {
+		fmt.Fprintf(os.Stderr, "error: -c must lie in between 0.0 and 1.0\n")
+		return 2
+	}
+	if *workers < 0 || *threads < 0 {
+		fmt.Fprintf(os.Stderr, "error: -j and -threads must not be negative\n")
+		return 2
+	}
+	if n := setThreads(*threads); *workers == 0 {
+		*workers = n
+	}
+	wanted := make(map[string]bool)
+	for _, code := range splitList(*keep) {
+		wanted[strings.ToLower(code)] = true
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	work := func(line string) cleanResult {
+		r := cleanResult{text: cleanText(line)}
+		if utf8.RuneCountInString(r.text) < *minLength {
+			r.reason = "too short"
+			return r
+		}
+		results, err := detector.ConfidenceValues(r.text)
+		switch {
+		case err != nil:
+			r.err = err
+		case len(results) == 0 || results[0].Score <= 0 || results[0].Score < *threshold:
+			r.reason = "low confidence"
+		case len(wanted) > 0 && !wanted[results[0].Code]:
+			r.reason = "other language"
+		default:
+			r.lang = results[0].Code
+		}
+		return r
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	seen := make(map[uint64]bool)
+	total, kept := 0, 0
+	dropped := make(map[string]int)
+	var detectErr error
+	emit := func(r cleanResult) {
+		total++
+		if r.err != nil {
+			if detectErr == nil {
+				detectErr = r.err
+			}
+			return
+		}
+		if r.reason == "" && *dedupe {
+			h := fnv.New64a()
+			io.WriteString(h, r.text)
+			if sum := h.Sum64(); seen[sum] {
+				r.reason = "duplicate"
+			} else {
+				seen[sum] = true
+			}
+		}
+		if r.reason != "" {
+			dropped[r.reason]++
+			return
+		}
+		kept++
+		if *withLang {
+			w.WriteString(r.lang)
+			w.WriteByte('\t')
+		}
+		w.WriteString(r.text)
+		w.WriteByte('\n')
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+	produce := func(send func(string)) error {
+		for _, name := range inputs {
+			in := io.Reader(os.Stdin)
+			if name != "-" {
+				f, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			scanner := newLineScanner(in)
+			scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+			for scanner.Scan() {
+				send(trimLineEnd(scanner.Text()))
+			}
+			if err := scanner.Err(); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil
+	}
+	if err := parallelMap(*workers, true, produce, work, emit); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if detectErr != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", detectErr)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "kept %d of %d lines; dropped %d too short, %d low confidence, %d other language, %d duplicate\n",
+		kept, total, dropped["too short"], dropped["low confidence"], dropped["other language"], dropped["duplicate"])
+	return 0
+}
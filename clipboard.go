@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands lists the commands printing the clipboard, by operating
+// system, in the order they are tried.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbpaste"}},
+	"windows": {{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "Get-Clipboard -Raw"}},
+	// Wayland first, then X11.
+	"linux": {{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}, {"xsel", "--clipboard", "--output"}},
+}
+
+// readClipboard returns the text on the system clipboard, using the first
+// clipboard command of the platform that is installed.
+func readClipboard() (string, error) {
+	commands, ok := clipboardCommands[runtime.GOOS]
+	if !ok {
+		// The BSDs use the X11 tools as well.
+		commands = clipboardCommands["linux"]
+	}
+	var tried []string
+	for _, command := range commands {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			tried = append(tried, command[0])
+			continue
+		}
+		out, err := exec.Command(path, command[1:]...).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+				return "", fmt.Errorf("reading the clipboard with %s: %s", command[0], strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return "", fmt.Errorf("reading the clipboard with %s: %w", command[0], err)
+		}
+		text := string(out)
+		if runtime.GOOS == "windows" {
+			text = strings.ReplaceAll(text, "\r\n", "\n")
+		}
+		return text, nil
+	}
+	return "", fmt.Errorf("no clipboard command found (tried %s)", strings.Join(tried, ", "))
+}
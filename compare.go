@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// runCompare implements the compare subcommand. It classifies every line of
+// the input under two detector configurations and reports how often and
+// where their results differ.
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var shared detectorConfig
+	shared.register(fs)
+	optionsA := fs.String("a", "",
+		"Detection options of configuration A, e.g. '-l en,de,fr'")
+	optionsB := fs.String("b", "",
+		"Detection options of configuration B, e.g. '-l en,de,fr -q'")
+	threshold := fs.Float64("c", 0,
+		"Confidence threshold for both configurations, results below it count as 'unknown' (0.0-1.0)")
+	examples := fs.Int("examples", 3,
+		"Number of example lines to show per kind of difference")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Compare the results of two detection configurations line by line.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli compare [OPTIONS] -a 'OPTIONS' -b 'OPTIONS' [FILE]\n\n")
+		fmt.Fprintf(os.Stderr, "Detection options given directly apply to both configurations, those in -a and -b\n")
+		fmt.Fprintf(os.Stderr, "(whitespace separated) to one of them. FILE defaults to stdin.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() > 1 || *optionsA == *optionsB {
+		if *optionsA == *optionsB {
+			fmt.Fprintf(os.Stderr, "error: -a and -b must describe different configurations\n\n")
+		}
+		fs.Usage()
+		return 2
+	}
+
+	// Flags set on the command line apply to both configurations.
+	var common []string
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "a", "b", "c", "examples":
+		default:
+			common = append(common, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	var detectors [2]langid.Detector
+	var descriptions [2]string
+	for i, options := range []string{*optionsA, *optionsB} {
+		cfs := flag.NewFlagSet(fmt.Sprintf("configuration %c", 'A'+i), flag.ContinueOnError)
+		var cfg detectorConfig
+		cfg.register(cfs)
+		cargs := append(append([]string(nil), common...), strings.Fields(options)...)
+		if err := parseFlags(cfs, cargs); err != nil {
+			return 2
+		}
+		descriptions[i] = strings.Join(cargs, " ")
+		if cfs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "error: unexpected argument %q in configuration %c\n", cfs.Arg(0), 'A'+i)
+			return 2
+		}
+		detector, err := cfg.build()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: configuration %c: %v\n", 'A'+i, err)
+			return 1
+		}
+		defer langid.Close(detector)
+		detectors[i] = detector
+	}
+
+	var input io.Reader = os.Stdin
+	if fs.NArg() == 1 && fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		input = f
+	}
+
+	type difference struct {
+		a, b string
+	}
+	type occurrence struct {
+		lineNo int
+		line   string
+	}
+	occurrences := make(map[difference][]occurrence)
+	texts, differing := 0, 0
+	scanner := bufio.NewScanner(input)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		texts++
+		var codes [2]string
+		for i, detector := range detectors {
+			results, err := detector.ConfidenceValues(line)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+			codes[i] = langid.Unknown
+			if len(results) > 0 && results[0].Score > 0 && results[0].Score >= *threshold {
+				codes[i] = results[0].Code
+			}
+		}
+		if codes[0] != codes[1] {
+			differing++
+			key := difference{codes[0], codes[1]}
+			occurrences[key] = append(occurrences[key], occurrence{lineNo, line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "error reading input: %v\n", err)
+		return 1
+	}
+
+	share := 0.0
+	if texts > 0 {
+		share = 100 * float64(differing) / float64(texts)
+	}
+	fmt.Printf("A: %s\nB: %s\n", descriptions[0], descriptions[1])
+	fmt.Printf("%d of %d texts differ (%.2f%%)\n", differing, texts, share)
+	if differing == 0 {
+		return 0
+	}
+
+	var kinds []difference
+	for key := range occurrences {
+		kinds = append(kinds, key)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		ni, nj := len(occurrences[kinds[i]]), len(occurrences[kinds[j]])
+		if ni != nj {
+			return ni > nj
+		}
+		if kinds[i].a != kinds[j].a {
+			return kinds[i].a < kinds[j].a
+		}
+		return kinds[i].b < kinds[j].b
+	})
+	fmt.Printf("\nA\tB\ttexts\n")
+	for _, key := range kinds {
+		fmt.Printf("%s\t%s\t%d\n", key.a, key.b, len(occurrences[key]))
+		for i, o := range occurrences[key] {
+			if i >= *examples {
+				break
+			}
+			fmt.Printf("  line %d: %s\n", o.lineNo, o.line)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// configFile holds the option settings of a configuration file: those at
+// the top, which apply to every run, and those of the named profiles, which
+// apply when selected with -profile.
+type configFile struct {
+	path     string
+	defaults []configSetting
+	profiles map[string][]configSetting
+}
+
+type configSetting struct {
+	line        int
+	name, value string
+}
+
+// defaultConfigPath returns the path of the configuration file read without
+// -config: lingua-cli/config.toml in the user's configuration directory.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "lingua-cli", "config.toml")
+}
+
+// loadConfig reads a configuration file in a subset of TOML: "name = value"
+// lines setting options by their long or short name, before any section or
+// in [profile.NAME] sections. Values are strings, numbers, booleans or arrays
+// of strings, which are joined with commas for list options such as
+// languages. Comments start with '#'.
+func loadConfig(path string) (*configFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg := &configFile{path: path, profiles: make(map[string][]configSetting)}
+	// profile is the name of the current section, "" before any.
+	profile := ""
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section, ok := strings.CutSuffix(strings.TrimPrefix(line, "["), "]")
+			name, isProfile := strings.CutPrefix(strings.TrimSpace(section), "profile.")
+			if !ok || !isProfile || name == "" {
+				return nil, fmt.Errorf("%s:%d: expected a [profile.NAME] section", path, number)
+			}
+			name = strings.Trim(name, `"`)
+			if _, ok := cfg.profiles[name]; ok {
+				return nil, fmt.Errorf("%s:%d: profile %q defined twice", path, number, name)
+			}
+			cfg.profiles[name] = []configSetting{}
+			profile = name
+			continue
+		}
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected name = value", path, number)
+		}
+		value, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		setting := configSetting{number, strings.TrimSpace(name), value}
+		if profile == "" {
+			cfg.defaults = append(cfg.defaults, setting)
+		} else {
+			cfg.profiles[profile] = append(cfg.profiles[profile], setting)
+		}
+	}
+	return cfg, scanner.Err()
+}
+
+// stripComment removes a '#' comment from a line, except within a quoted
+// string.
+func stripComment(line string) string {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"' && (i == 0 || line[i-1] != '\\'):
+			quoted = !quoted
+		case r == '#' && !quoted:
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// parseConfigValue parses a value of a configuration file into the form the
+// flag takes on the command line.
+func parseConfigValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "'"):
+		if raw[0] == '\'' {
+			if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+				return "", fmt.Errorf("unterminated string %s", raw)
+			}
+			return raw[1 : len(raw)-1], nil
+		}
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "["):
+		inner, ok := strings.CutSuffix(raw[1:], "]")
+		if !ok {
+			return "", fmt.Errorf("unterminated array %s", raw)
+		}
+		var items []string
+		for _, item := range strings.Split(inner, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, err := parseConfigValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, value)
+		}
+		return strings.Join(items, ","), nil
+	case raw == "":
+		return "", errors.New("missing value")
+	}
+	return raw, nil
+}
+
+// apply sets the flags of fs that are not set yet from the settings of the
+// given profile, if any, and then from those at the top of the file. With
+// partial set, fs holds the options of a subcommand, and settings of options
+// it does not have, which are those of detect mode, are skipped.
+func (c *configFile) apply(fs *flag.FlagSet, profile string, partial bool) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[canonicalFlag(f.Name)] = true
+	})
+	var sets [][]configSetting
+	if profile != "" {
+		settings, ok := c.profiles[profile]
+		if !ok {
+			return fmt.Errorf("%s: no profile %q", c.path, profile)
+		}
+		sets = append(sets, settings)
+	}
+	sets = append(sets, c.defaults)
+	for _, settings := range sets {
+		for _, s := range settings {
+			f := fs.Lookup(s.name)
+			if f == nil && partial && s.name != "config" && s.name != "profile" {
+				continue
+			}
+			if f == nil || s.name == "config" || s.name == "profile" {
+				return fmt.Errorf("%s:%d: unknown option %q", c.path, s.line, s.name)
+			}
+			name := canonicalFlag(s.name)
+			if envIgnored[name] {
+				return fmt.Errorf("%s:%d: %s can only be given on the command line", c.path, s.line, s.name)
+			}
+			if given[name] {
+				continue
+			}
+			if err := fs.Set(name, s.value); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for %s: %v", c.path, s.line, s.value, s.name, err)
+			}
+			given[name] = true
+		}
+	}
+	return nil
+}
+
+// canonicalFlag returns the short name of a flag given by its long name,
+// and other names unchanged.
+func canonicalFlag(name string) string {
+	if short, ok := longFlags[name]; ok {
+		return short
+	}
+	return name
+}
+
+// applyConfig sets the flags of fs that are not set yet from the
+// configuration file at path, or the default one if it exists, and from its
+// profile. partial is as for (*configFile).apply.
+func applyConfig(fs *flag.FlagSet, path, profile string, partial bool) error {
+	if path == "" {
+		path = defaultConfigPath()
+		if _, err := os.Stat(path); path == "" || err != nil {
+			if profile != "" {
+				return fmt.Errorf("-profile %s: no configuration file %s", profile, path)
+			}
+			return nil
+		}
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+	return cfg.apply(fs, profile, partial)
+}
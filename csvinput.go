@@ -0,0 +1,327 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// csvDelimiters are the delimiters -csv-delimiter auto chooses from.
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+// csvRaggedPolicies lists the values accepted by -csv-ragged.
+var csvRaggedPolicies = []string{"error", "pad", "skip", "pass"}
+
+// csvDialect describes a CSV file. A zero quote disables quoting; a zero
+// escape means quotes are escaped by doubling them, as in RFC 4180.
+// Otherwise escape makes the next character literal, inside quotes and
+// outside.
+type csvDialect struct {
+	delimiter, quote, escape rune
+}
+
+// parseCSVChar parses the value of a -csv-* option naming a character,
+// which may be written as an escape sequence such as '\t'.
+func parseCSVChar(option, value string) (rune, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if s, err := strconv.Unquote(`"` + value + `"`); err == nil {
+		value = s
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("%s must be a single character, not %q", option, value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	if r == '\n' || r == '\r' {
+		return 0, fmt.Errorf("%s must not be a line break", option)
+	}
+	return r, nil
+}
+
+// sniffCSVDelimiter guesses the delimiter of the CSV data at the start of
+// r: the candidate occurring equally often, and most often, in each of the
+// first lines outside quotes, or else the most frequent one.
+func sniffCSVDelimiter(r *bufio.Reader, quote rune) rune {
+	sample, _ := r.Peek(64 * 1024)
+	lines := strings.Split(string(sample), "\n")
+	if len(lines) > 1 {
+		// The last line may be cut off.
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 20 {
+		lines = lines[:20]
+	}
+	best, bestCount, frequent, frequentCount := rune(0), 0, ',', 0
+	for _, delimiter := range csvDelimiters {
+		consistent, total := -1, 0
+		quoted := false
+		for _, line := range lines {
+			count := 0
+			for _, c := range line {
+				switch {
+				case c == quote && quote != 0:
+					quoted = !quoted
+				case c == delimiter && !quoted:
+					count++
+				}
+			}
+			total += count
+			if quoted {
+				// A quoted field spans lines; count the record as a whole.
+				continue
+			}
+			if consistent == -1 {
+				consistent = count
+			} else if consistent != count {
+				consistent = 0
+			}
+		}
+		if consistent > bestCount {
+			best, bestCount = delimiter, consistent
+		}
+		if total > frequentCount {
+			frequent, frequentCount = delimiter, total
+		}
+	}
+	if best != 0 {
+		return best
+	}
+	return frequent
+}
+
+// csvReader reads the records of a CSV file in a dialect.
+type csvReader struct {
+	r *bufio.Reader
+	csvDialect
+	// line is the number of the line read last.
+	line int
+}
+
+// errCSVQuote reports a quoted field lacking its closing quote.
+var errCSVQuote = errors.New("quoted field not closed")
+
+// read returns the next record and the number of the line it starts on,
+// skipping empty lines. It returns io.EOF at the end of the input.
+func (r *csvReader) read() ([]string, int, error) {
+	var fields []string
+	var field strings.Builder
+	quoted, empty := false, true
+	start := r.line + 1
+	for {
+		c, _, err := r.r.ReadRune()
+		if err == io.EOF {
+			switch {
+			case quoted:
+				return nil, start, errCSVQuote
+			case empty && len(fields) == 0:
+				return nil, start, io.EOF
+			}
+			r.line++
+			return append(fields, field.String()), start, nil
+		}
+		if err != nil {
+			return nil, start, err
+		}
+		if c == '\n' {
+			r.line++
+		}
+		// Characters after the escape character are taken literally.
+		if r.escape != 0 && r.escape != r.quote && c == r.escape {
+			next, _, err := r.r.ReadRune()
+			if err != nil {
+				field.WriteRune(c)
+				continue
+			}
+			if next == '\n' {
+				r.line++
+			}
+			field.WriteRune(next)
+			empty = false
+			continue
+		}
+		if quoted {
+			if c == r.quote {
+				if next, _, err := r.r.ReadRune(); err == nil {
+					if next == r.quote && r.escape == 0 {
+						field.WriteRune(c)
+						continue
+					}
+					r.r.UnreadRune()
+				}
+				quoted = false
+				continue
+			}
+			field.WriteRune(c)
+			continue
+		}
+		switch {
+		case c == r.quote && r.quote != 0 && field.Len() == 0:
+			quoted, empty = true, false
+		case c == r.delimiter:
+			fields = append(fields, field.String())
+			field.Reset()
+			empty = false
+		case c == '\n':
+			if empty && len(fields) == 0 {
+				start = r.line + 1
+				continue
+			}
+			return append(fields, strings.TrimSuffix(field.String(), "\r")), start, nil
+		default:
+			field.WriteRune(c)
+			if c != '\r' {
+				empty = false
+			}
+		}
+	}
+}
+
+// csvWriter writes records in a dialect, quoting fields that need it.
+type csvWriter struct {
+	w *outputWriter
+	csvDialect
+}
+
+func (w *csvWriter) write(fields []string) {
+	for i, field := range fields {
+		if i > 0 {
+			w.w.WriteRune(w.delimiter)
+		}
+		special := string(w.delimiter) + "\r\n"
+		if w.quote != 0 {
+			special += string(w.quote)
+		}
+		if w.escape != 0 {
+			special += string(w.escape)
+		}
+		if !strings.ContainsAny(field, special) {
+			w.w.WriteString(field)
+			continue
+		}
+		if w.quote == 0 {
+			// Without quoting, the special characters are escaped.
+			for _, c := range field {
+				if strings.ContainsRune(special, c) && w.escape != 0 {
+					w.w.WriteRune(w.escape)
+				}
+				w.w.WriteRune(c)
+			}
+			continue
+		}
+		w.w.WriteRune(w.quote)
+		for _, c := range field {
+			switch {
+			case c == w.quote && w.escape == 0:
+				w.w.WriteRune(w.quote)
+			case c == w.quote || (c == w.escape && w.escape != 0):
+				w.w.WriteRune(w.escape)
+			}
+			w.w.WriteRune(c)
+		}
+		w.w.WriteRune(w.quote)
+	}
+	w.w.WriteByte('\n')
+}
+
+// csvColumns are the columns -csv reads the text from and writes the
+// result to, found in the header or given by number.
+type csvColumns struct {
+	text, lang, conf int
+	// hint is the column of -hint-field, or -1 without it.
+	hint int
+	// width is the number of fields of the records, from the header or the
+	// first record.
+	width int
+}
+
+// csvColumn finds a column by its name in the header, or by its number
+// counted from 1. ok is false if there is no such column.
+func csvColumn(header []string, name string) (int, bool) {
+	for i, field := range header {
+		if field == name {
+			return i, true
+		}
+	}
+	if n, err := strconv.Atoi(name); err == nil && n >= 1 {
+		return n - 1, true
+	}
+	return 0, false
+}
+
+// newCSVColumns finds the columns of -csv in the header, if there is one,
+// of records of width fields, with hint "" if there is no -hint-field. The
+// language and confidence columns replace those of the same names in the
+// header and are appended otherwise.
+func newCSVColumns(header []string, width int, text, lang, conf, hint string) (csvColumns, error) {
+	c := csvColumns{width: width, hint: -1}
+	var ok bool
+	if c.text, ok = csvColumn(header, text); !ok || c.text >= width {
+		if header == nil {
+			return c, fmt.Errorf("-text-field must be a column number without a header, not %q", text)
+		}
+		return c, fmt.Errorf("no column %q in the header", text)
+	}
+	if hint != "" {
+		if c.hint, ok = csvColumn(header, hint); !ok || c.hint >= width {
+			if header == nil {
+				return c, fmt.Errorf("-hint-field must be a column number without a header, not %q", hint)
+			}
+			return c, fmt.Errorf("no column %q in the header", hint)
+		}
+	}
+	next := width
+	for _, column := range []struct {
+		index *int
+		name  string
+	}{{&c.lang, lang}, {&c.conf, conf}} {
+		if i := slices.Index(header, column.name); i >= 0 {
+			*column.index = i
+		} else {
+			*column.index = next
+			next++
+		}
+	}
+	return c, nil
+}
+
+// fill returns the fields of a record with the language and confidence set.
+func (c csvColumns) fill(fields []string, lang, conf string) []string {
+	fields = slices.Clone(fields)
+	for len(fields) <= max(c.lang, c.conf) {
+		fields = append(fields, "")
+	}
+	fields[c.lang], fields[c.conf] = lang, conf
+	return fields
+}
+
+// csvRow is a record of -csv input and its result.
+type csvRow struct {
+	// line is the number of the line the record starts on.
+	line    int
+	fields  []string
+	results []langid.Confidence
+	err     error
+	// ragged is set for records with the wrong number of fields.
+	ragged bool
+}
+
+// printCSV prints a record of -csv input with the language and confidence
+// of its text set. Texts that could not be classified get the unknown label
+// and no confidence.
+func (p *printer) printCSV(w *csvWriter, c csvColumns, fields []string, results []langid.Confidence) {
+	results = p.labels.apply(results)
+	lang, conf := p.labels.label(langid.Unknown), ""
+	if len(results) > 0 && (!p.hasThreshold || results[0].Score >= p.threshold) {
+		lang, conf = results[0].Code, formatScore(results[0].Score)
+	}
+	w.write(c.fill(fields, lang, conf))
+	p.endResult()
+}
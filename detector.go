@@ -0,0 +1,302 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// detectorConfig holds the command line options that configure detection.
+// It is shared by detect mode and the subcommands that run the detector
+// themselves, so all of them accept the same options.
+type detectorConfig struct {
+	languages       string
+	quick           bool
+	minRelDist      float64
+	maxEntropy      float64
+	backend         string
+	externalCmd     string
+	fastTextModel   string
+	fastTextBin     string
+	server          string
+	profileFiles    string
+	profilesOnly    bool
+	hintsFile       string
+	priorsFile      string
+	short           bool
+	romanized       bool
+	calibrationFile string
+	cacheSize       int
+	cacheFile       string
+	timeout         time.Duration
+	// decideLater leaves -d and -max-entropy out of the detector build
+	// creates, for callers that adjust the scores of a text first and then
+	// apply decide.
+	decideLater bool
+}
+
+// register defines the detection flags on fs.
+func (c *detectorConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.languages, "l", "",
+		"Comma seperated list of iso-639-1 codes of languages to detect, if not specified, all supported language will be used. Setting this improves accuracy and resource usage.")
+	fs.BoolVar(&c.quick, "q", false,
+		"Quick/low accuracy mode")
+	fs.Float64Var(&c.minRelDist, "d", 0,
+		"Minimum relative distance between top language probabilities (0.0-1.0). Texts whose two best languages score closer than this are classified as 'unknown'.")
+	fs.Float64Var(&c.maxEntropy, "max-entropy", 0,
+		"Maximum normalized entropy of the confidence distribution (0.0-1.0, see -entropy). Texts whose distribution is flatter are classified as 'unknown' even if their best score clears -c, catching gibberish and transliterated text (0 for no limit).")
+	fs.StringVar(&c.backend, "backend", "lingua",
+		"Detection backend: "+strings.Join(langid.Backends, ", ")+". Only lingua, and remote with a lingua server, support -m.")
+	fs.StringVar(&c.externalCmd, "external-cmd", "",
+		"Command line of the external backend. It receives one text per line on stdin and must answer each with one line of 'label score' pairs.")
+	fs.StringVar(&c.fastTextModel, "fasttext-model", "",
+		"Path of a fastText language identification model (lid.176.bin or lid.176.ftz) used by the fasttext backend.")
+	fs.StringVar(&c.fastTextBin, "fasttext-bin", "fasttext",
+		"fastText executable used by the fasttext backend.")
+	fs.StringVar(&c.server, "addr", "",
+		"Address of the detection server used by the remote backend: host:port, a URL, or unix:PATH of a socket. The server answers like linguahttp.NewHandler at /detect unless the URL has another path.")
+	fs.StringVar(&c.profileFiles, "profiles", "",
+		"Comma separated list of custom language profiles created with 'lingua-cli train'. Text that fits a profile well is classified among the profiles, anything else by the backend.")
+	fs.BoolVar(&c.profilesOnly, "profiles-only", false,
+		"Classify among the custom profiles only, without the built-in languages of the backend.")
+	fs.StringVar(&c.hintsFile, "hints", "",
+		"File of domain-specific terms whose presence boosts the scores of given languages (lines: term<TAB>codes[<TAB>weight]).")
+	fs.StringVar(&c.priorsFile, "priors", "",
+		"File of the expected frequencies of languages, such as their share of past traffic, weighing their scores before -c, -d and -calibration apply (lines: code<TAB>weight; '*' for unlisted languages).")
+	fs.BoolVar(&c.short, "short", false,
+		"Short-text mode for tweets and queries: strips URLs, mentions, hashtags, numbers and emoji, decides unambiguous scripts directly, narrows candidates by script, and defaults to -c 0.5 -M 3.")
+	fs.BoolVar(&c.romanized, "romanized", false,
+		"Recognize Hindi, Arabic and Chinese written in Latin letters (romanized Hindi, Arabizi, pinyin) by their frequent words and report them as hi-Latn, ar-Latn and zh-Latn.")
+	fs.StringVar(&c.calibrationFile, "calibration", "",
+		"Calibration curve created with 'lingua-cli calibrate'. Confidence values (and -c) then denote the precision observed on the development set.")
+	fs.IntVar(&c.cacheSize, "cache", 0,
+		"Remember the results of this many distinct recent texts, so repeated texts are classified only once, e.g. 10000 for logs (0 disables the cache).")
+	fs.StringVar(&c.cacheFile, "cache-file", "",
+		"Keep the results of all texts in this database file across runs, so later runs only classify new texts. Results of other detection options are kept apart.")
+	fs.DurationVar(&c.timeout, "timeout-per-item", 0,
+		"Give up on texts not classified within this long (e.g. 500ms), reporting them as 'unknown' with a warning, so that one pathological text can not stall a run (0 for no limit).")
+}
+
+// options returns the backend options selected by the flags.
+func (c *detectorConfig) options() langid.Options {
+	return langid.Options{
+		Languages:           splitList(c.languages),
+		LowAccuracy:         c.quick,
+		MinRelativeDistance: c.minRelDist,
+		Command:             c.externalCmd,
+		FastTextModel:       c.fastTextModel,
+		FastTextBinary:      c.fastTextBin,
+		Server:              c.server,
+	}
+}
+
+// build creates the configured detector together with its post-processing.
+func (c *detectorConfig) build() (langid.Detector, error) {
+	opts := c.options()
+	detector, err := buildDetector(c.backend, opts, splitList(c.profileFiles), c.profilesOnly)
+	if err != nil {
+		return nil, err
+	}
+	if c.hintsFile != "" {
+		hints, err := langid.LoadHints(c.hintsFile)
+		if err != nil {
+			langid.Close(detector)
+			return nil, err
+		}
+		detector = langid.WithHints(detector, hints)
+	}
+	if c.priorsFile != "" {
+		weights, err := langid.LoadPriors(c.priorsFile)
+		if err != nil {
+			langid.Close(detector)
+			return nil, err
+		}
+		detector = langid.WithPrior(detector, weights)
+	}
+	if c.short {
+		detector = langid.WithShortText(detector, opts.Languages)
+	}
+	if c.romanized {
+		detector = langid.WithRomanized(detector)
+	}
+	if c.calibrationFile != "" {
+		calibration, err := langid.LoadCalibration(c.calibrationFile)
+		if err != nil {
+			langid.Close(detector)
+			return nil, err
+		}
+		detector = langid.WithCalibration(detector, calibration)
+	}
+	if c.minRelDist != 0 {
+		if c.minRelDist < 0 || c.minRelDist > 0.99 {
+			langid.Close(detector)
+			return nil, fmt.Errorf("minimum relative distance must lie in between 0.0 and 0.99")
+		}
+		if !c.decideLater {
+			detector = langid.WithMinDistance(detector, c.minRelDist)
+		}
+	}
+	if c.maxEntropy != 0 {
+		if c.maxEntropy < 0 || c.maxEntropy > 1 {
+			langid.Close(detector)
+			return nil, fmt.Errorf("-max-entropy must lie in between 0.0 and 1.0")
+		}
+		if !c.decideLater {
+			detector = langid.WithMaxEntropy(detector, c.maxEntropy)
+		}
+	}
+	if c.cacheFile != "" {
+		cached, err := langid.OpenDiskCache(detector, c.cacheFile, c.cacheKey())
+		if err != nil {
+			langid.Close(detector)
+			return nil, err
+		}
+		detector = cached
+	}
+	if c.cacheSize < 0 {
+		langid.Close(detector)
+		return nil, fmt.Errorf("-cache must not be negative")
+	}
+	if c.cacheSize > 0 {
+		detector = langid.WithCache(detector, c.cacheSize)
+	}
+	if c.timeout < 0 {
+		langid.Close(detector)
+		return nil, fmt.Errorf("-timeout-per-item must not be negative")
+	}
+	if c.timeout > 0 {
+		timeout := c.timeout
+		detector = langid.WithTimeout(detector, timeout, func(text string) {
+			fmt.Fprintf(os.Stderr, "warning: detection timed out after %v, reported as unknown: %s\n", timeout, excerpt(text, 60))
+		})
+	}
+	return detector, nil
+}
+
+// decide applies -d and -max-entropy to values as the detector does unless
+// it was built with decideLater: near-ties and flat distributions report no
+// language at all.
+func (c *detectorConfig) decide(values []langid.Confidence) []langid.Confidence {
+	if c.minRelDist != 0 && langid.Margin(values) < c.minRelDist || c.maxEntropy != 0 && langid.Entropy(values) > c.maxEntropy {
+		return nil
+	}
+	return values
+}
+
+// cacheKey describes everything that influences the results of the
+// configured detector, for keeping cached results of different
+// configurations apart. Files are identified by path, size and modification
+// time, so editing them invalidates the cache.
+func (c *detectorConfig) cacheKey() string {
+	languages := splitList(strings.ToLower(c.languages))
+	sort.Strings(languages)
+	// Results cached with decideLater are those before -d and -max-entropy.
+	minRelDist, maxEntropy := c.minRelDist, c.maxEntropy
+	if c.decideLater {
+		minRelDist, maxEntropy = 0, 0
+	}
+	file := func(path string) string {
+		if info, err := os.Stat(path); err == nil {
+			return fmt.Sprintf("%s@%d@%d", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return path
+	}
+	var profiles []string
+	for _, path := range splitList(c.profileFiles) {
+		profiles = append(profiles, file(path))
+	}
+	return strings.Join([]string{
+		"version=" + version,
+		"backend=" + c.backend,
+		"l=" + strings.Join(languages, ","),
+		fmt.Sprintf("q=%t", c.quick),
+		fmt.Sprintf("d=%g", minRelDist),
+		fmt.Sprintf("max-entropy=%g", maxEntropy),
+		"external-cmd=" + c.externalCmd,
+		"addr=" + c.server,
+		"fasttext-model=" + file(c.fastTextModel),
+		"fasttext-bin=" + c.fastTextBin,
+		"profiles=" + strings.Join(profiles, ","),
+		fmt.Sprintf("profiles-only=%t", c.profilesOnly),
+		"hints=" + file(c.hintsFile),
+		"priors=" + file(c.priorsFile),
+		fmt.Sprintf("short=%t", c.short),
+		fmt.Sprintf("romanized=%t", c.romanized),
+		"calibration=" + file(c.calibrationFile),
+	}, "\n")
+}
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// buildDetector creates the configured backend and combines it with custom
+// profiles when any are given. Codes in opts.Languages naming a loaded profile
+// select that profile rather than being passed to the backend; if only such
+// codes are given, the backend is not used at all.
+func buildDetector(backend string, opts langid.Options, profilePaths []string, profilesOnly bool) (langid.Detector, error) {
+	if len(profilePaths) == 0 {
+		if profilesOnly {
+			return nil, fmt.Errorf("-profiles-only requires -profiles")
+		}
+		return langid.New(backend, opts)
+	}
+
+	var profiles []*langid.Profile
+	for _, path := range profilePaths {
+		profile, err := langid.LoadProfile(path)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	if len(opts.Languages) > 0 {
+		requested := make(map[string]bool)
+		for _, code := range opts.Languages {
+			requested[strings.ToLower(code)] = true
+		}
+		var selected []*langid.Profile
+		for _, profile := range profiles {
+			if requested[profile.Code] {
+				selected = append(selected, profile)
+				delete(requested, profile.Code)
+			}
+		}
+		var builtin []string
+		for _, code := range opts.Languages {
+			if requested[strings.ToLower(code)] {
+				builtin = append(builtin, code)
+			}
+		}
+		if len(selected) > 0 {
+			profiles = selected
+			if len(builtin) == 0 {
+				profilesOnly = true
+			}
+		}
+		opts.Languages = builtin
+	}
+
+	profileDetector := langid.NewProfileDetector(profiles)
+	if profilesOnly {
+		return profileDetector, nil
+	}
+	detector, err := langid.New(backend, opts)
+	if err != nil {
+		return nil, err
+	}
+	return langid.WithProfiles(detector, profileDetector), nil
+}
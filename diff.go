@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// resultRecord is a result read back from JSON Lines output.
+type resultRecord struct {
+	key      string
+	language string
+	// score is NaN for results without one.
+	score float64
+}
+
+// resultFields are the paths of the fields of results read back from JSON
+// Lines output: those of -format json-v1 by default, or those of -jsonl.
+type resultFields struct {
+	key, lang, score []string
+}
+
+// getField returns the value at path in record, or nil if there is none.
+func getField(record map[string]any, path []string) any {
+	var value any = record
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// recordKey returns the key aligning a result of -format json-v1 with the
+// result of the same text in another run: its id field, its source, its
+// file and line, or else its position.
+func recordKey(record map[string]any, fields resultFields, position int) string {
+	if fields.key != nil {
+		if value := getField(record, fields.key); value != nil {
+			return fmt.Sprint(value)
+		}
+		return "#" + strconv.Itoa(position)
+	}
+	if id := getField(record, []string{"id"}); id != nil {
+		return fmt.Sprint(id)
+	}
+	if source, ok := record["source"].(string); ok {
+		return source
+	}
+	file, hasFile := record["file"].(string)
+	line, hasLine := record["line"].(json.Number)
+	switch {
+	case hasFile && hasLine:
+		return file + ":" + line.String()
+	case hasFile:
+		return file
+	case hasLine:
+		return "line " + line.String()
+	}
+	return "#" + strconv.Itoa(position)
+}
+
+// readResults reads the results of a JSON Lines file, in order, and their
+// index by key.
+func readResults(path string, fields resultFields) ([]resultRecord, map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return parseResults(path, f, fields)
+}
+
+// parseResults reads results like readResults from r, named path in
+// errors.
+func parseResults(path string, r io.Reader, fields resultFields) ([]resultRecord, map[string]int, error) {
+	var results []resultRecord
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		d := json.NewDecoder(strings.NewReader(line))
+		d.UseNumber()
+		var record map[string]any
+		if err := d.Decode(&record); err != nil || record == nil {
+			return nil, nil, fmt.Errorf("%s:%d: not a JSON object", path, lineNo)
+		}
+		result := resultRecord{key: recordKey(record, fields, len(results)+1), score: math.NaN()}
+		result.language, _ = getField(record, fields.lang).(string)
+		if score, ok := getField(record, fields.score).(json.Number); ok {
+			result.score, _ = score.Float64()
+		}
+		if _, ok := index[result.key]; ok {
+			return nil, nil, fmt.Errorf("%s:%d: duplicate key %q (see -key)", path, lineNo, result.key)
+		}
+		index[result.key] = len(results)
+		results = append(results, result)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return results, index, nil
+}
+
+// resultChange is the difference between the results of a text in two
+// runs.
+type resultChange struct {
+	// kind is "changed" for another language, "shifted" for a score moving
+	// by at least the tolerance, "added" or "removed".
+	kind     string
+	old, new resultRecord
+}
+
+// diffResults compares the results of two runs aligned by key. Scores
+// moving by less than tolerance do not count.
+func diffResults(old []resultRecord, oldIndex map[string]int, new []resultRecord, newIndex map[string]int, tolerance float64) []resultChange {
+	var changes []resultChange
+	for _, o := range old {
+		i, ok := newIndex[o.key]
+		if !ok {
+			changes = append(changes, resultChange{kind: "removed", old: o})
+			continue
+		}
+		n := new[i]
+		switch {
+		case o.language != n.language:
+			changes = append(changes, resultChange{"changed", o, n})
+		case math.IsNaN(o.score) != math.IsNaN(n.score) || math.Abs(o.score-n.score) >= tolerance:
+			changes = append(changes, resultChange{"shifted", o, n})
+		}
+	}
+	for _, n := range new {
+		if _, ok := oldIndex[n.key]; !ok {
+			changes = append(changes, resultChange{kind: "added", new: n})
+		}
+	}
+	return changes
+}
+
+// formatChange formats a change as tab-separated kind, key, old and new
+// language and score, and the score difference.
+func formatChange(c resultChange) string {
+	field := func(r resultRecord) (string, string) {
+		if r.key == "" {
+			return "-", "-"
+		}
+		language, score := r.language, "-"
+		if language == "" {
+			language = "-"
+		}
+		if !math.IsNaN(r.score) {
+			score = formatScore(r.score)
+		}
+		return language, score
+	}
+	key := c.old.key
+	if key == "" {
+		key = c.new.key
+	}
+	oldLang, oldScore := field(c.old)
+	newLang, newScore := field(c.new)
+	delta := "-"
+	if c.old.key != "" && c.new.key != "" && !math.IsNaN(c.old.score) && !math.IsNaN(c.new.score) {
+		delta = fmt.Sprintf("%+.4f", c.new.score-c.old.score)
+	}
+	return strings.Join([]string{c.kind, key, oldLang, oldScore, newLang, newScore, delta}, "\t")
+}
+
+// runDiff implements the diff command. It compares two result files, such
+// as the output of a run before and after upgrading lingua-go or changing
+// options, and reports the texts whose language changed or whose score
+// shifted.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	key := fs.String("key", "",
+		"Dotted path of the field aligning the results of both files (default: id, source, file and line, or else the position)")
+	langField := fs.String("lang-field", "language",
+		"Dotted path of the language of the results, such as the -lang-field of -jsonl output")
+	scoreField := fs.String("score-field", "score",
+		"Dotted path of the score of the results, such as the -conf-field of -jsonl output")
+	minShift := fs.Float64("min-shift", 0.1,
+		"Smallest change of the score of a text whose language did not change to report; above 1 reports none")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Compare two files of results, one JSON object per line as printed by\n")
+		fmt.Fprintf(os.Stderr, "-format json-v1 or -jsonl.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli diff [OPTIONS] OLD NEW\n\n")
+		fmt.Fprintf(os.Stderr, "Prints kind (changed, shifted, added or removed), key, old language and score,\n")
+		fmt.Fprintf(os.Stderr, "new language and score, and the score difference per differing text, then a\n")
+		fmt.Fprintf(os.Stderr, "summary with the language changes by pair of languages on stderr. Exits with\n")
+		fmt.Fprintf(os.Stderr, "status 1 if any language changed or any text was added or removed.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	var fields resultFields
+	var err error
+	if *key != "" {
+		if fields.key, err = parseFieldPath(*key); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -key: %v\n", err)
+			return 2
+		}
+	}
+	if fields.lang, err = parseFieldPath(*langField); err != nil {
+		fmt.Fprintf(os.Stderr, "error: -lang-field: %v\n", err)
+		return 2
+	}
+	if fields.score, err = parseFieldPath(*scoreField); err != nil {
+		fmt.Fprintf(os.Stderr, "error: -score-field: %v\n", err)
+		return 2
+	}
+	old, oldIndex, err := readResults(fs.Arg(0), fields)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	new, newIndex, err := readResults(fs.Arg(1), fields)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	changes := diffResults(old, oldIndex, new, newIndex, *minShift)
+	out := bufio.NewWriter(os.Stdout)
+	counts := make(map[string]int)
+	pairs := make(map[[2]string]int)
+	for _, c := range changes {
+		fmt.Fprintln(out, formatChange(c))
+		counts[c.kind]++
+		if c.kind == "changed" {
+			pairs[[2]string{c.old.language, c.new.language}]++
+		}
+	}
+	out.Flush()
+
+	fmt.Fprintf(os.Stderr, "\n%d old and %d new results\n", len(old), len(new))
+	fmt.Fprintf(os.Stderr, "differences: %s\n", formatCounts(counts, len(old)))
+	var kinds [][2]string
+	for pair := range pairs {
+		kinds = append(kinds, pair)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if pairs[kinds[i]] != pairs[kinds[j]] {
+			return pairs[kinds[i]] > pairs[kinds[j]]
+		}
+		return kinds[i][0]+"\t"+kinds[i][1] < kinds[j][0]+"\t"+kinds[j][1]
+	})
+	for _, pair := range kinds {
+		fmt.Fprintf(os.Stderr, "  %s -> %s: %d\n", pair[0], pair[1], pairs[pair])
+	}
+	if counts["changed"] > 0 || counts["added"] > 0 || counts["removed"] > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"time"
+)
+
+// ecsVersion is the version of the Elastic Common Schema the ecs format
+// follows.
+const ecsVersion = "8.11.0"
+
+// ecsDocument is a result in the ecs format: a json-v1 record mapped onto
+// the fields of the Elastic Common Schema, with the result in the custom
+// language field set, so that results can be indexed into Elasticsearch
+// without an ingest pipeline.
+type ecsDocument struct {
+	Timestamp string          `json:"@timestamp"`
+	ECS       ecsVersionField `json:"ecs"`
+	Event     ecsEvent        `json:"event"`
+	Message   *string         `json:"message,omitempty"`
+	Language  *ecsLanguage    `json:"language,omitempty"`
+	File      *ecsFile        `json:"file,omitempty"`
+	Labels    map[string]any  `json:"labels,omitempty"`
+}
+
+type ecsVersionField struct {
+	Version string `json:"version"`
+}
+
+type ecsEvent struct {
+	Created  string `json:"created"`
+	Kind     string `json:"kind"`
+	Dataset  string `json:"dataset"`
+	Sequence int    `json:"sequence,omitempty"`
+}
+
+type ecsLanguage struct {
+	Code        string           `json:"code"`
+	Score       *float64         `json:"score,omitempty"`
+	Entropy     *float64         `json:"entropy,omitempty"`
+	Margin      *float64         `json:"margin,omitempty"`
+	MixedScript []string         `json:"mixed_script,omitempty"`
+	Confidences []jsonConfidence `json:"confidences,omitempty"`
+}
+
+type ecsFile struct {
+	Path string `json:"path"`
+}
+
+// ecsDocument maps r onto the Elastic Common Schema: the line number
+// becomes event.sequence, the text the message, which for texts outside
+// line mode is the one the printer holds, and the input encoding a label.
+// Lines passed through without classification have no language.
+func (p *printer) ecsDocument(r jsonRecord) ecsDocument {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	d := ecsDocument{
+		Timestamp: now,
+		ECS:       ecsVersionField{ecsVersion},
+		Event:     ecsEvent{Created: now, Kind: "event", Dataset: "lingua", Sequence: r.Line},
+		Message:   r.Text,
+	}
+	if r.Language != nil {
+		d.Language = &ecsLanguage{Code: *r.Language, Score: r.Score, Entropy: r.Entropy, Margin: r.Margin, MixedScript: r.MixedScript, Confidences: r.Confidences}
+	}
+	if d.Message == nil && r.Line == 0 && r.File == "" && p.source != "" {
+		d.Message = &p.source
+	}
+	if r.File != "" {
+		d.File = &ecsFile{r.File}
+	}
+	if r.Encoding != "" {
+		d.Labels = map[string]any{"encoding": r.Encoding}
+	}
+	return d
+}
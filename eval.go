@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// runEval implements the eval subcommand. It classifies a labeled corpus and
+// reports precision, recall and F1 per language along with their micro and
+// macro averages, optionally as mean and standard deviation across k splits
+// of the corpus.
+func runEval(args []string) int {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0,
+		"Confidence threshold, results below it count as 'unknown' (0.0-1.0)")
+	splits := fs.Int("splits", 1,
+		"Evaluate this many stratified splits of the corpus separately and report the mean and standard deviation of every metric across them. Nothing is fitted on the other splits; this measures how much the metrics vary on samples of this size, not cross-validation.")
+	seed := fs.Int64("seed", 1,
+		"Random seed for assigning texts to splits")
+	jsonOutput := fs.Bool("json", false,
+		"Print the report as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Evaluate detection accuracy on a labeled corpus.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli eval [OPTIONS] FILE\n\n")
+		fmt.Fprintf(os.Stderr, "FILE holds one label<TAB>text pair per line ('-' reads stdin).\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *splits < 1 {
+		fmt.Fprintf(os.Stderr, "error: -splits must be at least 1\n")
+		return 2
+	}
+	entries, err := readLabeled(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	predicted := make([]string, len(entries))
+	for i, entry := range entries {
+		results, err := detector.ConfidenceValues(entry.text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		predicted[i] = langid.Unknown
+		if len(results) > 0 && results[0].Score > 0 && results[0].Score >= *threshold {
+			predicted[i] = results[0].Code
+		}
+	}
+
+	report := evaluate(entries, predicted, assignSplits(entries, *splits, *seed), *splits)
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+	report.print(*splits > 1)
+	return 0
+}
+
+// evalReport is the result of the eval subcommand. With several splits every
+// metric is the mean across splits, accompanied by its standard deviation.
+type evalReport struct {
+	Texts     int           `json:"texts"`
+	Splits    int           `json:"splits"`
+	Accuracy  float64       `json:"accuracy"`
+	Languages []metricsLine `json:"languages"`
+	Micro     metricsLine   `json:"micro"`
+	Macro     metricsLine   `json:"macro"`
+}
+
+// metricsLine holds the precision, recall and F1 of one language or average.
+type metricsLine struct {
+	Language        string   `json:"language,omitempty"`
+	Texts           int      `json:"texts,omitempty"`
+	Precision       float64  `json:"precision"`
+	PrecisionStddev *float64 `json:"precision_stddev,omitempty"`
+	Recall          float64  `json:"recall"`
+	RecallStddev    *float64 `json:"recall_stddev,omitempty"`
+	F1              float64  `json:"f1"`
+	F1Stddev        *float64 `json:"f1_stddev,omitempty"`
+}
+
+// prf is precision, recall and F1 measured on one split.
+type prf struct {
+	precision, recall, f1 float64
+}
+
+func newPRF(hits, predicted, texts int) prf {
+	var m prf
+	if predicted > 0 {
+		m.precision = float64(hits) / float64(predicted)
+	}
+	if texts > 0 {
+		m.recall = float64(hits) / float64(texts)
+	}
+	if m.precision+m.recall > 0 {
+		m.f1 = 2 * m.precision * m.recall / (m.precision + m.recall)
+	}
+	return m
+}
+
+// evaluate scores the predictions split by split. Texts classified as unknown
+// count against recall but not against the precision of any language.
+func evaluate(entries []labeledText, predicted []string, assignment []int, splits int) *evalReport {
+	// perSplit[code] collects the metrics of a language in every split it
+	// occurs in.
+	perSplit := make(map[string][]prf)
+	var micro, macro []prf
+	support := make(map[string]int)
+	hitsTotal := 0
+	for split := 0; split < splits; split++ {
+		hits := make(map[string]int)
+		predictions := make(map[string]int)
+		texts := make(map[string]int)
+		allHits, allPredictions, allTexts := 0, 0, 0
+		for i, entry := range entries {
+			if assignment[i] != split {
+				continue
+			}
+			texts[entry.label]++
+			allTexts++
+			if predicted[i] != langid.Unknown {
+				predictions[predicted[i]]++
+				allPredictions++
+			}
+			if predicted[i] == entry.label {
+				hits[entry.label]++
+				allHits++
+			}
+		}
+		if allTexts == 0 {
+			continue
+		}
+		var average prf
+		for code, n := range texts {
+			m := newPRF(hits[code], predictions[code], n)
+			perSplit[code] = append(perSplit[code], m)
+			support[code] += n
+			average.precision += m.precision / float64(len(texts))
+			average.recall += m.recall / float64(len(texts))
+			average.f1 += m.f1 / float64(len(texts))
+		}
+		micro = append(micro, newPRF(allHits, allPredictions, allTexts))
+		macro = append(macro, average)
+		hitsTotal += allHits
+	}
+
+	report := &evalReport{
+		Texts:    len(entries),
+		Splits:   splits,
+		Accuracy: float64(hitsTotal) / float64(len(entries)),
+	}
+	var languages []string
+	for code := range support {
+		languages = append(languages, code)
+	}
+	sort.Strings(languages)
+	for _, code := range languages {
+		line := summarize(perSplit[code], splits > 1)
+		line.Language, line.Texts = code, support[code]
+		report.Languages = append(report.Languages, line)
+	}
+	report.Micro = summarize(micro, splits > 1)
+	report.Macro = summarize(macro, splits > 1)
+	return report
+}
+
+// summarize averages per-split metrics, recording standard deviations if
+// withStddev is set.
+func summarize(splits []prf, withStddev bool) metricsLine {
+	var precision, recall, f1 []float64
+	for _, m := range splits {
+		precision = append(precision, m.precision)
+		recall = append(recall, m.recall)
+		f1 = append(f1, m.f1)
+	}
+	var line metricsLine
+	var stddevs [3]float64
+	line.Precision, stddevs[0] = meanStddev(precision)
+	line.Recall, stddevs[1] = meanStddev(recall)
+	line.F1, stddevs[2] = meanStddev(f1)
+	if withStddev {
+		line.PrecisionStddev, line.RecallStddev, line.F1Stddev = &stddevs[0], &stddevs[1], &stddevs[2]
+	}
+	return line
+}
+
+// print writes the report as a tab-separated table.
+func (r *evalReport) print(withStddev bool) {
+	fmt.Printf("accuracy %.4f on %d texts", r.Accuracy, r.Texts)
+	if withStddev {
+		fmt.Printf(" (%d splits, mean ±stddev)", r.Splits)
+	}
+	fmt.Printf("\n\nlanguage\ttexts\tprecision\trecall\tf1\n")
+	cell := func(mean float64, stddev *float64) string {
+		if stddev == nil {
+			return fmt.Sprintf("%.4f", mean)
+		}
+		return fmt.Sprintf("%.4f ±%.4f", mean, *stddev)
+	}
+	row := func(name string, texts int, line metricsLine) {
+		fmt.Printf("%s\t%d\t%s\t%s\t%s\n", name, texts,
+			cell(line.Precision, line.PrecisionStddev),
+			cell(line.Recall, line.RecallStddev),
+			cell(line.F1, line.F1Stddev))
+	}
+	for _, line := range r.Languages {
+		row(line.Language, line.Texts, line)
+	}
+	row("(micro)", r.Texts, r.Micro)
+	row("(macro)", r.Texts, r.Macro)
+}
+
+// assignSplits distributes the texts of every language evenly over k splits
+// in random order, so each split has about the same language mix.
+func assignSplits(entries []labeledText, k int, seed int64) []int {
+	byLabel := make(map[string][]int)
+	var labels []string
+	for i, entry := range entries {
+		if _, ok := byLabel[entry.label]; !ok {
+			labels = append(labels, entry.label)
+		}
+		byLabel[entry.label] = append(byLabel[entry.label], i)
+	}
+	sort.Strings(labels)
+
+	rng := rand.New(rand.NewSource(seed))
+	assignment := make([]int, len(entries))
+	next := 0
+	for _, label := range labels {
+		indices := byLabel[label]
+		rng.Shuffle(len(indices), func(i, j int) { indices[i], indices[j] = indices[j], indices[i] })
+		for _, i := range indices {
+			assignment[i] = next % k
+			next++
+		}
+	}
+	return assignment
+}
+
+// meanStddev returns the mean and sample standard deviation of values.
+func meanStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
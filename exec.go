@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// resultCommand is the -exec command, run for every result.
+type resultCommand struct {
+	args []string
+	// langs, if set, limits the command to results in these languages.
+	langs     map[string]bool
+	threshold float64
+	labels    labelMap
+}
+
+func newResultCommand(command, langs string, threshold float64, labels labelMap) (*resultCommand, error) {
+	args, err := langid.SplitCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty -exec command")
+	}
+	c := &resultCommand{args: args, threshold: threshold, labels: labels}
+	if list := splitList(langs); len(list) > 0 {
+		c.langs = make(map[string]bool)
+		for _, lang := range list {
+			c.langs[lang] = true
+		}
+	}
+	return c, nil
+}
+
+// run runs the command for the result of a text: a line of the input if
+// number is set, a file if name is set, or else a text. The language, score,
+// file name, line number and text replace {lang}, {score}, {file}, {line} and
+// {text} in the arguments, and are set as LINGUA_LANG, LINGUA_SCORE,
+// LINGUA_FILE, LINGUA_LINE and LINGUA_TEXT in its environment. Its output
+// goes to stderr, so that the results on stdout stay intact, and failures
+// are reported as warnings.
+func (c *resultCommand) run(name string, number int, text string, results []langid.Confidence) {
+	if c == nil {
+		return
+	}
+	lang, score := c.labels.label(langid.Unknown), ""
+	if results = c.labels.apply(results); len(results) > 0 && results[0].Score > 0 && results[0].Score >= c.threshold {
+		lang, score = results[0].Code, formatScore(results[0].Score)
+	}
+	if c.langs != nil && !c.langs[lang] {
+		return
+	}
+	line := ""
+	if number > 0 {
+		line = strconv.Itoa(number)
+	}
+	fields := strings.NewReplacer("{lang}", lang, "{score}", score, "{file}", name, "{line}", line, "{text}", text)
+	args := make([]string, len(c.args))
+	for i, arg := range c.args {
+		args[i] = fields.Replace(arg)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(),
+		"LINGUA_LANG="+lang, "LINGUA_SCORE="+score, "LINGUA_FILE="+name, "LINGUA_LINE="+line, "LINGUA_TEXT="+text)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -exec %s: %v\n", args[0], err)
+	}
+}
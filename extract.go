@@ -0,0 +1,388 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// sniffSize is how much of a file content sniffing looks at.
+const sniffSize = 512
+
+// maxExpandedSize bounds the decompressed content of a document, its
+// FlateDecode streams or the document part of a DOCX archive, which
+// -max-file-size does not see: a small file may expand to gigabytes.
+const maxExpandedSize = 64 << 20
+
+// skippedError reports a file that was not classified, and why.
+type skippedError struct {
+	reason string
+}
+
+func (e *skippedError) Error() string { return "skipped, " + e.reason }
+
+// extractor turns the content of a file into plain text. encoding is the
+// -encoding value, for formats that do not declare their own.
+type extractor func(data []byte, encoding string) (string, error)
+
+// sniffFile determines the kind of a file from its first bytes and its name,
+// returning the kind ("text", "html", "pdf", "docx", "rtf" or "doc") and the
+// extractor for it, or a skippedError for binary files.
+func sniffFile(path string, head []byte) (string, extractor, error) {
+	mime := http.DetectContentType(head)
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case bytes.HasPrefix(head, []byte(`{\rtf`)):
+		return "rtf", extractRTF, nil
+	case strings.HasPrefix(mime, "text/html"), ext == ".html" || ext == ".htm" || ext == ".xhtml":
+		return "html", extractHTML, nil
+	case mime == "application/pdf":
+		return "pdf", extractPDF, nil
+	case mime == "application/zip" && ext == ".docx":
+		return "docx", extractDOCX, nil
+	case bytes.HasPrefix(head, oleMagic) && (ext == ".doc" || ext == ".dot"):
+		return "doc", extractDOC, nil
+	case strings.HasPrefix(mime, "text/"):
+		return "text", extractPlain, nil
+	}
+	return "", nil, &skippedError{fmt.Sprintf("binary file (%s)", mime)}
+}
+
+// fileLimits bound how much of a file is read.
+type fileLimits struct {
+	// maxSize skips files larger than this many bytes (0 for no limit).
+	maxSize int64
+	// headBytes reads only the first this many bytes of text and HTML
+	// files (0 for all of them).
+	headBytes int64
+}
+
+// extractFile reads a file and returns its text, using the extractor for its
+// kind. Binary files, and files exceeding limits.maxSize, are rejected with a
+// skippedError.
+func extractFile(path, encoding string, limits fileLimits) (string, error) {
+	f, size, err := openFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return extractReader(path, f, size, encoding, limits)
+}
+
+// extractReader is extractFile for the content of the named file read from
+// f, which is size bytes long (-1 if unknown).
+func extractReader(path string, f io.Reader, size int64, encoding string, limits fileLimits) (string, error) {
+	if limits.maxSize > 0 && size > limits.maxSize {
+		return "", &skippedError{fmt.Sprintf("larger than %d bytes", limits.maxSize)}
+	}
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+	kind, extract, err := sniffFile(path, head)
+	if err != nil {
+		return "", err
+	}
+
+	// Documents can only be parsed as a whole; text is cut at the last
+	// whitespace before the limit.
+	var rest io.Reader = f
+	sampled := limits.headBytes > 0 && (kind == "text" || kind == "html")
+	if sampled {
+		rest = io.LimitReader(f, limits.headBytes-int64(len(head)))
+		head = head[:min(int64(len(head)), limits.headBytes)]
+	}
+	data, err := io.ReadAll(io.MultiReader(bytes.NewReader(head), rest))
+	if err != nil {
+		return "", err
+	}
+	if sampled && int64(len(data)) == limits.headBytes && (size < 0 || size > limits.headBytes) {
+		data = data[:completeRunes(data[:chunkCut(data, len(data))])]
+	}
+
+	text, err := extract(data, encoding)
+	if _, ok := err.(*skippedError); ok {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", kind, err)
+	}
+	if strings.TrimSpace(text) == "" && kind != "text" {
+		return "", &skippedError{fmt.Sprintf("no text found in %s file", kind)}
+	}
+	return text, nil
+}
+
+func extractPlain(data []byte, encoding string) (string, error) {
+	r, _, err := decodeInput(bytes.NewReader(data), encoding)
+	if err != nil {
+		return "", err
+	}
+	text, err := io.ReadAll(r)
+	return string(text), err
+}
+
+var (
+	htmlSkipped = regexp.MustCompile(`(?is)<!--.*?-->|<(script|style|noscript|template)\b.*?</(script|style|noscript|template)\s*>`)
+	htmlBlock   = regexp.MustCompile(`(?i)</?(p|div|br|li|h[1-6]|tr|td|th|section|article|blockquote|pre|title)\b[^>]*>`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// extractHTML returns the visible text of an HTML document, with block
+// elements on lines of their own.
+func extractHTML(data []byte, encoding string) (string, error) {
+	source, err := extractPlain(data, encoding)
+	if err != nil {
+		return "", err
+	}
+	return htmlText(source), nil
+}
+
+// htmlText returns the visible text of HTML source, a document or a part of
+// one, like extractHTML.
+func htmlText(source string) string {
+	text := htmlSkipped.ReplaceAllString(source, " ")
+	text = htmlBlock.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// extractDOCX returns the paragraphs of a Word document, one per line.
+func extractDOCX(data []byte, _ string) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	f, err := archive.Open("word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	document, err := readExpanded(f, maxExpandedSize)
+	if err != nil {
+		return "", err
+	}
+
+	var text strings.Builder
+	inText := false
+	decoder := xml.NewDecoder(bytes.NewReader(document))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab", "br":
+				text.WriteByte(' ')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				text.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				text.Write(t)
+			}
+		}
+	}
+	return text.String(), nil
+}
+
+var (
+	pdfStream    = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfTextBlock = regexp.MustCompile(`(?s)BT(.*?)ET`)
+)
+
+// extractPDF returns the text shown by the content streams of a PDF. It only
+// understands strings in the standard encodings; text drawn with embedded
+// font encodings, as well as scanned pages, yields nothing.
+func extractPDF(data []byte, _ string) (string, error) {
+	var text strings.Builder
+	expanded := int64(0)
+	for _, loc := range pdfStream.FindAllSubmatchIndex(data, -1) {
+		dict := data[loc[2]:loc[3]]
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		content := data[start : start+end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// Streams are often followed by garbage the decompressor
+			// complains about; keep what was decoded.
+			content, err = readExpanded(r, maxExpandedSize-expanded)
+			if _, ok := err.(*skippedError); ok {
+				return "", err
+			}
+			expanded += int64(len(content))
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		for _, block := range pdfTextBlock.FindAll(content, -1) {
+			pdfBlockText(&text, block)
+			text.WriteByte('\n')
+		}
+	}
+	return text.String(), nil
+}
+
+// readExpanded reads the decompressed content of r, which must not exceed
+// limit bytes. Longer content is rejected with a skippedError; it is read
+// only as far as needed to tell.
+func readExpanded(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(data)) > limit {
+		return nil, &skippedError{fmt.Sprintf("expands to more than %d bytes", int64(maxExpandedSize))}
+	}
+	return data, err
+}
+
+// pdfBlockText appends the strings of a BT ... ET text block to text.
+// Strings within one TJ array are joined directly, as their gaps are kerning
+// rather than word breaks, unless the gap is wide.
+func pdfBlockText(text *strings.Builder, block []byte) {
+	for i := 0; i < len(block); i++ {
+		switch block[i] {
+		case '(':
+			s, next := pdfLiteral(block, i+1)
+			text.WriteString(s)
+			i = next
+		case '<':
+			if i+1 < len(block) && block[i+1] == '<' {
+				i++
+				continue
+			}
+			end := bytes.IndexByte(block[i:], '>')
+			if end < 0 {
+				return
+			}
+			text.WriteString(pdfHex(block[i+1 : i+end]))
+			i += end
+		case '-':
+			// A large negative adjustment in a TJ array is a space.
+			j := i + 1
+			for j < len(block) && (block[j] >= '0' && block[j] <= '9' || block[j] == '.') {
+				j++
+			}
+			var n float64
+			if _, err := fmt.Sscan(string(block[i+1:j]), &n); err == nil && n > 200 {
+				text.WriteByte(' ')
+			}
+			i = j - 1
+		case '\'', '"':
+			// Operators starting a new line.
+			text.WriteByte('\n')
+		case 'T':
+			if i+1 < len(block) && (block[i+1] == 'd' || block[i+1] == 'D' || block[i+1] == '*') {
+				text.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// pdfLiteral decodes a literal string starting after its opening
+// parenthesis, returning it and the position of the closing parenthesis.
+func pdfLiteral(data []byte, i int) (string, int) {
+	var s []byte
+	depth := 0
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n', 'r':
+				s = append(s, ' ')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				n := 0
+				for k := 0; k < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; k++ {
+					n = n*8 + int(data[i]-'0')
+					i++
+				}
+				i--
+				s = append(s, byte(n))
+			case '\n', '\r':
+			default:
+				s = append(s, e)
+			}
+		case c == '(':
+			depth++
+			s = append(s, c)
+		case c == ')':
+			if depth == 0 {
+				return pdfString(s), i
+			}
+			depth--
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return pdfString(s), i
+}
+
+// pdfHex decodes a hexadecimal string.
+func pdfHex(digits []byte) string {
+	digits = bytes.Join(bytes.Fields(digits), nil)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s, err := hex.DecodeString(string(digits))
+	if err != nil {
+		return ""
+	}
+	return pdfString(s)
+}
+
+// pdfString converts the bytes of a PDF string to UTF-8. Strings starting
+// with a byte order mark are UTF-16, all others are read as Latin-1, which
+// matches the common WinAnsi and PDFDoc encodings for letters.
+func pdfString(s []byte) string {
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		var runes []rune
+		for i := 2; i+1 < len(s); i += 2 {
+			runes = append(runes, rune(s[i])<<8|rune(s[i+1]))
+		}
+		return string(runes)
+	}
+	runes := make([]rune, len(s))
+	for i, b := range s {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
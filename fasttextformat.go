@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// fastTextPrefix marks labels in the formats of fastText.
+const fastTextPrefix = "__label__"
+
+// printFastText prints a relabeled distribution like fastText's predict-prob
+// does, as "__label__en 0.98" followed by the other languages above the
+// threshold with -a, and then text after a tab if it is not empty. Texts
+// that could not be classified get the unknown label without a score.
+func (p *printer) printFastText(results []langid.Confidence, text string) {
+	var labels []string
+	for _, result := range results {
+		if p.hasThreshold && result.Score < p.threshold {
+			break
+		}
+		labels = append(labels, fastTextPrefix+result.Code+" "+formatScore(result.Score))
+		if !p.all {
+			break
+		}
+	}
+	if len(labels) == 0 {
+		labels = append(labels, fastTextPrefix+p.labels.label(langid.Unknown))
+	}
+	p.w.WriteString(strings.Join(labels, " "))
+	if text != "" {
+		p.w.WriteByte('\t')
+		p.w.WriteString(text)
+	}
+	p.w.WriteByte('\n')
+	p.endResult()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// urlFetcher downloads http:// and https:// inputs, retrying failures that
+// may be temporary.
+type urlFetcher struct {
+	client *http.Client
+	// retries is how often a request is repeated after a network error, a
+	// rate limit (429) or a server error (5xx).
+	retries int
+	// backoff is the delay before the first retry, doubled for every
+	// further one.
+	backoff time.Duration
+}
+
+// fetcher is the fetcher used for URL inputs, configured by -http-timeout
+// and -http-retries.
+var fetcher = &urlFetcher{
+	client:  &http.Client{Timeout: 30 * time.Second},
+	retries: 2,
+	backoff: time.Second,
+}
+
+// isURL reports whether path is an http:// or https:// URL.
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// httpStatusError reports a response with a status other than 2xx.
+type httpStatusError struct {
+	code   int
+	status string
+}
+
+func (e *httpStatusError) Error() string { return "HTTP " + e.status }
+
+// open fetches url, returning the response body and its length, which is -1
+// if unknown.
+func (f *urlFetcher) open(url string) (io.ReadCloser, int64, error) {
+	resp, err := f.fetch(url)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// fetch gets url, retrying as configured. Responses with a status other
+// than 2xx are returned as an httpStatusError.
+func (f *urlFetcher) fetch(url string) (*http.Response, error) {
+	return f.do(func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, url, nil)
+	})
+}
+
+// do sends the requests made by newRequest until one succeeds or may not be
+// retried, like fetch.
+func (f *urlFetcher) do(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	delay := f.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", fmt.Sprintf("lingua-cli/%s", version))
+		resp, err := f.client.Do(req)
+		retry := err != nil
+		if err == nil {
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return resp, nil
+			}
+			resp.Body.Close()
+			err = &httpStatusError{resp.StatusCode, resp.Status}
+			retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+			if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+				delay = time.Duration(seconds) * time.Second
+			}
+		}
+		if !retry || attempt >= f.retries {
+			return nil, err
+		}
+		// Jitter keeps parallel workers from retrying in lockstep.
+		time.Sleep(delay + time.Duration(rand.Int63n(int64(delay)/2+1)))
+		delay *= 2
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// fileResult is the classification of one file in file mode: either of the
+// whole file, or of each of its lines.
+type fileResult struct {
+	path    string
+	results []langid.Confidence
+	// detected holds the results before -lang-manifest restricted them.
+	detected []langid.Confidence
+	lines    []lineResult
+	// invalid holds the text of a file that is not valid UTF-8, so that
+	// it can be reported.
+	invalid string
+	// text holds the text of the file for the columns derived from it.
+	text string
+	// readErr is set if the file could not be read; err is a detection error.
+	readErr error
+	err     error
+}
+
+// walkFiles passes the files named by args to send, searching directories
+// recursively. Arguments containing glob metacharacters that do not name an
+// existing file are patterns ("**/*.md"), matched against the files below
+// their leading directories, s3://, gs:// and az:// URIs name objects or
+// prefixes in cloud storage, and http:// and https:// URLs are fetched.
+// Hidden files and directories are skipped, as are paths excluded by
+// .linguaignore files in the searched directories, and paths that can not be
+// read are reported as warnings.
+func walkFiles(args []string, send func(string)) error {
+	for _, arg := range args {
+		if isURL(arg) {
+			send(arg)
+			continue
+		}
+		if isObjectURI(arg) {
+			if err := walkObjects(arg, send); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			}
+			continue
+		}
+		root, pattern := arg, ""
+		if hasGlobMeta(arg) {
+			if _, err := os.Lstat(arg); err != nil {
+				root, pattern = globBase(arg)
+			}
+		}
+		ignores := make(ignoreSet)
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				return nil
+			}
+			if path != root && (strings.HasPrefix(entry.Name(), ".") || ignores.ignored(root, path, entry.IsDir())) {
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if entry.IsDir() {
+				rules, err := loadIgnoreFile(path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+				}
+				if rules != nil {
+					ignores[path] = rules
+				}
+				return nil
+			}
+			if entry.Type().IsRegular() && (pattern == "" || matchGlobOrParent(pattern, path)) {
+				send(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openFile opens a local file, a URL or a cloud storage object, returning
+// its size, which is -1 if unknown.
+func openFile(path string) (io.ReadCloser, int64, error) {
+	if isURL(path) {
+		return fetcher.open(path)
+	}
+	if isObjectURI(path) {
+		return openObject(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, info.Size(), nil
+}
+
+// shareOfLines returns the share of each language among the classified lines
+// of a file, sorted by descending share. Lines without a language, or whose
+// best score is below threshold, are not counted.
+func shareOfLines(lines []lineResult, threshold float64) []langid.Confidence {
+	counts := make(map[string]int)
+	total := 0
+	for _, r := range lines {
+		if r.err != nil || len(r.results) == 0 || r.results[0].Score <= 0 || r.results[0].Score < threshold {
+			continue
+		}
+		counts[r.results[0].Code]++
+		total++
+	}
+	var shares []langid.Confidence
+	for code, n := range counts {
+		shares = append(shares, langid.Confidence{Code: code, Score: float64(n) / float64(total)})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Score != shares[j].Score {
+			return shares[i].Score > shares[j].Score
+		}
+		return shares[i].Code < shares[j].Code
+	})
+	return shares
+}
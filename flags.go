@@ -0,0 +1,227 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// longFlags maps the long option names of the Rust lingua-cli, and the long
+// names of other single-letter flags, to the flags they stand for, so that
+// scripts written for the Rust version run unchanged ("--languages en,de").
+// The flag package accepts two dashes before any flag name.
+var longFlags = map[string]string{
+	"languages":      "l",
+	"list-languages": "L",
+	"per-line":       "n",
+	"all":            "a",
+	"confidence":     "c",
+	"multi":          "m",
+	"quick":          "q",
+	"minlength":      "M",
+	"distance":       "d",
+	"delimiter":      "D",
+	"jobs":           "j",
+	"version":        "V",
+}
+
+// addLongFlags defines the long names of the flags of fs that have one.
+func addLongFlags(fs *flag.FlagSet) {
+	for long, short := range longFlags {
+		if f := fs.Lookup(short); f != nil {
+			fs.Var(f.Value, long, f.Usage)
+		}
+	}
+}
+
+// flagDoc describes a flag for the help and the manual.
+type flagDoc struct {
+	// names are the names of the flag with their dashes, the long names
+	// after the short one ("-a", "--all").
+	names    []string
+	typeName string
+	usage    string
+	// def is the default value as the help shows it, or "".
+	def string
+}
+
+// describeFlags describes the flags of fs in the order of their names, with
+// the long names of flags next to their short ones.
+func describeFlags(fs *flag.FlagSet) []flagDoc {
+	long := make(map[string][]string)
+	for name, short := range longFlags {
+		if fs.Lookup(name) != nil {
+			long[short] = append(long[short], name)
+		}
+	}
+	var docs []flagDoc
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := longFlags[f.Name]; ok {
+			return
+		}
+		doc := flagDoc{names: []string{"-" + f.Name}}
+		names := long[f.Name]
+		sort.Strings(names)
+		for _, name := range names {
+			doc.names = append(doc.names, "--"+name)
+		}
+		doc.typeName, doc.usage = flag.UnquoteUsage(f)
+		if !isZeroValue(f) {
+			if reflect.TypeOf(f.Value).Elem().Kind() == reflect.String {
+				doc.def = fmt.Sprintf("%q", f.DefValue)
+			} else {
+				doc.def = f.DefValue
+			}
+		}
+		docs = append(docs, doc)
+	})
+	return docs
+}
+
+// printFlags prints the flags of fs like flag.PrintDefaults does, with the
+// long names of flags next to their short ones.
+func printFlags(w io.Writer, fs *flag.FlagSet) {
+	for _, doc := range describeFlags(fs) {
+		var b strings.Builder
+		b.WriteString("  " + strings.Join(doc.names, ", "))
+		if doc.typeName != "" {
+			b.WriteString(" " + doc.typeName)
+		}
+		if b.Len() <= 4 {
+			b.WriteString("\t")
+		} else {
+			b.WriteString("\n    \t")
+		}
+		b.WriteString(strings.ReplaceAll(doc.usage, "\n", "\n    \t"))
+		if doc.def != "" {
+			fmt.Fprintf(&b, " (default %s)", doc.def)
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// isZeroValue reports whether the default of f is the zero value of its
+// type, which flag.PrintDefaults does not print.
+func isZeroValue(f *flag.Flag) bool {
+	typ := reflect.TypeOf(f.Value)
+	if typ.Kind() != reflect.Pointer {
+		return f.DefValue == reflect.Zero(typ).Interface().(flag.Value).String()
+	}
+	return f.DefValue == reflect.New(typ.Elem()).Interface().(flag.Value).String()
+}
+
+// parseFlags parses args with fs the way GNU tools do: flags may follow
+// other arguments, single-letter flags may be combined ("-na" for "-n -a")
+// with the value of the last one attached ("-len" for "-l en"), and "--"
+// ends the flags.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	var flags, positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			positional = append(positional, arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if f := fs.Lookup(name); f != nil {
+			flags = append(flags, arg)
+			if !hasValue && !isBoolFlag(f) && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+			continue
+		}
+		if arg[1] != '-' {
+			if split, needsValue, ok := splitShortFlags(fs, arg[1:]); ok {
+				flags = append(flags, split...)
+				if needsValue && i+1 < len(args) {
+					i++
+					flags = append(flags, args[i])
+				}
+				continue
+			}
+		}
+		// Unknown flags are left to fs to report.
+		flags = append(flags, arg)
+	}
+	return fs.Parse(append(append(flags, "--"), positional...))
+}
+
+// splitShortFlags splits combined single-letter flags such as "na" or
+// "len" into separate arguments. needsValue reports whether the last flag
+// takes a value that was not attached.
+func splitShortFlags(fs *flag.FlagSet, letters string) (args []string, needsValue, ok bool) {
+	for i, r := range letters {
+		f := fs.Lookup(string(r))
+		if f == nil {
+			return nil, false, false
+		}
+		args = append(args, "-"+f.Name)
+		if !isBoolFlag(f) {
+			if rest := letters[i+len(string(r)):]; rest != "" {
+				return append(args, rest), false, true
+			}
+			return args, true, true
+		}
+	}
+	return args, false, true
+}
+
+// isBoolFlag reports whether f is a flag without a value.
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// envPrefix starts the names of the environment variables that set flags.
+const envPrefix = "LINGUA_CLI_"
+
+// envIgnored lists the flags neither an environment variable nor the
+// configuration file sets: actions that print something and exit, and
+// one-shot inputs, which would otherwise take over every run, such as
+// LINGUA_CLI_VERSION set by packaging scripts for -V.
+var envIgnored = map[string]bool{"V": true, "L": true, "print-schema": true, "clipboard": true}
+
+// envName returns the environment variable that sets a flag: its long name,
+// or else its name, in upper case with underscores for dashes, after
+// envPrefix, such as LINGUA_CLI_LANGUAGES for -l and LINGUA_CLI_MAX_CHARS
+// for -max-chars.
+func envName(name string) string {
+	for long, short := range longFlags {
+		if short == name {
+			name = long
+			break
+		}
+	}
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// setFlagsFromEnv sets the flags of fs that were not given on the command
+// line from their environment variables, except those of envIgnored.
+func setFlagsFromEnv(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		given[canonicalFlag(f.Name)] = true
+	})
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := longFlags[f.Name]; ok || given[f.Name] || envIgnored[f.Name] || err != nil {
+			return
+		}
+		name := envName(f.Name)
+		if value, ok := os.LookupEnv(name); ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", value, name, setErr)
+			}
+		}
+	})
+	return err
+}
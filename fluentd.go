@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/internal/msgpack"
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// fluentSink sends the results to a Fluentd or Fluent Bit forward input, as
+// events in the message mode of the forward protocol: msgpack-encoded
+// [tag, time, record] arrays over TCP, with the fields of a json-v1 record.
+type fluentSink struct {
+	address string
+	conn    net.Conn
+	// tag is the tag template, with {lang} replaced by the language.
+	tag       string
+	threshold float64
+	labels    labelMap
+}
+
+// dialFluent connects to the forward input at rawURL, tcp://host[:24224]
+// or host[:port].
+func dialFluent(rawURL, tag string, threshold float64, labels labelMap) (*fluentSink, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "tcp://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "tcp" {
+		return nil, fmt.Errorf("unsupported scheme %q (tcp)", u.Scheme)
+	}
+	s := &fluentSink{address: hostPort(u, "24224"), tag: tag, threshold: threshold, labels: labels}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *fluentSink) connect() error {
+	conn, err := net.DialTimeout("tcp", s.address, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// add sends the event of a result, reconnecting once if the connection was
+// lost, and reports failures as warnings.
+func (s *fluentSink) add(name string, number int, text string, results []langid.Confidence) {
+	if s == nil {
+		return
+	}
+	r := sinkRecord(name, number, text, results, s.threshold, s.labels)
+	event := s.encode(strings.ReplaceAll(s.tag, "{lang}", *r.Language), time.Now(), r)
+	_, err := s.conn.Write(event)
+	if err != nil {
+		s.conn.Close()
+		if err = s.connect(); err == nil {
+			_, err = s.conn.Write(event)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -fluentd: %v\n", err)
+	}
+}
+
+// encode returns the msgpack encoding of the event of a record.
+func (s *fluentSink) encode(tag string, t time.Time, r jsonRecord) []byte {
+	var b msgpack.Buffer
+	b.ArrayHeader(3)
+	b.Str(tag)
+	// EventTime, extension type 0: seconds and nanoseconds.
+	eventTime := binary.BigEndian.AppendUint32(nil, uint32(t.Unix()))
+	b.Ext(0, binary.BigEndian.AppendUint32(eventTime, uint32(t.Nanosecond())))
+
+	fields := 1
+	for _, set := range []bool{r.File != "", r.Line > 0, r.Score != nil, r.Text != nil} {
+		if set {
+			fields++
+		}
+	}
+	b.MapHeader(fields)
+	if r.File != "" {
+		b.Str("file")
+		b.Str(r.File)
+	}
+	if r.Line > 0 {
+		b.Str("line")
+		b.Int(int64(r.Line))
+	}
+	b.Str("language")
+	b.Str(*r.Language)
+	if r.Score != nil {
+		b.Str("score")
+		b.Float(*r.Score)
+	}
+	if r.Text != nil {
+		b.Str("text")
+		b.Str(*r.Text)
+	}
+	return b.Bytes()
+}
+
+func (s *fluentSink) close() {
+	s.conn.Close()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFluentEncode(t *testing.T) {
+	file, language, score, text := "a.txt", "en", 0.5, "hi"
+	unknown := "unknown"
+	eventTime := []byte{0xd7, 0x00, 0x65, 0x53, 0xf1, 0x00, 0x00, 0x00, 0x00, 0x7b}
+	tests := []struct {
+		record jsonRecord
+		want   []byte
+	}{
+		{
+			jsonRecord{File: file, Line: 3, Language: &language, Score: &score, Text: &text},
+			bytes.Join([][]byte{
+				{0x93, 0xa7}, []byte("lang.en"), eventTime,
+				{0x85},
+				{0xa4}, []byte("file"), {0xa5}, []byte("a.txt"),
+				{0xa4}, []byte("line"), {0x03},
+				{0xa8}, []byte("language"), {0xa2}, []byte("en"),
+				{0xa5}, []byte("score"), {0xcb, 0x3f, 0xe0, 0, 0, 0, 0, 0, 0},
+				{0xa4}, []byte("text"), {0xa2}, []byte("hi"),
+			}, nil),
+		},
+		{
+			jsonRecord{Line: 200, Language: &unknown},
+			bytes.Join([][]byte{
+				{0x93, 0xa7}, []byte("lang.en"), eventTime,
+				{0x82},
+				{0xa4}, []byte("line"), {0xd3, 0, 0, 0, 0, 0, 0, 0, 0xc8},
+				{0xa8}, []byte("language"), {0xa7}, []byte("unknown"),
+			}, nil),
+		},
+	}
+	s := &fluentSink{}
+	for _, test := range tests {
+		got := s.encode("lang.en", time.Unix(1700000000, 123), test.record)
+		if !bytes.Equal(got, test.want) {
+			t.Errorf("encoded\n% x\nwant\n% x", got, test.want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// gcsClient returns the client for gs:// inputs, created on first use with
+// Application Default Credentials (GOOGLE_APPLICATION_CREDENTIALS, gcloud
+// login, or the service account of the instance).
+var gcsClient = sync.OnceValues(func() (*storage.Client, error) {
+	return storage.NewClient(context.Background())
+})
+
+// gcsStore reads gs://bucket/name objects from Google Cloud Storage.
+type gcsStore struct{}
+
+func (gcsStore) exists(ctx context.Context, bucket, name string) (bool, error) {
+	client, err := gcsClient()
+	if err != nil {
+		return false, err
+	}
+	_, err = client.Bucket(bucket).Object(name).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (gcsStore) list(ctx context.Context, bucket, prefix string, send func(string)) error {
+	client, err := gcsClient()
+	if err != nil {
+		return err
+	}
+	objects := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := objects.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		send(attrs.Name)
+	}
+}
+
+func (gcsStore) open(ctx context.Context, bucket, name string) (io.ReadCloser, int64, error) {
+	client, err := gcsClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	r, err := client.Bucket(bucket).Object(name).NewReader(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return r, r.Attrs.Size, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// manFormats lists the values accepted by the -format of gen-man.
+var manFormats = []string{"man", "markdown"}
+
+// runGenMan implements the gen-man command. It documents the options of
+// detect mode, defined on detect, and the commands.
+func runGenMan(detect *flag.FlagSet, args []string) int {
+	fs := flag.NewFlagSet("gen-man", flag.ExitOnError)
+	format := fs.String("format", "man",
+		"Output format: man (roff for man(1)) or markdown")
+	date := fs.String("date", "",
+		"Date of the man page, YYYY-MM-DD (default: SOURCE_DATE_EPOCH if set, for reproducible builds, else today)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Generate the man page or Markdown documentation of the options.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli gen-man [OPTIONS] > lingua-cli.1\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return 2
+	}
+	if *date == "" {
+		t := time.Now()
+		if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+			t = time.Unix(epoch, 0)
+		}
+		*date = t.UTC().Format(time.DateOnly)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	switch *format {
+	case "man":
+		writeMan(out, detect, *date)
+	case "markdown":
+		writeMarkdown(out, detect)
+	default:
+		fmt.Fprintf(os.Stderr, "error: invalid -format value %q (expected %s)\n", *format, strings.Join(manFormats, ", "))
+		return 2
+	}
+	return 0
+}
+
+// manual holds the prose of the documentation next to the commands and
+// options.
+var manual = struct {
+	name, description, environment string
+}{
+	name: "language classification of texts, lines and files",
+	description: "lingua-cli classifies the language of the texts given as arguments, or else of " +
+		"standard input, as a whole or line by line, using the lingua-go library or another " +
+		"detection backend. It prints the best language and its confidence, or the confidences of " +
+		"all languages. The commands check the languages of documents and localization files, and " +
+		"train, calibrate and evaluate detection; run lingua-cli COMMAND -h for their options.",
+	environment: "All options but -V, -L, -print-schema and -clipboard can also be set in the " +
+		"configuration file (see -config) or by an environment variable named after their long " +
+		"name, such as LINGUA_CLI_LANGUAGES for -l. " +
+		"Options on the command line take precedence over the environment, which takes " +
+		"precedence over the selected -profile and then the rest of the configuration file.",
+}
+
+// roffEscaper escapes text for roff: backslashes, and dashes, which are
+// hyphens otherwise.
+var roffEscaper = strings.NewReplacer(`\`, `\e`, "-", `\-`)
+
+// roff escapes text for roff, also keeping a dot or quote at the start of
+// a line from starting a request.
+func roff(s string) string {
+	lines := strings.Split(roffEscaper.Replace(s), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeMan writes the man page in section 1.
+func writeMan(w io.Writer, detect *flag.FlagSet, date string) {
+	fmt.Fprintf(w, ".TH LINGUA\\-CLI 1 %q \"lingua-cli %s\" \"User Commands\"\n", date, version)
+	fmt.Fprintf(w, ".SH NAME\nlingua\\-cli \\- %s\n", roff(manual.name))
+	fmt.Fprintf(w, ".SH SYNOPSIS\n.B lingua\\-cli\n[\\fIOPTIONS\\fR] [\\fITEXT\\fR]...\n.br\n")
+	fmt.Fprintf(w, ".B lingua\\-cli\n\\fICOMMAND\\fR [\\fIOPTIONS\\fR] [\\fIARGS\\fR]...\n")
+	fmt.Fprintf(w, ".SH DESCRIPTION\n%s\n", roff(manual.description))
+	fmt.Fprintf(w, ".SH COMMANDS\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, ".TP\n.B %s\n%s\n", roff(c.name), roff(c.summary))
+	}
+	fmt.Fprintf(w, ".SH OPTIONS\n")
+	for _, doc := range describeFlags(detect) {
+		names := make([]string, len(doc.names))
+		for i, name := range doc.names {
+			names[i] = `\fB` + roff(name) + `\fR`
+		}
+		fmt.Fprintf(w, ".TP\n%s", strings.Join(names, ", "))
+		if doc.typeName != "" {
+			fmt.Fprintf(w, ` \fI%s\fR`, roff(doc.typeName))
+		}
+		fmt.Fprintf(w, "\n%s", roff(doc.usage))
+		if doc.def != "" {
+			fmt.Fprintf(w, " (default %s)", roff(doc.def))
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, ".SH ENVIRONMENT\n%s\n", roff(manual.environment))
+	fmt.Fprintf(w, ".SH EXIT STATUS\n0 on success, 1 on errors and when a check of one of the checking "+
+		"commands fails, 2 on unknown options and invalid configuration, and 130 when interrupted.\n")
+}
+
+// writeMarkdown writes the documentation in Markdown, for websites and
+// wikis.
+func writeMarkdown(w io.Writer, detect *flag.FlagSet) {
+	fmt.Fprintf(w, "# lingua-cli\n\n%s\n\n", manual.description)
+	fmt.Fprintf(w, "```\nlingua-cli [OPTIONS] [TEXT]...\nlingua-cli COMMAND [OPTIONS] [ARGS]...\n```\n\n")
+	fmt.Fprintf(w, "## Commands\n\n| Command | Description |\n|---------|-------------|\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "| `%s` | %s |\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\n## Options\n\n")
+	for _, doc := range describeFlags(detect) {
+		names := make([]string, len(doc.names))
+		for i, name := range doc.names {
+			names[i] = "`" + name + "`"
+		}
+		fmt.Fprintf(w, "- %s", strings.Join(names, ", "))
+		if doc.typeName != "" {
+			fmt.Fprintf(w, " *%s*", doc.typeName)
+		}
+		fmt.Fprintf(w, ": %s", doc.usage)
+		if doc.def != "" {
+			fmt.Fprintf(w, " (default `%s`)", doc.def)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "\n## Environment\n\n%s\n", manual.environment)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// goldenSuffix is appended to the name of a fixture to name its golden
+// file.
+const goldenSuffix = ".golden.jsonl"
+
+// goldenRecord is a line of a golden file, with the fields of -format
+// json-v1 in line mode.
+type goldenRecord struct {
+	Line     int      `json:"line"`
+	Language string   `json:"language"`
+	Score    *float64 `json:"score,omitempty"`
+	Text     string   `json:"text"`
+}
+
+// classifyFixture classifies the non-empty lines of a fixture, returning
+// the golden file of its results.
+func classifyFixture(detector langid.Detector, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	for i, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		results, err := detector.ConfidenceValues(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, i+1, err)
+		}
+		record := goldenRecord{Line: i + 1, Language: langid.Unknown, Text: line}
+		if len(results) > 0 && results[0].Score > 0 {
+			record.Language, record.Score = results[0].Code, &results[0].Score
+		}
+		if err := enc.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// runGolden implements the golden command. It classifies fixture corpora
+// and compares the results with golden files committed next to them, so
+// that changes of behavior, such as after upgrading lingua-go, fail a test.
+func runGolden(args []string) int {
+	fs := flag.NewFlagSet("golden", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	extensions := fs.String("ext", ".txt",
+		"Comma separated list of the extensions of the fixtures in directories")
+	tolerance := fs.Float64("tolerance", 1e-6,
+		"Largest change of a score that does not fail, for float drift between platforms and releases")
+	update := fs.Bool("update", false,
+		"Write the golden files from the current results instead of comparing with them")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Compare the results of fixture corpora with their golden files.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli golden [OPTIONS] PATH...\n\n")
+		fmt.Fprintf(os.Stderr, "Every non-empty line of a fixture is classified, and the results are compared\n")
+		fmt.Fprintf(os.Stderr, "with the golden file next to it, named after the fixture with %s\n", goldenSuffix)
+		fmt.Fprintf(os.Stderr, "appended and written by -update. Prints ok or FAIL per fixture, with the\n")
+		fmt.Fprintf(os.Stderr, "differing lines as by 'lingua-cli diff'. Exits with status 1 if any fixture\n")
+		fmt.Fprintf(os.Stderr, "has a changed language, a score changed by more than -tolerance, or no golden\n")
+		fmt.Fprintf(os.Stderr, "file.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	exts := splitList(*extensions)
+	var fixtures []string
+	err := walkFiles(fs.Args(), func(path string) {
+		if strings.HasSuffix(path, goldenSuffix) || isURL(path) || isObjectURI(path) {
+			return
+		}
+		if slices.Contains(fs.Args(), path) || slices.Contains(exts, filepath.Ext(path)) {
+			fixtures = append(fixtures, path)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	// Scores changed by exactly the tolerance pass.
+	minShift := math.Nextafter(*tolerance, math.Inf(1))
+	fields := resultFields{lang: []string{"language"}, score: []string{"score"}}
+	failed := 0
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	for _, fixture := range fixtures {
+		current, err := classifyFixture(detector, fixture)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		golden := fixture + goldenSuffix
+		if *update {
+			if err := os.WriteFile(golden, current, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+			fmt.Fprintf(out, "updated\t%s\n", golden)
+			continue
+		}
+		if _, err := os.Stat(golden); err != nil {
+			fmt.Fprintf(out, "FAIL\t%s\tno golden file %s (see -update)\n", fixture, golden)
+			failed++
+			continue
+		}
+		expected, expectedIndex, err := readResults(golden, fields)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		actual, actualIndex, err := parseResults(fixture, bytes.NewReader(current), fields)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		changes := diffResults(expected, expectedIndex, actual, actualIndex, minShift)
+		if len(changes) == 0 {
+			fmt.Fprintf(out, "ok\t%s\n", fixture)
+			continue
+		}
+		failed++
+		fmt.Fprintf(out, "FAIL\t%s\n", fixture)
+		for _, c := range changes {
+			fmt.Fprintf(out, "  %s\n", formatChange(c))
+		}
+	}
+	out.Flush()
+
+	if *update {
+		fmt.Fprintf(os.Stderr, "\n%d golden files written\n", len(fixtures))
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "\n%d fixtures, %d failed\n", len(fixtures), failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// heldResult is a complete result, all of its output lines, held back by a
+// resultBuffer.
+type heldResult struct {
+	lang  string
+	score float64
+	line  int
+	data  []byte
+}
+
+// resultBuffer holds back the results of a run while they are printed, so
+// that they can be written in another order at the end of the run.
+type resultBuffer struct {
+	buf     bytes.Buffer
+	current heldResult
+	// started is set once the first line of the current result is printed,
+	// which gives its language, score and line.
+	started bool
+	results []heldResult
+	// unknown is the label of results without a language.
+	unknown string
+	// decisions is set for the yes and no of -is, which are no languages.
+	decisions bool
+}
+
+// hold makes p print into a new buffer instead of its writer, which is
+// returned.
+func (p *printer) hold() *outputWriter {
+	w := p.w
+	p.held = &resultBuffer{unknown: p.labels.label(langid.Unknown)}
+	p.w = &outputWriter{Writer: bufio.NewWriter(&p.held.buf)}
+	p.flush = false
+	return w
+}
+
+// start records the language, score and line of the current result from
+// its first line.
+func (b *resultBuffer) start(lang string, score float64, line int) {
+	if b.started {
+		return
+	}
+	b.current = heldResult{lang: lang, score: score, line: line}
+	b.started = true
+}
+
+// end completes the current result with the output printed since the last
+// one.
+func (b *resultBuffer) end(w *outputWriter) {
+	w.Flush()
+	if b.buf.Len() == 0 {
+		// A result that printed nothing, such as one skipped with a
+		// warning.
+		b.started = false
+		return
+	}
+	b.current.data = bytes.Clone(b.buf.Bytes())
+	if b.current.lang == "" {
+		b.current.lang = b.unknown
+	}
+	b.results = append(b.results, b.current)
+	b.buf.Reset()
+	b.started = false
+}
+
+// sortKeys are the keys of -sort.
+var sortKeys = []string{"confidence", "language", "line"}
+
+// resultOrder is the order of -sort: by key, ascending or descending.
+type resultOrder struct {
+	key        string
+	descending bool
+}
+
+// parseResultOrder parses the value of -sort, a key optionally followed by
+// :asc or :desc.
+func parseResultOrder(value string) (resultOrder, error) {
+	key, direction, _ := strings.Cut(value, ":")
+	order := resultOrder{key: key}
+	switch direction {
+	case "", "asc":
+	case "desc":
+		order.descending = true
+	default:
+		return order, fmt.Errorf("invalid -sort direction %q (expected asc or desc)", direction)
+	}
+	if !slices.Contains(sortKeys, key) {
+		return order, fmt.Errorf("invalid -sort key %q (expected %s)", key, strings.Join(sortKeys, ", "))
+	}
+	return order, nil
+}
+
+// sort sorts the held results stably in order.
+func (b *resultBuffer) sort(order resultOrder) {
+	sort.SliceStable(b.results, func(i, j int) bool {
+		x, y := b.results[i], b.results[j]
+		if order.descending {
+			x, y = y, x
+		}
+		switch order.key {
+		case "confidence":
+			return x.score < y.score
+		case "language":
+			return x.lang < y.lang
+		}
+		return x.line < y.line
+	})
+}
+
+// distribution returns a copy of the distribution of a result shown with
+// -a, sorted in order. Distributions are sorted by descending score unless
+// -sort sorts them by language or ascending score.
+func (o resultOrder) distribution(results []langid.Confidence) []langid.Confidence {
+	if o.key != "confidence" && o.key != "language" || o.key == "confidence" && o.descending {
+		return results
+	}
+	results = slices.Clone(results)
+	sort.SliceStable(results, func(i, j int) bool {
+		x, y := results[i], results[j]
+		if o.descending {
+			x, y = y, x
+		}
+		if o.key == "language" {
+			return x.Code < y.Code
+		}
+		return x.Score < y.Score
+	})
+	return results
+}
+
+// print writes the held results to w in the order they were sorted in.
+func (b *resultBuffer) print(w *outputWriter) {
+	for _, r := range b.results {
+		w.Write(r.data)
+	}
+}
+
+// groups returns the held results by language, and the languages with the
+// most results first and unknown last.
+func (b *resultBuffer) groups() (map[string][]heldResult, []string) {
+	groups := make(map[string][]heldResult)
+	var languages []string
+	for _, r := range b.results {
+		if groups[r.lang] == nil {
+			languages = append(languages, r.lang)
+		}
+		groups[r.lang] = append(groups[r.lang], r)
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		x, y := languages[i], languages[j]
+		if (x == b.unknown) != (y == b.unknown) {
+			return y == b.unknown
+		}
+		if len(groups[x]) != len(groups[y]) {
+			return len(groups[x]) > len(groups[y])
+		}
+		return x < y
+	})
+	return groups, languages
+}
+
+// printGroups writes the held results to w grouped by language for
+// -group-by-lang, in the order they were sorted in within each group: under
+// a Markdown heading naming the language and counting
+// its results, or with asJSON as a JSON object mapping each language to the
+// array of its json-v1 records.
+func (b *resultBuffer) printGroups(w *outputWriter, asJSON bool) {
+	groups, languages := b.groups()
+	if asJSON {
+		w.WriteString("{")
+		for i, lang := range languages {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			key, _ := json.Marshal(lang)
+			w.WriteString("\n")
+			w.Write(key)
+			w.WriteString(": [")
+			for j, r := range groups[lang] {
+				if j > 0 {
+					w.WriteString(",")
+				}
+				w.WriteString("\n  ")
+				w.Write(bytes.TrimSuffix(r.data, []byte("\n")))
+			}
+			w.WriteString("\n]")
+		}
+		w.WriteString("\n}\n")
+		return
+	}
+	for i, lang := range languages {
+		if i > 0 {
+			w.WriteByte('\n')
+		}
+		heading := lang
+		if name := languageName(lang); name != lang && !b.decisions {
+			heading += " " + name
+		}
+		w.WriteString("## " + heading + " (" + strconv.Itoa(len(groups[lang])) + ")\n")
+		for _, r := range groups[lang] {
+			w.Write(r.data)
+		}
+	}
+}
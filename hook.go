@@ -0,0 +1,213 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// hookScissors is the line below which git drops the rest of a commit
+// message, as with commit --verbose.
+const hookScissors = "# ------------------------ >8 ------------------------"
+
+// hookTrailer matches the trailer lines of commit messages, such as
+// "Signed-off-by: ...", which are no prose.
+var hookTrailer = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*: \S`)
+
+// commitMessageText returns the part of a commit message to check: without
+// comments, the part below the scissors line and trailers, and only the
+// first line for scope "subject". skip reports messages git writes itself,
+// merges, reverts, and fixup and squash commits.
+func commitMessageText(message, scope string) (text string, skip bool) {
+	message, _, _ = strings.Cut(message, hookScissors)
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.TrimRight(line, " \r"))
+		}
+	}
+	for len(lines) > 0 && (lines[len(lines)-1] == "" || hookTrailer.MatchString(lines[len(lines)-1])) {
+		lines = lines[:len(lines)-1]
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return "", true
+	}
+	for _, prefix := range []string{"Merge ", "Revert \"", "fixup!", "squash!", "amend!"} {
+		if strings.HasPrefix(lines[0], prefix) {
+			return "", true
+		}
+	}
+	if scope == "subject" {
+		return lines[0], false
+	}
+	return strings.Join(lines, "\n"), false
+}
+
+// stagedFiles returns the paths of the files added, copied, modified or
+// renamed in the index of the git repository in the working directory.
+func stagedFiles() ([]string, error) {
+	out, err := exec.Command("git", "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff --cached: %w", err)
+	}
+	var paths []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// runHook implements the hook command, run as the commit-msg or pre-commit
+// hook of git to check that commit messages, or the documentation files of
+// a commit, are written in the expected languages.
+func runHook(args []string) int {
+	fs := flag.NewFlagSet("hook", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	expect := fs.String("expect", "en",
+		"Comma separated list of the languages allowed")
+	threshold := fs.Float64("c", 0.5,
+		"Confidence threshold, texts scoring below it are 'unknown' rather than in a wrong language (0.0-1.0)")
+	scope := fs.String("scope", "message",
+		"Part of commit messages to check: the whole message without comments and trailers, or its subject line")
+	include := fs.String("include", "*.md,*.markdown,*.rst,*.txt,*.adoc",
+		"With pre-commit, comma separated list of patterns of the staged files to check, matched against their names")
+	minLength := fs.Int("min-length", 20,
+		"Minimum length in characters of a text to check it")
+	unknown := fs.String("unknown", "pass",
+		"Whether texts of unknown language pass or fail")
+	exitCode := fs.Int("exit-code", 1,
+		"Exit status when a text is in a wrong language; 0 only warns")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Check that commit messages or documentation files are in the expected language,\n")
+		fmt.Fprintf(os.Stderr, "as a git hook.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli hook [OPTIONS] commit-msg FILE\n")
+		fmt.Fprintf(os.Stderr, "       lingua-cli hook [OPTIONS] pre-commit\n\n")
+		fmt.Fprintf(os.Stderr, "commit-msg checks the message in FILE, as git passes it to the commit-msg hook;\n")
+		fmt.Fprintf(os.Stderr, "merges, reverts and fixup and squash commits are skipped. pre-commit checks the\n")
+		fmt.Fprintf(os.Stderr, "staged content of the files matching -include, Markdown without its markup.\n")
+		fmt.Fprintf(os.Stderr, "Texts in a wrong language are reported on stderr, and the command exits with\n")
+		fmt.Fprintf(os.Stderr, "status -exit-code.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	mode := fs.Arg(0)
+	if !(mode == "commit-msg" && fs.NArg() == 2 || mode == "pre-commit" && fs.NArg() == 1) {
+		fs.Usage()
+		return 2
+	}
+	var allowed []string
+	for _, code := range splitList(*expect) {
+		lang := declaredLanguage(code)
+		if lang == "" {
+			fmt.Fprintf(os.Stderr, "error: invalid -expect language %q\n", code)
+			return 2
+		}
+		allowed = append(allowed, lang)
+	}
+	if len(allowed) == 0 {
+		fmt.Fprintf(os.Stderr, "error: -expect names no language\n")
+		return 2
+	}
+	if *scope != "message" && *scope != "subject" {
+		fmt.Fprintf(os.Stderr, "error: invalid -scope value %q (expected message, subject)\n", *scope)
+		return 2
+	}
+	if *unknown != "pass" && *unknown != "fail" {
+		fmt.Fprintf(os.Stderr, "error: invalid -unknown value %q (expected pass, fail)\n", *unknown)
+		return 2
+	}
+
+	// Collect the texts before building the detector, which takes a while,
+	// so that commits without anything to check are not held up.
+	type hookText struct{ name, text string }
+	var texts []hookText
+	if mode == "commit-msg" {
+		data, err := os.ReadFile(fs.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		if text, skip := commitMessageText(string(data), *scope); !skip {
+			texts = append(texts, hookText{"commit message", text})
+		}
+	} else {
+		paths, err := stagedFiles()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		patterns := splitList(*include)
+		for _, path := range paths {
+			if !slices.ContainsFunc(patterns, func(pattern string) bool {
+				ok, _ := filepath.Match(pattern, filepath.Base(path))
+				return ok
+			}) {
+				continue
+			}
+			// The staged content is what gets committed, not the working tree.
+			data, err := exec.Command("git", "show", ":"+path).Output()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+				continue
+			}
+			text := string(data)
+			if ext := strings.ToLower(filepath.Ext(path)); ext == ".md" || ext == ".markdown" {
+				_, _, content := splitFrontMatter(text)
+				text = markdownText(content)
+			}
+			texts = append(texts, hookText{path, text})
+		}
+	}
+	texts = slices.DeleteFunc(texts, func(t hookText) bool {
+		return utf8.RuneCountInString(strings.Join(strings.Fields(t.text), " ")) < *minLength
+	})
+	if len(texts) == 0 {
+		return 0
+	}
+
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	failed := false
+	for _, t := range texts {
+		results, err := detector.ConfidenceValues(strings.Join(strings.Fields(t.text), " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s: %v\n", t.name, err)
+			return 1
+		}
+		switch {
+		case len(results) == 0 || results[0].Score <= 0 || results[0].Score < *threshold:
+			if *unknown == "fail" {
+				fmt.Fprintf(os.Stderr, "%s: language unknown, expected %s\n", t.name, strings.Join(allowed, " or "))
+				failed = true
+			}
+		case !slices.Contains(allowed, results[0].Code):
+			fmt.Fprintf(os.Stderr, "%s: written in %s (%s), expected %s\n", t.name, results[0].Code, formatScore(results[0].Score), strings.Join(allowed, " or "))
+			failed = true
+		}
+	}
+	if failed {
+		return *exitCode
+	}
+	return 0
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+var (
+	htmlStartTag  = regexp.MustCompile(`(?s)<([a-zA-Z][a-zA-Z0-9-]*)(\s[^>]*)?>`)
+	htmlAttribute = regexp.MustCompile(`([a-zA-Z_:][a-zA-Z0-9_:.-]*)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+)
+
+// htmlAttributes returns the attributes of a start tag by lowercase name.
+func htmlAttributes(tag string) map[string]string {
+	attributes := make(map[string]string)
+	for _, m := range htmlAttribute.FindAllStringSubmatch(tag, -1) {
+		value := strings.Trim(m[2], `"'`)
+		attributes[strings.ToLower(m[1])] = strings.TrimSpace(html.UnescapeString(value))
+	}
+	return attributes
+}
+
+// langCheck is the comparison of a declared language with the detected one.
+type langCheck struct {
+	// status is "ok", "mismatch", "unknown" if the language of the text is
+	// not known for certain, or "missing" for pages declaring none.
+	status string
+	page   string
+	// element names the element and attribute declaring the language, such
+	// as "html lang" or "link hreflang".
+	element  string
+	declared string
+	results  []langid.Confidence
+	// context is the linked URL of hreflang checks and the start of the text
+	// of other elements.
+	context string
+	// line is the line of the text in its file, or 0 for whole files.
+	line int
+}
+
+// langChecker compares declared languages with detected ones.
+type langChecker struct {
+	detector  langid.Detector
+	threshold float64
+}
+
+// check compares the language declared by tag with the language of text.
+// Texts scoring below the threshold are unknown rather than mismatches.
+func (c *langChecker) check(page, element, tag, text string) langCheck {
+	check := langCheck{page: page, element: element, declared: tag}
+	results, err := c.detector.ConfidenceValues(strings.Join(strings.Fields(text), " "))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %s: %v\n", page, err)
+	}
+	check.results, check.status = results, c.status(tag, results)
+	return check
+}
+
+// status compares the language declared by tag with detection results.
+func (c *langChecker) status(tag string, results []langid.Confidence) string {
+	switch {
+	case len(results) == 0 || results[0].Score <= 0 || results[0].Score < c.threshold:
+		return "unknown"
+	case results[0].Code == declaredLanguage(tag):
+		return "ok"
+	}
+	return "mismatch"
+}
+
+// excerpt returns the start of a text as a single line.
+func excerpt(text string, runes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= runes {
+		return text
+	}
+	return string([]rune(text)[:runes]) + "…"
+}
+
+// htmlLangPage holds the checks of one page.
+type htmlLangPage struct {
+	checks []langCheck
+	err    error
+}
+
+// runHTMLLang implements the htmllang subcommand. It compares the languages
+// HTML pages declare in lang attributes, and those declared for linked pages
+// by hreflang, with the languages of their visible text, and reports the
+// mismatches.
+func runHTMLLang(args []string) int {
+	fs := flag.NewFlagSet("htmllang", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0.5,
+		"Confidence threshold, texts scoring below it are reported as 'unknown' rather than mismatches (0.0-1.0)")
+	workers := fs.Int("j", 8,
+		"Number of pages to read and check in parallel")
+	minLength := fs.Int("min-length", 40,
+		"Minimum length in characters of the text of elements with their own lang attribute below <html> to check them")
+	hreflang := fs.Bool("hreflang", true,
+		"Fetch the pages linked with hreflang and check their language too")
+	list := fs.Bool("list", false,
+		"The arguments are files with one URL per line or sitemaps, as for the urls command")
+	all := fs.Bool("all", false,
+		"Report every check, not only mismatches and pages without a lang attribute")
+	timeout := fs.Duration("http-timeout", 30*time.Second,
+		"Time limit for fetching a page, including its content")
+	retries := fs.Int("http-retries", 2,
+		"How often to retry fetching a page after a network error, HTTP 429 or 5xx")
+	encoding := fs.String("encoding", "auto",
+		"Character encoding of pages that do not declare one; 'auto' detects it")
+	junit := fs.String("junit", "",
+		"Write a JUnit XML report to this file, with a test case per check, for CI systems")
+	sarif := fs.String("sarif", "",
+		"Write the findings as a SARIF 2.1.0 log to this file, for code scanning tools")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Check the lang and hreflang attributes of HTML pages against their text.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli htmllang [OPTIONS] FILE|DIR|URL...\n\n")
+		fmt.Fprintf(os.Stderr, "Prints status (mismatch, missing, and with -all ok and unknown), page, element,\n")
+		fmt.Fprintf(os.Stderr, "declared language, detected language, score and context (the linked URL, or the\n")
+		fmt.Fprintf(os.Stderr, "start of the element's text) per check, then a summary on stderr. Exits with\n")
+		fmt.Fprintf(os.Stderr, "status 1 if any page has a mismatch or lacks a lang attribute.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *retries < 0 {
+		fmt.Fprintf(os.Stderr, "error: -http-retries must not be negative\n")
+		return 2
+	}
+	fetcher.client.Timeout = *timeout
+	fetcher.retries = *retries
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+	checker := &langChecker{detector: detector, threshold: *threshold}
+	report := newJUnitReport(*junit, "mismatch", "missing")
+	findings := newSARIFReport(*sarif)
+
+	// linked holds the results of pages linked by hreflang, which are often
+	// linked from many pages, by URL or path.
+	var mu sync.Mutex
+	linked := make(map[string][]langid.Confidence)
+	classifyLinked := func(target string) ([]langid.Confidence, error) {
+		mu.Lock()
+		results, ok := linked[target]
+		mu.Unlock()
+		if ok {
+			return results, nil
+		}
+		source, isHTML, err := readHTML(target, *encoding)
+		if err != nil {
+			return nil, err
+		}
+		if !isHTML {
+			return nil, errors.New("not an HTML page")
+		}
+		results, err = detector.ConfidenceValues(strings.Join(strings.Fields(htmlText(source)), " "))
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		linked[target] = results
+		mu.Unlock()
+		return results, nil
+	}
+
+	produce := func(send func(string)) error {
+		if *list {
+			for _, arg := range fs.Args() {
+				if err := readURLList(arg, send, 0); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return walkFiles(fs.Args(), send)
+	}
+	work := func(page string) htmlLangPage {
+		source, isHTML, err := readHTML(page, *encoding)
+		if err != nil || !isHTML {
+			return htmlLangPage{err: err}
+		}
+		checks := checkHTMLLang(checker, page, source, *minLength)
+		if !*hreflang {
+			return htmlLangPage{checks: checks}
+		}
+		for _, link := range hreflangLinks(page, source) {
+			check := langCheck{page: page, element: link.element, declared: link.lang, context: link.target}
+			results, err := classifyLinked(link.target)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %s: %v\n", link.target, err)
+				continue
+			}
+			check.results, check.status = results, checker.status(link.lang, results)
+			checks = append(checks, check)
+		}
+		return htmlLangPage{checks: checks}
+	}
+
+	pages := 0
+	statuses := make(map[string]int)
+	out := bufio.NewWriter(os.Stdout)
+	emit := func(page htmlLangPage) {
+		if page.err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", page.err)
+			return
+		}
+		if page.checks == nil {
+			return
+		}
+		pages++
+		for _, check := range page.checks {
+			statuses[check.status]++
+			report.add(check.page, check)
+			findings.add(check.page, check)
+			if !*all && check.status != "mismatch" && check.status != "missing" {
+				continue
+			}
+			detected, score := "-", "-"
+			if len(check.results) > 0 && check.results[0].Score > 0 {
+				detected, score = check.results[0].Code, formatScore(check.results[0].Score)
+			}
+			declared, context := check.declared, check.context
+			if declared == "" {
+				declared = "-"
+			}
+			if context == "" {
+				context = "-"
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", check.status, check.page, check.element, declared, detected, score, context)
+		}
+	}
+	err = parallelMap(*workers, true, produce, work, emit)
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	checks := 0
+	for _, n := range statuses {
+		checks += n
+	}
+	if err := report.write(*junit, "htmllang"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := findings.write(*sarif); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\n%d pages, %d checks\n", pages, checks)
+	fmt.Fprintf(os.Stderr, "status: %s\n", formatCounts(statuses, checks))
+	if statuses["mismatch"] > 0 || statuses["missing"] > 0 {
+		return 1
+	}
+	return 0
+}
+
+// readHTML returns the source of the HTML file or page at path, decoded,
+// and whether it is HTML at all.
+func readHTML(path, encoding string) (string, bool, error) {
+	f, _, err := openFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", path, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", path, err)
+	}
+	if kind, _, err := sniffFile(path, data[:min(len(data), sniffSize)]); err != nil || kind != "html" {
+		return "", false, nil
+	}
+	source, err := extractPlain(data, encoding)
+	if err != nil {
+		return "", false, fmt.Errorf("%s: %w", path, err)
+	}
+	return source, true, nil
+}
+
+// checkHTMLLang checks the language of a page, as declared by the lang or
+// xml:lang attribute of <html> or else a Content-Language <meta> element,
+// and of the elements below <html> with a lang attribute of their own whose
+// text is at least minLength characters long.
+func checkHTMLLang(checker *langChecker, page, source string, minLength int) []langCheck {
+	source = htmlSkipped.ReplaceAllString(source, " ")
+	var checks []langCheck
+	pageElement, pageLang := "", ""
+	for _, m := range htmlStartTag.FindAllStringSubmatchIndex(source, -1) {
+		name := strings.ToLower(source[m[2]:m[3]])
+		attributes := htmlAttributes(source[m[0]:m[1]])
+		lang, attribute := attributes["lang"], "lang"
+		if lang == "" && attributes["xml:lang"] != "" {
+			lang, attribute = attributes["xml:lang"], "xml:lang"
+		}
+		switch {
+		case name == "html":
+			if lang != "" {
+				pageElement, pageLang = "html "+attribute, lang
+			}
+		case name == "meta" && strings.EqualFold(attributes["http-equiv"], "content-language"):
+			if pageLang == "" {
+				// Content-Language may list several languages.
+				first, _, _ := strings.Cut(attributes["content"], ",")
+				pageElement, pageLang = "meta content-language", strings.TrimSpace(first)
+			}
+		case lang != "":
+			inner, ok := elementContent(source, name, m[1])
+			if !ok {
+				continue
+			}
+			text := htmlText(inner)
+			if utf8.RuneCountInString(text) < minLength {
+				continue
+			}
+			check := checker.check(page, name+" "+attribute, lang, text)
+			check.context = excerpt(text, 40)
+			checks = append(checks, check)
+		}
+	}
+	if pageLang == "" {
+		check := langCheck{status: "missing", page: page, element: "html lang"}
+		return append([]langCheck{check}, checks...)
+	}
+	check := checker.check(page, pageElement, pageLang, htmlText(source))
+	return append([]langCheck{check}, checks...)
+}
+
+// elementContent returns the content of the element with the given name
+// whose start tag ends at start, up to its matching end tag. ok is false
+// if there is no end tag.
+func elementContent(source, name string, start int) (string, bool) {
+	tags := regexp.MustCompile(`(?i)<(/?)` + regexp.QuoteMeta(name) + `\b[^>]*>`)
+	depth := 1
+	for _, m := range tags.FindAllStringSubmatchIndex(source[start:], -1) {
+		if m[3] > m[2] {
+			depth--
+		} else if !strings.HasSuffix(source[start+m[0]:start+m[1]], "/>") {
+			depth++
+		}
+		if depth == 0 {
+			return source[start : start+m[0]], true
+		}
+	}
+	return "", false
+}
+
+// hreflangLink is a link to a page in a declared language.
+type hreflangLink struct {
+	element string
+	lang    string
+	// target is the URL or path of the linked page.
+	target string
+}
+
+// hreflangLinks returns the <link> and <a> elements of a page with an
+// hreflang attribute, other than x-default, with their targets resolved
+// against the page. Links from files to other sites, and root-relative
+// links from files, are left out.
+func hreflangLinks(page, source string) []hreflangLink {
+	source = htmlSkipped.ReplaceAllString(source, " ")
+	var links []hreflangLink
+	seen := make(map[hreflangLink]bool)
+	for _, m := range htmlStartTag.FindAllStringSubmatch(source, -1) {
+		name := strings.ToLower(m[1])
+		if name != "link" && name != "a" {
+			continue
+		}
+		attributes := htmlAttributes(m[0])
+		lang, href := attributes["hreflang"], attributes["href"]
+		if lang == "" || href == "" || strings.EqualFold(lang, "x-default") {
+			continue
+		}
+		target, ok := resolveLink(page, href)
+		if !ok {
+			continue
+		}
+		link := hreflangLink{element: name + " hreflang", lang: lang, target: target}
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// resolveLink resolves href against the page it appears on, a URL or a
+// file path.
+func resolveLink(page, href string) (string, bool) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	ref.Fragment = ""
+	if isURL(page) {
+		base, err := url.Parse(page)
+		if err != nil {
+			return "", false
+		}
+		resolved := base.ResolveReference(ref)
+		if resolved.Scheme != "http" && resolved.Scheme != "https" {
+			return "", false
+		}
+		return resolved.String(), true
+	}
+	if ref.IsAbs() || ref.Host != "" || strings.HasPrefix(ref.Path, "/") || ref.Path == "" {
+		return "", false
+	}
+	target := filepath.Join(filepath.Dir(page), filepath.FromSlash(ref.Path))
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		target = filepath.Join(target, "index.html")
+	}
+	return target, true
+}
@@ -0,0 +1,439 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// resourceValue is a localized string of a resource file.
+type resourceValue struct {
+	// key is the path of the value, with the keys of nested objects joined
+	// by dots and list indexes in brackets, such as "cart.items[0]".
+	key  string
+	line int
+	text string
+}
+
+// resourceReaders read the values of localization resource files by
+// extension.
+var resourceReaders = map[string]func([]byte) ([]resourceValue, error){
+	".json":        readJSONResource,
+	".yaml":        readYAMLResource,
+	".yml":         readYAMLResource,
+	".xml":         readAndroidResource,
+	".strings":     readAppleStrings,
+	".stringsdict": readStringsDict,
+}
+
+// resourceExtensions returns the extensions of the files the i18nlang
+// command reads, sorted.
+func resourceExtensions() []string {
+	var extensions []string
+	for ext := range resourceReaders {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+func joinResourceKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// readJSONResource returns the string values of a JSON file of nested
+// objects, in the order of the file.
+func readJSONResource(data []byte) ([]resourceValue, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var values []resourceValue
+	// line is the line of offset, counted incrementally.
+	line, offset := 1, 0
+	lineAt := func(end int) int {
+		line += bytes.Count(data[offset:end], []byte("\n"))
+		offset = end
+		return line
+	}
+	var walk func(prefix string) error
+	walk = func(prefix string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case json.Delim:
+			for i := 0; decoder.More(); i++ {
+				key := fmt.Sprintf("%s[%d]", prefix, i)
+				if t == '{' {
+					name, err := decoder.Token()
+					if err != nil {
+						return err
+					}
+					key = joinResourceKey(prefix, fmt.Sprint(name))
+				}
+				if err := walk(key); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token()
+			return err
+		case string:
+			values = append(values, resourceValue{key: prefix, line: lineAt(int(decoder.InputOffset())), text: t})
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// yamlKey matches the key of a mapping entry, plain or quoted.
+var yamlKey = regexp.MustCompile(`^("(?:[^"\\]|\\.)*"|'(?:[^']|'')*'|[^\s#'"][^:#]*?)\s*:(?:\s+|$)`)
+
+// readYAMLResource returns the string values of a YAML file of nested
+// mappings, the subset of YAML localization files use: plain, quoted and
+// block scalars, and lists. Anchors, aliases and flow collections are
+// skipped.
+func readYAMLResource(data []byte) ([]resourceValue, error) {
+	type level struct {
+		indent int
+		key    string
+		// items counts the items of a list.
+		items int
+	}
+	stack := []level{{indent: -1}}
+	var values []resourceValue
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		raw := lines[i]
+		content := strings.TrimSpace(raw)
+		if content == "" || strings.HasPrefix(content, "#") || content == "---" || content == "..." {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		item, isItem := strings.CutPrefix(content, "-")
+		isItem = isItem && (item == "" || item[0] == ' ')
+		// The items of a list may be indented like its key.
+		for len(stack) > 1 && (stack[len(stack)-1].indent > indent || stack[len(stack)-1].indent == indent && !isItem) {
+			stack = stack[:len(stack)-1]
+		}
+		parent := &stack[len(stack)-1]
+
+		var key, value string
+		if isItem {
+			key = fmt.Sprintf("%s[%d]", parent.key, parent.items)
+			parent.items++
+			value = strings.TrimSpace(item)
+			if m := yamlKey.FindStringSubmatch(value); m != nil {
+				// A mapping in a list item; its keys are indented like the
+				// first one.
+				indent += len(content) - len(value)
+				stack = append(stack, level{indent: indent - 1, key: key})
+				key, value = joinResourceKey(key, unquoteYAML(m[1])), strings.TrimSpace(value[len(m[0]):])
+			}
+		} else {
+			m := yamlKey.FindStringSubmatch(content)
+			if m == nil {
+				return nil, fmt.Errorf("line %d: expected a key", i+1)
+			}
+			key, value = joinResourceKey(parent.key, unquoteYAML(m[1])), strings.TrimSpace(content[len(m[0]):])
+		}
+
+		if strings.HasPrefix(value, "&") {
+			// An anchor names the value.
+			_, value, _ = strings.Cut(value, " ")
+			value = strings.TrimSpace(value)
+		}
+		switch {
+		case value == "" || strings.HasPrefix(value, "#"):
+			stack = append(stack, level{indent: indent, key: key})
+		case value[0] == '|' || value[0] == '>':
+			// A block scalar: the following lines indented further.
+			start := i + 1
+			var block []string
+			blockIndent := -1
+			for i+1 < len(lines) {
+				next := lines[i+1]
+				trimmed := strings.TrimLeft(next, " ")
+				if trimmed != "" {
+					if len(next)-len(trimmed) <= indent {
+						break
+					}
+					if blockIndent < 0 {
+						blockIndent = len(next) - len(trimmed)
+					}
+					next = next[min(blockIndent, len(next)-len(trimmed)):]
+				}
+				block = append(block, next)
+				i++
+			}
+			separator := "\n"
+			if value[0] == '>' {
+				separator = " "
+			}
+			values = append(values, resourceValue{key: key, line: start, text: strings.TrimSpace(strings.Join(block, separator))})
+		case value[0] == '"' || value[0] == '\'':
+			values = append(values, resourceValue{key: key, line: i + 1, text: unquoteYAML(strings.TrimSpace(stripComment(value)))})
+		case value[0] == '*' || value[0] == '[' || value[0] == '{':
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+			values = append(values, resourceValue{key: key, line: i + 1, text: value})
+		}
+	}
+	return values, nil
+}
+
+// unquoteYAML returns the value of a quoted YAML scalar, or s if it is not
+// quoted.
+func unquoteYAML(s string) string {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		if value, err := strconv.Unquote(s); err == nil {
+			return value
+		}
+		return s[1 : len(s)-1]
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'")
+	}
+	return s
+}
+
+// localeName matches directory and file name parts naming a locale: an ISO
+// 639-1 code, possibly followed by a region or script ("de", "pt_BR",
+// "zh-Hant").
+var localeName = regexp.MustCompile(`^[a-zA-Z]{2}([-_][a-zA-Z0-9]{2,8})*$`)
+
+// resourceLocale returns the locale of a resource file named after it
+// ("de.json", "messages.de.yml") or in a directory named after it
+// ("locales/de/common.json", "values-de/strings.xml", "de.lproj"), or "" if
+// there is none.
+func resourceLocale(path string) string {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), ".")
+	candidates := []string{parts[len(parts)-1]}
+	dir := filepath.Dir(path)
+	for i := 0; i < 2 && dir != "." && dir != string(filepath.Separator); i++ {
+		name := filepath.Base(dir)
+		if locale := platformLocale(name); locale != "" {
+			name = locale
+		}
+		candidates = append(candidates, name)
+		dir = filepath.Dir(dir)
+	}
+	for _, name := range candidates {
+		if localeName.MatchString(name) && declaredLanguage(name) != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// rootLocale returns the locale all values are nested under, as in Ruby on
+// Rails files ("de:" at the top), and the values without it.
+func rootLocale(values []resourceValue) (string, []resourceValue) {
+	if len(values) == 0 {
+		return "", values
+	}
+	root, _, _ := strings.Cut(values[0].key, ".")
+	if !localeName.MatchString(root) || declaredLanguage(root) == "" {
+		return "", values
+	}
+	stripped := make([]resourceValue, len(values))
+	for i, value := range values {
+		key, ok := strings.CutPrefix(value.key, root+".")
+		if !ok {
+			return "", values
+		}
+		stripped[i] = value
+		stripped[i].key = key
+	}
+	return root, stripped
+}
+
+// runI18NLang implements the i18nlang command. It checks that the values of
+// localization resource files are in the language of their locale.
+func runI18NLang(args []string) int {
+	fs := flag.NewFlagSet("i18nlang", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0.3,
+		"Confidence threshold, values scoring below it are reported as 'unknown' rather than mismatches (0.0-1.0); short values score lower than texts")
+	lang := fs.String("lang", "",
+		"Language of all files (default: the locale of each file, from its name, its directory or its root key)")
+	sourceLang := fs.String("source-lang", "en",
+		"Language of the default resources of Android (values/) and Apple (en.lproj or Base.lproj) apps, which translations must not be copies of")
+	minLength := fs.Int("min-length", 20,
+		"Minimum length in characters of a value, without placeholders, to check it")
+	all := fs.Bool("all", false,
+		"Report every check, not only mismatches and copies")
+	junit := fs.String("junit", "",
+		"Write a JUnit XML report to this file, with a test case per check, for CI systems")
+	sarif := fs.String("sarif", "",
+		"Write the findings as a SARIF 2.1.0 log to this file, for code scanning tools")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Check that the values of localization resource files are in their locale's language.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli i18nlang [OPTIONS] PATH...\n\n")
+		fmt.Fprintf(os.Stderr, "Directories are searched recursively for files with the extensions:\n")
+		fmt.Fprintf(os.Stderr, "%s\n\n", strings.Join(resourceExtensions(), " "))
+		fmt.Fprintf(os.Stderr, "Prints status (mismatch, copied, and with -all ok, unknown and short), file:line,\n")
+		fmt.Fprintf(os.Stderr, "key, expected language, detected language, score and value per check, then a\n")
+		fmt.Fprintf(os.Stderr, "summary on stderr. Exits with status 1 if any value is in another language or,\n")
+		fmt.Fprintf(os.Stderr, "in Android and Apple apps, a copy of the default resource.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	for _, option := range []struct{ name, value string }{{"-lang", *lang}, {"-source-lang", *sourceLang}} {
+		if option.value != "" && declaredLanguage(option.value) == "" {
+			fmt.Fprintf(os.Stderr, "error: invalid %s %q\n", option.name, option.value)
+			return 2
+		}
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+	checker := &langChecker{detector: detector, threshold: *threshold}
+	report := newJUnitReport(*junit, "mismatch", "copied")
+	findings := newSARIFReport(*sarif)
+
+	// sources holds the values of the default resources by path and key.
+	sources := make(map[string]map[string]string)
+	sourceValues := func(path string) map[string]string {
+		if values, ok := sources[path]; ok {
+			return values
+		}
+		sources[path] = nil
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			return nil
+		}
+		values, err := resourceReaders[strings.ToLower(filepath.Ext(path))](data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+			return nil
+		}
+		byKey := make(map[string]string)
+		for _, value := range values {
+			byKey[value.key] = value.text
+		}
+		sources[path] = byKey
+		return byKey
+	}
+
+	checks, files, skipped := 0, 0, 0
+	statuses := make(map[string]int)
+	out := bufio.NewWriter(os.Stdout)
+	err = walkFiles(fs.Args(), func(path string) {
+		read := resourceReaders[strings.ToLower(filepath.Ext(path))]
+		if read == nil {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			return
+		}
+		values, err := read(data)
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			err = fmt.Errorf("offset %d: %v", syntaxErr.Offset, err)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+			return
+		}
+		root, stripped := rootLocale(values)
+		locale := *lang
+		switch {
+		case locale != "":
+		case root != "":
+			locale, values = root, stripped
+		default:
+			locale = resourceLocale(path)
+		}
+		if locale == "" {
+			skipped++
+			return
+		}
+		files++
+		var source map[string]string
+		if declaredLanguage(locale) != declaredLanguage(*sourceLang) {
+			if sourcePath := platformSource(path, *sourceLang); sourcePath != "" {
+				source = sourceValues(sourcePath)
+			}
+		}
+		for _, value := range values {
+			where := fmt.Sprintf("%s:%d", path, value.line)
+			check := langCheck{status: "short", page: where, element: value.key, declared: locale}
+			text := poText(value.text)
+			switch {
+			case utf8.RuneCountInString(text) < *minLength:
+			case source != nil && source[value.key] == value.text:
+				check.status = "copied"
+			default:
+				check = checker.check(where, value.key, locale, text)
+			}
+			checks++
+			statuses[check.status]++
+			check.line = value.line
+			report.add(path, check)
+			findings.add(path, check)
+			if !*all && check.status != "mismatch" && check.status != "copied" {
+				continue
+			}
+			detected, score := "-", "-"
+			if len(check.results) > 0 && check.results[0].Score > 0 {
+				detected, score = check.results[0].Code, formatScore(check.results[0].Score)
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", check.status, where, value.key, locale, detected, score, excerpt(value.text, 60))
+		}
+	})
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if err := report.write(*junit, "i18nlang"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := findings.write(*sarif); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\n%d files, %d checks\n", files, checks)
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "without a locale (skipped, see -lang): %d files\n", skipped)
+	}
+	fmt.Fprintf(os.Stderr, "status: %s\n", formatCounts(statuses, checks))
+	if statuses["mismatch"] > 0 || statuses["copied"] > 0 {
+		return 1
+	}
+	return 0
+}
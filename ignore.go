@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName is the name of the files listing paths that directory
+// searches skip, in the syntax of .gitignore.
+const ignoreFileName = ".linguaignore"
+
+// ignorePattern is one line of an ignore file.
+type ignorePattern struct {
+	glob     string
+	negate   bool // "!pattern" re-includes paths excluded before
+	dirOnly  bool // "pattern/" matches directories only
+	anchored bool // a pattern containing "/" is relative to the ignore file
+}
+
+// ignoreRules are the patterns of the ignore file in one directory.
+type ignoreRules []ignorePattern
+
+// loadIgnoreFile reads the ignore file in dir. It returns nil if there is none.
+func loadIgnoreFile(dir string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var rules ignoreRules
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p ignorePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate, line = true, line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly, line = true, strings.TrimRight(line, "/")
+		}
+		p.anchored = strings.Contains(line, "/")
+		p.glob = strings.TrimPrefix(line, "/")
+		if p.glob != "" {
+			rules = append(rules, p)
+		}
+	}
+	return rules, scanner.Err()
+}
+
+// ignoreSet holds the ignore rules found while searching a directory tree,
+// by the directory they were found in.
+type ignoreSet map[string]ignoreRules
+
+// ignored reports whether the path below root is excluded by the rules of
+// its ancestor directories. Rules of deeper directories take precedence, and
+// within one file the last matching pattern wins.
+func (s ignoreSet) ignored(root, p string, isDir bool) bool {
+	var dirs []string
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	excluded := false
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rules := s[dirs[i]]
+		if rules == nil {
+			continue
+		}
+		rel, err := filepath.Rel(dirs[i], p)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pattern := range rules {
+			if pattern.dirOnly && !isDir {
+				continue
+			}
+			name := rel
+			if !pattern.anchored {
+				name = path.Base(rel)
+			}
+			if matchGlob(pattern.glob, name) {
+				excluded = !pattern.negate
+			}
+		}
+	}
+	return excluded
+}
+
+// hasGlobMeta reports whether s contains glob metacharacters.
+func hasGlobMeta(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// globBase splits a glob pattern into the directory to search, made of the
+// segments before the first one containing metacharacters, and the cleaned
+// pattern that the paths found there are matched against.
+func globBase(pattern string) (string, string) {
+	pattern = path.Clean(filepath.ToSlash(pattern))
+	segments := strings.Split(pattern, "/")
+	i := 0
+	for i < len(segments) && !hasGlobMeta(segments[i]) {
+		i++
+	}
+	base := strings.Join(segments[:i], "/")
+	switch {
+	case base == "" && strings.HasPrefix(pattern, "/"):
+		base = "/"
+	case base == "":
+		base = "."
+	}
+	return filepath.FromSlash(base), pattern
+}
+
+// matchGlob reports whether the slash-separated name matches pattern, in
+// which "**" stands for any number of directories and the other segments
+// follow path.Match.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			pattern = pattern[1:]
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// matchGlobOrParent reports whether the path, or one of the directories it
+// is in, matches pattern, so that a pattern naming a directory includes the
+// files inside it.
+func matchGlobOrParent(pattern, p string) bool {
+	segments := strings.Split(filepath.ToSlash(p), "/")
+	for i := len(segments); i > 0; i-- {
+		if matchGlob(pattern, strings.Join(segments[:i], "/")) {
+			return true
+		}
+	}
+	return false
+}
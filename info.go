@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	lingua "github.com/pemistahl/lingua-go"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// languageReport is what the info subcommand knows about a language.
+type languageReport struct {
+	Query string `json:"query"`
+	// Language is nil for codes lingua does not know.
+	Language *languageInfo `json:"language,omitempty"`
+	// Backends lists the built-in backends that detect the language.
+	Backends []string        `json:"backends"`
+	Profiles []profileReport `json:"profiles,omitempty"`
+	// Detected reports whether the configured detector detects the
+	// language.
+	Detected bool `json:"detected"`
+	// Notes explain why the language is not detected or how to refer to it.
+	Notes []string `json:"notes,omitempty"`
+}
+
+type profileReport struct {
+	File string `json:"file"`
+	// Ngrams is the number of n-grams in the profile, its model size.
+	Ngrams int `json:"ngrams"`
+}
+
+// runInfo implements the info subcommand. It prints the metadata of
+// languages given by code or name, and whether the detector configured by
+// the usual flags detects them.
+func runInfo(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	asJSON := fs.Bool("json", false,
+		"Print the metadata as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Print metadata about languages.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli info [OPTIONS] LANGUAGE...\n\n")
+		fmt.Fprintf(os.Stderr, "LANGUAGE is an ISO 639-1 or 639-3 code or an English name. Detection options\n")
+		fmt.Fprintf(os.Stderr, "such as -l, -backend and -profiles tell whether that detector detects it.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	profiles := make(map[string][]profileReport)
+	for _, path := range splitList(cfg.profileFiles) {
+		profile, err := langid.LoadProfile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		ngrams := 0
+		for _, grams := range profile.Ngrams {
+			ngrams += len(grams)
+		}
+		profiles[profile.Code] = append(profiles[profile.Code], profileReport{path, ngrams})
+	}
+
+	status := 0
+	var reports []languageReport
+	for _, query := range fs.Args() {
+		report := describeQuery(query, &cfg, profiles)
+		if report.Language == nil && len(report.Backends) == 0 && len(report.Profiles) == 0 {
+			status = 1
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		data, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		fmt.Printf("%s\n", data)
+		return status
+	}
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printLanguageReport(report)
+	}
+	return status
+}
+
+// resolveLanguage finds the lingua language with the given ISO 639-1 or
+// 639-3 code or English name.
+func resolveLanguage(query string) (lingua.Language, bool) {
+	for _, lang := range lingua.AllLanguages() {
+		if strings.EqualFold(lang.IsoCode639_1().String(), query) ||
+			strings.EqualFold(lang.IsoCode639_3().String(), query) ||
+			strings.EqualFold(lang.String(), query) {
+			return lang, true
+		}
+	}
+	return lingua.Unknown, false
+}
+
+// describeQuery collects what is known about the language query refers
+// to, for the detector configured by cfg.
+func describeQuery(query string, cfg *detectorConfig, profiles map[string][]profileReport) languageReport {
+	report := languageReport{Query: query, Backends: []string{}}
+	code := strings.ToLower(strings.TrimSpace(query))
+	if lang, ok := resolveLanguage(code); ok {
+		info := describeLanguage(lang)
+		report.Language = &info
+		if code != info.Code {
+			report.Notes = append(report.Notes, fmt.Sprintf("lingua-cli refers to %s by its ISO 639-1 code %q", info.Name, info.Code))
+		}
+		code = info.Code
+	}
+	for _, backend := range langid.Backends {
+		if supported, _ := langid.Supports(backend, code); supported {
+			report.Backends = append(report.Backends, backend)
+		}
+	}
+	report.Profiles = profiles[code]
+
+	backend := cfg.backend
+	supported, known := langid.Supports(backend, code)
+	switch {
+	case len(report.Profiles) > 0:
+		report.Detected = true
+	case cfg.profilesOnly:
+		report.Notes = append(report.Notes, "-profiles-only is set and none of the profiles is for this language")
+	case supported:
+		report.Detected = true
+	case !known:
+		report.Notes = append(report.Notes, fmt.Sprintf("whether the %s backend detects it depends on its model", backend))
+	default:
+		report.Notes = append(report.Notes, fmt.Sprintf("the %s backend does not support it", backend))
+	}
+	if languages := splitList(strings.ToLower(cfg.languages)); report.Detected && len(languages) > 0 && !slices.Contains(languages, code) {
+		report.Detected = false
+		report.Notes = append(report.Notes, "it is not in the -l list")
+	}
+	return report
+}
+
+func printLanguageReport(report languageReport) {
+	fmt.Printf("%s\n", report.Query)
+	if info := report.Language; info != nil {
+		fmt.Printf("  ISO 639-1:    %s\n", info.Code)
+		fmt.Printf("  ISO 639-3:    %s\n", info.Code3)
+		fmt.Printf("  name:         %s\n", info.Name)
+		fmt.Printf("  native name:  %s\n", info.NativeName)
+		fmt.Printf("  scripts:      %s\n", strings.Join(info.Scripts, ", "))
+	}
+	backends := strings.Join(report.Backends, ", ")
+	if backends == "" {
+		backends = "-"
+	}
+	fmt.Printf("  backends:     %s\n", backends)
+	for _, profile := range report.Profiles {
+		fmt.Printf("  profile:      %s (%d n-grams)\n", profile.File, profile.Ngrams)
+	}
+	detected := "no"
+	if report.Detected {
+		detected = "yes"
+	}
+	fmt.Printf("  detected:     %s\n", detected)
+	for _, note := range report.Notes {
+		fmt.Printf("  note:         %s\n", note)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// utf8Policy decides what happens to input that is not valid UTF-8.
+type utf8Policy string
+
+const (
+	// utf8Passthrough classifies and prints invalid input unchanged.
+	utf8Passthrough utf8Policy = "passthrough"
+	// utf8Replace replaces invalid bytes with U+FFFD.
+	utf8Replace utf8Policy = "replace"
+	// utf8Skip drops texts containing invalid bytes.
+	utf8Skip utf8Policy = "skip"
+	// utf8Fail aborts the run.
+	utf8Fail utf8Policy = "fail"
+)
+
+func parseUTF8Policy(value string) (utf8Policy, error) {
+	switch p := utf8Policy(value); p {
+	case utf8Passthrough, utf8Replace, utf8Skip, utf8Fail:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid -on-invalid-utf8 value %q (expected skip, replace, fail or passthrough)", value)
+}
+
+// clean applies the policy to text without reporting anything. It returns
+// the text to classify and false if the text is to be dropped.
+func (p utf8Policy) clean(text string) (string, bool) {
+	if p == utf8Passthrough || utf8.ValidString(text) {
+		return text, true
+	}
+	if p == utf8Replace {
+		return strings.ToValidUTF8(text, "\uFFFD"), true
+	}
+	return text, false
+}
+
+// check applies the policy to a text read from the input, reporting the
+// affected lines (counted from firstLine) on stderr, prefixed with name if
+// it is not empty. It exits for utf8Fail.
+func (p utf8Policy) check(text, name string, firstLine int) (string, bool) {
+	if p == utf8Passthrough || utf8.ValidString(text) {
+		return text, true
+	}
+	var lines []string
+	for i, line := range strings.Split(text, "\n") {
+		if !utf8.ValidString(line) {
+			lines = append(lines, strconv.Itoa(firstLine+i))
+		}
+	}
+	where := "line " + lines[0]
+	if len(lines) > 1 {
+		where = "lines " + strings.Join(lines, ", ")
+	}
+	if name != "" {
+		where = name + ": " + where
+	}
+	switch p {
+	case utf8Fail:
+		fmt.Fprintf(os.Stderr, "error: %s: invalid UTF-8\n", where)
+		exit(1)
+	case utf8Replace:
+		fmt.Fprintf(os.Stderr, "warning: %s: invalid UTF-8, replaced\n", where)
+	case utf8Skip:
+		fmt.Fprintf(os.Stderr, "warning: %s: invalid UTF-8, skipped\n", where)
+	}
+	return p.clean(text)
+}
+
+// readText reads the whole input and returns its size. With maxChars set,
+// reading stops after the bytes that can hold that many characters, since the
+// rest is not looked at. With maxBytes set, reading stops after maxBytes+1
+// bytes, which tells longer inputs apart without holding them whole.
+func readText(r io.Reader, maxChars, maxBytes int) (string, int64, error) {
+	if maxChars > 0 && (maxBytes <= 0 || maxChars*utf8.UTFMax <= maxBytes) {
+		limit := int64(maxChars) * utf8.UTFMax
+		data, err := io.ReadAll(io.LimitReader(r, limit))
+		size := int64(len(data))
+		if size == limit {
+			data = data[:completeRunes(data)]
+		}
+		return string(data), size, err
+	}
+	if maxBytes > 0 {
+		r = io.LimitReader(r, int64(maxBytes)+1)
+	}
+	data, err := io.ReadAll(r)
+	return string(data), int64(len(data)), err
+}
+
+// splitTexts splits the content of a -texts-from file into texts: at NUL
+// bytes if there are any, so texts may span lines, and at line ends
+// otherwise. A final terminator does not start another text.
+func splitTexts(data string) []string {
+	if strings.Contains(data, "\x00") {
+		return strings.Split(strings.TrimSuffix(data, "\x00"), "\x00")
+	}
+	if data == "" {
+		return nil
+	}
+	texts := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+	for i, text := range texts {
+		texts[i] = strings.TrimSuffix(text, "\r")
+	}
+	return texts
+}
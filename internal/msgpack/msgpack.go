@@ -0,0 +1,95 @@
+// Package msgpack encodes the MessagePack values lingua-cli writes: the
+// events of the Fluentd forward protocol and the responses of linguahttp.
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Buffer accumulates encoded values.
+type Buffer struct {
+	buf []byte
+}
+
+// Bytes returns the values encoded so far.
+func (b *Buffer) Bytes() []byte {
+	return b.buf
+}
+
+// ArrayHeader starts an array of n values.
+func (b *Buffer) ArrayHeader(n int) {
+	switch {
+	case n < 16:
+		b.buf = append(b.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		b.buf = binary.BigEndian.AppendUint16(append(b.buf, 0xdc), uint16(n))
+	default:
+		b.buf = binary.BigEndian.AppendUint32(append(b.buf, 0xdd), uint32(n))
+	}
+}
+
+// MapHeader starts a map of n key and value pairs.
+func (b *Buffer) MapHeader(n int) {
+	switch {
+	case n < 16:
+		b.buf = append(b.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		b.buf = binary.BigEndian.AppendUint16(append(b.buf, 0xde), uint16(n))
+	default:
+		b.buf = binary.BigEndian.AppendUint32(append(b.buf, 0xdf), uint32(n))
+	}
+}
+
+// Str encodes a string.
+func (b *Buffer) Str(s string) {
+	switch n := len(s); {
+	case n < 32:
+		b.buf = append(b.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b.buf = append(b.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b.buf = binary.BigEndian.AppendUint16(append(b.buf, 0xda), uint16(n))
+	default:
+		b.buf = binary.BigEndian.AppendUint32(append(b.buf, 0xdb), uint32(n))
+	}
+	b.buf = append(b.buf, s...)
+}
+
+// Int encodes an integer.
+func (b *Buffer) Int(n int64) {
+	if n >= 0 && n < 128 {
+		b.buf = append(b.buf, byte(n))
+		return
+	}
+	b.buf = binary.BigEndian.AppendUint64(append(b.buf, 0xd3), uint64(n))
+}
+
+// Float encodes a 64-bit float.
+func (b *Buffer) Float(f float64) {
+	b.buf = binary.BigEndian.AppendUint64(append(b.buf, 0xcb), math.Float64bits(f))
+}
+
+// Ext encodes an extension value of the given type, such as the EventTime
+// (type 0) of Fluentd.
+func (b *Buffer) Ext(typ int8, data []byte) {
+	switch n := len(data); {
+	case n == 1:
+		b.buf = append(b.buf, 0xd4)
+	case n == 2:
+		b.buf = append(b.buf, 0xd5)
+	case n == 4:
+		b.buf = append(b.buf, 0xd6)
+	case n == 8:
+		b.buf = append(b.buf, 0xd7)
+	case n == 16:
+		b.buf = append(b.buf, 0xd8)
+	case n <= math.MaxUint8:
+		b.buf = append(b.buf, 0xc7, byte(n))
+	case n <= math.MaxUint16:
+		b.buf = binary.BigEndian.AppendUint16(append(b.buf, 0xc8), uint16(n))
+	default:
+		b.buf = binary.BigEndian.AppendUint32(append(b.buf, 0xc9), uint32(n))
+	}
+	b.buf = append(append(b.buf, byte(typ)), data...)
+}
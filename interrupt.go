@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// exitInterrupted is the exit status of runs stopped by an interrupt, the
+// status shells report for processes killed by SIGINT.
+const exitInterrupted = 130
+
+// stopRequested is set by the first interrupt of a run.
+var stopRequested atomic.Bool
+
+// stopping reports whether the run was interrupted, and should stop reading
+// input.
+func stopping() bool {
+	return stopRequested.Load()
+}
+
+// stopOnInterrupt makes the first SIGINT or SIGTERM stop the run gracefully:
+// reading input stops, a blocked read of stdin included, so that the texts
+// already read are classified and written before the run ends. A second one
+// exits at once, without writing anything more.
+func stopOnInterrupt() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		stopRequested.Store(true)
+		fmt.Fprintf(os.Stderr, "\ninterrupted, finishing the texts read so far (interrupt again to quit at once)\n")
+		os.Stdin.Close()
+		<-signals
+		os.Exit(exitInterrupted)
+	}()
+}
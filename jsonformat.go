@@ -0,0 +1,154 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// jsonV1Schema is the JSON Schema of the json-v1 output format. Later
+// versions of lingua-cli may add fields to it, but never remove or change
+// existing ones; incompatible changes get a new format version.
+//
+//go:embed schema/json-v1.json
+var jsonV1Schema []byte
+
+// outputFormats lists the values accepted by -format.
+var outputFormats = []string{"tsv", "json-v1", "json-run", "ecs", "avro", "protobuf", "cbor", "cbor-seq", "fasttext"}
+
+// jsonRecord is a result in the json-v1 format: one JSON object per line
+// and text.
+type jsonRecord struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Source is the file:line location of lines of files.
+	Source string `json:"source,omitempty"`
+	// Language is absent for lines passed through without classification
+	// and in multi-language mode.
+	Language *string  `json:"language,omitempty"`
+	Score    *float64 `json:"score,omitempty"`
+	Entropy  *float64 `json:"entropy,omitempty"`
+	Margin   *float64 `json:"margin,omitempty"`
+	// MixedScript lists the scripts of texts mixing them, with
+	// -mixed-script.
+	MixedScript []string         `json:"mixed_script,omitempty"`
+	Encoding    string           `json:"encoding,omitempty"`
+	Confidences []jsonConfidence `json:"confidences,omitempty"`
+	Spans       []jsonSpan       `json:"spans,omitempty"`
+	Text        *string          `json:"text,omitempty"`
+}
+
+type jsonConfidence struct {
+	Language string  `json:"language"`
+	Score    float64 `json:"score"`
+}
+
+type jsonSpan struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Language string `json:"language"`
+	Text     string `json:"text"`
+}
+
+// record returns the json-v1 record of a relabeled distribution, applying
+// the threshold and -a like the delimited output does.
+func (p *printer) record(results []langid.Confidence) jsonRecord {
+	r := jsonRecord{Encoding: p.encoding}
+	language := p.labels.label(langid.Unknown)
+	r.Language = &language
+	if len(results) > 0 && (!p.hasThreshold || results[0].Score >= p.threshold) {
+		language, score := results[0].Code, results[0].Score
+		r.Language, r.Score = &language, &score
+	}
+	if p.entropy && len(results) > 0 && results[0].Score > 0 {
+		entropy := langid.Entropy(results)
+		r.Entropy = &entropy
+	}
+	if p.margin && len(results) > 0 {
+		margin := langid.Margin(results)
+		r.Margin = &margin
+	}
+	if p.all {
+		for _, result := range p.ordered(results) {
+			if !p.hasThreshold || result.Score >= p.threshold {
+				r.Confidences = append(r.Confidences, jsonConfidence{result.Code, result.Score})
+			}
+		}
+	}
+	return r
+}
+
+// sinkRecord returns the json-v1 record that -webhook, -mqtt, -nats-publish
+// and -fluentd send for the result of a text: a line of the input if number
+// is set, a file if name is set, or else a text. Texts that could not be
+// classified have no results.
+func sinkRecord(name string, number int, text string, results []langid.Confidence, threshold float64, labels labelMap) jsonRecord {
+	r := jsonRecord{File: name, Line: number}
+	language := labels.label(langid.Unknown)
+	r.Language = &language
+	if results = labels.apply(results); len(results) > 0 && results[0].Score > 0 && results[0].Score >= threshold {
+		language, score := results[0].Code, results[0].Score
+		r.Language, r.Score = &language, &score
+	}
+	if text != "" {
+		r.Text = &text
+	}
+	return r
+}
+
+// printRecord prints r as a line of JSON, mapped onto the Elastic Common
+// Schema with -format ecs, adds it to the Avro container file of -format
+// avro, or prints it as a protobuf message with -format protobuf or a CBOR
+// map with -format cbor and cbor-seq.
+func (p *printer) printRecord(r jsonRecord) {
+	if r.File != "" && r.Line > 0 {
+		r.Source = sourceLocation(r.File, r.Line)
+	}
+	if p.mixedScript {
+		text := p.source
+		if r.Text != nil {
+			text = *r.Text
+		}
+		r.MixedScript = langid.MixedScripts(text, langid.MixedScriptShare)
+	}
+	if p.avro != nil {
+		p.avro.add(r, p.flush)
+		p.endResult()
+		return
+	}
+	if p.held != nil {
+		lang, score := "", 0.0
+		if r.Language != nil {
+			lang = *r.Language
+		}
+		if r.Score != nil {
+			score = *r.Score
+		}
+		p.held.start(lang, score, r.Line)
+	}
+	if p.protobuf {
+		p.printProtobuf(r)
+		return
+	}
+	if p.cbor {
+		p.printCBOR(r)
+		return
+	}
+	var v any = r
+	if p.ecs {
+		v = p.ecsDocument(r)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Such as a NaN score, which JSON can not represent.
+		fmt.Fprintf(os.Stderr, "warning: skipped a result that can not be encoded as JSON: %v\n", err)
+		p.endResult()
+		return
+	}
+	p.w.Write(data)
+	p.w.WriteByte('\n')
+	p.endResult()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// jsonlFields are the paths of the fields -jsonl reads the text and the
+// optional language hint from and writes the result to. A path is a list of
+// keys into nested objects.
+type jsonlFields struct {
+	text, lang, conf []string
+	// hint is nil without -hint-field.
+	hint []string
+}
+
+// parseFieldPath parses a dotted field path such as "payload.text".
+func parseFieldPath(path string) ([]string, error) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("invalid field path %q", path)
+		}
+	}
+	return keys, nil
+}
+
+func newJSONLFields(text, lang, conf, hint string) (*jsonlFields, error) {
+	var f jsonlFields
+	var err error
+	if hint != "" {
+		if f.hint, err = parseFieldPath(hint); err != nil {
+			return nil, err
+		}
+	}
+	if f.text, err = parseFieldPath(text); err != nil {
+		return nil, err
+	}
+	if f.lang, err = parseFieldPath(lang); err != nil {
+		return nil, err
+	}
+	if f.conf, err = parseFieldPath(conf); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// decode parses a line of JSON Lines input, returning the object and the
+// text to classify. Records without a string at the text path have no text.
+// Numbers are kept as written.
+func (f *jsonlFields) decode(line string) (map[string]any, string, error) {
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+	var record map[string]any
+	if err := d.Decode(&record); err != nil {
+		return nil, "", err
+	}
+	if record == nil {
+		return nil, "", fmt.Errorf("not a JSON object")
+	}
+	if d.More() {
+		return nil, "", fmt.Errorf("more than one JSON value")
+	}
+	return record, stringField(record, f.text), nil
+}
+
+// stringField returns the string at path in record, or "" if there is none.
+func stringField(record map[string]any, path []string) string {
+	var value any = record
+	for _, key := range path {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	s, _ := value.(string)
+	return s
+}
+
+// setField sets the field at path in record, creating the objects on the
+// way and replacing values that are not objects.
+func setField(record map[string]any, path []string, value any) {
+	for _, key := range path[:len(path)-1] {
+		object, ok := record[key].(map[string]any)
+		if !ok {
+			object = make(map[string]any)
+			record[key] = object
+		}
+		record = object
+	}
+	record[path[len(path)-1]] = value
+}
+
+// printJSONL prints a JSON Lines input record with the language and
+// confidence of its text added at the fields of f. Texts that could not be
+// classified get the unknown label and no confidence. Keys are printed in
+// sorted order.
+func (p *printer) printJSONL(f *jsonlFields, record map[string]any, results []langid.Confidence) {
+	results = p.labels.apply(results)
+	if len(results) > 0 && (!p.hasThreshold || results[0].Score >= p.threshold) {
+		setField(record, f.lang, results[0].Code)
+		setField(record, f.conf, results[0].Score)
+	} else {
+		setField(record, f.lang, p.labels.label(langid.Unknown))
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(record); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: skipped a record that can not be encoded as JSON: %v\n", err)
+		p.endResult()
+		return
+	}
+	p.w.Write(buf.Bytes())
+	p.endResult()
+}
+
+// printRawRecord prints a JSON Lines input line unchanged.
+func (p *printer) printRawRecord(line string) {
+	p.w.WriteString(line)
+	p.w.WriteByte('\n')
+	p.endResult()
+}
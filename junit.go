@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// junitReport collects the checks of a checking command for -junit, as a
+// test suite per file with a test case per check, so that CI systems show
+// wrong-language findings like failed tests.
+type junitReport struct {
+	// failing are the statuses of failed checks. Checks of statuses other
+	// than these and "ok" are skipped.
+	failing []string
+	suites  []*junitSuite
+	bySuite map[string]*junitSuite
+}
+
+type junitSuites struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Name     string        `xml:"name,attr"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Skipped  int           `xml:"skipped,attr"`
+	Suites   []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure"`
+	Skipped   *junitMessage `xml:"skipped"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+}
+
+// newJUnitReport returns a report failing the checks of the given
+// statuses, or nil if path is empty, on which add and write do nothing.
+func newJUnitReport(path string, failing ...string) *junitReport {
+	if path == "" {
+		return nil
+	}
+	return &junitReport{failing: failing, bySuite: make(map[string]*junitSuite)}
+}
+
+// add records check in the suite of file.
+func (r *junitReport) add(file string, check langCheck) {
+	if r == nil {
+		return
+	}
+	suite := r.bySuite[file]
+	if suite == nil {
+		suite = &junitSuite{Name: file}
+		r.bySuite[file] = suite
+		r.suites = append(r.suites, suite)
+	}
+	c := junitCase{Name: check.element, ClassName: check.page}
+	detected := "no language"
+	if len(check.results) > 0 && check.results[0].Score > 0 {
+		detected = fmt.Sprintf("%s (%s)", check.results[0].Code, formatScore(check.results[0].Score))
+	}
+	switch {
+	case check.status == "ok":
+	case slices.Contains(r.failing, check.status):
+		message := fmt.Sprintf("declared %s, detected %s", check.declared, detected)
+		switch check.status {
+		case "missing":
+			message = "no language declared"
+		case "copied":
+			message = "translation identical to the source text"
+		}
+		c.Failure = &junitMessage{Message: message, Type: check.status}
+		suite.Failures++
+	default:
+		c.Skipped = &junitMessage{Message: check.status}
+		suite.Skipped++
+	}
+	suite.Tests++
+	suite.Cases = append(suite.Cases, c)
+}
+
+// write writes the report to path, named after the command.
+func (r *junitReport) write(path, command string) error {
+	if r == nil {
+		return nil
+	}
+	report := junitSuites{Name: "lingua-cli " + command, Suites: r.suites}
+	for _, suite := range r.suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0o644)
+}
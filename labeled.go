@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// labeledText is one entry of a labeled development set.
+type labeledText struct {
+	label string
+	text  string
+}
+
+// readLabeled reads a labeled development set of "label<TAB>text" lines from
+// path ("-" for stdin). Empty lines and lines starting with # are ignored.
+func readLabeled(path string) ([]labeledText, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var entries []labeledText
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		label, text, ok := strings.Cut(line, "\t")
+		if !ok || strings.TrimSpace(label) == "" {
+			return nil, fmt.Errorf("%s:%d: expected label<TAB>text", path, lineNo)
+		}
+		entries = append(entries, labeledText{label: strings.ToLower(strings.TrimSpace(label)), text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: no labeled texts", path)
+	}
+	return entries, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// labelMap rewrites output labels to match an external taxonomy, e.g. nb→no,
+// bs/hr/sr→sh or unknown→und. A nil map leaves labels unchanged.
+type labelMap map[string]string
+
+// loadLabelMap reads a mapping file of "from<whitespace>to" lines. Empty lines
+// and lines starting with # are ignored. The key "unknown" renames the label
+// printed for unclassifiable text, including results below the -c threshold.
+func loadLabelMap(path string) (labelMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m := make(labelMap)
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected 'from to'", path, lineNo)
+		}
+		m[strings.ToLower(fields[0])] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// label returns the output label for a language code.
+func (m labelMap) label(code string) string {
+	if mapped, ok := m[code]; ok {
+		return mapped
+	}
+	return code
+}
+
+// apply relabels a distribution. Languages collapsed into the same label
+// have their scores summed, and the result is re-sorted by score.
+func (m labelMap) apply(values []langid.Confidence) []langid.Confidence {
+	if len(m) == 0 {
+		return values
+	}
+	merged := make([]langid.Confidence, 0, len(values))
+	index := make(map[string]int)
+	for _, value := range values {
+		value.Code = m.label(value.Code)
+		if i, ok := index[value.Code]; ok {
+			merged[i].Score += value.Score
+			continue
+		}
+		index[value.Code] = len(merged)
+		merged = append(merged, value)
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	return merged
+}
@@ -0,0 +1,107 @@
+package langid
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithCache remembers the results of d for the size most recently used
+// texts, so that repeated texts, such as boilerplate lines in logs, are only
+// classified once.
+func WithCache(d Detector, size int) Detector {
+	return &cached{base: d, size: size, entries: make(map[string]*list.Element), order: list.New()}
+}
+
+type cached struct {
+	base Detector
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds the cache entries, most recently used first.
+	order *list.List
+}
+
+type cacheEntry struct {
+	text   string
+	values []Confidence
+}
+
+func (d *cached) ConfidenceValues(text string) ([]Confidence, error) {
+	d.mu.Lock()
+	if element, ok := d.entries[text]; ok {
+		d.order.MoveToFront(element)
+		values := element.Value.(*cacheEntry).values
+		d.mu.Unlock()
+		return append([]Confidence(nil), values...), nil
+	}
+	d.mu.Unlock()
+
+	// Classify without holding the lock, so that concurrent callers are not
+	// serialized. Two callers may then classify the same new text at once.
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.entries[text]; !ok {
+		d.entries[text] = d.order.PushFront(&cacheEntry{text, append([]Confidence(nil), values...)})
+		if d.order.Len() > d.size {
+			oldest := d.order.Back()
+			d.order.Remove(oldest)
+			delete(d.entries, oldest.Value.(*cacheEntry).text)
+		}
+	}
+	return values, nil
+}
+
+func (d *cached) Unwrap() Detector { return d.base }
+
+func (d *cached) flush() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := d.order.Len()
+	clear(d.entries)
+	d.order.Init()
+	return n
+}
+
+func (d *cached) caches() {}
+
+// Uncached returns the detector below the caches of d, those of WithCache
+// and OpenDiskCache, for classifying texts whose results are not worth
+// remembering. It returns d itself if d has no cache.
+func Uncached(d Detector) Detector {
+	below := d
+	for d != nil {
+		w, ok := d.(wrapper)
+		if !ok {
+			break
+		}
+		d = w.Unwrap()
+		if _, ok := w.(interface{ caches() }); ok {
+			below = d
+		}
+	}
+	return below
+}
+
+// FlushCaches empties the in-memory caches of d, those of WithCache, to give
+// their memory back when it runs short, and returns the number of results
+// dropped. The results kept by OpenDiskCache stay on disk.
+func FlushCaches(d Detector) int {
+	n := 0
+	for d != nil {
+		if c, ok := d.(*cached); ok {
+			n += c.flush()
+		}
+		w, ok := d.(wrapper)
+		if !ok {
+			break
+		}
+		d = w.Unwrap()
+	}
+	return n
+}
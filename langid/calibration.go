@@ -0,0 +1,203 @@
+package langid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// minSamplesPerBin keeps calibration bins large enough for a meaningful precision.
+const minSamplesPerBin = 20
+
+// Calibration maps raw confidence values to the precision observed for them
+// on a labeled development set, so that a threshold on calibrated values
+// corresponds to an expected error rate.
+type Calibration struct {
+	// Points are (raw score, precision) pairs in increasing order of both.
+	// Scores in between are interpolated linearly.
+	Points []CalibrationPoint
+}
+
+// CalibrationPoint is one knot of a calibration curve.
+type CalibrationPoint struct {
+	Raw       float64
+	Precision float64
+	// Samples is the number of development texts the point is based on.
+	Samples int
+}
+
+// Calibrate fits a calibration curve from the top scores a detector assigned
+// to labeled texts and whether the top language was the correct one. Scores
+// are grouped into at most bins equal-frequency bins, which are then pooled
+// until precision increases monotonically (isotonic regression).
+func Calibrate(scores []float64, correct []bool, bins int) (*Calibration, error) {
+	if len(scores) != len(correct) {
+		return nil, fmt.Errorf("got %d scores but %d outcomes", len(scores), len(correct))
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("no samples to calibrate on")
+	}
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return scores[order[i]] < scores[order[j]] })
+
+	if limit := len(scores) / minSamplesPerBin; bins > limit {
+		bins = limit
+	}
+	if bins < 1 {
+		bins = 1
+	}
+
+	type block struct {
+		rawSum  float64
+		hits    int
+		samples int
+	}
+	var blocks []block
+	for b := 0; b < bins; b++ {
+		start, end := b*len(order)/bins, (b+1)*len(order)/bins
+		var blk block
+		for _, i := range order[start:end] {
+			blk.rawSum += scores[i]
+			blk.samples++
+			if correct[i] {
+				blk.hits++
+			}
+		}
+		blocks = append(blocks, blk)
+		// Pool adjacent violators: merge while precision would decrease.
+		for len(blocks) > 1 {
+			last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+			if float64(prev.hits)/float64(prev.samples) <= float64(last.hits)/float64(last.samples) {
+				break
+			}
+			blocks = blocks[:len(blocks)-2]
+			blocks = append(blocks, block{prev.rawSum + last.rawSum, prev.hits + last.hits, prev.samples + last.samples})
+		}
+	}
+
+	c := &Calibration{}
+	for _, blk := range blocks {
+		c.Points = append(c.Points, CalibrationPoint{
+			Raw:       blk.rawSum / float64(blk.samples),
+			Precision: float64(blk.hits) / float64(blk.samples),
+			Samples:   blk.samples,
+		})
+	}
+	return c, nil
+}
+
+// Apply maps a raw score to its calibrated value. Scores below the first
+// point are interpolated towards zero, so that a language that was not
+// considered at all stays at zero.
+func (c *Calibration) Apply(score float64) float64 {
+	points := c.Points
+	if score <= 0 || len(points) == 0 {
+		return score
+	}
+	if score <= points[0].Raw {
+		return score / points[0].Raw * points[0].Precision
+	}
+	for i := 1; i < len(points); i++ {
+		if score <= points[i].Raw {
+			lo, hi := points[i-1], points[i]
+			if hi.Raw == lo.Raw {
+				return hi.Precision
+			}
+			return lo.Precision + (score-lo.Raw)/(hi.Raw-lo.Raw)*(hi.Precision-lo.Precision)
+		}
+	}
+	return points[len(points)-1].Precision
+}
+
+// Save writes the curve as tab-separated "raw precision samples" lines.
+func (c *Calibration) Save(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "# raw\tprecision\tsamples"); err != nil {
+		return err
+	}
+	for _, p := range c.Points {
+		if _, err := fmt.Fprintf(w, "%s\t%s\t%d\n",
+			strconv.FormatFloat(p.Raw, 'f', -1, 64),
+			strconv.FormatFloat(p.Precision, 'f', -1, 64),
+			p.Samples,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadCalibration reads a curve written by Save.
+func LoadCalibration(path string) (*Calibration, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &Calibration{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected 'raw precision [samples]'", path, lineNo)
+		}
+		var p CalibrationPoint
+		if p.Raw, err = strconv.ParseFloat(fields[0], 64); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if p.Precision, err = strconv.ParseFloat(fields[1], 64); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if len(fields) > 2 {
+			p.Samples, _ = strconv.Atoi(fields[2])
+		}
+		if n := len(c.Points); n > 0 && (p.Raw < c.Points[n-1].Raw || p.Precision < c.Points[n-1].Precision) {
+			return nil, fmt.Errorf("%s:%d: calibration points must not decrease", path, lineNo)
+		}
+		c.Points = append(c.Points, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(c.Points) == 0 {
+		return nil, fmt.Errorf("%s: no calibration points", path)
+	}
+	return c, nil
+}
+
+// WithCalibration maps every score computed by d through the calibration.
+func WithCalibration(d Detector, c *Calibration) Detector {
+	return &calibrated{base: d, calibration: c}
+}
+
+type calibrated struct {
+	base        Detector
+	calibration *Calibration
+}
+
+func (d *calibrated) ConfidenceValues(text string) ([]Confidence, error) {
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+	mapped := make([]Confidence, len(values))
+	for i, value := range values {
+		mapped[i] = Confidence{Code: value.Code, Score: d.calibration.Apply(value.Score)}
+	}
+	return mapped, nil
+}
+
+func (d *calibrated) Unwrap() Detector { return d.base }
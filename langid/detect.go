@@ -0,0 +1,172 @@
+package langid
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrNoMultiSupport is returned by DetectMultiple for detectors that cannot
+// split mixed-language text.
+var ErrNoMultiSupport = errors.New("detector does not support multi-language detection")
+
+// Result is the outcome of Detect.
+type Result struct {
+	// Language is the code of the best language, or Unknown if there is
+	// none or its score lies below the threshold.
+	Language string
+	Score    float64
+	// Confidences is the full distribution, sorted by descending score.
+	Confidences []Confidence
+}
+
+// Known reports whether a language was assigned.
+func (r Result) Known() bool { return r.Language != Unknown }
+
+// settings are the per-call configuration built from Options.
+type settings struct {
+	languages  map[string]bool
+	threshold  float64
+	preprocess []func(string) string
+}
+
+// Option configures a call of Detect, DetectMultiple or DetectAll.
+type Option func(*settings)
+
+// WithLanguages limits the results to the given ISO 639-1 codes, with the
+// scores of the remaining languages rescaled to sum to 1. Restricting the
+// detector itself with Options.Languages is faster and usually more
+// accurate; this is for varying the languages per call.
+func WithLanguages(codes ...string) Option {
+	return func(s *settings) {
+		s.languages = make(map[string]bool, len(codes))
+		for _, code := range codes {
+			s.languages[strings.ToLower(strings.TrimSpace(code))] = true
+		}
+	}
+}
+
+// WithThreshold reports texts whose best score lies below threshold as
+// Unknown.
+func WithThreshold(threshold float64) Option {
+	return func(s *settings) { s.threshold = threshold }
+}
+
+// WithPreprocessor transforms texts before detection, for example to strip
+// markup. Several preprocessors apply in the order given.
+func WithPreprocessor(preprocess func(string) string) Option {
+	return func(s *settings) { s.preprocess = append(s.preprocess, preprocess) }
+}
+
+func newSettings(opts []Option) *settings {
+	s := &settings{}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *settings) prepare(text string) string {
+	for _, preprocess := range s.preprocess {
+		text = preprocess(text)
+	}
+	return text
+}
+
+// restrict applies WithLanguages to a distribution.
+func (s *settings) restrict(values []Confidence) []Confidence {
+	if s.languages == nil {
+		return values
+	}
+	var kept []Confidence
+	total := 0.0
+	for _, value := range values {
+		if s.languages[value.Code] {
+			kept = append(kept, value)
+			total += value.Score
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+	for i := range kept {
+		kept[i].Score /= total
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Score > kept[j].Score })
+	return kept
+}
+
+// Detect classifies text with d. If ctx ends before d returns, Detect
+// returns ctx.Err() without waiting for it.
+func Detect(ctx context.Context, d Detector, text string, opts ...Option) (Result, error) {
+	return newSettings(opts).detect(ctx, d, text)
+}
+
+func (s *settings) detect(ctx context.Context, d Detector, text string) (Result, error) {
+	text = s.prepare(text)
+	values, err := await(ctx, func() ([]Confidence, error) { return d.ConfidenceValues(text) })
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Language: Unknown, Confidences: s.restrict(values)}
+	if len(result.Confidences) > 0 && result.Confidences[0].Score > 0 && result.Confidences[0].Score >= s.threshold {
+		result.Language, result.Score = result.Confidences[0].Code, result.Confidences[0].Score
+	}
+	return result, nil
+}
+
+// DetectAll classifies texts with d, stopping with ctx.Err() as soon as ctx
+// ends.
+func DetectAll(ctx context.Context, d Detector, texts []string, opts ...Option) ([]Result, error) {
+	s := newSettings(opts)
+	results := make([]Result, 0, len(texts))
+	for _, text := range texts {
+		result, err := s.detect(ctx, d, text)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// DetectMultiple splits a mixed-language text into single-language spans
+// with d, or the first detector it wraps that supports this; otherwise it
+// returns ErrNoMultiSupport. Span offsets refer to the preprocessed text.
+// WithLanguages and WithThreshold do not apply to spans.
+func DetectMultiple(ctx context.Context, d Detector, text string, opts ...Option) ([]Span, error) {
+	md, ok := AsMulti(d)
+	if !ok {
+		return nil, ErrNoMultiSupport
+	}
+	text = newSettings(opts).prepare(text)
+	return await(ctx, func() ([]Span, error) { return md.DetectMultiple(text) })
+}
+
+// await runs detect, returning early with ctx.Err() if ctx ends first.
+// Backends cannot be interrupted, so detect then finishes in the background.
+func await[T any](ctx context.Context, detect func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+	if ctx.Done() == nil {
+		return detect()
+	}
+	type outcome struct {
+		value T
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		value, err := detect()
+		done <- outcome{value, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case o := <-done:
+		return o.value, o.err
+	}
+}
@@ -0,0 +1,36 @@
+package langid
+
+import "golang.org/x/text/unicode/bidi"
+
+// mixedDirectionShare is the share of the strongly directional characters
+// of a text that have to run against the majority for Direction to report
+// the text as mixed, so that single foreign words do not count.
+const mixedDirectionShare = 0.1
+
+// Direction returns the dominant writing direction of text from the Unicode
+// bidirectional classes of its characters: "ltr" or "rtl", the values of the
+// HTML dir attribute, "mixed" if at least a tenth of the strongly
+// directional characters run against the majority, or "" if text has no
+// such characters.
+func Direction(text string) string {
+	ltr, rtl := 0, 0
+	for _, r := range text {
+		props, _ := bidi.LookupRune(r)
+		switch props.Class() {
+		case bidi.L:
+			ltr++
+		case bidi.R, bidi.AL:
+			rtl++
+		}
+	}
+	total := float64(ltr + rtl)
+	switch {
+	case total == 0:
+		return ""
+	case float64(min(ltr, rtl)) >= mixedDirectionShare*total:
+		return "mixed"
+	case ltr > rtl:
+		return "ltr"
+	}
+	return "rtl"
+}
@@ -0,0 +1,155 @@
+//go:build !js && !wasip1
+
+package langid
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// diskCacheBatch is how many new results are collected before they are
+// written to the cache file in one transaction.
+const diskCacheBatch = 1000
+
+// OpenDiskCache wraps d with a persistent cache of its results in the bbolt
+// database at path, so that repeated runs over mostly unchanged texts only
+// classify the new ones. Results are stored per config, a description of
+// everything that influences them, such as the backend and its options;
+// entries stored under a different config are not used. Close writes out
+// pending results and closes the database as well as d.
+func OpenDiskCache(d Detector, path, config string) (Detector, error) {
+	db, err := bolt.Open(path, 0o666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		if err == bolt.ErrTimeout {
+			return nil, fmt.Errorf("%s: cache file is in use by another process", path)
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sum := sha256.Sum256([]byte(config))
+	bucket := []byte(fmt.Sprintf("%x", sum[:16]))
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &diskCache{base: d, db: db, bucket: bucket, pending: make(map[[sha256.Size]byte][]byte)}, nil
+}
+
+type diskCache struct {
+	base   Detector
+	db     *bolt.DB
+	bucket []byte
+
+	mu sync.Mutex
+	// pending holds results not yet written to the database.
+	pending map[[sha256.Size]byte][]byte
+}
+
+func (d *diskCache) ConfidenceValues(text string) ([]Confidence, error) {
+	key := sha256.Sum256([]byte(text))
+	d.mu.Lock()
+	value, ok := d.pending[key]
+	d.mu.Unlock()
+	if !ok {
+		d.db.View(func(tx *bolt.Tx) error {
+			if stored := tx.Bucket(d.bucket).Get(key[:]); stored != nil {
+				value, ok = append([]byte(nil), stored...), true
+			}
+			return nil
+		})
+	}
+	if ok {
+		if values, err := decodeConfidences(value); err == nil {
+			return values, nil
+		}
+	}
+
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pending[key] = encodeConfidences(values)
+	if len(d.pending) >= diskCacheBatch {
+		if err := d.flush(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// flush writes the pending results; d.mu must be held.
+func (d *diskCache) flush() error {
+	if len(d.pending) == 0 {
+		return nil
+	}
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(d.bucket)
+		for key, value := range d.pending {
+			if err := b.Put(key[:], value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	clear(d.pending)
+	return nil
+}
+
+func (d *diskCache) Close() error {
+	d.mu.Lock()
+	err := d.flush()
+	d.mu.Unlock()
+	if closeErr := d.db.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := Close(d.base); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (d *diskCache) Unwrap() Detector { return d.base }
+
+func (d *diskCache) caches() {}
+
+// encodeConfidences serializes results as a sequence of (code length, code,
+// score) records. No results encode as an empty value.
+func encodeConfidences(values []Confidence) []byte {
+	buf := make([]byte, 0, len(values)*12)
+	for _, v := range values {
+		buf = append(buf, byte(len(v.Code)))
+		buf = append(buf, v.Code...)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Score))
+	}
+	return buf
+}
+
+func decodeConfidences(buf []byte) ([]Confidence, error) {
+	var values []Confidence
+	for len(buf) > 0 {
+		n := int(buf[0])
+		if len(buf) < 1+n+8 {
+			return nil, fmt.Errorf("corrupt cache entry")
+		}
+		values = append(values, Confidence{
+			Code:  string(buf[1 : 1+n]),
+			Score: math.Float64frombits(binary.LittleEndian.Uint64(buf[1+n:])),
+		})
+		buf = buf[1+n+8:]
+	}
+	return values, nil
+}
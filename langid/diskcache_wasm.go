@@ -0,0 +1,11 @@
+//go:build js || wasip1
+
+package langid
+
+import "fmt"
+
+// OpenDiskCache is not available in WebAssembly builds, which lack the
+// memory-mapped files the cache is stored in.
+func OpenDiskCache(d Detector, path, config string) (Detector, error) {
+	return nil, fmt.Errorf("%s: cache files are not supported on this platform", path)
+}
@@ -0,0 +1,54 @@
+package langid
+
+import "math"
+
+// Entropy returns the entropy of a distribution normalized to lie between 0
+// (all confidence on one language) and 1 (spread evenly over all languages
+// of the distribution). Unlike the best score alone, it reflects how the
+// rest of the confidence is spread, which makes it a better signal for
+// sending uncertain texts to review.
+func Entropy(values []Confidence) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	total := 0.0
+	for _, value := range values {
+		total += value.Score
+	}
+	if total <= 0 {
+		return 0
+	}
+	entropy := 0.0
+	for _, value := range values {
+		if p := value.Score / total; p > 0 {
+			entropy -= p * math.Log(p)
+		}
+	}
+	return entropy / math.Log(float64(len(values)))
+}
+
+// WithMaxEntropy makes d report no language at all when the entropy of its
+// distribution exceeds max, that is when the confidence is spread too evenly
+// for the best language to mean much, as with gibberish and transliterated
+// text, even if its score is high.
+func WithMaxEntropy(d Detector, max float64) Detector {
+	return &maxEntropy{base: d, max: max}
+}
+
+type maxEntropy struct {
+	base Detector
+	max  float64
+}
+
+func (d *maxEntropy) ConfidenceValues(text string) ([]Confidence, error) {
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+	if Entropy(values) > d.max {
+		return nil, nil
+	}
+	return values, nil
+}
+
+func (d *maxEntropy) Unwrap() Detector { return d.base }
@@ -0,0 +1,146 @@
+package langid
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// externalDetector delegates detection to a long-running child process.
+//
+// The protocol is line based: every text is written to the child's stdin as a
+// single line (with line breaks and tabs folded into spaces), and the child must
+// answer with exactly one line of whitespace-separated "label score" pairs, e.g.
+// "en 0.93 de 0.05". A "__label__" prefix on labels is stripped, which makes
+// fastText's predict-prob output usable as is. An empty reply means unknown.
+// The child must flush its output after every line.
+type externalDetector struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+func newExternal(opts Options) (*externalDetector, error) {
+	args, err := SplitCommand(opts.Command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("the external backend requires a command")
+	}
+	return startExternal(args)
+}
+
+func startExternal(args []string) (*externalDetector, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting external backend: %w", err)
+	}
+	return &externalDetector{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+func (d *externalDetector) ConfidenceValues(text string) ([]Confidence, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, err := fmt.Fprintln(d.stdin, strings.Join(strings.Fields(text), " ")); err != nil {
+		return nil, fmt.Errorf("writing to external backend: %w", err)
+	}
+	reply, err := d.stdout.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading from external backend: %w", err)
+	}
+	return parseExternalReply(reply)
+}
+
+// Close ends the child's input and waits for it to exit.
+func (d *externalDetector) Close() error {
+	d.stdin.Close()
+	return d.cmd.Wait()
+}
+
+func parseExternalReply(reply string) ([]Confidence, error) {
+	fields := strings.Fields(reply)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("malformed reply from external backend: %q", strings.TrimSpace(reply))
+	}
+	results := make([]Confidence, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		score, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed score from external backend: %q", fields[i+1])
+		}
+		results = append(results, Confidence{
+			Code:  strings.TrimPrefix(fields[i], "__label__"),
+			Score: score,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, nil
+}
+
+// SplitCommand splits a command line into arguments, honouring single and
+// double quotes and backslash escapes the way a POSIX shell would.
+func SplitCommand(s string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in command %q", s)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,60 @@
+package langid
+
+import (
+	"errors"
+	"strings"
+)
+
+// fastTextDetector runs a fastText language identification model such as
+// lid.176.bin or lid.176.ftz through the fasttext binary's predict-prob mode,
+// which already speaks the external backend's line protocol.
+type fastTextDetector struct {
+	*externalDetector
+	languages map[string]bool
+}
+
+func newFastText(opts Options) (*fastTextDetector, error) {
+	if opts.FastTextModel == "" {
+		return nil, errors.New("the fasttext backend requires a model file")
+	}
+	binary := opts.FastTextBinary
+	if binary == "" {
+		binary = "fasttext"
+	}
+	// k = -1 asks for the full distribution, so -a and -l work as with lingua.
+	ext, err := startExternal([]string{binary, "predict-prob", opts.FastTextModel, "-", "-1"})
+	if err != nil {
+		return nil, err
+	}
+	d := &fastTextDetector{externalDetector: ext}
+	if len(opts.Languages) > 0 {
+		d.languages = make(map[string]bool)
+		for _, code := range opts.Languages {
+			d.languages[strings.ToLower(code)] = true
+		}
+	}
+	return d, nil
+}
+
+// ConfidenceValues returns the model's distribution, restricted to the
+// configured languages and renormalized over them when a restriction is set.
+func (d *fastTextDetector) ConfidenceValues(text string) ([]Confidence, error) {
+	results, err := d.externalDetector.ConfidenceValues(text)
+	if err != nil || d.languages == nil {
+		return results, err
+	}
+	filtered := results[:0]
+	total := 0.0
+	for _, result := range results {
+		if d.languages[result.Code] {
+			filtered = append(filtered, result)
+			total += result.Score
+		}
+	}
+	if total > 0 {
+		for i := range filtered {
+			filtered[i].Score /= total
+		}
+	}
+	return filtered, nil
+}
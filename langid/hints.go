@@ -0,0 +1,162 @@
+package langid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultHintWeight is the score added to a hinted language for every
+// occurrence of a term when the hints file gives no weight.
+const DefaultHintWeight = 0.2
+
+// Hints maps domain-specific terms such as product names or jargon to the
+// languages their presence suggests.
+type Hints struct {
+	entries []hint
+}
+
+type hint struct {
+	term   string
+	codes  []string
+	weight float64
+}
+
+// LoadHints reads a hints file, see ParseHints.
+func LoadHints(path string) (*Hints, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	hints, err := ParseHints(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return hints, nil
+}
+
+// ParseHints reads tab-separated lines of the form
+//
+//	term<TAB>code[,code...][<TAB>weight]
+//
+// Terms are matched case-insensitively as whole words and may contain spaces.
+// Empty lines and lines starting with # are ignored.
+func ParseHints(r io.Reader) (*Hints, error) {
+	h := &Hints{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected term<TAB>codes[<TAB>weight]", lineNo)
+		}
+		entry := hint{term: strings.ToLower(strings.TrimSpace(fields[0])), weight: DefaultHintWeight}
+		for _, code := range strings.Split(fields[1], ",") {
+			if code = strings.ToLower(strings.TrimSpace(code)); code != "" {
+				entry.codes = append(entry.codes, code)
+			}
+		}
+		if entry.term == "" || len(entry.codes) == 0 {
+			return nil, fmt.Errorf("line %d: missing term or language codes", lineNo)
+		}
+		if len(fields) == 3 {
+			weight, err := strconv.ParseFloat(strings.TrimSpace(fields[2]), 64)
+			if err != nil || weight < 0 {
+				return nil, fmt.Errorf("line %d: invalid weight %q", lineNo, fields[2])
+			}
+			entry.weight = weight
+		}
+		h.entries = append(h.entries, entry)
+	}
+	return h, scanner.Err()
+}
+
+// Apply boosts the languages hinted at by the terms found in text and
+// renormalizes the distribution. Languages absent from values are not added.
+func (h *Hints) Apply(text string, values []Confidence) []Confidence {
+	lower := strings.ToLower(text)
+	boosts := make(map[string]float64)
+	for _, entry := range h.entries {
+		if n := countWord(lower, entry.term); n > 0 {
+			for _, code := range entry.codes {
+				boosts[code] += float64(n) * entry.weight
+			}
+		}
+	}
+	if len(boosts) == 0 {
+		return values
+	}
+
+	boosted := make([]Confidence, len(values))
+	total := 0.0
+	for i, value := range values {
+		value.Score += boosts[value.Code]
+		boosted[i] = value
+		total += value.Score
+	}
+	if total > 0 {
+		for i := range boosted {
+			boosted[i].Score /= total
+		}
+	}
+	sort.SliceStable(boosted, func(i, j int) bool {
+		return boosted[i].Score > boosted[j].Score
+	})
+	return boosted
+}
+
+// countWord counts the occurrences of term in text that are not directly
+// preceded or followed by a letter or digit.
+func countWord(text, term string) int {
+	count := 0
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return count
+		}
+		start := offset + i
+		end := start + len(term)
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			count++
+		}
+		offset = start + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// WithHints applies hints to every distribution computed by d.
+func WithHints(d Detector, hints *Hints) Detector {
+	return &hinted{base: d, hints: hints}
+}
+
+type hinted struct {
+	base  Detector
+	hints *Hints
+}
+
+func (d *hinted) ConfidenceValues(text string) ([]Confidence, error) {
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+	return d.hints.Apply(text, values), nil
+}
+
+func (d *hinted) Unwrap() Detector { return d.base }
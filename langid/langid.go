@@ -0,0 +1,145 @@
+// Package langid puts the language identification engines used by lingua-cli
+// behind a single Detector interface, so the command's output handling does
+// not depend on which backend produced a result.
+package langid
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Unknown is the code reported for text that could not be attributed to any language.
+const Unknown = "unknown"
+
+// Confidence is the score a backend assigned to a single language.
+type Confidence struct {
+	// Code is the lowercase ISO 639-1 code of the language, or the label
+	// reported verbatim by a backend that does not use ISO 639-1 codes.
+	Code  string
+	Score float64
+}
+
+// Span is a contiguous single-language section of a mixed-language text,
+// delimited by UTF-8 byte offsets.
+type Span struct {
+	Start int
+	End   int
+	Code  string
+}
+
+// Detector is implemented by every language identification backend. All
+// detectors in this package are safe for concurrent use.
+type Detector interface {
+	// ConfidenceValues returns the confidence values for text, sorted by
+	// descending score. An empty slice means no language could be assigned.
+	ConfidenceValues(text string) ([]Confidence, error)
+}
+
+// MultiDetector is implemented by backends that can split mixed-language
+// text into single-language spans.
+type MultiDetector interface {
+	Detector
+	DetectMultiple(text string) ([]Span, error)
+}
+
+// Wrappers such as WithHints post-process the distributions of another
+// detector and expose it through an Unwrap() Detector method.
+type wrapper interface {
+	Unwrap() Detector
+}
+
+// AsMulti returns the detector, or the first detector it wraps, that supports
+// multi-language detection. Post-processing wrappers only apply to confidence
+// distributions, not to spans.
+func AsMulti(d Detector) (MultiDetector, bool) {
+	for d != nil {
+		if md, ok := d.(MultiDetector); ok {
+			return md, true
+		}
+		w, ok := d.(wrapper)
+		if !ok {
+			break
+		}
+		d = w.Unwrap()
+	}
+	return nil, false
+}
+
+// Close releases the resources of the backend behind d, such as the child
+// process of the external backend.
+func Close(d Detector) error {
+	for d != nil {
+		if closer, ok := d.(io.Closer); ok {
+			return closer.Close()
+		}
+		w, ok := d.(wrapper)
+		if !ok {
+			break
+		}
+		d = w.Unwrap()
+	}
+	return nil
+}
+
+// Options configures a backend. Settings a backend has no use for are ignored.
+type Options struct {
+	// Languages restricts detection to these ISO 639-1 codes; empty means all
+	// languages supported by the backend.
+	Languages []string
+	// LowAccuracy trades accuracy for speed (lingua only).
+	LowAccuracy bool
+	// MinRelativeDistance is the minimum distance between the top two
+	// languages (lingua only), between 0.0 and 0.99.
+	MinRelativeDistance float64
+	// Command is the command line of the external backend.
+	Command string
+	// FastTextModel is the path of the fastText language identification model.
+	FastTextModel string
+	// FastTextBinary is the fasttext executable; empty means "fasttext" from PATH.
+	FastTextBinary string
+	// Server is the address of the detection server of the remote backend:
+	// host:port, a URL, or unix: and the path of a socket. Without a path,
+	// the server's detection endpoint is /detect.
+	Server string
+}
+
+// Backends lists the backend names accepted by New.
+var Backends = []string{"lingua", "whatlang", "fasttext", "external", "scripts", "remote"}
+
+// New builds the named backend.
+func New(backend string, opts Options) (Detector, error) {
+	switch backend {
+	case "lingua":
+		return newLingua(opts)
+	case "whatlang":
+		return newWhatlang(opts)
+	case "fasttext":
+		return newFastText(opts)
+	case "external":
+		return newExternal(opts)
+	case "scripts":
+		return scriptShares{}, nil
+	case "remote":
+		return newRemote(opts)
+	}
+	return nil, fmt.Errorf("unknown backend %q (expected one of: %s)", backend, strings.Join(Backends, ", "))
+}
+
+// Supports reports whether the named backend can detect the language with
+// the given code. known is false for the fasttext, external and remote
+// backends, whose languages depend on their model or server. The scripts
+// backend detects no languages.
+func Supports(backend, code string) (supported, known bool) {
+	switch backend {
+	case "lingua":
+		_, ok := LinguaLanguage(code)
+		return ok, true
+	case "whatlang":
+		_, ok := whatlangLanguage(code)
+		return ok, true
+	case "scripts":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,90 @@
+package langid
+
+import (
+	"fmt"
+	"strings"
+
+	lingua "github.com/pemistahl/lingua-go"
+)
+
+// linguaDetector is the default backend, backed by lingua-go.
+type linguaDetector struct {
+	detector lingua.LanguageDetector
+}
+
+func newLingua(opts Options) (*linguaDetector, error) {
+	var languages []lingua.Language
+	for _, code := range opts.Languages {
+		lang, ok := LinguaLanguage(code)
+		if !ok {
+			return nil, fmt.Errorf("unknown ISO 639-1 language code: %q", code)
+		}
+		languages = append(languages, lang)
+	}
+
+	if len(languages) == 1 {
+		return nil, fmt.Errorf("the lingua backend needs at least two languages to choose from")
+	}
+
+	var builder lingua.LanguageDetectorBuilder
+	if len(languages) == 0 {
+		builder = lingua.NewLanguageDetectorBuilder().FromAllLanguages()
+	} else {
+		builder = lingua.NewLanguageDetectorBuilder().FromLanguages(languages...)
+	}
+
+	if opts.LowAccuracy {
+		builder = builder.WithLowAccuracyMode()
+	}
+	if opts.MinRelativeDistance != 0 {
+		if opts.MinRelativeDistance < 0 || opts.MinRelativeDistance > 0.99 {
+			return nil, fmt.Errorf("minimum relative distance must lie in between 0.0 and 0.99")
+		}
+		builder = builder.WithMinimumRelativeDistance(opts.MinRelativeDistance)
+	}
+
+	return &linguaDetector{detector: builder.Build()}, nil
+}
+
+func (d *linguaDetector) ConfidenceValues(text string) ([]Confidence, error) {
+	values := d.detector.ComputeLanguageConfidenceValues(text)
+	results := make([]Confidence, len(values))
+	for i, value := range values {
+		results[i] = Confidence{Code: LinguaCode(value.Language()), Score: value.Value()}
+	}
+	return results, nil
+}
+
+func (d *linguaDetector) DetectMultiple(text string) ([]Span, error) {
+	results := d.detector.DetectMultipleLanguagesOf(text)
+	spans := make([]Span, len(results))
+	for i, result := range results {
+		spans[i] = Span{
+			Start: result.StartIndex(),
+			End:   result.EndIndex(),
+			Code:  LinguaCode(result.Language()),
+		}
+	}
+	return spans, nil
+}
+
+// LinguaLanguage maps an ISO 639-1 code string to a lingua.Language.
+// Returns lingua.Unknown and false if the code is not recognized.
+func LinguaLanguage(code string) (lingua.Language, bool) {
+	upper := strings.ToUpper(code)
+	for _, lang := range lingua.AllLanguages() {
+		if lang.IsoCode639_1().String() == upper {
+			return lang, true
+		}
+	}
+	return lingua.Unknown, false
+}
+
+// LinguaCode returns the lowercase ISO 639-1 code string for a language,
+// matching the output format of the Rust lingua-cli.
+func LinguaCode(lang lingua.Language) string {
+	if lang == lingua.Unknown {
+		return Unknown
+	}
+	return strings.ToLower(lang.IsoCode639_1().String())
+}
@@ -0,0 +1,145 @@
+package linguahttp
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the default limit on the number of responses cached.
+const DefaultCacheSize = 10000
+
+// DefaultCacheTTL is the default time a response is cached.
+const DefaultCacheTTL = 10 * time.Minute
+
+// Cache keeps the responses of NewHandler for repeated texts, such as UI
+// labels or canned replies, so that they are only classified once. Entries
+// are keyed by a hash of the text and of everything else the response
+// depends on: the pool, the all and spans parameters and the weights of
+// Config.Prior and Config.Sessions. The zero value is ready to use with the
+// defaults; a Cache must not be copied after first use.
+type Cache struct {
+	// Size limits the number of responses cached, the least recently used
+	// one being dropped for a new one, DefaultCacheSize if zero.
+	Size int
+	// TTL is how long a response is cached, DefaultCacheTTL if zero.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	// order holds the cache entries, most recently used first.
+	order  *list.List
+	hits   uint64
+	misses uint64
+}
+
+// CacheStats are the counters of a Cache.
+type CacheStats struct {
+	// Hits and Misses count the lookups that found a response or not.
+	Hits, Misses uint64
+	// Entries is the number of responses cached, expired ones included
+	// until they are looked up or evicted.
+	Entries int
+}
+
+// HitRate returns the share of lookups that found a response, 0 before the
+// first one.
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	body    response
+	expires time.Time
+}
+
+// Stats returns the counters of c, for metrics.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := CacheStats{Hits: c.hits, Misses: c.misses}
+	if c.order != nil {
+		stats.Entries = c.order.Len()
+	}
+	return stats
+}
+
+// cacheKey returns the key of the response to text with the given pool,
+// parameters and prior weights.
+func cacheKey(pool, text string, all, spans bool, priors []map[string]float64) [sha256.Size]byte {
+	hash := sha256.New()
+	hash.Write([]byte(pool + "\x00" + strconv.FormatBool(all) + "," + strconv.FormatBool(spans) + "\x00"))
+	for _, weights := range priors {
+		codes := make([]string, 0, len(weights))
+		for code := range weights {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			hash.Write([]byte(code + "=" + strconv.FormatFloat(weights[code], 'g', -1, 64) + ","))
+		}
+		hash.Write([]byte{0})
+	}
+	hash.Write([]byte(text))
+	var key [sha256.Size]byte
+	hash.Sum(key[:0])
+	return key
+}
+
+// get returns the response cached for key.
+func (c *Cache) get(key [sha256.Size]byte) (response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	element, ok := c.entries[key]
+	if ok && time.Now().After(element.Value.(*cacheEntry).expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return response{}, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).body, true
+}
+
+// put caches body for key.
+func (c *Cache) put(key [sha256.Size]byte, body response) {
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	size := c.Size
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key, body, time.Now().Add(ttl)})
+	for c.order.Len() > size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *Cache) init() {
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]*list.Element)
+		c.order = list.New()
+	}
+}
@@ -0,0 +1,124 @@
+package linguahttp
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// contentEncodings are the content codings of request bodies NewHandler
+// decompresses and of the responses it compresses, the first preferred.
+var contentEncodings = []string{"gzip", "deflate"}
+
+// compressMinBytes is the size from which responses are compressed. Smaller
+// ones, such as the best language of a single text, would hardly shrink.
+const compressMinBytes = 1024
+
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding, expected " + strings.Join(contentEncodings, " or "))
+
+// decompressBody replaces the body of r, if its Content-Encoding is one of
+// contentEncodings, by a reader of the decompressed body, and removes the
+// header. Deflate bodies may be zlib streams, as HTTP specifies, or raw
+// deflate data, as some clients send.
+func decompressBody(r *http.Request) error {
+	coding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if coding == "" || coding == "identity" || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	var reader io.Reader
+	switch coding {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return fmt.Errorf("invalid gzip request body: %w", err)
+		}
+		reader = gz
+	case "deflate":
+		buffered := bufio.NewReader(r.Body)
+		header, _ := buffered.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint(header[0])<<8|uint(header[1]))%31 == 0 {
+			zr, err := zlib.NewReader(buffered)
+			if err != nil {
+				return fmt.Errorf("invalid deflate request body: %w", err)
+			}
+			reader = zr
+		} else {
+			reader = flate.NewReader(buffered)
+		}
+	default:
+		return errUnsupportedEncoding
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{reader, r.Body}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	r.ContentLength = -1
+	return nil
+}
+
+// negotiateEncoding returns the coding of contentEncodings the
+// Accept-Encoding header of r prefers, or "" if it accepts none of them.
+// Codings it does not name are accepted with the quality of "*", if any.
+func negotiateEncoding(r *http.Request) string {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(name), "x-"))
+		if name == "" {
+			continue
+		}
+		quality := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			var err error
+			if quality, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+				continue
+			}
+		}
+		qualities[name] = quality
+	}
+	best, bestQuality := "", 0.0
+	for _, name := range contentEncodings {
+		quality, ok := qualities[name]
+		if !ok {
+			quality = qualities["*"]
+		}
+		if quality > bestQuality {
+			best, bestQuality = name, quality
+		}
+	}
+	return best
+}
+
+// writeBody writes data, compressed in the coding the client of r prefers
+// if there is one and data is large enough. The Content-Type must already
+// be set.
+func writeBody(w http.ResponseWriter, r *http.Request, data []byte, compress bool) {
+	coding := ""
+	if compress && len(data) >= compressMinBytes {
+		coding = negotiateEncoding(r)
+	}
+	var out io.WriteCloser
+	switch coding {
+	case "gzip":
+		out = gzip.NewWriter(w)
+	case "deflate":
+		out = zlib.NewWriter(w)
+	default:
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+		return
+	}
+	w.Header().Set("Content-Encoding", coding)
+	w.Header().Del("Content-Length")
+	out.Write(data)
+	out.Close()
+}
@@ -0,0 +1,86 @@
+package linguahttp
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures the cross-origin requests AllowCORS allows, so that
+// browser-based tools on other origins can call a handler directly.
+type CORS struct {
+	// AllowedOrigins are the origins allowed to call the handler, such as
+	// "https://tools.example.com", "https://*.example.com" for all
+	// subdomains of a site, or "*" for any origin.
+	AllowedOrigins []string
+	// AllowedMethods are the methods allowed, POST if empty.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed, Content-Type,
+	// Content-Encoding, Accept, Accept-Language and X-Request-ID if empty.
+	AllowedHeaders []string
+	// MaxAge is how long browsers may cache the answer to a preflight
+	// request, as they choose if zero.
+	MaxAge time.Duration
+}
+
+// AllowCORS returns a handler answering the preflight requests of the
+// origins cors allows and adding the CORS headers to their other requests,
+// which next handles. Requests of other origins are passed on without them,
+// so that browsers withhold the responses.
+func AllowCORS(next http.Handler, cors CORS) http.Handler {
+	methods := cors.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost}
+	}
+	headers := cors.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "Content-Encoding", "Accept", "Accept-Language", RequestIDHeader}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !cors.allows(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if slices.Contains(cors.AllowedOrigins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+		}
+		method := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || method == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// A preflight request, answered here.
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if slices.Contains(methods, method) {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+			if cors.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cors.MaxAge.Seconds())))
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allows reports whether origin is one of the allowed origins.
+func (cors CORS) allows(origin string) bool {
+	for _, allowed := range cors.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if scheme, domain, ok := strings.Cut(allowed, "://*."); ok {
+			rest, found := strings.CutPrefix(strings.ToLower(origin), strings.ToLower(scheme)+"://")
+			if found && strings.HasSuffix(rest, "."+strings.ToLower(domain)) {
+				return true
+			}
+		}
+	}
+	return false
+}
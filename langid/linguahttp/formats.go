@@ -0,0 +1,136 @@
+package linguahttp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/internal/msgpack"
+)
+
+// responseTypes are the media types Handler answers in, the first by
+// default.
+var responseTypes = []string{"application/json", "text/csv", "text/plain", "application/msgpack", "application/x-msgpack"}
+
+// negotiate returns the media type of responseTypes the Accept header of r
+// prefers, or "" if it accepts none of them.
+func negotiate(r *http.Request) string {
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return responseTypes[0]
+	}
+	type mediaRange struct {
+		mediaType string
+		quality   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality > 0 {
+			ranges = append(ranges, mediaRange{mediaType, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].quality > ranges[j].quality })
+	for _, accepted := range ranges {
+		for _, mediaType := range responseTypes {
+			kind, _, _ := strings.Cut(mediaType, "/")
+			if accepted.mediaType == mediaType || accepted.mediaType == kind+"/*" || accepted.mediaType == "*/*" {
+				return mediaType
+			}
+		}
+	}
+	return ""
+}
+
+// writeResponse writes body in the given media type of responseTypes,
+// compressed as the client of r accepts unless compress is false. The
+// delimited types have a line per language, of the best one or, with
+// confidences, of every one, and leave out spans.
+func writeResponse(w http.ResponseWriter, r *http.Request, mediaType string, body response, compress bool) {
+	var out bytes.Buffer
+	rows := []confidence{{body.Language, body.Score}}
+	if body.Confidences != nil {
+		rows = body.Confidences
+	}
+	switch mediaType {
+	case "text/csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		records := csv.NewWriter(&out)
+		records.Write([]string{"language", "score"})
+		for _, row := range rows {
+			records.Write([]string{row.Language, formatScore(row.Score)})
+		}
+		records.Flush()
+	case "text/plain":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, row := range rows {
+			fmt.Fprintf(&out, "%s\t%s\n", row.Language, formatScore(row.Score))
+		}
+	case "application/msgpack", "application/x-msgpack":
+		var b msgpack.Buffer
+		fields := 2
+		if body.Confidences != nil {
+			fields++
+		}
+		if body.Spans != nil {
+			fields++
+		}
+		b.MapHeader(fields)
+		b.Str("language")
+		b.Str(body.Language)
+		b.Str("score")
+		b.Float(body.Score)
+		if body.Confidences != nil {
+			b.Str("confidences")
+			b.ArrayHeader(len(body.Confidences))
+			for _, value := range body.Confidences {
+				b.MapHeader(2)
+				b.Str("language")
+				b.Str(value.Language)
+				b.Str("score")
+				b.Float(value.Score)
+			}
+		}
+		if body.Spans != nil {
+			b.Str("spans")
+			b.ArrayHeader(len(body.Spans))
+			for _, s := range body.Spans {
+				b.MapHeader(4)
+				b.Str("language")
+				b.Str(s.Language)
+				b.Str("start")
+				b.Int(int64(s.Start))
+				b.Str("end")
+				b.Int(int64(s.End))
+				b.Str("text")
+				b.Str(s.Text)
+			}
+		}
+		w.Header().Set("Content-Type", mediaType)
+		out.Write(b.Bytes())
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(&out).Encode(body)
+	}
+	writeBody(w, r, out.Bytes(), compress)
+}
+
+// formatScore formats a score as lingua-cli prints it, with 16 decimals.
+func formatScore(score float64) string {
+	return strconv.FormatFloat(score, 'f', 16, 64)
+}
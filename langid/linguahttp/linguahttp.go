@@ -0,0 +1,402 @@
+// Package linguahttp embeds language detection in net/http servers: a
+// middleware that annotates requests with the language of their body, and a
+// handler serving detection requests.
+package linguahttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+	"golang.org/x/text/language"
+)
+
+// DefaultMaxBytes is the default limit on the part of a request body that
+// is read for detection.
+const DefaultMaxBytes = 1 << 20
+
+// DefaultPriorMaxLength is the default length in characters up to which
+// NewHandler weighs the scores of texts by the languages of the client.
+const DefaultPriorMaxLength = 30
+
+// DefaultPriorWeight is the default strength of the prior of Config.Prior.
+const DefaultPriorWeight = 3.0
+
+// ErrorCodeHeader is the response header by which NewHandler tells clients
+// the reason of some errors, such as ErrorNoText, without them having to
+// parse the message in the body.
+const ErrorCodeHeader = "X-Error-Code"
+
+// ErrorNoText is the ErrorCodeHeader of the 400 Bad Request to requests
+// without text to classify, such as a body of whitespace.
+const ErrorNoText = "no_text"
+
+type contextKey struct{}
+
+// Config configures Middleware.
+type Config struct {
+	// Field is the dotted path of the text in JSON bodies, such as
+	// "payload.text", or the name of the field in form bodies. Bodies of
+	// other text types are classified whole. Empty means "text".
+	Field string
+	// MaxBytes limits the part of the body that is read, DefaultMaxBytes if
+	// zero. Longer bodies are classified by their beginning.
+	MaxBytes int64
+	// Header, if set, is a request header set to the detected language, for
+	// handlers that only look at headers, e.g. "X-Detected-Language".
+	Header string
+	// Options apply to every detection.
+	Options []langid.Option
+	// Prior makes NewHandler break near-ties on short texts, such as
+	// one-word queries, in favour of the languages of the client: those of
+	// a "hint" field or query parameter, a comma-separated list of language
+	// codes, or else those of the Accept-Language header. Their scores are
+	// multiplied by 1 plus PriorWeight, times the quality value of the
+	// language in Accept-Language. The middleware ignores it.
+	Prior bool
+	// PriorMaxLength is the length in characters up to which texts are
+	// short for Prior, DefaultPriorMaxLength if zero.
+	PriorMaxLength int
+	// PriorWeight is the strength of the prior, DefaultPriorWeight if zero.
+	PriorWeight float64
+	// Sessions, if set, makes NewHandler also weigh the short texts of a
+	// session by the languages of its earlier texts, with the strength
+	// PriorWeight, whether or not Prior is set. The middleware ignores it.
+	Sessions *Sessions
+	// Pools are named detectors with their own options, which NewHandler
+	// uses instead of its detector and Options for requests naming them in
+	// a "pool" query parameter or body field, so that one handler serves
+	// clients with different needs. The middleware ignores them.
+	Pools map[string]Pool
+	// Cache, if set, makes NewHandler answer repeated texts from a cache of
+	// its responses. The middleware ignores it.
+	Cache *Cache
+	// CORS, if set, makes NewHandler allow cross-origin requests, see
+	// AllowCORS. The middleware ignores it.
+	CORS *CORS
+	// DisableCompression makes NewHandler answer uncompressed even to
+	// clients accepting gzip or deflate. Compressed request bodies are
+	// decompressed either way. The middleware ignores it.
+	DisableCompression bool
+	// Observe, if set, is called by NewHandler with every request it
+	// answers with a result, the text classified and the result, such as
+	// to write an audit log. Results from Cache have only their language
+	// and score. The middleware ignores it.
+	Observe func(r *http.Request, text string, result langid.Result)
+}
+
+// Middleware returns middleware that detects the language of the text in a
+// request's body with d and stores the result in the request's context,
+// where FromContext finds it. The body remains readable by the next handler.
+// Requests without text, and those whose detection fails, are passed on
+// without a result.
+func Middleware(d langid.Detector, cfg Config) func(http.Handler) http.Handler {
+	if cfg.Field == "" {
+		cfg.Field = "text"
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultMaxBytes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			text, _, ok := readText(r, cfg)
+			if ok {
+				result, err := langid.Detect(r.Context(), d, text, cfg.Options...)
+				if err == nil {
+					r = r.WithContext(context.WithValue(r.Context(), contextKey{}, result))
+					if cfg.Header != "" {
+						r.Header.Set(cfg.Header, result.Language)
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Detector wraps next in Middleware with the default configuration,
+// classifying the "text" field of JSON and form bodies.
+func Detector(d langid.Detector, next http.Handler) http.Handler {
+	return Middleware(d, Config{})(next)
+}
+
+// FromContext returns the result Middleware stored in ctx.
+func FromContext(ctx context.Context) (langid.Result, bool) {
+	result, ok := ctx.Value(contextKey{}).(langid.Result)
+	return result, ok
+}
+
+// readText reads the text to classify from the body of r and puts back
+// what it read, so that the body can be read again. It also returns the
+// part of the body read, for bodyField.
+func readText(r *http.Request, cfg Config) (string, []byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return "", nil, false
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, cfg.MaxBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+	if err != nil {
+		return "", nil, false
+	}
+
+	text := bodyField(r, data, cfg.Field)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "text/") {
+		text = string(data)
+	}
+	return text, data, strings.TrimSpace(text) != ""
+}
+
+// bodyField returns the string at the dotted path field of a JSON body, or
+// the field of a form body, or "" if there is none.
+func bodyField(r *http.Request, data []byte, field string) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var body any
+		if json.Unmarshal(data, &body) != nil {
+			return ""
+		}
+		for _, key := range strings.Split(field, ".") {
+			object, ok := body.(map[string]any)
+			if !ok {
+				return ""
+			}
+			body = object[key]
+		}
+		text, _ := body.(string)
+		return text
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(data))
+		if err != nil {
+			return ""
+		}
+		return values.Get(field)
+	}
+	return ""
+}
+
+// clientLanguages returns the weights Config.Prior gives the languages of
+// the client of r, or nil if it names none.
+func clientLanguages(r *http.Request, body []byte, strength float64) map[string]float64 {
+	hint := r.URL.Query().Get("hint")
+	if hint == "" {
+		hint = bodyField(r, body, "hint")
+	}
+	weights := make(map[string]float64)
+	if hint != "" {
+		for _, code := range strings.Split(hint, ",") {
+			if tag, err := language.Parse(strings.TrimSpace(code)); err == nil {
+				base, _ := tag.Base()
+				weights[base.String()] = 1 + strength
+			}
+		}
+		return weights
+	}
+	tags, qualities, _ := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	for i, tag := range tags {
+		base, confidence := tag.Base()
+		if confidence == language.No || qualities[i] <= 0 {
+			continue
+		}
+		if weight := 1 + strength*float64(qualities[i]); weight > weights[base.String()] {
+			weights[base.String()] = weight
+		}
+	}
+	if len(weights) == 0 {
+		return nil
+	}
+	return weights
+}
+
+// response is the body returned by Handler.
+type response struct {
+	// Language is the best language, or "unknown".
+	Language    string       `json:"language"`
+	Score       float64      `json:"score"`
+	Confidences []confidence `json:"confidences,omitempty"`
+	Spans       []span       `json:"spans,omitempty"`
+}
+
+type confidence struct {
+	Language string  `json:"language"`
+	Score    float64 `json:"score"`
+}
+
+// span is a single-language part of the text, Start and End being byte
+// offsets into the text as preprocessed by the detection options.
+type span struct {
+	Language string `json:"language"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Text     string `json:"text"`
+}
+
+// Handler returns a handler classifying the text of POST requests with d,
+// given as in Middleware with the default configuration, and answering with
+// a JSON object of the best language and its score, and the confidences of
+// all languages if the query has all=1. With spans=1 it also splits the text
+// into single-language spans, if d supports it (see langid.DetectMultiple).
+// Clients may ask for CSV, plain text
+// (tab-separated, as lingua-cli prints results) or MessagePack instead in the
+// Accept header. Request bodies may be compressed with gzip or deflate, as
+// their Content-Encoding says, and responses of 1 KiB or more are compressed
+// for clients accepting either in Accept-Encoding.
+func Handler(d langid.Detector, opts ...langid.Option) http.Handler {
+	return NewHandler(d, Config{Options: opts})
+}
+
+// NewHandler returns a handler like Handler, configured by cfg, whose Header
+// does not apply.
+func NewHandler(d langid.Detector, cfg Config) http.Handler {
+	if cfg.Field == "" {
+		cfg.Field = "text"
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultMaxBytes
+	}
+	if cfg.PriorMaxLength <= 0 {
+		cfg.PriorMaxLength = DefaultPriorMaxLength
+	}
+	if cfg.PriorWeight <= 0 {
+		cfg.PriorWeight = DefaultPriorWeight
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Accept-Encoding")
+		mediaType := negotiate(r)
+		if mediaType == "" {
+			http.Error(w, "no acceptable response type, expected one of "+strings.Join(responseTypes, ", "), http.StatusNotAcceptable)
+			return
+		}
+		if err := decompressBody(r); errors.Is(err, errUnsupportedEncoding) {
+			w.Header().Set("Accept-Encoding", strings.Join(contentEncodings, ", "))
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		text, data, ok := readText(r, cfg)
+		if !ok {
+			w.Header().Set(ErrorCodeHeader, ErrorNoText)
+			http.Error(w, "no text to classify in the request body", http.StatusBadRequest)
+			return
+		}
+		name, base, opts, err := pool(r, data, cfg, d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		detector := base
+		var priors []map[string]float64
+		short := utf8.RuneCountInString(strings.TrimSpace(text)) <= cfg.PriorMaxLength
+		if cfg.Prior && short {
+			if weights := clientLanguages(r, data, cfg.PriorWeight); weights != nil {
+				detector = langid.WithPrior(detector, weights)
+				priors = append(priors, weights)
+			}
+		}
+		session := ""
+		if cfg.Sessions != nil {
+			session = cfg.Sessions.key(r, data)
+		}
+		if session != "" && short {
+			if weights := cfg.Sessions.weights(session, cfg.PriorWeight); weights != nil {
+				detector = langid.WithPrior(detector, weights)
+				priors = append(priors, weights)
+			}
+		}
+		all, withSpans := r.URL.Query().Get("all") == "1", r.URL.Query().Get("spans") == "1"
+		var key [sha256.Size]byte
+		if cfg.Cache != nil {
+			key = cacheKey(name, text, all, withSpans, priors)
+			if body, ok := cfg.Cache.get(key); ok {
+				if session != "" {
+					cfg.Sessions.observe(session, langid.Result{Language: body.Language, Score: body.Score})
+				}
+				w.Header().Set("X-Cache", "hit")
+				writeResponse(w, r, mediaType, body, !cfg.DisableCompression)
+				if cfg.Observe != nil {
+					cfg.Observe(r, text, langid.Result{Language: body.Language, Score: body.Score})
+				}
+				return
+			}
+			w.Header().Set("X-Cache", "miss")
+		}
+		body, result, err := answer(r.Context(), base, detector, text, opts, all, withSpans)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if session != "" {
+			cfg.Sessions.observe(session, result)
+		}
+		if cfg.Cache != nil {
+			cfg.Cache.put(key, body)
+		}
+		writeResponse(w, r, mediaType, body, !cfg.DisableCompression)
+		if cfg.Observe != nil {
+			cfg.Observe(r, text, result)
+		}
+	})
+	if cfg.CORS != nil {
+		return AllowCORS(handler, *cfg.CORS)
+	}
+	return handler
+}
+
+// answer classifies text with detector and builds the response, with the
+// confidences of all languages if all is set and the spans of base, the
+// detector without priors, if withSpans is set and base supports them.
+func answer(ctx context.Context, base, detector langid.Detector, text string, opts []langid.Option, all, withSpans bool) (response, langid.Result, error) {
+	result, err := langid.Detect(ctx, detector, text, opts...)
+	if err != nil {
+		return response{}, result, err
+	}
+	body := response{Language: result.Language, Score: result.Score}
+	if all {
+		body.Confidences = []confidence{}
+		for _, value := range result.Confidences {
+			body.Confidences = append(body.Confidences, confidence{value.Code, value.Score})
+		}
+	}
+	if withSpans {
+		// Span offsets refer to the preprocessed text, which the last
+		// option records.
+		var prepared string
+		opts := append(slices.Clip(opts), langid.WithPreprocessor(func(s string) string {
+			prepared = s
+			return s
+		}))
+		spans, err := langid.DetectMultiple(ctx, base, text, opts...)
+		switch {
+		case errors.Is(err, langid.ErrNoMultiSupport):
+		case err != nil:
+			return response{}, result, err
+		default:
+			body.Spans = []span{}
+			for _, s := range spans {
+				body.Spans = append(body.Spans, span{s.Code, s.Start, s.End, prepared[s.Start:s.End]})
+			}
+		}
+	}
+	return body, result, nil
+}
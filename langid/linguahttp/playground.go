@@ -0,0 +1,28 @@
+package linguahttp
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+//go:embed playground.html
+var playgroundPage string
+
+var playgroundTemplate = template.Must(template.New("playground").Parse(playgroundPage))
+
+// Playground returns a handler serving a page where users paste text and
+// see the confidences of the languages and, if the detector supports it, the
+// single-language spans of the text highlighted. The page posts the text to
+// endpoint, a URL served by NewHandler with the default Field.
+func Playground(endpoint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		playgroundTemplate.Execute(w, endpoint)
+	})
+}
@@ -0,0 +1,34 @@
+package linguahttp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// Pool is a detector with its own options, such as another language set,
+// accuracy mode or threshold, that requests of NewHandler select by name.
+type Pool struct {
+	Detector langid.Detector
+	// Options apply to the detections of the pool instead of
+	// Config.Options.
+	Options []langid.Option
+}
+
+// pool returns the detector and options of the pool r names in its "pool"
+// query parameter or body field, those of the handler if it names none.
+func pool(r *http.Request, body []byte, cfg Config, d langid.Detector) (string, langid.Detector, []langid.Option, error) {
+	name := r.URL.Query().Get("pool")
+	if name == "" {
+		name = bodyField(r, body, "pool")
+	}
+	if name == "" {
+		return "", d, cfg.Options, nil
+	}
+	p, ok := cfg.Pools[name]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unknown pool %q", name)
+	}
+	return name, p.Detector, p.Options, nil
+}
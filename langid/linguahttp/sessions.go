@@ -0,0 +1,156 @@
+package linguahttp
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// DefaultSessionHeader is the default request header naming the session.
+const DefaultSessionHeader = "X-Session-ID"
+
+// DefaultSessionDecay is the default factor by which the languages of a
+// session's earlier texts fade with every new text.
+const DefaultSessionDecay = 0.8
+
+// DefaultSessionTTL is the default time a session is kept after its last
+// text.
+const DefaultSessionTTL = 30 * time.Minute
+
+// DefaultMaxSessions is the default limit on the number of sessions kept.
+const DefaultMaxSessions = 10000
+
+// Sessions keeps rolling statistics of the languages of each session's
+// texts, so that NewHandler can weigh the short texts of a session, such as
+// one-word chat messages, by the languages of its earlier ones. A session is
+// named by the "session" query parameter or body field, or else by the
+// Header of the request. The zero value is ready to use with the defaults;
+// a Sessions must not be copied after first use.
+type Sessions struct {
+	// Header is the request header naming the session, such as a chat or
+	// client key, DefaultSessionHeader if empty.
+	Header string
+	// Decay is the factor by which the earlier texts of a session count
+	// less with every new one, DefaultSessionDecay if zero.
+	Decay float64
+	// TTL is how long a session is kept after its last text,
+	// DefaultSessionTTL if zero.
+	TTL time.Duration
+	// MaxSessions limits the number of sessions kept, the least recently
+	// used one being dropped for a new one, DefaultMaxSessions if zero.
+	MaxSessions int
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session holds the decayed counts of the languages of a session's texts,
+// each text counting with the score of its best language.
+type session struct {
+	counts map[string]float64
+	total  float64
+	last   time.Time
+}
+
+// key returns the session of r, or "" if it names none.
+func (s *Sessions) key(r *http.Request, body []byte) string {
+	if key := r.URL.Query().Get("session"); key != "" {
+		return key
+	}
+	if key := bodyField(r, body, "session"); key != "" {
+		return key
+	}
+	header := s.Header
+	if header == "" {
+		header = DefaultSessionHeader
+	}
+	return r.Header.Get(header)
+}
+
+// weights returns the prior weights of the session key: the score of each
+// of its languages is multiplied by 1 plus strength times the language's
+// share of the session's texts. It returns nil for new sessions.
+func (s *Sessions) weights(key string, strength float64) map[string]float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.lookup(key, time.Now())
+	if current == nil || current.total <= 0 {
+		return nil
+	}
+	weights := make(map[string]float64, len(current.counts))
+	for code, count := range current.counts {
+		weights[code] = 1 + strength*count/current.total
+	}
+	return weights
+}
+
+// observe records the result of a text of the session key.
+func (s *Sessions) observe(key string, result langid.Result) {
+	if !result.Known() {
+		return
+	}
+	decay := s.Decay
+	if decay <= 0 {
+		decay = DefaultSessionDecay
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.lookup(key, now)
+	if current == nil {
+		s.evict(now)
+		current = &session{counts: make(map[string]float64)}
+		s.sessions[key] = current
+	}
+	for code := range current.counts {
+		current.counts[code] *= decay
+	}
+	current.counts[result.Language] += result.Score
+	current.total = current.total*decay + result.Score
+	current.last = now
+}
+
+// lookup returns the session key, or nil if it is unknown or expired.
+func (s *Sessions) lookup(key string, now time.Time) *session {
+	if s.sessions == nil {
+		s.sessions = make(map[string]*session)
+	}
+	current := s.sessions[key]
+	if current != nil && now.Sub(current.last) > s.ttl() {
+		delete(s.sessions, key)
+		return nil
+	}
+	return current
+}
+
+// evict makes room for a new session, dropping the expired sessions, or
+// else the least recently used one, when the limit is reached.
+func (s *Sessions) evict(now time.Time) {
+	limit := s.MaxSessions
+	if limit <= 0 {
+		limit = DefaultMaxSessions
+	}
+	if len(s.sessions) < limit {
+		return
+	}
+	oldest := ""
+	for key, current := range s.sessions {
+		if now.Sub(current.last) > s.ttl() {
+			delete(s.sessions, key)
+		} else if oldest == "" || current.last.Before(s.sessions[oldest].last) {
+			oldest = key
+		}
+	}
+	if len(s.sessions) >= limit {
+		delete(s.sessions, oldest)
+	}
+}
+
+func (s *Sessions) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultSessionTTL
+	}
+	return s.TTL
+}
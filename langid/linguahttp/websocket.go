@@ -0,0 +1,122 @@
+package linguahttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+	"golang.org/x/net/websocket"
+)
+
+// webSocketError is the message NewWebSocketHandler answers a text with
+// when it can not classify it.
+type webSocketError struct {
+	Error string `json:"error"`
+	// Code is ErrorNoText for texts without anything to classify.
+	Code string `json:"code,omitempty"`
+}
+
+// NewWebSocketHandler returns a handler of WebSocket connections, for
+// browser-based tools and dashboards detecting languages live: every text
+// message a client sends is classified with d and answered, in order, by a
+// text message with the JSON object Handler answers with, or an object with
+// an "error" field. The query of the connection's URL selects the pool, all
+// and spans as for NewHandler, and its hint or Accept-Language header the
+// languages of Config.Prior. Config.Field, Header, Sessions, Cache and
+// DisableCompression do not apply; MaxBytes limits messages. Browsers on
+// other origins than the handler's may only connect if Config.CORS allows
+// their origin. The connection is closed when the context of its request
+// is done.
+func NewWebSocketHandler(d langid.Detector, cfg Config) http.Handler {
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultMaxBytes
+	}
+	if cfg.PriorMaxLength <= 0 {
+		cfg.PriorMaxLength = DefaultPriorMaxLength
+	}
+	if cfg.PriorWeight <= 0 {
+		cfg.PriorWeight = DefaultPriorWeight
+	}
+	return websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				// Not a browser.
+				return nil
+			}
+			u, err := url.Parse(origin)
+			if err != nil {
+				return err
+			}
+			if !strings.EqualFold(u.Host, r.Host) && (cfg.CORS == nil || !cfg.CORS.allows(origin)) {
+				return fmt.Errorf("origin %s not allowed", origin)
+			}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			serveWebSocket(ws, d, cfg)
+		},
+	}
+}
+
+// serveWebSocket answers the texts of the connection ws until it is closed.
+func serveWebSocket(ws *websocket.Conn, d langid.Detector, cfg Config) {
+	defer ws.Close()
+	r := ws.Request()
+	ctx := r.Context()
+	go func() {
+		<-ctx.Done()
+		ws.Close()
+	}()
+	ws.MaxPayloadBytes = int(cfg.MaxBytes)
+	_, base, opts, err := pool(r, nil, cfg, d)
+	if err != nil {
+		websocket.JSON.Send(ws, webSocketError{Error: err.Error()})
+		return
+	}
+	var weights map[string]float64
+	if cfg.Prior {
+		weights = clientLanguages(r, nil, cfg.PriorWeight)
+	}
+	all, withSpans := r.URL.Query().Get("all") == "1", r.URL.Query().Get("spans") == "1"
+	for {
+		var text string
+		err := websocket.Message.Receive(ws, &text)
+		switch {
+		case errors.Is(err, websocket.ErrFrameTooLarge):
+			// The rest of the message is discarded.
+			if websocket.JSON.Send(ws, webSocketError{Error: err.Error()}) != nil {
+				return
+			}
+			continue
+		case err != nil:
+			return
+		case strings.TrimSpace(text) == "":
+			if websocket.JSON.Send(ws, webSocketError{Error: "no text to classify in the message", Code: ErrorNoText}) != nil {
+				return
+			}
+			continue
+		}
+		detector := base
+		if weights != nil && utf8.RuneCountInString(strings.TrimSpace(text)) <= cfg.PriorMaxLength {
+			detector = langid.WithPrior(detector, weights)
+		}
+		var reply any
+		body, result, err := answer(ctx, base, detector, text, opts, all, withSpans)
+		if err != nil {
+			reply = webSocketError{Error: err.Error()}
+		} else {
+			reply = body
+		}
+		if websocket.JSON.Send(ws, reply) != nil {
+			return
+		}
+		if err == nil && cfg.Observe != nil {
+			cfg.Observe(r, text, result)
+		}
+	}
+}
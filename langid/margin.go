@@ -0,0 +1,39 @@
+package langid
+
+// WithMinDistance makes d report no language at all when the scores of its
+// two best languages are less than distance apart, instead of guessing
+// between near-ties.
+func WithMinDistance(d Detector, distance float64) Detector {
+	return &minDistance{base: d, distance: distance}
+}
+
+type minDistance struct {
+	base     Detector
+	distance float64
+}
+
+func (d *minDistance) ConfidenceValues(text string) ([]Confidence, error) {
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+	if Margin(values) < d.distance {
+		return nil, nil
+	}
+	return values, nil
+}
+
+func (d *minDistance) Unwrap() Detector { return d.base }
+
+// Margin returns the difference between the two best scores of a
+// distribution sorted in descending order. A single language has a margin of
+// its own score.
+func Margin(values []Confidence) float64 {
+	switch len(values) {
+	case 0:
+		return 0
+	case 1:
+		return values[0].Score
+	}
+	return values[0].Score - values[1].Score
+}
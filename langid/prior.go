@@ -0,0 +1,126 @@
+package langid
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithPrior weighs the scores of d by prior odds of the languages, such as
+// the languages a user is known to write: the score of every language in
+// weights is multiplied by its weight, and the distribution rescaled to sum
+// to 1. Languages not in weights keep a weight of 1. Priors matter most for
+// short texts, whose scores are close; on longer texts the evidence of the
+// text outweighs them.
+func WithPrior(d Detector, weights map[string]float64) Detector {
+	return &prior{base: d, weights: weights}
+}
+
+type prior struct {
+	base    Detector
+	weights map[string]float64
+}
+
+func (d *prior) ConfidenceValues(text string) ([]Confidence, error) {
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil || len(values) == 0 {
+		return values, err
+	}
+	return ApplyPrior(values, d.weights), nil
+}
+
+// ApplyPrior weighs confidence values as WithPrior does, for results
+// obtained without it, such as when the prior differs from text to text.
+func ApplyPrior(values []Confidence, weights map[string]float64) []Confidence {
+	if len(values) == 0 {
+		return values
+	}
+	weighted := make([]Confidence, len(values))
+	total := 0.0
+	for i, value := range values {
+		if weight, ok := weights[value.Code]; ok {
+			value.Score *= weight
+		}
+		weighted[i] = value
+		total += value.Score
+	}
+	if total > 0 {
+		for i := range weighted {
+			weighted[i].Score /= total
+		}
+	}
+	sort.SliceStable(weighted, func(i, j int) bool {
+		return weighted[i].Score > weighted[j].Score
+	})
+	return weighted
+}
+
+func (d *prior) Unwrap() Detector { return d.base }
+
+// LoadPriors reads a priors file, see ParsePriors.
+func LoadPriors(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	weights, err := ParsePriors(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return weights, nil
+}
+
+// ParsePriors reads the weights of WithPrior from lines of the form
+//
+//	code<TAB>weight
+//
+// where weights are proportional to how often the languages occur, such as
+// their share of observed traffic; only their ratios matter. Languages not
+// listed get the weight of the code "*", or else the smallest weight listed.
+// The weights returned are scaled so that theirs is 1. Empty lines and lines
+// starting with # are ignored.
+func ParsePriors(r io.Reader) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected code<TAB>weight", lineNo)
+		}
+		weight, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("line %d: invalid weight %q", lineNo, fields[1])
+		}
+		weights[strings.ToLower(fields[0])] = weight
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("no priors")
+	}
+	unlisted, ok := weights["*"]
+	if !ok {
+		for _, weight := range weights {
+			if unlisted == 0 || weight < unlisted {
+				unlisted = weight
+			}
+		}
+	}
+	delete(weights, "*")
+	for code := range weights {
+		weights[code] /= unlisted
+	}
+	return weights, nil
+}
@@ -0,0 +1,313 @@
+package langid
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxProfileNgram is the longest character n-gram stored in a profile.
+	maxProfileNgram = 3
+	// maxProfileEntries caps the number of n-grams kept per order.
+	maxProfileEntries = 20000
+	// holdoutEvery assigns every n-th training line to the held-out set that
+	// calibrates the acceptance threshold.
+	holdoutEvery = 10
+	// maxHoldoutLines bounds the memory spent on held-out lines.
+	maxHoldoutLines = 2000
+	// acceptancePercentile is the held-out percentile used as threshold.
+	acceptancePercentile = 0.05
+)
+
+// Profile is a character n-gram model of a single language, trained from a
+// monolingual corpus with Train.
+type Profile struct {
+	Code string `json:"code"`
+	// Ngrams holds the absolute n-gram frequencies, indexed by n-gram length minus one.
+	Ngrams [maxProfileNgram]map[string]uint64 `json:"ngrams"`
+	// Threshold is the lowest mean log-probability per n-gram at which text is
+	// attributed to this profile when it is used alongside another backend.
+	Threshold float64 `json:"threshold"`
+
+	logProbs   [maxProfileNgram]map[string]float64
+	logUnknown [maxProfileNgram]float64
+}
+
+// Train builds a profile for the language code from a monolingual corpus with
+// one sentence or paragraph per line.
+func Train(code string, corpus io.Reader) (*Profile, error) {
+	p := &Profile{Code: strings.ToLower(code)}
+	for n := range p.Ngrams {
+		p.Ngrams[n] = make(map[string]uint64)
+	}
+
+	var holdout []string
+	scanner := bufio.NewScanner(corpus)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineNo++
+		if lineNo%holdoutEvery == 0 && len(holdout) < maxHoldoutLines {
+			holdout = append(holdout, line)
+			continue
+		}
+		p.add(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.Ngrams[0]) == 0 && len(holdout) == 0 {
+		return nil, errors.New("the training corpus contains no letters")
+	}
+
+	// Calibrate the threshold on lines the model has not seen, then fold
+	// them into the final counts.
+	p.prepare()
+	var scores []float64
+	for _, line := range holdout {
+		if score, ok := p.meanLogProb(line); ok {
+			scores = append(scores, score)
+		}
+	}
+	if len(scores) == 0 {
+		// Too little text to calibrate: accept whatever the profile wins.
+		p.Threshold = -math.MaxFloat64
+	} else {
+		sort.Float64s(scores)
+		p.Threshold = scores[int(float64(len(scores)-1)*acceptancePercentile)]
+	}
+	for _, line := range holdout {
+		p.add(line)
+	}
+	p.prune()
+	p.prepare()
+	return p, nil
+}
+
+// LoadProfile reads a profile written by Save.
+func LoadProfile(path string) (*Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var p Profile
+	if err := json.NewDecoder(bufio.NewReader(f)).Decode(&p); err != nil {
+		return nil, fmt.Errorf("reading profile %s: %w", path, err)
+	}
+	if p.Code == "" {
+		return nil, fmt.Errorf("reading profile %s: missing language code", path)
+	}
+	for n := range p.Ngrams {
+		if p.Ngrams[n] == nil {
+			p.Ngrams[n] = make(map[string]uint64)
+		}
+	}
+	p.prepare()
+	return &p, nil
+}
+
+// Save writes the profile as JSON.
+func (p *Profile) Save(w io.Writer) error {
+	return json.NewEncoder(w).Encode(p)
+}
+
+func (p *Profile) add(text string) {
+	for n, grams := range profileNgrams(text) {
+		for _, gram := range grams {
+			p.Ngrams[n][gram]++
+		}
+	}
+}
+
+// prune keeps only the most frequent n-grams of every order.
+func (p *Profile) prune() {
+	for _, counts := range p.Ngrams {
+		if len(counts) <= maxProfileEntries {
+			continue
+		}
+		grams := make([]string, 0, len(counts))
+		for gram := range counts {
+			grams = append(grams, gram)
+		}
+		sort.Slice(grams, func(i, j int) bool {
+			if counts[grams[i]] != counts[grams[j]] {
+				return counts[grams[i]] > counts[grams[j]]
+			}
+			return grams[i] < grams[j]
+		})
+		for _, gram := range grams[maxProfileEntries:] {
+			delete(counts, gram)
+		}
+	}
+}
+
+// prepare derives add-one smoothed log probabilities from the counts.
+func (p *Profile) prepare() {
+	for n, counts := range p.Ngrams {
+		var total uint64
+		for _, c := range counts {
+			total += c
+		}
+		denominator := float64(total) + float64(len(counts)) + 1
+		p.logProbs[n] = make(map[string]float64, len(counts))
+		for gram, c := range counts {
+			p.logProbs[n][gram] = math.Log((float64(c) + 1) / denominator)
+		}
+		p.logUnknown[n] = math.Log(1 / denominator)
+	}
+}
+
+// logLikelihood sums the log probabilities of all n-grams of text and returns
+// the number of n-grams scored.
+func (p *Profile) logLikelihood(grams [maxProfileNgram][]string) (float64, int) {
+	sum, count := 0.0, 0
+	for n := range grams {
+		for _, gram := range grams[n] {
+			if lp, ok := p.logProbs[n][gram]; ok {
+				sum += lp
+			} else {
+				sum += p.logUnknown[n]
+			}
+			count++
+		}
+	}
+	return sum, count
+}
+
+func (p *Profile) meanLogProb(text string) (float64, bool) {
+	sum, count := p.logLikelihood(profileNgrams(text))
+	if count == 0 {
+		return 0, false
+	}
+	return sum / float64(count), true
+}
+
+// profileNgrams extracts the character n-grams of the lowercased words of
+// text. Words are padded with spaces so that longer n-grams capture word
+// beginnings and endings.
+func profileNgrams(text string) [maxProfileNgram][]string {
+	var grams [maxProfileNgram][]string
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, word := range words {
+		runes := []rune(word)
+		for _, r := range runes {
+			grams[0] = append(grams[0], string(r))
+		}
+		padded := append(append([]rune{' '}, runes...), ' ')
+		for n := 2; n <= maxProfileNgram; n++ {
+			for i := 0; i+n <= len(padded); i++ {
+				grams[n-1] = append(grams[n-1], string(padded[i:i+n]))
+			}
+		}
+	}
+	return grams
+}
+
+// ProfileDetector classifies text among a set of trained profiles.
+type ProfileDetector struct {
+	profiles []*Profile
+}
+
+// NewProfileDetector returns a detector choosing between the given profiles.
+func NewProfileDetector(profiles []*Profile) *ProfileDetector {
+	return &ProfileDetector{profiles: profiles}
+}
+
+// ConfidenceValues normalizes the profiles' likelihoods into a distribution.
+func (d *ProfileDetector) ConfidenceValues(text string) ([]Confidence, error) {
+	results, _ := d.score(text)
+	return results, nil
+}
+
+// RawScores returns the mean log-probability per n-gram of text under each
+// profile, the measure Profile.Threshold applies to.
+func (d *ProfileDetector) RawScores(text string) ([]Confidence, error) {
+	grams := profileNgrams(text)
+	var results []Confidence
+	for _, p := range d.profiles {
+		sum, count := p.logLikelihood(grams)
+		if count == 0 {
+			return nil, nil
+		}
+		results = append(results, Confidence{Code: p.Code, Score: sum / float64(count)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, nil
+}
+
+// score returns the confidence values together with whether the most likely
+// profile's mean log-probability reaches its acceptance threshold.
+func (d *ProfileDetector) score(text string) ([]Confidence, bool) {
+	grams := profileNgrams(text)
+	results := make([]Confidence, len(d.profiles))
+	sums := make([]float64, len(d.profiles))
+	best, count := 0, 0
+	for i, p := range d.profiles {
+		results[i].Code = p.Code
+		sums[i], count = p.logLikelihood(grams)
+		if sums[i] > sums[best] {
+			best = i
+		}
+	}
+	if count == 0 {
+		return results, false
+	}
+	accepted := sums[best]/float64(count) >= d.profiles[best].Threshold
+
+	total := 0.0
+	for i := range results {
+		results[i].Score = math.Exp(sums[i] - sums[best])
+		total += results[i].Score
+	}
+	for i := range results {
+		results[i].Score /= total
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	return results, accepted
+}
+
+// WithProfiles combines a backend with custom profiles: text that fits one of
+// the profiles well enough (see Profile.Threshold) is classified among the
+// profiles, everything else is handed to base.
+func WithProfiles(base Detector, profiles *ProfileDetector) Detector {
+	return &profileFallback{base: base, profiles: profiles}
+}
+
+type profileFallback struct {
+	base     Detector
+	profiles *ProfileDetector
+}
+
+func (d *profileFallback) ConfidenceValues(text string) ([]Confidence, error) {
+	if results, accepted := d.profiles.score(text); accepted {
+		return results, nil
+	}
+	return d.base.ConfidenceValues(text)
+}
+
+// RawScores reports the raw scores of the profiles, which decide whether a
+// text is handed to the base backend.
+func (d *profileFallback) RawScores(text string) ([]Confidence, error) {
+	return d.profiles.RawScores(text)
+}
+
+func (d *profileFallback) Unwrap() Detector { return d.base }
@@ -0,0 +1,49 @@
+package langid
+
+// RawScorer is implemented by backends that can report their unnormalized
+// scores. Unlike confidence values, which always sum to 1, they show how
+// well a text fits each language in absolute terms, so a text that fits no
+// language well stands out.
+type RawScorer interface {
+	// RawScores returns the scores for text, sorted by descending score.
+	RawScores(text string) ([]Confidence, error)
+}
+
+// AsRawScorer returns the detector, or the first detector it wraps, that
+// reports raw scores.
+func AsRawScorer(d Detector) (RawScorer, bool) {
+	for d != nil {
+		if rs, ok := d.(RawScorer); ok {
+			return rs, true
+		}
+		w, ok := d.(wrapper)
+		if !ok {
+			break
+		}
+		d = w.Unwrap()
+	}
+	return nil, false
+}
+
+// WithRawScores makes d report the raw scores of the first detector in its
+// chain that has them in place of confidence values, bypassing any
+// post-processing. It returns false if no detector in the chain reports
+// raw scores.
+func WithRawScores(d Detector) (Detector, bool) {
+	rs, ok := AsRawScorer(d)
+	if !ok {
+		return nil, false
+	}
+	return &rawScores{base: d, raw: rs}, true
+}
+
+type rawScores struct {
+	base Detector
+	raw  RawScorer
+}
+
+func (d *rawScores) ConfidenceValues(text string) ([]Confidence, error) {
+	return d.raw.RawScores(text)
+}
+
+func (d *rawScores) Unwrap() Detector { return d.base }
@@ -0,0 +1,157 @@
+package langid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// remoteDetector delegates detection to a server answering like the handler
+// of linguahttp.NewHandler: POST requests of a JSON object with a "text"
+// field, answered with the confidences of all languages for all=1 and the
+// single-language spans of the text for spans=1.
+type remoteDetector struct {
+	url    string
+	client *http.Client
+}
+
+// remoteErrorCode and remoteNoText are linguahttp.ErrorCodeHeader and
+// linguahttp.ErrorNoText, which linguahttp.NewHandler sets on its reply to
+// requests without text to classify, the only error the detector takes for
+// an empty result. This package can not import linguahttp, which imports it.
+const (
+	remoteErrorCode = "X-Error-Code"
+	remoteNoText    = "no_text"
+)
+
+// remoteResponse is the part of the server's answer the detector reads.
+type remoteResponse struct {
+	Confidences []struct {
+		Language string  `json:"language"`
+		Score    float64 `json:"score"`
+	} `json:"confidences"`
+	Spans []struct {
+		Language string `json:"language"`
+		Start    int    `json:"start"`
+		End      int    `json:"end"`
+	} `json:"spans"`
+}
+
+func newRemote(opts Options) (*remoteDetector, error) {
+	addr := strings.TrimSpace(opts.Server)
+	if addr == "" {
+		return nil, errors.New("the remote backend requires a server address")
+	}
+	d := &remoteDetector{client: &http.Client{}}
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		// Requests go to the socket whatever the host of the URL.
+		socket := strings.TrimPrefix(addr, "unix:")
+		d.url = "http://unix/detect"
+		d.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+	case strings.Contains(addr, "://"):
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid server address %q: %w", addr, err)
+		}
+		if u.Path == "" {
+			u.Path = "/detect"
+		}
+		d.url = u.String()
+	default:
+		d.url = "http://" + addr + "/detect"
+	}
+	return d, nil
+}
+
+func (d *remoteDetector) ConfidenceValues(text string) ([]Confidence, error) {
+	response, err := d.post(text, "all")
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Confidence, 0, len(response.Confidences))
+	for _, value := range response.Confidences {
+		if value.Language != Unknown {
+			results = append(results, Confidence{Code: value.Language, Score: value.Score})
+		}
+	}
+	return results, nil
+}
+
+// DetectMultiple returns the spans of the server, or ErrNoMultiSupport if
+// its detector has none. Their offsets refer to the text as the server
+// preprocesses it.
+func (d *remoteDetector) DetectMultiple(text string) ([]Span, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	response, err := d.post(text, "spans")
+	if err != nil {
+		return nil, err
+	}
+	if response.Spans == nil {
+		return nil, ErrNoMultiSupport
+	}
+	spans := make([]Span, len(response.Spans))
+	for i, s := range response.Spans {
+		spans[i] = Span{Start: s.Start, End: s.End, Code: s.Language}
+	}
+	return spans, nil
+}
+
+// post sends text to the server with the query parameter param set to 1.
+func (d *remoteDetector) post(text, param string) (*remoteResponse, error) {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, err
+	}
+	target := d.url + "?"
+	if strings.Contains(d.url, "?") {
+		target = d.url + "&"
+	}
+	request, err := http.NewRequest(http.MethodPost, target+param+"=1", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	reply, err := d.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("remote backend: %w", err)
+	}
+	defer reply.Body.Close()
+	data, err := io.ReadAll(reply.Body)
+	if err != nil {
+		return nil, fmt.Errorf("remote backend: %w", err)
+	}
+	switch {
+	case reply.StatusCode == http.StatusBadRequest && reply.Header.Get(remoteErrorCode) == remoteNoText:
+		// The server found no text to classify, such as in whitespace.
+		return &remoteResponse{}, nil
+	case reply.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("remote backend: %s: %s", reply.Status, strings.TrimSpace(string(data)))
+	}
+	var response remoteResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("remote backend: invalid response: %w", err)
+	}
+	return &response, nil
+}
+
+// Close closes the idle connections to the server.
+func (d *remoteDetector) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,143 @@
+package langid
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// romanizedMinShare is the share of a text's words that must be
+	// typical of a romanization for the text to be reported as romanized.
+	romanizedMinShare = 0.3
+	// romanizedMinWords is the least number of typical words required.
+	romanizedMinWords = 2
+)
+
+// romanization describes how a language is commonly written in Latin
+// letters, by frequent words of that writing that are rare elsewhere.
+type romanization struct {
+	// code is the BCP 47 tag reported, the language with the Latn script.
+	code  string
+	words map[string]bool
+	// typical reports further words typical of the romanization.
+	typical func(word string) bool
+}
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
+
+var (
+	// arabiziDigit matches digits standing in for Arabic letters (2 hamza,
+	// 3 ain, 5 kha, 6 ta, 7 ha, 8 qaf, 9 sad) within a word.
+	arabiziDigit = regexp.MustCompile(`^[a-z]*[235679][a-z]+[0-9a-z]*$|^[a-z]+[235679]$`)
+	// pinyinNumbered matches pinyin with tone numbers, e.g. "zhong1guo2".
+	pinyinNumbered = regexp.MustCompile(`^([bcdfghjklmnpqrstwxyz]?h?[aeiouü]+n?g?r?[1-5])+$`)
+)
+
+var romanizations = []romanization{
+	{
+		code: "hi-Latn",
+		words: wordSet(`hai hain nahi nahin kya kyun kyon mein aap hum tum bahut bohot acha accha achha
+			kaise kaisa ke ki ka ko se yeh woh kuch kuchh abhi bhi aur lekin tha thi raha rahe rahi
+			karna karo kar gaya gayi hoga mujhe tujhe mera meri tera teri uska unka hamara tumhara
+			yaar matlab sirf kab kahan jab jaisa wala wali dekho chalo haan thik theek pata bolo bhai`),
+	},
+	{
+		code: "ar-Latn",
+		words: wordSet(`ana enta enti inta inti howa hiya ehna shu sho leh ezay izzay keef kif kifak
+			keefak wallah walla yalla yallah habibi habibti yani mesh mish msh mafi kaman kman tayeb
+			tayyib inshallah mashallah hamdulillah ahlan marhaba shukran lazem khalas akeed mnih
+			kteer ktir`),
+		typical: arabiziDigit.MatchString,
+	},
+	{
+		code: "zh-Latn",
+		words: wordSet(`wo ni ta women nimen tamen shi bu hen hao zai mei zhe nage zhege shenme
+			weishenme zenme keyi xiang yao qu lai dui xiexie duibuqi meiyou zhidao xihuan pengyou
+			laoshi xuesheng zhongguo jintian mingtian zuotian shijian yidian yiqi haishi huozhe
+			yinwei suoyi danshi ranhou xianzai gen dou hui`),
+		typical: func(word string) bool {
+			return strings.ContainsAny(word, "āēīōūǖǎěǐǒǔǚǘǜ") || pinyinNumbered.MatchString(word)
+		},
+	},
+}
+
+// WithRomanized makes d recognize Hindi, Arabic and Chinese written in Latin
+// letters (romanized Hindi, Arabizi, pinyin), which language models trained
+// on native scripts mistake for European languages. Texts in Latin script
+// in which enough words are typical of one of these romanizations are
+// reported as hi-Latn, ar-Latn or zh-Latn, ahead of the distribution of d.
+// The test is a heuristic based on frequent words.
+func WithRomanized(d Detector) Detector {
+	return &romanized{base: d}
+}
+
+type romanized struct {
+	base Detector
+}
+
+func (d *romanized) ConfidenceValues(text string) ([]Confidence, error) {
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil {
+		return nil, err
+	}
+	code, share := detectRomanization(text)
+	if code == "" {
+		return values, nil
+	}
+	// Half the words being typical leaves little doubt.
+	score := min(1, 2*share)
+	results := []Confidence{{Code: code, Score: score}}
+	for _, value := range values {
+		results = append(results, Confidence{Code: value.Code, Score: value.Score * (1 - score)})
+	}
+	return results, nil
+}
+
+func (d *romanized) Unwrap() Detector { return d.base }
+
+// detectRomanization returns the tag of the romanization most of the words
+// of text are typical of, with their share, or "" if text is not written in
+// Latin script or no romanization reaches romanizedMinShare.
+func detectRomanization(text string) (string, float64) {
+	latin, other := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			if unicode.Is(unicode.Latin, r) {
+				latin++
+			} else {
+				other++
+			}
+		}
+	}
+	if latin == 0 || other > latin/10 {
+		return "", 0
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	bestCode, bestHits := "", 0
+	for _, rz := range romanizations {
+		hits := 0
+		for _, word := range words {
+			if rz.words[word] || (rz.typical != nil && rz.typical(word)) {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			bestCode, bestHits = rz.code, hits
+		}
+	}
+	share := float64(bestHits) / float64(len(words))
+	if bestHits < romanizedMinWords || share < romanizedMinShare {
+		return "", 0
+	}
+	return bestCode, share
+}
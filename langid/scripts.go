@@ -0,0 +1,233 @@
+package langid
+
+import (
+	"slices"
+	"sort"
+	"unicode"
+)
+
+// scriptTables maps ISO 15924 script codes to their Unicode range tables, in
+// the order Script checks them.
+var scriptTables = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"Latn", unicode.Latin},
+	{"Cyrl", unicode.Cyrillic},
+	{"Arab", unicode.Arabic},
+	{"Hani", unicode.Han},
+	{"Hira", unicode.Hiragana},
+	{"Kana", unicode.Katakana},
+	{"Hang", unicode.Hangul},
+	{"Deva", unicode.Devanagari},
+	{"Grek", unicode.Greek},
+	{"Hebr", unicode.Hebrew},
+	{"Thai", unicode.Thai},
+	{"Armn", unicode.Armenian},
+	{"Geor", unicode.Georgian},
+	{"Beng", unicode.Bengali},
+	{"Gujr", unicode.Gujarati},
+	{"Guru", unicode.Gurmukhi},
+	{"Taml", unicode.Tamil},
+	{"Telu", unicode.Telugu},
+	{"Knda", unicode.Kannada},
+	{"Mlym", unicode.Malayalam},
+	{"Orya", unicode.Oriya},
+	{"Sinh", unicode.Sinhala},
+	{"Mymr", unicode.Myanmar},
+	{"Khmr", unicode.Khmer},
+	{"Laoo", unicode.Lao},
+	{"Tibt", unicode.Tibetan},
+	{"Ethi", unicode.Ethiopic},
+	{"Mong", unicode.Mongolian},
+	{"Thaa", unicode.Thaana},
+	{"Syrc", unicode.Syriac},
+}
+
+// Script returns the ISO 15924 code of the script r belongs to: "Zyyy" for
+// characters shared by all scripts (digits, punctuation, symbols), "Zinh" for
+// inherited ones (combining marks) and "Zzzz" for scripts not listed above.
+func Script(r rune) string {
+	for _, s := range scriptTables {
+		if unicode.Is(s.table, r) {
+			return s.code
+		}
+	}
+	switch {
+	case unicode.Is(unicode.Inherited, r):
+		return "Zinh"
+	case unicode.Is(unicode.Common, r):
+		return "Zyyy"
+	}
+	return "Zzzz"
+}
+
+// languageScripts lists the ISO 15924 scripts each language supported by
+// lingua is written in, keyed by ISO 639-1 code.
+var languageScripts = map[string][]string{
+	"af": {"Latn"}, "ar": {"Arab"}, "az": {"Latn"}, "be": {"Cyrl"},
+	"bg": {"Cyrl"}, "bn": {"Beng"}, "bs": {"Latn"}, "ca": {"Latn"},
+	"cs": {"Latn"}, "cy": {"Latn"}, "da": {"Latn"}, "de": {"Latn"},
+	"el": {"Grek"}, "en": {"Latn"}, "eo": {"Latn"}, "es": {"Latn"},
+	"et": {"Latn"}, "eu": {"Latn"}, "fa": {"Arab"}, "fi": {"Latn"},
+	"fr": {"Latn"}, "ga": {"Latn"}, "gu": {"Gujr"}, "he": {"Hebr"},
+	"hi": {"Deva"}, "hr": {"Latn"}, "hu": {"Latn"}, "hy": {"Armn"},
+	"id": {"Latn"}, "is": {"Latn"}, "it": {"Latn"}, "ja": {"Hira", "Kana", "Hani"},
+	"ka": {"Geor"}, "kk": {"Cyrl"}, "ko": {"Hang", "Hani"}, "la": {"Latn"},
+	"lg": {"Latn"}, "lt": {"Latn"}, "lv": {"Latn"}, "mi": {"Latn"},
+	"mk": {"Cyrl"}, "mn": {"Cyrl"}, "mr": {"Deva"}, "ms": {"Latn"},
+	"nb": {"Latn"}, "nl": {"Latn"}, "nn": {"Latn"}, "pa": {"Guru"},
+	"pl": {"Latn"}, "pt": {"Latn"}, "ro": {"Latn"}, "ru": {"Cyrl"},
+	"sk": {"Latn"}, "sl": {"Latn"}, "sn": {"Latn"}, "so": {"Latn"},
+	"sq": {"Latn"}, "sr": {"Cyrl"}, "st": {"Latn"}, "sv": {"Latn"},
+	"sw": {"Latn"}, "ta": {"Taml"}, "te": {"Telu"}, "th": {"Thai"},
+	"tl": {"Latn"}, "tn": {"Latn"}, "tr": {"Latn"}, "ts": {"Latn"},
+	"uk": {"Cyrl"}, "ur": {"Arab"}, "vi": {"Latn"}, "xh": {"Latn"},
+	"yo": {"Latn"}, "zh": {"Hani"}, "zu": {"Latn"},
+}
+
+// LanguageScripts returns the ISO 15924 scripts a language is written in, or
+// nil if the language is not known.
+func LanguageScripts(code string) []string {
+	return languageScripts[code]
+}
+
+// TextScripts returns the set of scripts of the letters in text, ignoring
+// shared and inherited characters.
+func TextScripts(text string) map[string]bool {
+	scripts := make(map[string]bool)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		switch script := Script(r); script {
+		case "Zyyy", "Zinh":
+		default:
+			scripts[script] = true
+		}
+	}
+	return scripts
+}
+
+// DominantScript returns the ISO 15924 code of the script most letters of
+// text belong to, or "" if text has no letters. Japanese, which mixes kana
+// with Han characters, is reported as "Jpan", and Korean mixing Hangul with
+// Han as "Kore".
+func DominantScript(text string) string {
+	counts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		switch script := Script(r); script {
+		case "Zyyy", "Zinh":
+		default:
+			counts[script]++
+		}
+	}
+	best := ""
+	for script, n := range counts {
+		if best == "" || n > counts[best] || (n == counts[best] && script < best) {
+			best = script
+		}
+	}
+	switch {
+	case (best == "Hira" || best == "Kana" || best == "Hani") && counts["Hira"]+counts["Kana"] > 0:
+		return "Jpan"
+	case (best == "Hang" || best == "Hani") && counts["Hang"] > 0:
+		return "Kore"
+	}
+	return best
+}
+
+// MixedScriptShare is the share of the letters of a text a second script
+// must make up for MixedScripts to report the text.
+const MixedScriptShare = 0.1
+
+// MixedScripts returns the scripts of the letters of text, sorted by code,
+// if at least two of them each make up at least minShare of the letters, or
+// if a word mixes letters of several scripts, and nil otherwise. Such texts
+// mix languages, or spoof words with look-alike letters of another script,
+// as in "pаypal" with a Cyrillic а. Japanese and Korean, which mix scripts
+// by nature, count as a single script each, as in DominantScript.
+func MixedScripts(text string, minShare float64) []string {
+	counts := make(map[string]int)
+	total := 0
+	// words holds the scripts of each word mixing them, other than the
+	// scripts of Chinese, Japanese and Korean alone.
+	var words [][]string
+	var word []string
+	endWord := func() {
+		if len(word) > 1 && slices.ContainsFunc(word, func(s string) bool { return !cjkScript(s) }) {
+			words = append(words, word)
+		}
+		word = nil
+	}
+	for _, r := range text {
+		script := Script(r)
+		if !unicode.IsLetter(r) {
+			if script != "Zinh" {
+				endWord()
+			}
+			continue
+		}
+		if script == "Zyyy" || script == "Zinh" {
+			continue
+		}
+		counts[script]++
+		total++
+		if !slices.Contains(word, script) {
+			word = append(word, script)
+		}
+	}
+	endWord()
+	merged := func(script string) string { return script }
+	switch {
+	case counts["Hira"]+counts["Kana"] > 0:
+		merged = func(script string) string {
+			if script == "Hira" || script == "Kana" || script == "Hani" {
+				return "Jpan"
+			}
+			return script
+		}
+	case counts["Hang"] > 0:
+		merged = func(script string) string {
+			if script == "Hang" || script == "Hani" {
+				return "Kore"
+			}
+			return script
+		}
+	}
+	shares := make(map[string]int)
+	for script, n := range counts {
+		shares[merged(script)] += n
+	}
+	var scripts []string
+	for script, n := range shares {
+		if float64(n) >= minShare*float64(total) {
+			scripts = append(scripts, script)
+		}
+	}
+	for _, word := range words {
+		for _, script := range word {
+			if script = merged(script); !slices.Contains(scripts, script) {
+				scripts = append(scripts, script)
+			}
+		}
+	}
+	if len(scripts) < 2 {
+		return nil
+	}
+	sort.Strings(scripts)
+	return scripts
+}
+
+// cjkScript reports whether script is one of those Chinese, Japanese and
+// Korean are written in, which mix within words.
+func cjkScript(script string) bool {
+	switch script {
+	case "Hani", "Hira", "Kana", "Hang":
+		return true
+	}
+	return false
+}
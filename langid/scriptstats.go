@@ -0,0 +1,38 @@
+package langid
+
+import (
+	"sort"
+	"unicode"
+)
+
+// scriptShares is the scripts backend. Rather than languages it reports the
+// share of the non-space characters of a text in each Unicode script, by
+// ISO 15924 code (see Script), which is much faster than language detection
+// and useful for routing documents beforehand.
+type scriptShares struct{}
+
+func (scriptShares) ConfidenceValues(text string) ([]Confidence, error) {
+	counts := make(map[string]int)
+	total := 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		counts[Script(r)]++
+		total++
+	}
+	if total == 0 {
+		return nil, nil
+	}
+	results := make([]Confidence, 0, len(counts))
+	for script, n := range counts {
+		results = append(results, Confidence{Code: script, Score: float64(n) / float64(total)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Code < results[j].Code
+	})
+	return results, nil
+}
@@ -0,0 +1,137 @@
+package langid
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	urlPattern     = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)\S+`)
+	emailPattern   = regexp.MustCompile(`\S+@\S+\.\S+`)
+	mentionPattern = regexp.MustCompile(`[@#][\p{L}\p{N}_]+`)
+)
+
+// ScrubShortText removes the parts of tweets, chat messages and search
+// queries that carry no language signal: URLs, e-mail addresses, @mentions,
+// #hashtags, numbers, emoji and other symbols.
+func ScrubShortText(text string) string {
+	text = urlPattern.ReplaceAllString(text, " ")
+	text = emailPattern.ReplaceAllString(text, " ")
+	text = mentionPattern.ReplaceAllString(text, " ")
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || unicode.IsSymbol(r) || unicode.Is(unicode.Cf, r) {
+			return ' '
+		}
+		return r
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// WithShortText tunes a detector for very short texts. Text is scrubbed with
+// ScrubShortText first. Text written entirely in a script that only one of the
+// candidate languages uses (Hangul, Greek, Thai, kana, ...) is attributed to
+// that language without consulting d; otherwise d's distribution is narrowed
+// to languages written in the scripts present in the text. Candidates are
+// ISO 639-1 codes; empty means all languages lingua supports.
+func WithShortText(d Detector, candidates []string) Detector {
+	s := &shortText{base: d}
+	if len(candidates) == 0 {
+		for code := range languageScripts {
+			candidates = append(candidates, code)
+		}
+		sort.Strings(candidates)
+	}
+	for _, code := range candidates {
+		s.candidates = append(s.candidates, strings.ToLower(code))
+	}
+	return s
+}
+
+type shortText struct {
+	base       Detector
+	candidates []string
+}
+
+func (d *shortText) ConfidenceValues(text string) ([]Confidence, error) {
+	text = ScrubShortText(text)
+	scripts := TextScripts(text)
+	if code, ok := d.shortcut(scripts); ok {
+		return []Confidence{{Code: code, Score: 1}}, nil
+	}
+	values, err := d.base.ConfidenceValues(text)
+	if err != nil || len(scripts) == 0 {
+		return values, err
+	}
+	return narrowByScript(values, scripts), nil
+}
+
+func (d *shortText) Unwrap() Detector { return d.base }
+
+// shortcut returns the only candidate language whose scripts cover all
+// scripts of the text, if there is exactly one.
+func (d *shortText) shortcut(scripts map[string]bool) (string, bool) {
+	if len(scripts) == 0 {
+		return "", false
+	}
+	match := ""
+	for _, code := range d.candidates {
+		if !coversScripts(languageScripts[code], scripts) {
+			continue
+		}
+		if match != "" {
+			return "", false
+		}
+		match = code
+	}
+	return match, match != ""
+}
+
+func coversScripts(languageScripts []string, scripts map[string]bool) bool {
+	if len(languageScripts) == 0 {
+		return false
+	}
+	for script := range scripts {
+		found := false
+		for _, s := range languageScripts {
+			if s == script {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// narrowByScript drops languages not written in any of the given scripts and
+// renormalizes the remaining scores. Languages with unknown scripts are kept.
+// If nothing would remain, values is returned unchanged.
+func narrowByScript(values []Confidence, scripts map[string]bool) []Confidence {
+	var narrowed []Confidence
+	total := 0.0
+	for _, value := range values {
+		known := languageScripts[value.Code]
+		keep := len(known) == 0
+		for _, s := range known {
+			if scripts[s] {
+				keep = true
+				break
+			}
+		}
+		if keep {
+			narrowed = append(narrowed, value)
+			total += value.Score
+		}
+	}
+	if len(narrowed) == 0 || total == 0 {
+		return values
+	}
+	for i := range narrowed {
+		narrowed[i].Score /= total
+	}
+	return narrowed
+}
@@ -0,0 +1,73 @@
+package langid
+
+// MergeSpans joins consecutive spans of the same language into one span
+// reaching from the start of the first to the end of the last, including
+// any text between them.
+func MergeSpans(spans []Span) []Span {
+	var merged []Span
+	for _, span := range spans {
+		if n := len(merged); n > 0 && merged[n-1].Code == span.Code {
+			merged[n-1].End = span.End
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// AbsorbShortSpans merges spans of text with fewer than minWords words, such
+// as single foreign words within monolingual text, into the neighbouring span
+// with more words (the preceding one among equals), until no such span is
+// left or a single span remains. Spans of the same language that end up next
+// to each other are joined as by MergeSpans.
+func AbsorbShortSpans(text string, spans []Span, minWords int) []Span {
+	spans = MergeSpans(spans)
+	for len(spans) > 1 {
+		words := make([]int, len(spans))
+		shortest := -1
+		for i, span := range spans {
+			words[i] = len(tokenize(text[span.Start:span.End]))
+			if words[i] < minWords && (shortest < 0 || words[i] < words[shortest]) {
+				shortest = i
+			}
+		}
+		if shortest < 0 {
+			break
+		}
+		i := shortest
+		switch {
+		case i == 0:
+			spans[i].Code = spans[i+1].Code
+		case i == len(spans)-1 || words[i-1] >= words[i+1]:
+			spans[i].Code = spans[i-1].Code
+		default:
+			spans[i].Code = spans[i+1].Code
+		}
+		spans = MergeSpans(spans)
+	}
+	return spans
+}
+
+// ThresholdSpans reclassifies the text of each span of text with d and
+// labels spans whose language scores below threshold there as Unknown.
+func ThresholdSpans(d Detector, text string, spans []Span, threshold float64) ([]Span, error) {
+	filtered := make([]Span, len(spans))
+	for i, span := range spans {
+		values, err := d.ConfidenceValues(text[span.Start:span.End])
+		if err != nil {
+			return nil, err
+		}
+		score := 0.0
+		for _, value := range values {
+			if value.Code == span.Code {
+				score = value.Score
+				break
+			}
+		}
+		filtered[i] = span
+		if score < threshold {
+			filtered[i].Code = Unknown
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,36 @@
+package langid
+
+import (
+	"context"
+	"time"
+)
+
+// WithTimeout bounds how long d may take to classify a text: texts it has
+// not classified within timeout get no language at all, after a call of
+// onTimeout, if not nil, with the text. Backends cannot be interrupted, so a
+// text that timed out is still classified in the background, and a cache
+// below the returned detector still receives its result.
+func WithTimeout(d Detector, timeout time.Duration, onTimeout func(text string)) Detector {
+	return &timeLimited{base: d, timeout: timeout, onTimeout: onTimeout}
+}
+
+type timeLimited struct {
+	base      Detector
+	timeout   time.Duration
+	onTimeout func(string)
+}
+
+func (d *timeLimited) ConfidenceValues(text string) ([]Confidence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	values, err := await(ctx, func() ([]Confidence, error) { return d.base.ConfidenceValues(text) })
+	if err == context.DeadlineExceeded {
+		if d.onTimeout != nil {
+			d.onTimeout(text)
+		}
+		return nil, nil
+	}
+	return values, err
+}
+
+func (d *timeLimited) Unwrap() Detector { return d.base }
@@ -0,0 +1,100 @@
+package langid
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenContextWeight is the weight of each neighbouring word's distribution
+// in the language of a word tagged by TagTokens.
+const tokenContextWeight = 0.5
+
+// TagTokens assigns a language to every word of text, for code-switching
+// analysis at a finer granularity than DetectMultiple. Words are runs of
+// letters, marks and digits, including inner apostrophes and hyphens; those
+// without letters are left out. Each word is classified by d on its own and
+// its distribution blended with those of its two neighbours, so that words
+// common to several languages follow their context. Words d assigns no
+// language to are tagged Unknown.
+func TagTokens(d Detector, text string) ([]Span, error) {
+	tokens := tokenize(text)
+	values := make([][]Confidence, len(tokens))
+	for i, token := range tokens {
+		var err error
+		values[i], err = d.ConfidenceValues(text[token.Start:token.End])
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range tokens {
+		if len(values[i]) == 0 {
+			tokens[i].Code = Unknown
+			continue
+		}
+		scores := make(map[string]float64)
+		for _, value := range values[i] {
+			scores[value.Code] += value.Score
+		}
+		for _, j := range []int{i - 1, i + 1} {
+			if j < 0 || j >= len(tokens) {
+				continue
+			}
+			for _, value := range values[j] {
+				if _, ok := scores[value.Code]; ok {
+					scores[value.Code] += tokenContextWeight * value.Score
+				}
+			}
+		}
+		tokens[i].Code = bestCode(scores)
+	}
+	return tokens, nil
+}
+
+// bestCode returns the code with the highest score, the alphabetically
+// first among equals.
+func bestCode(scores map[string]float64) string {
+	codes := make([]string, 0, len(scores))
+	for code := range scores {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if scores[codes[i]] != scores[codes[j]] {
+			return scores[codes[i]] > scores[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	return codes[0]
+}
+
+// tokenize returns the spans of the words of text that contain letters.
+func tokenize(text string) []Span {
+	isWordRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsDigit(r)
+	}
+	var tokens []Span
+	start, letters := -1, false
+	for i, r := range text {
+		switch {
+		case isWordRune(r):
+			if start < 0 {
+				start = i
+			}
+			letters = letters || unicode.IsLetter(r)
+			continue
+		case start >= 0 && (r == '\'' || r == '’' || r == '-'):
+			// Inner apostrophes and hyphens belong to the word.
+			if next, _ := utf8.DecodeRuneInString(text[i+utf8.RuneLen(r):]); isWordRune(next) {
+				continue
+			}
+		}
+		if start >= 0 && letters {
+			tokens = append(tokens, Span{Start: start, End: i})
+		}
+		start, letters = -1, false
+	}
+	if start >= 0 && letters {
+		tokens = append(tokens, Span{Start: start, End: len(text)})
+	}
+	return tokens
+}
@@ -0,0 +1,57 @@
+package langid
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abadojack/whatlanggo"
+)
+
+// whatlangDetector is a fast trigram-based backend built on whatlanggo.
+// It only reports the winning language, so its distributions have a single entry.
+type whatlangDetector struct {
+	options whatlanggo.Options
+}
+
+func newWhatlang(opts Options) (*whatlangDetector, error) {
+	d := &whatlangDetector{}
+	if len(opts.Languages) > 0 {
+		d.options.Whitelist = make(map[whatlanggo.Lang]bool)
+		for _, code := range opts.Languages {
+			lang, ok := whatlangLanguage(code)
+			if !ok {
+				return nil, fmt.Errorf("language code %q is not supported by the whatlang backend", code)
+			}
+			d.options.Whitelist[lang] = true
+		}
+	}
+	return d, nil
+}
+
+func (d *whatlangDetector) ConfidenceValues(text string) ([]Confidence, error) {
+	info := whatlanggo.DetectWithOptions(text, d.options)
+	if info.Lang < 0 {
+		return nil, nil
+	}
+	return []Confidence{{Code: whatlangCode(info.Lang), Score: info.Confidence}}, nil
+}
+
+// whatlangLanguage resolves an ISO 639-1 code, or an ISO 639-3 code for the
+// languages whatlanggo supports that have no two-letter code.
+func whatlangLanguage(code string) (whatlanggo.Lang, bool) {
+	code = strings.ToLower(code)
+	for lang := range whatlanggo.Langs {
+		if lang.Iso6391() == code {
+			return lang, true
+		}
+	}
+	lang := whatlanggo.CodeToLang(code)
+	return lang, lang >= 0
+}
+
+func whatlangCode(lang whatlanggo.Lang) string {
+	if code := lang.Iso6391(); code != "" {
+		return code
+	}
+	return lang.Iso6393()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	lingua "github.com/pemistahl/lingua-go"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// languageInfo describes a language supported by the lingua backend.
+type languageInfo struct {
+	Code       string `json:"iso639_1"`
+	Code3      string `json:"iso639_3"`
+	Name       string `json:"name"`
+	NativeName string `json:"native_name"`
+	// LocalizedName is the name in the -names-locale language, if given.
+	LocalizedName string   `json:"localized_name,omitempty"`
+	Scripts       []string `json:"scripts"`
+}
+
+// languageNames names languages in the -names-locale language, English by
+// default.
+var languageNames = struct {
+	namer display.Namer
+	// localized reports whether -names-locale was given.
+	localized bool
+}{namer: display.Tags(language.English)}
+
+// setNamesLocale makes languageName use the language names of locale, a
+// BCP 47 tag such as "de" or "pt-BR", from the CLDR.
+func setNamesLocale(locale string) error {
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return fmt.Errorf("invalid -names-locale %q: %v", locale, err)
+	}
+	namer := display.Tags(tag)
+	if namer == nil {
+		return fmt.Errorf("no language names for -names-locale %q", locale)
+	}
+	languageNames.namer, languageNames.localized = namer, true
+	return nil
+}
+
+// languageName returns the name of the language with the given code or
+// BCP 47 tag. The unknown label is named as the undetermined language, and
+// other labels that are no language tags are returned unchanged.
+func languageName(code string) string {
+	if code == langid.Unknown {
+		return languageNames.namer.Name(language.Und)
+	}
+	tag, err := language.Parse(code)
+	if err != nil {
+		return code
+	}
+	if name := languageNames.namer.Name(tag); name != "" {
+		return name
+	}
+	return code
+}
+
+// declaredLanguage returns the language a BCP 47 tag or POSIX locale
+// declares, such as "pt" for "pt-BR" or "pt_BR.UTF-8", as a code comparable
+// to detected ones, or "" if it declares none.
+func declaredLanguage(tag string) string {
+	tag, _, _ = strings.Cut(tag, ".")
+	tag, _, _ = strings.Cut(tag, "@")
+	parsed, err := language.Parse(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if err != nil {
+		return ""
+	}
+	base, _ := parsed.Base()
+	switch code := base.String(); code {
+	case "und":
+		return ""
+	case "no":
+		// Norwegian is detected as Bokmål or Nynorsk.
+		return "nb"
+	default:
+		return code
+	}
+}
+
+// nativeNames supplies the names CLDR lacks or gives differently.
+var nativeNames = map[string]string{
+	"la": "Latina",
+	"mi": "Māori",
+	"st": "Sesotho",
+	"tl": "Tagalog",
+	"tn": "Setswana",
+	"ts": "Xitsonga",
+	"xh": "isiXhosa",
+}
+
+// describeLanguage returns the metadata of a lingua language.
+func describeLanguage(lang lingua.Language) languageInfo {
+	code := strings.ToLower(lang.IsoCode639_1().String())
+	native, ok := nativeNames[code]
+	if !ok {
+		native = display.Self.Name(language.Make(code))
+	}
+	info := languageInfo{
+		Code:       code,
+		Code3:      strings.ToLower(lang.IsoCode639_3().String()),
+		Name:       lang.String(),
+		NativeName: native,
+		Scripts:    langid.LanguageScripts(code),
+	}
+	if languageNames.localized {
+		info.LocalizedName = languageName(code)
+	}
+	return info
+}
+
+// supportedLanguages returns the metadata of all languages of the lingua
+// backend, sorted by English name.
+func supportedLanguages() []languageInfo {
+	all := lingua.AllLanguages()
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].String() < all[j].String()
+	})
+	infos := make([]languageInfo, len(all))
+	for i, lang := range all {
+		infos[i] = describeLanguage(lang)
+	}
+	return infos
+}
+
+// listFormats lists the values accepted by -list-format.
+var listFormats = []string{"text", "json", "csv"}
+
+// listLanguages writes the supported languages to w for -L: "code - name"
+// lines, a JSON array or CSV with a header row.
+func listLanguages(w io.Writer, format string) error {
+	infos := supportedLanguages()
+	switch format {
+	case "text":
+		for _, info := range infos {
+			name := info.Name
+			if info.LocalizedName != "" {
+				name = info.LocalizedName
+			}
+			fmt.Fprintf(w, "%s - %s\n", info.Code, name)
+		}
+		return nil
+	case "json":
+		data, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		header := []string{"iso639_1", "iso639_3", "name", "native_name", "scripts"}
+		if languageNames.localized {
+			header = append(header, "localized_name")
+		}
+		cw.Write(header)
+		for _, info := range infos {
+			record := []string{info.Code, info.Code3, info.Name, info.NativeName, strings.Join(info.Scripts, " ")}
+			if languageNames.localized {
+				record = append(record, info.LocalizedName)
+			}
+			cw.Write(record)
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("invalid -list-format value %q (expected %s)", format, strings.Join(listFormats, ", "))
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding"
+)
+
+// rtfSkipped are the RTF destinations holding no document text.
+var rtfSkipped = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"object": true, "header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true, "fldinst": true,
+	"listtable": true, "listoverridetable": true, "revtbl": true, "rsidtbl": true,
+	"filetbl": true, "themedata": true, "colorschememapping": true, "latentstyles": true,
+	"datastore": true, "xmlnstbl": true, "generator": true, "pgdsctbl": true,
+}
+
+// rtfSymbols are the RTF control words standing for text.
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n", "page": "\n", "row": "\n",
+	"tab": "\t", "cell": "\t", "emdash": "—", "endash": "–", "bullet": "•",
+	"lquote": "‘", "rquote": "’", "ldblquote": "“", "rdblquote": "”",
+	"emspace": " ", "enspace": " ", "qmspace": " ",
+}
+
+// rtfCodePages names the code pages \ansicpg gives whose encodings are not
+// known as windows-N.
+var rtfCodePages = map[int]string{
+	437: "ibm437", 850: "ibm850", 866: "ibm866", 932: "shift_jis", 950: "big5", 10000: "macintosh",
+}
+
+// extractRTF returns the text of an RTF document: its paragraphs, without
+// the font, style and other tables, headers, footers, field instructions
+// and pictures. Characters given by code are decoded in the code page the
+// document declares.
+func extractRTF(data []byte, _ string) (string, error) {
+	type group struct {
+		skip bool
+		// uc is the number of fallback characters following a \u character.
+		uc int
+	}
+	var text strings.Builder
+	stack := []group{{uc: 1}}
+	state := &stack[0]
+	var codePage encoding.Encoding
+	// pending holds the bytes of \'hh escapes and other non-ASCII bytes,
+	// decoded together so that double-byte code pages work.
+	var pending []byte
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if codePage == nil {
+			codePage, _ = lookupEncoding("windows-1252")
+		}
+		if decoded, err := codePage.NewDecoder().Bytes(pending); err == nil && !state.skip {
+			text.Write(decoded)
+		}
+		pending = pending[:0]
+	}
+	// fallback counts the characters still to skip after a \u character.
+	fallback := 0
+	write := func(s string) {
+		if fallback > 0 {
+			fallback--
+			return
+		}
+		if !state.skip {
+			text.WriteString(s)
+		}
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c < 0x80 && (c != '\\' || i+1 >= len(data) || data[i+1] != '\'') {
+			flush()
+		}
+		switch c {
+		case '{':
+			stack = append(stack, *state)
+			state = &stack[len(stack)-1]
+			fallback = 0
+		case '}':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+				state = &stack[len(stack)-1]
+			}
+			fallback = 0
+		case '\r', '\n':
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+			c = data[i]
+			if !isASCIILetter(c) {
+				switch c {
+				case '\'':
+					if i+2 < len(data) {
+						if b, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8); err == nil {
+							if fallback > 0 {
+								fallback--
+							} else {
+								pending = append(pending, byte(b))
+							}
+						}
+						i += 2
+					}
+				case '*':
+					// An ignorable destination: its group holds nothing
+					// this reader understands.
+					state.skip = true
+				case '~':
+					write(" ")
+				case '_':
+					write("-")
+				case '\\', '{', '}':
+					write(string(c))
+				case '\n', '\r':
+					write("\n")
+				}
+				break
+			}
+			start := i
+			for i < len(data) && isASCIILetter(data[i]) {
+				i++
+			}
+			word := string(data[start:i])
+			paramStart := i
+			if i < len(data) && data[i] == '-' {
+				i++
+			}
+			for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+				i++
+			}
+			param, hasParam := 0, i > paramStart
+			if hasParam {
+				param, _ = strconv.Atoi(string(data[paramStart:i]))
+			}
+			// A space ends the control word and belongs to it.
+			if i >= len(data) || data[i] != ' ' {
+				i--
+			}
+			switch {
+			case rtfSkipped[word]:
+				state.skip = true
+			case word == "ansicpg" && hasParam:
+				name, ok := rtfCodePages[param]
+				if !ok {
+					name = "windows-" + strconv.Itoa(param)
+				}
+				if enc, err := lookupEncoding(name); err == nil {
+					codePage = enc
+				}
+			case word == "uc" && hasParam:
+				state.uc = param
+			case word == "u" && hasParam:
+				if param < 0 {
+					param += 65536
+				}
+				write(string(rune(param)))
+				fallback = state.uc
+			case word == "bin" && param > 0:
+				i += param
+			case rtfSymbols[word] != "":
+				write(rtfSymbols[word])
+			}
+		default:
+			if c >= 0x80 {
+				if fallback > 0 {
+					fallback--
+				} else {
+					pending = append(pending, c)
+				}
+				break
+			}
+			write(string(c))
+		}
+	}
+	flush()
+	return text.String(), nil
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// oleMagic starts compound files, the container of legacy Office documents.
+var oleMagic = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+
+// compoundFile reads the streams of a compound file (MS-CFB).
+type compoundFile struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	cutoff     uint32
+	// entries are the directory entries.
+	entries []compoundEntry
+}
+
+type compoundEntry struct {
+	name  string
+	kind  byte
+	start uint32
+	size  uint64
+}
+
+const (
+	cfbEndOfChain = 0xfffffffe
+	cfbMaxSector  = 0xfffffffa
+)
+
+var errCompoundFile = errors.New("malformed compound file")
+
+func openCompoundFile(data []byte) (*compoundFile, error) {
+	if len(data) < 512 || !bytes.HasPrefix(data, oleMagic) {
+		return nil, errCompoundFile
+	}
+	shift := binary.LittleEndian.Uint16(data[0x1e:])
+	if shift != 9 && shift != 12 {
+		return nil, errCompoundFile
+	}
+	f := &compoundFile{data: data, sectorSize: 1 << shift, cutoff: binary.LittleEndian.Uint32(data[0x38:])}
+
+	// The sectors of the FAT are listed in the header and in a chain of
+	// DIFAT sectors.
+	var fatSectors []uint32
+	for i := 0; i < 109; i++ {
+		fatSectors = append(fatSectors, binary.LittleEndian.Uint32(data[0x4c+4*i:]))
+	}
+	difat := binary.LittleEndian.Uint32(data[0x44:])
+	for n := 0; difat <= cfbMaxSector && n < len(data)/f.sectorSize; n++ {
+		sector := f.sector(difat)
+		if sector == nil {
+			return nil, errCompoundFile
+		}
+		for i := 0; i < f.sectorSize/4-1; i++ {
+			fatSectors = append(fatSectors, binary.LittleEndian.Uint32(sector[4*i:]))
+		}
+		difat = binary.LittleEndian.Uint32(sector[f.sectorSize-4:])
+	}
+	for _, s := range fatSectors {
+		if s > cfbMaxSector {
+			continue
+		}
+		sector := f.sector(s)
+		if sector == nil {
+			return nil, errCompoundFile
+		}
+		for i := 0; i < f.sectorSize/4; i++ {
+			f.fat = append(f.fat, binary.LittleEndian.Uint32(sector[4*i:]))
+		}
+	}
+
+	directory, err := f.chain(binary.LittleEndian.Uint32(data[0x30:]), f.fat, f.sector)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+128 <= len(directory); i += 128 {
+		entry := directory[i : i+128]
+		nameLen := int(binary.LittleEndian.Uint16(entry[64:]))
+		if nameLen < 2 || nameLen > 64 {
+			f.entries = append(f.entries, compoundEntry{})
+			continue
+		}
+		units := make([]uint16, nameLen/2-1)
+		for j := range units {
+			units[j] = binary.LittleEndian.Uint16(entry[2*j:])
+		}
+		size := binary.LittleEndian.Uint64(entry[120:])
+		if f.sectorSize == 512 {
+			size &= 0xffffffff
+		}
+		f.entries = append(f.entries, compoundEntry{
+			name:  string(utf16.Decode(units)),
+			kind:  entry[66],
+			start: binary.LittleEndian.Uint32(entry[116:]),
+			size:  size,
+		})
+	}
+	if len(f.entries) == 0 || f.entries[0].kind != 5 {
+		return nil, errCompoundFile
+	}
+
+	// Small streams live in the mini stream, the stream of the root entry,
+	// in 64-byte sectors allocated by the mini FAT.
+	miniFAT, err := f.chain(binary.LittleEndian.Uint32(data[0x3c:]), f.fat, f.sector)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+4 <= len(miniFAT); i += 4 {
+		f.miniFAT = append(f.miniFAT, binary.LittleEndian.Uint32(miniFAT[i:]))
+	}
+	if f.miniStream, err = f.chain(f.entries[0].start, f.fat, f.sector); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// sector returns sector s, or nil if it lies outside the file.
+func (f *compoundFile) sector(s uint32) []byte {
+	offset := (int(s) + 1) * f.sectorSize
+	if s > cfbMaxSector || offset+f.sectorSize > len(f.data) {
+		return nil
+	}
+	return f.data[offset : offset+f.sectorSize]
+}
+
+func (f *compoundFile) miniSector(s uint32) []byte {
+	offset := int(s) * 64
+	if s > cfbMaxSector || offset+64 > len(f.miniStream) {
+		return nil
+	}
+	return f.miniStream[offset : offset+64]
+}
+
+// chain returns the content of the sectors of a chain starting at start.
+func (f *compoundFile) chain(start uint32, fat []uint32, sector func(uint32) []byte) ([]byte, error) {
+	var data []byte
+	for s, n := start, 0; s != cfbEndOfChain && s <= cfbMaxSector; n++ {
+		content := sector(s)
+		if content == nil || int(s) >= len(fat) || n > len(fat) {
+			return nil, errCompoundFile
+		}
+		data = append(data, content...)
+		s = fat[s]
+	}
+	return data, nil
+}
+
+// stream returns the content of the stream with the given name.
+func (f *compoundFile) stream(name string) ([]byte, error) {
+	for _, entry := range f.entries[1:] {
+		if entry.kind != 2 || entry.name != name {
+			continue
+		}
+		var data []byte
+		var err error
+		if entry.size < uint64(f.cutoff) {
+			data, err = f.chain(entry.start, f.miniFAT, f.miniSector)
+		} else {
+			data, err = f.chain(entry.start, f.fat, f.sector)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) < entry.size {
+			return nil, errCompoundFile
+		}
+		return data[:entry.size], nil
+	}
+	return nil, errors.New("no " + name + " stream")
+}
+
+// extractDOC returns the main text of a Word 97-2003 document, read from its
+// piece table the way antiword does, with paragraphs on lines of their own,
+// table cells separated by tabs and fields replaced by their results.
+func extractDOC(data []byte, _ string) (string, error) {
+	cf, err := openCompoundFile(data)
+	if err != nil {
+		return "", err
+	}
+	word, err := cf.stream("WordDocument")
+	if err != nil {
+		return "", err
+	}
+	if len(word) < 0x1aa || binary.LittleEndian.Uint16(word) != 0xa5ec {
+		return "", errors.New("not a Word 97 or later document")
+	}
+	flags := binary.LittleEndian.Uint16(word[0x0a:])
+	if flags&0x0100 != 0 {
+		return "", errors.New("encrypted document")
+	}
+	tableName := "0Table"
+	if flags&0x0200 != 0 {
+		tableName = "1Table"
+	}
+	table, err := cf.stream(tableName)
+	if err != nil {
+		return "", err
+	}
+	textLength := binary.LittleEndian.Uint32(word[0x4c:])
+	clxOffset := binary.LittleEndian.Uint32(word[0x1a2:])
+	clxLength := binary.LittleEndian.Uint32(word[0x1a6:])
+	if uint64(clxOffset)+uint64(clxLength) > uint64(len(table)) {
+		return "", errors.New("malformed piece table")
+	}
+	clx := table[clxOffset : clxOffset+clxLength]
+	// The piece table follows any property modifiers.
+	for len(clx) > 0 && clx[0] == 0x01 {
+		if len(clx) < 3 {
+			return "", errors.New("malformed piece table")
+		}
+		clx = clx[min(3+int(binary.LittleEndian.Uint16(clx[1:])), len(clx)):]
+	}
+	if len(clx) < 5 || clx[0] != 0x02 {
+		return "", errors.New("malformed piece table")
+	}
+	plc := clx[5:min(5+int(binary.LittleEndian.Uint32(clx[1:])), len(clx))]
+	pieces := (len(plc) - 4) / 12
+	if pieces < 1 {
+		return "", errors.New("malformed piece table")
+	}
+	cp1252, _ := lookupEncoding("windows-1252")
+
+	var raw []rune
+	for i := 0; i < pieces; i++ {
+		start := binary.LittleEndian.Uint32(plc[4*i:])
+		end := min(binary.LittleEndian.Uint32(plc[4*(i+1):]), textLength)
+		if start >= end {
+			continue
+		}
+		n := int(end - start)
+		pcd := plc[4*(pieces+1)+8*i:]
+		fc := binary.LittleEndian.Uint32(pcd[2:])
+		if fc&0x40000000 != 0 {
+			// Compressed pieces hold one byte per character in code page
+			// 1252.
+			offset := int(fc&0x3fffffff) / 2
+			if offset+n > len(word) {
+				return "", errors.New("malformed piece table")
+			}
+			decoded, err := cp1252.NewDecoder().Bytes(word[offset : offset+n])
+			if err != nil {
+				return "", err
+			}
+			raw = append(raw, []rune(string(decoded))...)
+		} else {
+			offset := int(fc)
+			if offset+2*n > len(word) {
+				return "", errors.New("malformed piece table")
+			}
+			units := make([]uint16, n)
+			for j := range units {
+				units[j] = binary.LittleEndian.Uint16(word[offset+2*j:])
+			}
+			raw = append(raw, utf16.Decode(units)...)
+		}
+	}
+
+	var text strings.Builder
+	// fields holds, for every field being read, whether its instructions
+	// are over and its result is being read.
+	var fields []bool
+	for _, r := range raw {
+		switch r {
+		case 0x13:
+			fields = append(fields, false)
+			continue
+		case 0x14:
+			if len(fields) > 0 {
+				fields[len(fields)-1] = true
+			}
+			continue
+		case 0x15:
+			if len(fields) > 0 {
+				fields = fields[:len(fields)-1]
+			}
+			continue
+		}
+		if len(fields) > 0 && !fields[len(fields)-1] {
+			continue
+		}
+		switch r {
+		case '\r', 0x0b, 0x0c:
+			text.WriteByte('\n')
+		case 0x07:
+			text.WriteByte('\t')
+		case 0x1e:
+			text.WriteByte('-')
+		case '\t':
+			text.WriteByte('\t')
+		default:
+			if r >= 0x20 {
+				text.WriteRune(r)
+			}
+		}
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// oversizedPolicy decides what happens to records longer than
+// -max-record-bytes.
+type oversizedPolicy string
+
+const (
+	// oversizedTruncate classifies the first -max-record-bytes of the record.
+	oversizedTruncate oversizedPolicy = "truncate"
+	// oversizedSkip drops the record.
+	oversizedSkip oversizedPolicy = "skip"
+	// oversizedFail aborts the run.
+	oversizedFail oversizedPolicy = "fail"
+)
+
+func parseOversizedPolicy(value string) (oversizedPolicy, error) {
+	switch p := oversizedPolicy(value); p {
+	case oversizedTruncate, oversizedSkip, oversizedFail:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid -on-oversized value %q (expected truncate, skip or fail)", value)
+}
+
+// recordLimit bounds the size of a record of the input, a line in line
+// mode and the whole input otherwise, so that a single huge record, such as
+// a line of gigabytes without a line break, can not exhaust memory.
+type recordLimit struct {
+	// maxBytes is the size beyond which records are oversized, including
+	// the line terminator of lines (0 for no limit).
+	maxBytes int
+	policy   oversizedPolicy
+}
+
+// check applies the limit to text, the first bytes of a record of size
+// bytes, reporting oversized records on stderr as where. It returns the text
+// to classify, cut at the limit, and false if the record is to be dropped,
+// or an error for oversizedFail.
+func (l recordLimit) check(text string, size int64, where string) (string, bool, error) {
+	if l.maxBytes <= 0 || size <= int64(l.maxBytes) {
+		return text, true, nil
+	}
+	switch l.policy {
+	case oversizedFail:
+		return "", false, fmt.Errorf("%s: longer than %d bytes (see -max-record-bytes)", where, l.maxBytes)
+	case oversizedSkip:
+		fmt.Fprintf(os.Stderr, "warning: %s: longer than %d bytes, skipped\n", where, l.maxBytes)
+		return "", false, nil
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s: longer than %d bytes, truncated\n", where, l.maxBytes)
+	return cutBytes(text, l.maxBytes), true, nil
+}
+
+// cutBytes returns the longest prefix of text of at most n bytes that does
+// not end within a character.
+func cutBytes(text string, n int) string {
+	if len(text) <= n {
+		return text
+	}
+	return text[:completeRunes([]byte(text[:n]))]
+}
+
+// lineReader reads lines like newLineScanner, keeping their line
+// terminators, but holds at most maxBytes of each line (plus its
+// terminator) in memory and skips over the rest, counting it in Size.
+type lineReader struct {
+	r        *bufio.Reader
+	maxBytes int
+	line     []byte
+	size     int64
+	err      error
+}
+
+func newLineReader(r io.Reader, maxBytes int) *lineReader {
+	return &lineReader{r: bufio.NewReader(r), maxBytes: maxBytes}
+}
+
+// Scan reads the next line, reporting false at the end of the input or on
+// an error.
+func (l *lineReader) Scan() bool {
+	if l.err != nil {
+		return false
+	}
+	l.line, l.size = l.line[:0], 0
+	for {
+		chunk, err := l.r.ReadSlice('\n')
+		l.size += int64(len(chunk))
+		if l.maxBytes <= 0 {
+			l.line = append(l.line, chunk...)
+		} else if room := l.maxBytes - len(l.line); room > 0 {
+			l.line = append(l.line, chunk[:min(room, len(chunk))]...)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			l.err = err
+		}
+		if l.size > int64(len(l.line)) {
+			// A cut line ends with a whole character and keeps its
+			// terminator.
+			l.line = l.line[:completeRunes(l.line)]
+			if len(chunk) > 0 && chunk[len(chunk)-1] == '\n' {
+				l.line = append(l.line, '\n')
+			}
+		}
+		return l.size > 0
+	}
+}
+
+// Text returns the line read by Scan, cut at maxBytes if it is longer.
+func (l *lineReader) Text() string { return string(l.line) }
+
+// Size returns the length of the line read by Scan in the input.
+func (l *lineReader) Size() int64 { return l.size }
+
+// Err returns the first error other than io.EOF.
+func (l *lineReader) Err() error { return l.err }
@@ -4,46 +4,28 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"sort"
-	"strconv"
+	"slices"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
-	lingua "github.com/pemistahl/lingua-go"
+	"github.com/rinodrops/lingua-cli-go/langid"
 )
 
 const version = "0.2.0"
 
-// isoCodeToLanguage maps an ISO 639-1 code string to a lingua.Language.
-// Returns lingua.Unknown and false if the code is not recognized.
-func isoCodeToLanguage(code string) (lingua.Language, bool) {
-	upper := strings.ToUpper(code)
-	for _, lang := range lingua.AllLanguages() {
-		if lang.IsoCode639_1().String() == upper {
-			return lang, true
-		}
-	}
-	return lingua.Unknown, false
-}
-
-// isoCode639_1 returns the lowercase ISO 639-1 code string for a language,
-// matching the output format of the Rust lingua-cli.
-func isoCode639_1(lang lingua.Language) string {
-	return strings.ToLower(lang.IsoCode639_1().String())
-}
-
-// formatScore formats a confidence score to match the Rust lingua-cli output:
-// exactly 1.0 is printed as "1", all other values use up to 16 significant decimal digits.
-func formatScore(score float64) string {
-	if score == 1.0 {
-		return "1"
-	}
-	return strconv.FormatFloat(score, 'f', 16, 64)
-}
+// Defaults applied by -short unless -c or -M are given explicitly.
+const (
+	shortTextConfidence = 0.5
+	shortTextMinLength  = 3
+)
 
 // longEnough returns true if the text contains at least minLength alphabetic characters.
 func longEnough(text string, minLength int) bool {
@@ -59,227 +41,2050 @@ func longEnough(text string, minLength int) bool {
 	return false
 }
 
-// printConfidenceValues prints language detection results to stdout.
-// If all is false, only the top result is printed.
-// If a confidence threshold is set, results below it are suppressed (printing "unknown" instead).
-func printConfidenceValues(
-	results []lingua.ConfidenceValue,
-	delimiter string,
-	confidenceThreshold float64,
-	hasThreshold bool,
-	all bool,
-) {
-	found := false
-	for _, result := range results {
-		score := result.Value()
-		if !hasThreshold || score >= confidenceThreshold {
-			found = true
-			fmt.Printf("%s%s%s\n", isoCode639_1(result.Language()), delimiter, formatScore(score))
-		}
-		if !all {
-			break
-		}
-	}
-	if !found {
-		fmt.Printf("unknown%s\n", delimiter)
+// truncateRunes returns the first n runes of text, or all of text if n is 0.
+func truncateRunes(text string, n int) string {
+	if n <= 0 || len(text) <= n {
+		return text
 	}
+	count := 0
+	for i := range text {
+		if count == n {
+			return text[:i]
+		}
+		count++
+	}
+	return text
 }
 
-// printLineWithConfidenceValues prints per-line detection results including the original line.
-func printLineWithConfidenceValues(
-	line string,
-	results []lingua.ConfidenceValue,
-	delimiter string,
-	confidenceThreshold float64,
-	hasThreshold bool,
-	all bool,
-) {
-	printed := false
-	for _, result := range results {
-		score := result.Value()
-		if !hasThreshold || score >= confidenceThreshold {
-			fmt.Printf("%s%s%s%s%s\n",
-				isoCode639_1(result.Language()), delimiter,
-				formatScore(score), delimiter,
-				line,
-			)
-			printed = true
-		} else {
-			fmt.Printf("unknown%s%s%s\n", delimiter, delimiter, line)
-			printed = true
+// wordCount returns the number of whitespace-delimited tokens in text that
+// contain at least one letter.
+func wordCount(text string) int {
+	count := 0
+	for _, token := range strings.Fields(text) {
+		if strings.IndexFunc(token, unicode.IsLetter) >= 0 {
+			count++
 		}
-		if !all {
-			break
+	}
+	return count
+}
+
+// letterRatio returns the share of letters among the non-space characters of
+// text, or 1 for text without any.
+func letterRatio(text string) float64 {
+	letters, total := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) {
+			letters++
 		}
 	}
-	if !printed {
-		fmt.Printf("unknown%s%s%s\n", delimiter, delimiter, line)
+	if total == 0 {
+		return 1
 	}
+	return float64(letters) / float64(total)
 }
 
-// printWithOffset prints multi-language detection results with byte offsets.
-func printWithOffset(results []lingua.DetectionResult, text string, delimiter string) {
-	for _, result := range results {
-		start := result.StartIndex()
-		end := result.EndIndex()
-		fragment := text[start:end]
-		fmt.Printf("%d%s%d%s%s%s%s\n",
-			start, delimiter,
-			end, delimiter,
-			isoCode639_1(result.Language()), delimiter,
-			fragment,
-		)
+// exitOnDetectError aborts the run when a backend fails to process a text.
+func exitOnDetectError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
 	}
 }
 
+// commands lists the subcommands in the order of the help, with their
+// summaries.
+var commands = []struct{ name, summary string }{
+	{"train", "Build a custom language profile from a monolingual corpus"},
+	{"calibrate", "Fit a confidence calibration curve on a labeled development set"},
+	{"tune", "Recommend -c and -d thresholds for a target precision"},
+	{"eval", "Evaluate detection accuracy on a labeled corpus"},
+	{"compare", "Compare the results of two detection configurations"},
+	{"diff", "Compare two files of JSON results, such as before and after an upgrade"},
+	{"golden", "Compare the results of fixture corpora with committed golden files"},
+	{"source", "Classify the comments and string literals of source code"},
+	{"urls", "Report the language of web pages listed in a file or sitemap"},
+	{"htmllang", "Check the lang and hreflang attributes of HTML pages against their text"},
+	{"mdlang", "Check the language declared in the front matter of Markdown files"},
+	{"polang", "Check the languages of messages and translations in gettext catalogs"},
+	{"i18nlang", "Check the languages of the values of localization files and app resources"},
+	{"sublang", "Report the language consistency of subtitle files"},
+	{"hook", "Check the language of commit messages or documentation as a git hook"},
+	{"info", "Print metadata about languages and whether they are detected"},
+	{"clean", "Clean a corpus for training: scrub, normalize, filter by language and dedupe"},
+	{"gen-man", "Generate the man page or Markdown documentation of the options"},
+	{"serve", "Answer detection requests over HTTP, for the client command and web services"},
+	{"client", "Classify like this with a running detection server (-addr host:port)"},
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "train":
+			os.Exit(runTrain(os.Args[2:]))
+		case "calibrate":
+			os.Exit(runCalibrate(os.Args[2:]))
+		case "tune":
+			os.Exit(runTune(os.Args[2:]))
+		case "eval":
+			os.Exit(runEval(os.Args[2:]))
+		case "compare":
+			os.Exit(runCompare(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "golden":
+			os.Exit(runGolden(os.Args[2:]))
+		case "source":
+			os.Exit(runSource(os.Args[2:]))
+		case "urls":
+			os.Exit(runURLs(os.Args[2:]))
+		case "htmllang":
+			os.Exit(runHTMLLang(os.Args[2:]))
+		case "mdlang":
+			os.Exit(runMDLang(os.Args[2:]))
+		case "polang":
+			os.Exit(runPOLang(os.Args[2:]))
+		case "i18nlang":
+			os.Exit(runI18NLang(os.Args[2:]))
+		case "sublang":
+			os.Exit(runSubLang(os.Args[2:]))
+		case "hook":
+			os.Exit(runHook(os.Args[2:]))
+		case "info":
+			os.Exit(runInfo(os.Args[2:]))
+		case "clean":
+			os.Exit(runClean(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "client":
+			// client is detect mode with the remote backend, so that it
+			// takes the same options and prints the same output.
+			os.Args = append([]string{os.Args[0], "-backend", "remote"}, os.Args[2:]...)
+		}
+	}
+
 	// --- flag definitions ---
-	languages := flag.String("l", "",
-		"Comma seperated list of iso-639-1 codes of languages to detect, if not specified, all supported language will be used. Setting this improves accuracy and resource usage.")
+	var cfg detectorConfig
+	cfg.register(flag.CommandLine)
 	perLine := flag.Bool("n", false,
 		"Classify language per line, this only works if text is not supplied directly as an argument")
 	listLangs := flag.Bool("L", false,
 		"List all supported languages")
+	namesLocale := flag.String("names-locale", "",
+		"Print language names in this locale, e.g. 'de' or 'pt-BR', in -L listings and the name column of -columns (default: English).")
+	listFormat := flag.String("list-format", "text",
+		"Format of the -L listing: text, json or csv. JSON and CSV include ISO 639-3 codes, native names and scripts.")
 	showAll := flag.Bool("a", false,
 		"Show all confidence values (entire probability distribution), rather than just the winning score. Does not work with --multi")
-	quick := flag.Bool("q", false,
-		"Quick/low accuracy mode")
+	top := flag.Int("top", 0,
+		"With -a, print only the N best languages (0 prints all of them).")
+	hideBelow := flag.Float64("hide-below", 0,
+		"With -a, leave out languages scoring below this, e.g. 0.001 to hide the near-zero scores of unlikely languages.")
+	renormalize := flag.Bool("renormalize", false,
+		"With -a, rescale the printed scores to sum to 1 after -top and -hide-below.")
 	multi := flag.Bool("m", false,
 		"Classify multiple languages in mixed texts, will return matches along with UTF-8 byte offsets. Can not be combined with line mode.")
+	tokens := flag.Bool("tokens", false,
+		"Assign a language to every word, printed like -m with UTF-8 byte offsets, for code-switching analysis. Each word is classified on its own, in the context of its neighbours. Can not be combined with -m or line mode.")
+	mergeSpans := flag.Bool("merge-spans", false,
+		"With -m or -tokens, join consecutive spans of the same language into one.")
+	minSpan := flag.Int("min-span", 0,
+		"With -m or -tokens, merge spans of fewer than this many words into the neighbouring span with more words (0 disables).")
+	each := flag.Bool("each", false,
+		"Classify each text argument on its own instead of joining them into one text, printing the number of the argument before each result.")
+	textsFrom := flag.String("texts-from", "",
+		"Classify the texts in this file, one per line or, if it contains NUL bytes, separated by NUL, like -each, leaving stdin free.")
+	clipboard := flag.Bool("clipboard", false,
+		"Classify the text on the system clipboard (read with pbpaste on macOS, PowerShell on Windows, and wl-paste, xclip or xsel elsewhere).")
 	confidenceVal := flag.Float64("c", 0,
 		"Confidence threshold, only output results with at least this confidence value (0.0-1.0)")
 	minLength := flag.Int("M", 0,
 		"Minimum text length (without regard for whitespace, punctuation or numerals!). Shorter fragments will be classified as 'unknown'")
-	minRelDist := flag.Float64("d", 0,
-		"Minimum relative distance between top language probabilities (0.0-1.0).")
+	maxChars := flag.Int("max-chars", 0,
+		"Only look at the first N characters of each text (0 for no limit). Accuracy hardly improves beyond a few thousand characters.")
+	minWords := flag.Int("min-words", 0,
+		"Minimum number of words (whitespace-delimited tokens containing letters). Texts with fewer words will be classified as 'unknown'")
+	minLetterRatio := flag.Float64("min-letter-ratio", 0,
+		"Classify texts in which letters make up less than this share of the non-space characters as 'unknown', e.g. log lines full of IDs and numbers (0.0-1.0, 0 disables).")
+	emptyLines := flag.String("empty-lines", "detect",
+		"In line mode, what to do with empty and whitespace-only lines: detect (classify them like any other line), skip (print nothing), pass (print the line unchanged) or unknown (label them 'unknown').")
+	skipFiltered := flag.Bool("skip-filtered", false,
+		"In line mode, leave out lines rejected by -M, -min-words or -min-letter-ratio instead of printing them as 'unknown'.")
+	jsonlInput := flag.Bool("jsonl", false,
+		"Read JSON Lines (implies -n): classify the -text-field of each object and print the object with all its fields and the result added as -lang-field and -conf-field.")
+	textField := flag.String("text-field", "text",
+		"With -jsonl, the field holding the text, a dotted path such as 'payload.text' for nested objects; with -csv, the name or number of the column.")
+	langField := flag.String("lang-field", "lang",
+		"With -jsonl, the field the language is written to, a dotted path for nested objects; with -csv, the name of the column.")
+	confField := flag.String("conf-field", "confidence",
+		"With -jsonl, the field the confidence value is written to, a dotted path for nested objects; with -csv, the name of the column.")
+	hintField := flag.String("hint-field", "",
+		"With -jsonl or -csv, the field or column holding the language each record is expected to be in, such as the user's UI locale: one or more comma-separated codes, BCP 47 tags or locales, applied as -hint-mode says. Records without one are classified as usual.")
+	hintMode := flag.String("hint-mode", "prior",
+		"How -hint-field applies, before -d and -max-entropy decide: prior (multiply the scores of the hinted languages by 1 plus -hint-weight, breaking near-ties in their favour) or restrict (keep only the scores of the hinted languages, rescaled; the detector still scores all languages of -l, and a record hinting only at languages outside -l is unknown).")
+	hintWeight := flag.Float64("hint-weight", 3,
+		"Strength of the prior of -hint-mode prior.")
+	csvInput := flag.Bool("csv", false,
+		"Read CSV: classify the -text-field column of each row and print the rows with the result added as the -lang-field and -conf-field columns, in the same dialect.")
+	csvDelimiter := flag.String("csv-delimiter", "auto",
+		"With -csv, the field delimiter, such as ';' or '\\t', or 'auto' to detect ',', ';', tab or '|'.")
+	csvQuote := flag.String("csv-quote", `"`,
+		"With -csv, the quote character, or '' for fields that are never quoted.")
+	csvEscape := flag.String("csv-escape", "",
+		"With -csv, a character making the next one literal, such as '\\'; by default, quotes in quoted fields are doubled.")
+	csvHeader := flag.Bool("csv-header", true,
+		"With -csv, whether the first row is a header naming the columns; without one (-csv-header=false), -text-field is a column number and the result columns are appended.")
+	csvRagged := flag.String("csv-ragged", "error",
+		"With -csv, what to do with rows whose number of fields differs from the first row: error, pad (fill missing fields and drop extra ones), skip (drop the row with a warning) or pass (print it unchanged).")
 	delimiter := flag.String("D", "\t",
 		"Output column delimiter.")
-	showVersion := flag.Bool("V", false, "Print version")
+	format := flag.String("format", "tsv",
+		"Output format: tsv (delimited columns), json-v1 (a JSON object per result and line, see -print-schema), json-run (one JSON document for the whole run, with its input, timing, language shares and json-v1 records, at the end of the run), ecs (json-v1 mapped onto the Elastic Common Schema), avro (the json-v1 records as an Avro container file), protobuf (the json-v1 records as length-delimited protobuf messages, see schema/result-v1.proto), cbor (the json-v1 records as a single CBOR document, an array closed at the end of the run), cbor-seq (the json-v1 records as a CBOR sequence, one map per result) or fasttext ('__label__en 0.98' and the line after a tab, like fastText's predict-prob).")
+	printSchema := flag.Bool("print-schema", false,
+		"Print the JSON Schema of the -format json-v1 output and exit.")
+	columnList := flag.String("columns", "",
+		"Comma-separated columns to print, in this order, instead of the default ones: file, line, source (file:line), offset, lang, name, score, script, direction, entropy, margin, mixed (mixed-script), encoding, bucket, text, and start and end for -m.")
+	numberLines := flag.Bool("number-lines", false,
+		"In line mode, print the line number and byte offset of each line in the input before its result, for joining results back to the source.")
+	labelMapFile := flag.String("label-map", "",
+		"File of 'from to' lines rewriting output labels, e.g. 'nb no' or 'unknown und'. Languages mapped to the same label have their scores summed.")
+	unknownLabel := flag.String("unknown-label", langid.Unknown,
+		"Label printed for texts that could not be classified, e.g. 'und' (BCP 47) or '' for an empty field. Overrides an 'unknown' entry of -label-map.")
+	smoothWindow := flag.Int("smooth", 0,
+		"In line mode, blend each line's distribution with the average of the previous N lines (0 disables).")
+	smoothWeight := flag.Float64("smooth-weight", 0.3,
+		"Weight of the previous lines when smoothing with -smooth (0.0-1.0).")
+	workers := flag.Int("j", 1,
+		"Number of lines (or files, with -files) to classify in parallel (0 uses one worker per thread, see -threads).")
+	threads := flag.Int("threads", 0,
+		"Number of threads running Go code at once, setting GOMAXPROCS, and the number of workers of -j 0 (0 for GOMAXPROCS, one per CPU unless the environment sets it). Set it to the cores granted to a container, which the CPU count over-reports.")
+	unordered := flag.Bool("unordered", false,
+		"In line mode, print results as soon as they are ready, prefixed with their line number, instead of in input order.")
+	inputEncoding := flag.String("encoding", "utf-8",
+		"Character encoding of stdin, e.g. windows-1252, shift_jis or koi8-r; 'auto' detects it from the input.")
+	showEncoding := flag.Bool("show-encoding", false,
+		"Add the encoding of the input as a column after the score.")
+	precision := flag.Int("precision", -1,
+		"Print scores with this many decimals, e.g. 4 (default: 16 decimals, and '1' for a score of exactly 1).")
+	percent := flag.Bool("percent", false,
+		"Print scores as percentages, e.g. 93.21%, with 2 decimals unless -precision is given.")
+	showRaw := flag.Bool("raw-scores", false,
+		"Print the backend's unnormalized scores instead of confidence values, which show how well a text fits each language in absolute terms. Only custom profiles (-profiles) report them, as the mean log-probability per n-gram.")
+	showEntropy := flag.Bool("entropy", false,
+		"Add the normalized entropy of the confidence distribution as a column after the score: 0 when all confidence is on one language, 1 when it is spread evenly.")
+	showMargin := flag.Bool("margin", false,
+		"Add the difference between the scores of the two best languages, the distance -d compares, as a column after the score, for deciding on near-ties downstream without -a.")
+	mixedScript := flag.Bool("mixed-script", false,
+		"Add a column marking texts that mix scripts, such as Latin and Cyrillic, with mixed-script: two scripts each making up a tenth of the letters, or a word of letters of several, to route them to -m or to spot look-alike letters spoofing words. json-v1 lists the scripts as mixed_script.")
+	onInvalidUTF8 := flag.String("on-invalid-utf8", "passthrough",
+		"What to do with input that is not valid UTF-8: skip, replace (with U+FFFD), fail or passthrough. All but passthrough report the affected lines on stderr.")
+	maxRecordBytes := flag.Int("max-record-bytes", 0,
+		"Largest record, a line in line mode and the whole input otherwise, that is read whole, in bytes including the line terminator (0 for no limit), so that a huge input without line breaks can not exhaust memory. -stdio rejects longer messages.")
+	onOversized := flag.String("on-oversized", "truncate",
+		"What to do with records longer than -max-record-bytes: truncate (classify their first -max-record-bytes), skip or fail. All report the affected records on stderr.")
+	outputFile := flag.String("output", "",
+		"Write results to this file instead of stdout.")
+	buckets := flag.String("buckets", "",
+		"Add a bucket column sorting results by their best score into high, medium and low confidence at these comma-separated cutoffs, e.g. '0.9,0.6', or into high and low at a single cutoff.")
+	bucketOutput := flag.String("bucket-output", "",
+		"With -buckets, write the results of each bucket to its own file, named by this pattern with {bucket} replaced by the name of the bucket, e.g. 'review-{bucket}.tsv'.")
+	samples := flag.Int("samples", 0,
+		"Instead of the results, print N random texts per language at the end of the run, most frequent language first, to check whether each language looks right. With -c, texts below it are not sampled.")
+	isLang := flag.String("is", "",
+		"Decide whether each text is in this language: print yes or no instead of the language, with the confidence of this language rather than of the best one, and exit with status 1 if any text is not. Texts are in the language if its confidence reaches -c (0.5 without -c).")
+	groupByLang := flag.Bool("group-by-lang", false,
+		"Hold back the results until the end of the run and print them grouped by language, under a heading with the language and its number of results, most frequent language first; with -format json-v1, as one JSON object mapping each language to its records.")
+	sortOrder := flag.String("sort", "",
+		"Hold back the results until the end of the run and print them sorted by confidence, language or line, ascending or, with :desc appended, descending (e.g. 'confidence' puts the least confident first). With -a, the distribution of each text is sorted by confidence or language as well.")
+	seed := flag.Int64("seed", 1,
+		"Random seed for -samples.")
+	execCommand := flag.String("exec", "",
+		"Run this command for every result, with {lang}, {score}, {file}, {line} and {text} in its arguments replaced by the result, which is also in the environment variables LINGUA_LANG, LINGUA_SCORE, LINGUA_FILE, LINGUA_LINE and LINGUA_TEXT. Its output goes to stderr.")
+	execLangs := flag.String("exec-lang", "",
+		"With -exec, only run the command for results in these comma-separated languages ('unknown' included).")
+	webhookURL := flag.String("webhook", "",
+		"POST the results to this URL as JSON arrays of json-v1 records, retrying failed requests with exponential backoff.")
+	webhookBatch := flag.Int("webhook-batch", 100,
+		"Number of results -webhook sends per request; fewer are sent when no more arrive for 5 seconds and at the end.")
+	webhookRetries := flag.Int("webhook-retries", 3,
+		"How often -webhook repeats a request after a network error, a rate limit (429) or a server error (5xx) before dropping its results.")
+	mqttURL := flag.String("mqtt", "",
+		"Publish the results as json-v1 records to the MQTT broker at this URL, mqtt://[user:password@]host[:port] or mqtts:// for TLS.")
+	mqttTopic := flag.String("mqtt-topic", "lingua/{lang}",
+		"Topic -mqtt publishes to, with {lang} replaced by the language of the result.")
+	mqttQoS := flag.Int("mqtt-qos", 0,
+		"Quality of service of -mqtt messages: 0 (at most once), 1 (at least once) or 2 (exactly once).")
+	fluentdURL := flag.String("fluentd", "",
+		"Send the results as events to the Fluentd or Fluent Bit forward input at this address, tcp://host[:port] or host[:port], with the fields of json-v1 records.")
+	fluentdTag := flag.String("fluentd-tag", "lingua.{lang}",
+		"Tag of the -fluentd events, with {lang} replaced by the language of the result.")
+	syslogListen := flag.String("syslog", "",
+		"Classify the messages of syslog clients sending to this address, udp://[host]:port or tcp://[host]:port, in the RFC 5424 or BSD format, until interrupted, printing a numbered result per message.")
+	syslogIdleTimeout := flag.Duration("syslog-idle-timeout", 0,
+		"With -syslog over TCP, close connections that send nothing for this long, e.g. 10m (default: no limit).")
+	syslogKeepAlive := flag.Duration("syslog-keepalive", 15*time.Second,
+		"With -syslog over TCP, the period of the TCP keepalive probes that detect clients gone without closing their connections, or 0 for none.")
+	syslogMaxConns := flag.Int("syslog-max-conns", 0,
+		"With -syslog over TCP, the largest number of open connections; further ones are closed at once and reported on standard error (default: no limit).")
+	syslogForward := flag.String("syslog-forward", "",
+		"With -syslog, forward every message in the RFC 5424 format to the syslog server at this address, udp://host[:port] or tcp://host[:port], adding its language and score as structured data.")
+	natsURL := flag.String("nats", "",
+		"NATS server for -nats-subject and -nats-publish, nats://[user:password@ or token@]host[:port] or tls:// for TLS.")
+	natsSubject := flag.String("nats-subject", "",
+		"Classify the messages of this NATS subject, like -each does its texts, until interrupted, answering requests with their json-v1 record.")
+	natsQueue := flag.String("nats-queue", "",
+		"With -nats-subject, join this queue group, sharing the messages with the other members of the group.")
+	natsPublish := flag.String("nats-publish", "",
+		"Publish the results as json-v1 records to this NATS subject, with {lang} replaced by the language of the result.")
+	zmqAddress := flag.String("zmq", "",
+		"Classify the messages received on a ZeroMQ socket of -zmq-type at this endpoint, tcp://host:port to connect to or tcp://*:port to bind, like -each does its texts, until interrupted.")
+	zmqType := flag.String("zmq-type", "pull",
+		"Type of the -zmq socket: pull, receiving the texts of PUSH sockets, or rep, answering the requests of REQ sockets with their json-v1 record.")
+	zmqPush := flag.String("zmq-push", "",
+		"Send the results as json-v1 records on a ZeroMQ PUSH socket at this endpoint, tcp://host:port to connect to or tcp://*:port to bind, in turn to its PULL peers.")
+	reportFile := flag.String("report", "",
+		"At the end of the run, write an HTML report of the results to this file: the distribution of languages and scores, random samples per language and the texts below -c (0.5 without -c).")
+	idle := flag.Duration("idle", 0,
+		"Without -n, classify the input received so far whenever no more arrives for this long (e.g. 2s), for long-lived pipes that never reach EOF.")
+	chunkSize := flag.Int("chunk-size", 0,
+		"Without -n, classify the input in chunks of at most this many bytes, cut at whitespace where possible.")
+	chunkBoundary := flag.String("chunk-boundary", "word",
+		"Where -chunk-size cuts the input where possible: between characters (char), after whitespace (word) or after the end of a sentence (sentence). Chunks never end inside a UTF-8 sequence.")
+	filesMode := flag.Bool("files", false,
+		"Treat the arguments as files, directories (searched recursively), glob patterns such as '**/*.md', http(s):// URLs or s3://, gs:// and az:// URIs, and print each file's name with its result. With -n, the lines of each file are classified. Use -j to process several files at a time.")
+	aggregate := flag.Bool("aggregate", false,
+		"With -files -n, print the share of lines in each language per file instead of the result of every line.")
+	pathLang := flag.String("path-lang", "",
+		"With -files, use the language named by each file's path, as in foo.de.md, locales/fr/ or messages_ja.properties: as a prior, multiplying its score by 1 plus -path-lang-weight (prior), or as the expected language, reporting the files detected in another one on standard error and exiting with status 1 (check).")
+	pathLangWeight := flag.Float64("path-lang-weight", 3,
+		"Strength of the prior of -path-lang prior.")
+	pathLangRules := flag.String("path-lang-rules", "",
+		"File of regular expressions, one per line, matched against the paths for -path-lang in place of the built-in conventions. The first matching one names the language with its group named lang, or else its first group, e.g. ^docs/([a-z]{2})/.")
+	manifestFile := flag.String("lang-manifest", "",
+		"With -files, restrict the languages of each file to those allowed for its path by the first matching line of this manifest, of the form 'pattern code[,code...]' such as 'docs/fr/** fr', with patterns relative to the manifest. Without -n, files detected in another language before the restriction are reported on standard error, and the exit status is 1.")
+	maxFileSize := flag.Int64("max-file-size", 0,
+		"With -files or -watch, skip files larger than this many bytes (0 for no limit).")
+	headBytes := flag.Int64("head-bytes", 0,
+		"With -files or -watch, classify only the first this many bytes of text and HTML files, cut at whitespace (0 reads them whole).")
+	httpTimeout := flag.Duration("http-timeout", 30*time.Second,
+		"With -files, time limit for fetching a URL, including its content.")
+	httpRetries := flag.Int("http-retries", 2,
+		"With -files, how often to retry fetching a URL after a network error, HTTP 429 or 5xx, waiting 1s, 2s, 4s... in between.")
+	checkpointFile := flag.String("checkpoint", "",
+		"With -n or -files, record progress in this file every few seconds, and resume from it if it exists. Removed when the run completes.")
+	shardSpec := flag.String("shard", "",
+		"Process only the records of shard i of n, e.g. 2/4, so that several runs, on several machines, split the input between them: the lines with -n, the rows with -csv, the files with -files or the texts with -each or -texts-from. Files written with -output, -checkpoint, -bucket-output and -report are named for the shard, e.g. results-2-of-4.tsv.")
+	shardBy := flag.String("shard-by", "index",
+		"How -shard assigns records to shards: by position (index), every n-th record, or by a hash of their content (hash), which keeps equal records in the same shard whatever the order of the input.")
+	watchDir := flag.String("watch", "",
+		"Watch this directory and classify files as they are created or modified, printing path, language and score, until interrupted.")
+	stdio := flag.Bool("stdio", false,
+		"Serve JSON-RPC 2.0 requests on stdin, one per line or with Content-Length headers, answering on stdout, for editors and tools running lingua-cli as a child process.")
+	warmup := flag.Bool("warmup", false,
+		"Classify built-in samples of the major scripts after loading the detector, before reading any input, so that long-running modes such as -stdio or -watch do not keep their first texts waiting for the models to load.")
+	warmupFile := flag.String("warmup-file", "",
+		"Like -warmup, with the lines of this file instead of the built-in samples, such as representative texts of the traffic.")
+	tee := flag.String("tee", "",
+		"Pass the input through to stdout unchanged and write the results, with line numbers in line mode, to this file instead ('stderr' for standard error).")
+	compress := flag.Bool("compress", false,
+		"Gzip-compress the results (implied by an -output name ending in .gz).")
+	flush := flag.Bool("flush", false,
+		"Flush the output after every result, for consumers reading results while lingua-cli runs.")
+	cpuProfile := flag.String("cpuprofile", "",
+		"Write a CPU profile of the run to this file (for 'go tool pprof').")
+	maxMemory := flag.String("max-memory", "",
+		"Memory budget of the run, such as 512MiB or 2GiB: sets the memory limit of the Go runtime like GOMEMLIMIT, sizes -cache and -max-record-bytes to fit, and flushes the result cache when memory runs short, so that the run slows down instead of being killed in a constrained container.")
+	memProfile := flag.String("memprofile", "",
+		"Write a heap profile at the end of the run to this file (for 'go tool pprof').")
+	otelTracing := flag.Bool("otel", false,
+		"Export OpenTelemetry traces of detector builds, detections and output writes over OTLP/HTTP, configured by the standard OTEL_EXPORTER_OTLP_* environment variables.")
+	traceFile := flag.String("trace", "",
+		"Write an execution trace of the run to this file (for 'go tool trace').")
+	showVersion := flag.Bool("V", false, "Print version, with the lingua-go version, commit, build date, Go release and number of languages of the build")
+	configPath := flag.String("config", "",
+		"Read default options from this configuration file instead of lingua-cli/config.toml in the user configuration directory (e.g. ~/.config), if it exists.")
+	profile := flag.String("profile", "",
+		"Apply the options of this named profile, a [profile.NAME] section of the configuration file.")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "lingua-cli is a command line tool for language classification, using the lingua-go library.\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: lingua-cli [OPTIONS] [TEXT]...\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli [OPTIONS] [TEXT]...\n")
+		fmt.Fprintf(os.Stderr, "       lingua-cli COMMAND [OPTIONS] [ARGS]...\n\n")
 		fmt.Fprintf(os.Stderr, "Arguments:\n  [TEXT]... \n\n")
+		fmt.Fprintf(os.Stderr, "Commands:\n")
+		for _, c := range commands {
+			fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+		}
+		fmt.Fprintf(os.Stderr, "\n")
+		fmt.Fprintf(os.Stderr, "All options but -V, -L, -print-schema and -clipboard can also be set in the configuration\n")
+		fmt.Fprintf(os.Stderr, "file (see -config) or by an environment variable named after their long name, such as\n")
+		fmt.Fprintf(os.Stderr, "LINGUA_CLI_LANGUAGES for -l.\n")
+		fmt.Fprintf(os.Stderr, "Options on the command line take precedence over the environment, which takes\n")
+		fmt.Fprintf(os.Stderr, "precedence over the selected -profile and then the rest of the configuration file.\n\n")
 		fmt.Fprintf(os.Stderr, "Options:\n")
-		flag.PrintDefaults()
+		printFlags(os.Stderr, flag.CommandLine)
+	}
+	addLongFlags(flag.CommandLine)
+	if len(os.Args) > 1 && os.Args[1] == "gen-man" {
+		// gen-man documents the flags defined above.
+		os.Exit(runGenMan(flag.CommandLine, os.Args[2:]))
 	}
 
-	flag.Parse()
+	parseFlags(flag.CommandLine, os.Args[1:])
+	if err := setFlagsFromEnv(flag.CommandLine); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+	if err := applyConfig(flag.CommandLine, *configPath, *profile, false); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
 
-	// Detect whether -c and -d were explicitly provided via flag.Visit
+	// Detect whether -c was explicitly provided via flag.Visit
 	hasConfidence := false
-	hasMinRelDist := false
 	flag.Visit(func(f *flag.Flag) {
-		switch f.Name {
-		case "c":
+		if f.Name == "c" || longFlags[f.Name] == "c" {
 			hasConfidence = true
-		case "d":
-			hasMinRelDist = true
 		}
 	})
 
 	if *showVersion {
-		fmt.Printf("lingua-cli %s\n", version)
+		printVersion(os.Stdout)
 		os.Exit(0)
 	}
 
+	if *printSchema {
+		os.Stdout.Write(jsonV1Schema)
+		os.Exit(0)
+	}
+
+	if *namesLocale != "" {
+		if err := setNamesLocale(*namesLocale); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// --- list supported languages ---
 	if *listLangs {
-		all := lingua.AllLanguages()
-		sort.Slice(all, func(i, j int) bool {
-			return all[i].String() < all[j].String()
-		})
-		for _, lang := range all {
-			fmt.Printf("%s - %s\n", strings.ToLower(lang.IsoCode639_1().String()), lang)
+		if err := listLanguages(os.Stdout, *listFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
 		}
 		os.Exit(0)
 	}
 
+	if err := startProfiling(*cpuProfile, *memProfile, *traceFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
+	}
+	defer runCleanups()
+	if *otelTracing {
+		if err := startTracing(); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+	}
+
+	var budget memoryBudget
+	if *maxMemory != "" {
+		size, err := parseByteSize(*maxMemory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -max-memory: %v\n", err)
+			exit(1)
+		}
+		budget = memoryBudget(size)
+		budget.apply()
+		cfg.cacheSize = budget.cacheSize(cfg.cacheSize)
+		*maxRecordBytes = budget.recordSize(*maxRecordBytes)
+	}
+
 	// --- build detector ---
-	var targetLanguages []lingua.Language
-	if *languages != "" {
-		for _, code := range strings.Split(*languages, ",") {
-			code = strings.TrimSpace(code)
-			if code == "" {
-				continue
-			}
-			lang, ok := isoCodeToLanguage(code)
-			if !ok {
-				fmt.Fprintf(os.Stderr, "error: unknown ISO 639-1 language code: %q\n", code)
-				os.Exit(1)
+	// -hint-field weighs the scores of a record before -d and -max-entropy
+	// decide on them.
+	cfg.decideLater = *hintField != ""
+	started := time.Now()
+	detector, err := traceBuild(traceCtx, &cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
+	}
+	loadTime := time.Since(started)
+	atExit(func() { langid.Close(detector) })
+	if budget > 0 {
+		budget.watch(detector)
+	}
+	if *warmup || *warmupFile != "" {
+		var texts []string
+		if *warmupFile != "" {
+			if texts, err = readWarmupFile(*warmupFile); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				exit(1)
 			}
-			targetLanguages = append(targetLanguages, lang)
+		}
+		if err := warmUp(detector, texts); err != nil {
+			fmt.Fprintf(os.Stderr, "error: warm-up: %v\n", err)
+			exit(1)
 		}
 	}
+	if *precision < -1 || *precision > 16 {
+		fmt.Fprintf(os.Stderr, "error: -precision must lie in between 0 and 16\n")
+		exit(1)
+	}
+	scoreFormat.precision, scoreFormat.percent = *precision, *percent
+	if *showRaw {
+		if *percent {
+			fmt.Fprintf(os.Stderr, "error: -raw-scores can not be combined with -percent\n")
+			exit(1)
+		}
+		raw, ok := langid.WithRawScores(detector)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: -raw-scores requires -profiles, the %s backend does not report raw scores\n", cfg.backend)
+			exit(1)
+		}
+		if *showEntropy || *showMargin {
+			fmt.Fprintf(os.Stderr, "error: -raw-scores can not be combined with -entropy or -margin\n")
+			exit(1)
+		}
+		detector = raw
+	}
 
-	var builder lingua.LanguageDetectorBuilder
-	if len(targetLanguages) == 0 {
-		builder = lingua.NewLanguageDetectorBuilder().FromAllLanguages()
-	} else {
-		builder = lingua.NewLanguageDetectorBuilder().FromLanguages(targetLanguages...)
+	if *maxChars < 0 {
+		fmt.Fprintf(os.Stderr, "error: -max-chars must not be negative\n")
+		exit(1)
+	}
+	if *maxRecordBytes < 0 {
+		fmt.Fprintf(os.Stderr, "error: -max-record-bytes must not be negative\n")
+		exit(1)
+	}
+	oversized, err := parseOversizedPolicy(*onOversized)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
+	}
+	records := recordLimit{maxBytes: *maxRecordBytes, policy: oversized}
+	// truncate limits the part of a text that is looked at to -max-chars.
+	truncate := func(text string) string { return truncateRunes(text, *maxChars) }
+
+	// preprocess prepares a text for detection; output always shows the original.
+	preprocess := truncate
+	if cfg.short {
+		if *multi || *tokens {
+			fmt.Fprintf(os.Stderr, "error: -short can not be combined with -m or -tokens\n")
+			exit(1)
+		}
+		preprocess = func(text string) string { return langid.ScrubShortText(truncate(text)) }
+		if !hasConfidence {
+			*confidenceVal, hasConfidence = shortTextConfidence, true
+		}
+		if *minLength == 0 {
+			*minLength = shortTextMinLength
+		}
+	}
+
+	if *minLetterRatio < 0 || *minLetterRatio > 1 {
+		fmt.Fprintf(os.Stderr, "error: -min-letter-ratio must lie in between 0.0 and 1.0\n")
+		exit(1)
+	}
+
+	// classifiable reports whether a (preprocessed) text passes the filters
+	// for texts not worth classifying.
+	classifiable := func(text string) bool {
+		if *minLength > 0 && !longEnough(text, *minLength) {
+			return false
+		}
+		if *minWords > 0 && wordCount(text) < *minWords {
+			return false
+		}
+		return *minLetterRatio == 0 || letterRatio(text) >= *minLetterRatio
+	}
+
+	// detectSpans splits a text into single-language spans for -m, or
+	// into words for -tokens.
+	var detectSpans func(text string) ([]langid.Span, error)
+	if *multi && *tokens {
+		fmt.Fprintf(os.Stderr, "error: -m can not be combined with -tokens\n")
+		exit(1)
+	}
+	if *multi {
+		if cfg.profileFiles != "" {
+			fmt.Fprintf(os.Stderr, "error: -m can not be combined with -profiles\n")
+			exit(1)
+		}
+		md, ok := langid.AsMulti(detector)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "error: the %s backend does not support -m\n", cfg.backend)
+			exit(1)
+		}
+		detectSpans = md.DetectMultiple
+	}
+	if *tokens {
+		detectSpans = func(text string) ([]langid.Span, error) { return langid.TagTokens(detector, text) }
+	}
+	spanMode := detectSpans != nil
+	if spanMode && hasConfidence {
+		split := detectSpans
+		detectSpans = func(text string) ([]langid.Span, error) {
+			spans, err := split(text)
+			if err != nil {
+				return nil, err
+			}
+			return langid.ThresholdSpans(detector, text, spans, *confidenceVal)
+		}
+	}
+	if *minSpan < 0 {
+		fmt.Fprintf(os.Stderr, "error: -min-span must not be negative\n")
+		exit(1)
+	}
+	if *minSpan > 0 {
+		if !spanMode {
+			fmt.Fprintf(os.Stderr, "error: -min-span requires -m or -tokens\n")
+			exit(1)
+		}
+		split := detectSpans
+		detectSpans = func(text string) ([]langid.Span, error) {
+			spans, err := split(text)
+			return langid.AbsorbShortSpans(text, spans, *minSpan), err
+		}
+	}
+	if *mergeSpans {
+		if !spanMode {
+			fmt.Fprintf(os.Stderr, "error: -merge-spans requires -m or -tokens\n")
+			exit(1)
+		}
+		split := detectSpans
+		detectSpans = func(text string) ([]langid.Span, error) {
+			spans, err := split(text)
+			return langid.MergeSpans(spans), err
+		}
+	}
+	if spanMode {
+		split := detectSpans
+		detectSpans = func(text string) ([]langid.Span, error) { return traceSpans(traceCtx, text, split) }
+	}
+
+	if *smoothWindow < 0 || *smoothWeight < 0 || *smoothWeight > 1 {
+		fmt.Fprintf(os.Stderr, "error: -smooth must not be negative and -smooth-weight must lie in between 0.0 and 1.0\n")
+		exit(1)
+	}
+
+	if *workers < 0 || *threads < 0 {
+		fmt.Fprintf(os.Stderr, "error: -j and -threads must not be negative\n")
+		exit(1)
+	}
+	if n := setThreads(*threads); *workers == 0 {
+		*workers = n
+	}
+	switch *emptyLines {
+	case "detect", "skip", "pass", "unknown":
+	default:
+		fmt.Fprintf(os.Stderr, "error: invalid -empty-lines value %q (expected detect, skip, pass or unknown)\n", *emptyLines)
+		exit(1)
+	}
+	if *filesMode && (spanMode || *tee != "" || *idle > 0 || *chunkSize > 0 || len(flag.Args()) == 0) {
+		fmt.Fprintf(os.Stderr, "error: -files needs file arguments and can not be combined with -m, -tokens, -tee, -idle or -chunk-size\n")
+		exit(1)
+	}
+	if *aggregate && !(*filesMode && *perLine) {
+		fmt.Fprintf(os.Stderr, "error: -aggregate requires -files and -n\n")
+		exit(1)
+	}
+	var paths pathLanguages
+	if *pathLang != "" || *pathLangRules != "" {
+		if !*filesMode || (*pathLang == "check" && *perLine) {
+			fmt.Fprintf(os.Stderr, "error: -path-lang and -path-lang-rules require -files, and -path-lang check can not be combined with -n\n")
+			exit(1)
+		}
+		if !slices.Contains(pathLangModes, *pathLang) {
+			fmt.Fprintf(os.Stderr, "error: invalid -path-lang value %q (expected %s)\n", *pathLang, strings.Join(pathLangModes, ", "))
+			exit(1)
+		}
+		if *pathLangWeight < 0 {
+			fmt.Fprintf(os.Stderr, "error: -path-lang-weight must not be negative\n")
+			exit(1)
+		}
+		if *pathLangRules != "" {
+			if paths.rules, err = loadPathRules(*pathLangRules); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				exit(1)
+			}
+		}
+	}
+	var manifest *langManifest
+	if *manifestFile != "" {
+		if !*filesMode {
+			fmt.Fprintf(os.Stderr, "error: -lang-manifest requires -files\n")
+			exit(1)
+		}
+		if manifest, err = loadLangManifest(*manifestFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		if cfg.languages != "" {
+			for _, code := range manifest.languages() {
+				if !slices.Contains(splitList(strings.ToLower(cfg.languages)), code) {
+					fmt.Fprintf(os.Stderr, "error: -lang-manifest language %s is not among the -l languages\n", code)
+					exit(1)
+				}
+			}
+		}
+	}
+	if *httpRetries < 0 {
+		fmt.Fprintf(os.Stderr, "error: -http-retries must not be negative\n")
+		exit(1)
+	}
+	fetcher.client.Timeout = *httpTimeout
+	fetcher.retries = *httpRetries
+	if *maxFileSize < 0 || *headBytes < 0 {
+		fmt.Fprintf(os.Stderr, "error: -max-file-size and -head-bytes must not be negative\n")
+		exit(1)
+	}
+	if *checkpointFile != "" && (!(*perLine || *filesMode) || *unordered || *tee != "" || *compress || strings.HasSuffix(*outputFile, ".gz")) {
+		fmt.Fprintf(os.Stderr, "error: -checkpoint requires -n or -files and can not be combined with -unordered, -tee or compressed output\n")
+		exit(1)
+	}
+	var sh shard
+	if *shardSpec != "" {
+		if !(*perLine || *jsonlInput || *csvInput || *filesMode || *each || *textsFrom != "") || *tee != "" || *watchDir != "" || *syslogListen != "" || *natsSubject != "" || *stdio {
+			fmt.Fprintf(os.Stderr, "error: -shard requires -n, -csv, -files, -each or -texts-from and can not be combined with -tee, -watch, -syslog, -nats-subject or -stdio\n")
+			exit(1)
+		}
+		if !slices.Contains(shardModes, *shardBy) {
+			fmt.Fprintf(os.Stderr, "error: invalid -shard-by value %q (expected %s)\n", *shardBy, strings.Join(shardModes, ", "))
+			exit(1)
+		}
+		if sh, err = parseShard(*shardSpec, *shardBy); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		*outputFile, *checkpointFile = sh.path(*outputFile), sh.path(*checkpointFile)
+		*bucketOutput, *reportFile = sh.path(*bucketOutput), sh.path(*reportFile)
+	}
+	if *watchDir != "" && (spanMode || *perLine || *tee != "" || len(flag.Args()) > 0) {
+		fmt.Fprintf(os.Stderr, "error: -watch can not be combined with -m, -tokens, -n, -tee or text arguments\n")
+		exit(1)
+	}
+	if (*idle > 0 || *chunkSize > 0) && *perLine {
+		fmt.Fprintf(os.Stderr, "error: -idle and -chunk-size can not be combined with -n\n")
+		exit(1)
+	}
+	if *chunkSize < 0 {
+		fmt.Fprintf(os.Stderr, "error: -chunk-size must not be negative\n")
+		exit(1)
+	}
+	if !slices.Contains(chunkBoundaries, *chunkBoundary) {
+		fmt.Fprintf(os.Stderr, "error: invalid -chunk-boundary value %q (expected %s)\n", *chunkBoundary, strings.Join(chunkBoundaries, ", "))
+		exit(1)
+	}
+	if *stdio && (spanMode || *perLine || *filesMode || *watchDir != "" || *tee != "" || *outputFile != "" || *checkpointFile != "" || *idle > 0 || *chunkSize > 0 || *clipboard || *reportFile != "" || len(flag.Args()) > 0) {
+		fmt.Fprintf(os.Stderr, "error: -stdio can not be combined with -m, -tokens, -n, -files, -watch, -tee, -output, -checkpoint, -idle, -chunk-size, -clipboard, -report or text arguments\n")
+		exit(1)
+	}
+	if *stdio {
+		server := &rpcServer{cfg: cfg, threshold: *confidenceVal, preprocess: preprocess, classifiable: classifiable, maxMessage: *maxRecordBytes}
+		if err := runStdio(server, detector, *workers); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		exit(0)
+	}
+	if *unordered && *smoothWindow > 0 {
+		fmt.Fprintf(os.Stderr, "error: -unordered can not be combined with -smooth\n")
+		exit(1)
+	}
+
+	policy, err := parseUTF8Policy(*onInvalidUTF8)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
+	}
+
+	// In tee mode stdout carries the input, and the results go elsewhere.
+	resultsPath := *outputFile
+	var passthrough *outputWriter
+	if *tee != "" {
+		if *outputFile != "" || *unordered {
+			fmt.Fprintf(os.Stderr, "error: -tee can not be combined with -output or -unordered\n")
+			exit(1)
+		}
+		resultsPath = *tee
+		passthrough = &outputWriter{Writer: bufio.NewWriter(os.Stdout)}
+		atExit(func() { passthrough.Flush() })
+	}
+	// A resumed run replaces the output written after the checkpoint.
+	var cp *checkpoint
+	var keepOutput int64
+	if *checkpointFile != "" {
+		cp, err = loadCheckpoint(*checkpointFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		if cp.done > 0 {
+			unit := "lines"
+			if *filesMode {
+				unit = "files"
+			}
+			fmt.Fprintf(os.Stderr, "resuming after %d %s\n", cp.done, unit)
+		}
+		keepOutput = cp.output
+	}
+	w, err := openOutput(resultsPath, *compress || strings.HasSuffix(resultsPath, ".gz"), keepOutput)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
+	}
+	out := &printer{
+		w:            w,
+		flush:        *flush,
+		delimiter:    *delimiter,
+		threshold:    *confidenceVal,
+		hasThreshold: hasConfidence,
+		all:          *showAll,
+		entropy:      *showEntropy,
+		margin:       *showMargin,
+		mixedScript:  *mixedScript,
+		numberLines:  *unordered || *tee != "" || *numberLines,
+		offsets:      *numberLines,
+	}
+	if (*top != 0 || *hideBelow != 0 || *renormalize) && !*showAll {
+		fmt.Fprintf(os.Stderr, "error: -top, -hide-below and -renormalize require -a\n")
+		exit(1)
+	}
+	if *top < 0 || *hideBelow < 0 || *hideBelow > 1 {
+		fmt.Fprintf(os.Stderr, "error: -top must not be negative and -hide-below must lie in between 0.0 and 1.0\n")
+		exit(1)
+	}
+	out.top, out.hideBelow, out.renormalize = *top, *hideBelow, *renormalize
+	switch *format {
+	case "tsv":
+	case "json-v1":
+		if *columnList != "" {
+			fmt.Fprintf(os.Stderr, "error: -columns can not be combined with -format json-v1\n")
+			exit(1)
+		}
+		out.json = true
+	case "json-run":
+		if *columnList != "" {
+			fmt.Fprintf(os.Stderr, "error: -columns can not be combined with -format json-run\n")
+			exit(1)
+		}
+		out.json = true
+	case "ecs":
+		if *columnList != "" || spanMode {
+			fmt.Fprintf(os.Stderr, "error: -format ecs can not be combined with -columns, -m or -tokens\n")
+			exit(1)
+		}
+		out.json, out.ecs = true, true
+	case "avro":
+		if *columnList != "" || *groupByLang || *sortOrder != "" || *checkpointFile != "" || *stdio {
+			fmt.Fprintf(os.Stderr, "error: -format avro can not be combined with -columns, -group-by-lang, -sort, -checkpoint or -stdio\n")
+			exit(1)
+		}
+		out.json, out.avro = true, newAvroWriter(out.w)
+		atExit(out.avro.close)
+	case "protobuf":
+		if *columnList != "" || *groupByLang || *stdio {
+			fmt.Fprintf(os.Stderr, "error: -format protobuf can not be combined with -columns, -group-by-lang or -stdio\n")
+			exit(1)
+		}
+		out.json, out.protobuf = true, true
+	case "cbor":
+		if *columnList != "" || *groupByLang || *checkpointFile != "" || *stdio {
+			fmt.Fprintf(os.Stderr, "error: -format cbor can not be combined with -columns, -group-by-lang, -checkpoint or -stdio\n")
+			exit(1)
+		}
+		out.json, out.cbor = true, true
+		w := out.w
+		w.WriteByte(cborArrayStart)
+		atExit(func() { w.WriteByte(cborBreak) })
+	case "cbor-seq":
+		if *columnList != "" || *groupByLang || *stdio {
+			fmt.Fprintf(os.Stderr, "error: -format cbor-seq can not be combined with -columns, -group-by-lang or -stdio\n")
+			exit(1)
+		}
+		out.json, out.cbor = true, true
+	case "fasttext":
+		if *columnList != "" || spanMode {
+			fmt.Fprintf(os.Stderr, "error: -format fasttext can not be combined with -columns, -m or -tokens\n")
+			exit(1)
+		}
+		out.fastText = true
+	default:
+		fmt.Fprintf(os.Stderr, "error: invalid -format value %q (expected %s)\n", *format, strings.Join(outputFormats, ", "))
+		exit(1)
+	}
+	if *columnList != "" {
+		out.columns, err = parseColumns(*columnList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -columns: %v\n", err)
+			exit(1)
+		}
+	}
+	if *labelMapFile != "" {
+		out.labels, err = loadLabelMap(*labelMapFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+	}
+	if *unknownLabel != langid.Unknown {
+		if out.labels == nil {
+			out.labels = make(labelMap)
+		}
+		out.labels[langid.Unknown] = *unknownLabel
 	}
 
-	if *quick {
-		builder = builder.WithLowAccuracyMode()
+	if *bucketOutput != "" && *buckets == "" {
+		fmt.Fprintf(os.Stderr, "error: -bucket-output requires -buckets\n")
+		exit(1)
+	}
+	if *buckets != "" {
+		if spanMode || out.json || out.fastText || *jsonlInput || *showRaw {
+			fmt.Fprintf(os.Stderr, "error: -buckets can not be combined with -m, -tokens, -format json-v1 or fasttext, -jsonl or -raw-scores\n")
+			exit(1)
+		}
+		out.buckets, err = parseBuckets(*buckets)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+	}
+	if *bucketOutput != "" {
+		if !strings.Contains(*bucketOutput, "{bucket}") || *outputFile != "" || *tee != "" || *checkpointFile != "" || *samples != 0 {
+			fmt.Fprintf(os.Stderr, "error: -bucket-output needs a {bucket} placeholder and can not be combined with -output, -tee, -checkpoint or -samples\n")
+			exit(1)
+		}
+		out.bucketWriters = make(map[string]*outputWriter)
+		for _, name := range bucketNames(len(out.buckets)) {
+			path := strings.ReplaceAll(*bucketOutput, "{bucket}", name)
+			out.bucketWriters[name], err = openOutput(path, *compress || strings.HasSuffix(path, ".gz"), 0)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				exit(1)
+			}
+		}
+	}
+
+	if *each && (len(flag.Args()) == 0 || spanMode || *perLine || *filesMode) {
+		fmt.Fprintf(os.Stderr, "error: -each needs text arguments and can not be combined with -m, -tokens, -n or -files\n")
+		exit(1)
+	}
+	if *textsFrom != "" && (len(flag.Args()) > 0 || spanMode || *perLine || *filesMode || *watchDir != "" || *tee != "") {
+		fmt.Fprintf(os.Stderr, "error: -texts-from can not be combined with text arguments, -m, -tokens, -n, -files, -watch or -tee\n")
+		exit(1)
+	}
+
+	if *clipboard && (len(flag.Args()) > 0 || *each || *textsFrom != "" || *perLine || *filesMode || *watchDir != "" || *tee != "") {
+		fmt.Fprintf(os.Stderr, "error: -clipboard can not be combined with text arguments, -each, -texts-from, -n, -files, -watch or -tee\n")
+		exit(1)
+	}
+
+	var jsonl *jsonlFields
+	if *jsonlInput {
+		if spanMode || *showAll || *columnList != "" || out.json || out.fastText || *aggregate {
+			fmt.Fprintf(os.Stderr, "error: -jsonl can not be combined with -m, -tokens, -a, -columns, -format json-v1 or fasttext, or -aggregate\n")
+			exit(1)
+		}
+		jsonl, err = newJSONLFields(*textField, *langField, *confField, *hintField)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		*perLine = true
+	}
+	var hint *recordHint
+	if *hintField != "" {
+		if !*jsonlInput && !*csvInput {
+			fmt.Fprintf(os.Stderr, "error: -hint-field requires -jsonl or -csv\n")
+			exit(1)
+		}
+		if hint, err = newRecordHint(*hintMode, *hintWeight, cfg.decide); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+	}
+	var csvFormat csvDialect
+	if *csvInput {
+		if *jsonlInput || spanMode || *perLine || *showAll || *columnList != "" || out.json || out.fastText || *aggregate || *filesMode || *watchDir != "" || *each || *textsFrom != "" || *checkpointFile != "" || *tee != "" || len(flag.Args()) > 0 {
+			fmt.Fprintf(os.Stderr, "error: -csv can not be combined with -jsonl, -m, -tokens, -n, -a, -columns, -format json-v1, ecs or fasttext, -aggregate, -files, -watch, -each, -texts-from, -checkpoint, -tee or text arguments\n")
+			exit(1)
+		}
+		if !slices.Contains(csvRaggedPolicies, *csvRagged) {
+			fmt.Fprintf(os.Stderr, "error: invalid -csv-ragged value %q (expected %s)\n", *csvRagged, strings.Join(csvRaggedPolicies, ", "))
+			exit(1)
+		}
+		if *csvDelimiter != "auto" {
+			csvFormat.delimiter, err = parseCSVChar("-csv-delimiter", *csvDelimiter)
+		}
+		if err == nil {
+			csvFormat.quote, err = parseCSVChar("-csv-quote", *csvQuote)
+		}
+		if err == nil {
+			csvFormat.escape, err = parseCSVChar("-csv-escape", *csvEscape)
+		}
+		if err == nil && (*csvDelimiter == "" || (*csvDelimiter != "auto" && (csvFormat.delimiter == csvFormat.quote || csvFormat.delimiter == csvFormat.escape))) {
+			err = fmt.Errorf("-csv-delimiter must be set and differ from -csv-quote and -csv-escape")
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		if csvFormat.escape == csvFormat.quote {
+			csvFormat.escape = 0
+		}
+	}
+
+	var report *corpusReport
+	if *reportFile != "" || *samples != 0 {
+		if spanMode {
+			fmt.Fprintf(os.Stderr, "error: -report and -samples can not be combined with -m or -tokens\n")
+			exit(1)
+		}
+		threshold := reportLowConfidence
+		if hasConfidence {
+			threshold = *confidenceVal
+		}
+		report = newCorpusReport(threshold)
+	}
+	if *reportFile != "" {
+		atExit(func() {
+			if err := report.write(*reportFile); err != nil {
+				fmt.Fprintf(os.Stderr, "error: writing the report: %v\n", err)
+			}
+		})
+	}
+	if *samples != 0 {
+		if *samples < 0 || *jsonlInput || out.json || *tee != "" || *checkpointFile != "" || *watchDir != "" {
+			fmt.Fprintf(os.Stderr, "error: -samples must be positive and can not be combined with -jsonl, -format json-v1, -tee, -checkpoint or -watch\n")
+			exit(1)
+		}
+		report.samples = newSampler(*samples, *seed)
+		if hasConfidence {
+			report.minSampleScore = *confidenceVal
+		}
+		// The results are only counted, the samples printed at the end.
+		sampleOut := *out
+		out.w = &outputWriter{Writer: bufio.NewWriter(io.Discard)}
+		atExit(func() { report.printSamples(&sampleOut) })
+	}
+
+	runDocument := *format == "json-run"
+	if *groupByLang || *sortOrder != "" || runDocument {
+		if spanMode || *jsonlInput || *csvInput || *stdio || out.ecs || out.fastText || *samples != 0 || *bucketOutput != "" || *checkpointFile != "" {
+			fmt.Fprintf(os.Stderr, "error: -group-by-lang, -sort and -format json-run can not be combined with -m, -tokens, -jsonl, -csv, -stdio, -format ecs or fasttext, -samples, -bucket-output or -checkpoint\n")
+			exit(1)
+		}
+		if runDocument && *groupByLang {
+			fmt.Fprintf(os.Stderr, "error: -group-by-lang can not be combined with -format json-run, which lists the share of each language\n")
+			exit(1)
+		}
+		if *sortOrder != "" {
+			if out.order, err = parseResultOrder(*sortOrder); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				exit(1)
+			}
+		}
+		run := runMetadata{command: os.Args, mode: "text", sources: []string{"stdin"}, started: started, loadTime: loadTime}
+		switch {
+		case *filesMode:
+			run.mode, run.sources = "files", flag.Args()
+		case *perLine:
+			run.mode = "lines"
+		case *each || *textsFrom != "":
+			run.mode = "texts"
+		}
+		switch {
+		case *textsFrom != "":
+			run.sources = []string{*textsFrom}
+		case *clipboard:
+			run.sources = []string{"clipboard"}
+		case *watchDir != "":
+			run.sources = []string{*watchDir}
+		case len(flag.Args()) > 0 && !*filesMode:
+			run.sources = []string{"arguments"}
+		}
+		w := out.hold()
+		held, order := out.held, out.order
+		atExit(func() {
+			if order.key != "" {
+				held.sort(order)
+			}
+			switch {
+			case runDocument:
+				if err := held.printRunDocument(w, run); err != nil {
+					fmt.Fprintf(os.Stderr, "error: -format json-run: %v\n", err)
+				}
+			case *groupByLang:
+				held.printGroups(w, out.json)
+			default:
+				held.print(w)
+			}
+		})
+	}
+
+	if *execLangs != "" && *execCommand == "" {
+		fmt.Fprintf(os.Stderr, "error: -exec-lang requires -exec\n")
+		exit(1)
+	}
+	var command *resultCommand
+	if *execCommand != "" {
+		if spanMode || *jsonlInput {
+			fmt.Fprintf(os.Stderr, "error: -exec can not be combined with -m, -tokens or -jsonl\n")
+			exit(1)
+		}
+		command, err = newResultCommand(*execCommand, *execLangs, out.minScore(), out.labels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -exec: %v\n", err)
+			exit(1)
+		}
+	}
+	var webhook *webhookSink
+	if *webhookURL != "" {
+		if spanMode || *jsonlInput {
+			fmt.Fprintf(os.Stderr, "error: -webhook can not be combined with -m, -tokens or -jsonl\n")
+			exit(1)
+		}
+		if !isURL(*webhookURL) {
+			fmt.Fprintf(os.Stderr, "error: -webhook must be an http:// or https:// URL\n")
+			exit(1)
+		}
+		if *webhookBatch < 1 || *webhookRetries < 0 {
+			fmt.Fprintf(os.Stderr, "error: -webhook-batch must be positive and -webhook-retries not negative\n")
+			exit(1)
+		}
+		webhook = newWebhookSink(*webhookURL, *webhookBatch, *webhookRetries, out.minScore(), out.labels)
+		atExit(webhook.close)
+	}
+	var mqtt *mqttSink
+	if *mqttURL != "" {
+		if spanMode || *jsonlInput {
+			fmt.Fprintf(os.Stderr, "error: -mqtt can not be combined with -m, -tokens or -jsonl\n")
+			exit(1)
+		}
+		mqtt, err = dialMQTT(*mqttURL, *mqttTopic, *mqttQoS, out.minScore(), out.labels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -mqtt: %v\n", err)
+			exit(1)
+		}
+		atExit(mqtt.close)
+	}
+	var nats *natsConn
+	var natsOut *natsSink
+	if (*natsSubject != "" || *natsPublish != "") != (*natsURL != "") {
+		fmt.Fprintf(os.Stderr, "error: -nats requires -nats-subject or -nats-publish, and they require -nats\n")
+		exit(1)
+	}
+	if (*syslogForward != "" || *syslogIdleTimeout != 0 || *syslogMaxConns != 0) && *syslogListen == "" {
+		fmt.Fprintf(os.Stderr, "error: -syslog-forward, -syslog-idle-timeout and -syslog-max-conns require -syslog\n")
+		exit(1)
+	}
+	if *syslogIdleTimeout < 0 || *syslogKeepAlive < 0 || *syslogMaxConns < 0 {
+		fmt.Fprintf(os.Stderr, "error: -syslog-idle-timeout, -syslog-keepalive and -syslog-max-conns must not be negative\n")
+		exit(1)
+	}
+	if *syslogListen != "" && (spanMode || *jsonlInput || *perLine || *filesMode || *watchDir != "" || *each || *textsFrom != "" || *clipboard || *tee != "" || *natsSubject != "" || len(flag.Args()) > 0) {
+		fmt.Fprintf(os.Stderr, "error: -syslog can not be combined with -m, -tokens, -jsonl, -n, -files, -watch, -each, -texts-from, -clipboard, -tee, -nats-subject or text arguments\n")
+		exit(1)
+	}
+	if *natsQueue != "" && *natsSubject == "" {
+		fmt.Fprintf(os.Stderr, "error: -nats-queue requires -nats-subject\n")
+		exit(1)
+	}
+	if *natsURL != "" {
+		if spanMode || *jsonlInput {
+			fmt.Fprintf(os.Stderr, "error: -nats can not be combined with -m, -tokens or -jsonl\n")
+			exit(1)
+		}
+		if *natsSubject != "" && (*perLine || *filesMode || *watchDir != "" || *each || *textsFrom != "" || *clipboard || *tee != "" || len(flag.Args()) > 0) {
+			fmt.Fprintf(os.Stderr, "error: -nats-subject can not be combined with -n, -files, -watch, -each, -texts-from, -clipboard, -tee or text arguments\n")
+			exit(1)
+		}
+		nats, err = dialNATS(*natsURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -nats: %v\n", err)
+			exit(1)
+		}
+		atExit(nats.close)
+		if *natsPublish != "" {
+			natsOut = &natsSink{conn: nats, subject: *natsPublish, threshold: out.minScore(), labels: out.labels}
+		}
+		if *natsSubject == "" {
+			nats.keepAlive()
+		}
+	}
+	var zmqIn *zmqSocket
+	var zmqOut *zmqSink
+	if *zmqType != "pull" && *zmqAddress == "" {
+		fmt.Fprintf(os.Stderr, "error: -zmq-type requires -zmq\n")
+		exit(1)
+	}
+	if *zmqAddress != "" {
+		if spanMode || *jsonlInput || *perLine || *filesMode || *watchDir != "" || *each || *textsFrom != "" || *clipboard || *tee != "" || *syslogListen != "" || *natsSubject != "" || len(flag.Args()) > 0 {
+			fmt.Fprintf(os.Stderr, "error: -zmq can not be combined with -m, -tokens, -jsonl, -n, -files, -watch, -each, -texts-from, -clipboard, -tee, -syslog, -nats-subject or text arguments\n")
+			exit(1)
+		}
+		if !slices.Contains(zmqTypes, *zmqType) {
+			fmt.Fprintf(os.Stderr, "error: invalid -zmq-type value %q (expected %s)\n", *zmqType, strings.Join(zmqTypes, ", "))
+			exit(1)
+		}
+		zmqIn, err = openZMQ(*zmqAddress, strings.ToUpper(*zmqType), *maxRecordBytes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -zmq: %v\n", err)
+			exit(1)
+		}
+		atExit(zmqIn.close)
+	}
+	if *zmqPush != "" {
+		if spanMode || *jsonlInput {
+			fmt.Fprintf(os.Stderr, "error: -zmq-push can not be combined with -m, -tokens or -jsonl\n")
+			exit(1)
+		}
+		socket, err := openZMQ(*zmqPush, "PUSH", 0)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -zmq-push: %v\n", err)
+			exit(1)
+		}
+		atExit(socket.close)
+		zmqOut = &zmqSink{socket: socket, threshold: out.minScore(), labels: out.labels}
 	}
-	if hasMinRelDist {
-		builder = builder.WithMinimumRelativeDistance(*minRelDist)
+	var fluent *fluentSink
+	if *fluentdURL != "" {
+		if spanMode || *jsonlInput {
+			fmt.Fprintf(os.Stderr, "error: -fluentd can not be combined with -m, -tokens or -jsonl\n")
+			exit(1)
+		}
+		fluent, err = dialFluent(*fluentdURL, *fluentdTag, out.minScore(), out.labels)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -fluentd: %v\n", err)
+			exit(1)
+		}
+		atExit(fluent.close)
+	}
+	if *isLang != "" {
+		if spanMode || *showAll || out.json || out.fastText || *jsonlInput || *csvInput || *stdio || *buckets != "" || *showEntropy || *showMargin || *labelMapFile != "" || *unknownLabel != langid.Unknown {
+			fmt.Fprintf(os.Stderr, "error: -is can not be combined with -m, -tokens, -a, -format json-v1, json-run, ecs or fasttext, -jsonl, -csv, -stdio, -buckets, -entropy, -margin, -label-map or -unknown-label\n")
+			exit(1)
+		}
+		code := declaredLanguage(*isLang)
+		if code == "" {
+			fmt.Fprintf(os.Stderr, "error: invalid -is language %q\n", *isLang)
+			exit(1)
+		}
+		if cfg.languages != "" && !slices.Contains(splitList(strings.ToLower(cfg.languages)), code) {
+			fmt.Fprintf(os.Stderr, "error: -is language %s is not among the -l languages\n", code)
+			exit(1)
+		}
+		// The threshold decides between yes and no instead of suppressing
+		// results.
+		out.is, out.isThreshold, out.hasThreshold = code, 0.5, false
+		if hasConfidence {
+			out.isThreshold = *confidenceVal
+		}
+		if out.held != nil {
+			out.held.decisions = true
+		}
+		defer func() {
+			if out.rejected > 0 {
+				exit(1)
+			}
+		}()
 	}
 
-	detector := builder.Build()
+	// observe passes the result of a text to -report, -samples, -exec,
+	// -webhook, -mqtt, -nats-publish, -zmq-push and -fluentd.
+	observe := func(name string, number int, text string, results []langid.Confidence) {
+		report.add(name, number, text, results)
+		command.run(name, number, text, results)
+		webhook.add(name, number, text, results)
+		mqtt.add(name, number, text, results)
+		natsOut.add(name, number, text, results)
+		zmqOut.add(name, number, text, results)
+		fluent.add(name, number, text, results)
+	}
 
 	// --- process input ---
 	positionalArgs := flag.Args()
+	if *clipboard {
+		text, err := readClipboard()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		if strings.TrimSpace(text) == "" {
+			fmt.Fprintf(os.Stderr, "error: the clipboard holds no text\n")
+			exit(1)
+		}
+		positionalArgs = []string{text}
+	}
+
+	// detectText classifies a whole text, returning errFiltered for texts
+	// rejected by the filters.
+	detectText := func(text string) ([]langid.Confidence, error) {
+		text = preprocess(text)
+		if !classifiable(text) {
+			return nil, errFiltered
+		}
+		return traceDetect(traceCtx, text, detector.ConfidenceValues)
+	}
+
+	// classifyLine classifies a line in line mode.
+	classifyLine := func(line string) ([]langid.Confidence, error) {
+		line, ok := policy.clean(line)
+		if !ok {
+			return nil, nil
+		}
+		if jsonl != nil {
+			record, text, err := jsonl.decode(line)
+			if err != nil || strings.TrimSpace(text) == "" {
+				return nil, errFiltered
+			}
+			results, err := detectText(text)
+			if err != nil || jsonl.hint == nil {
+				return results, err
+			}
+			return hint.apply(results, stringField(record, jsonl.hint)), nil
+		}
+		if *emptyLines != "detect" && strings.TrimSpace(line) == "" {
+			return nil, errFiltered
+		}
+		return detectText(line)
+	}
+
+	// emitLine prints the result of a line of the input, or of the named file.
+	var smooth *smoother
+	if *smoothWindow > 0 {
+		smooth = newSmoother(*smoothWindow, *smoothWeight)
+	}
+	emitLine := func(name string, r lineResult) {
+		line, ok := policy.check(r.line, name, r.number)
+		if !ok {
+			return
+		}
+		r.line = line
+		if jsonl != nil {
+			if strings.TrimSpace(r.line) == "" {
+				return
+			}
+			record, _, err := jsonl.decode(r.line)
+			if err != nil {
+				where := fmt.Sprintf("line %d", r.number)
+				if name != "" {
+					where = name + ": " + where
+				}
+				fmt.Fprintf(os.Stderr, "warning: %s: invalid JSON, passed through: %v\n", where, err)
+				out.printRawRecord(r.line)
+				return
+			}
+			if r.err == errFiltered && *skipFiltered {
+				return
+			}
+			if r.err != errFiltered {
+				exitOnDetectError(r.err)
+			}
+			if r.results != nil && smooth != nil {
+				r.results = smooth.apply(r.results)
+			}
+			observe(name, r.number, r.line, r.results)
+			out.printJSONL(jsonl, record, r.results)
+			return
+		}
+		if *emptyLines != "detect" && strings.TrimSpace(r.line) == "" {
+			switch *emptyLines {
+			case "unknown":
+				observe(name, r.number, r.line, nil)
+				out.printUnknownLine(r.number, r.offset, r.line)
+			case "pass":
+				out.printRawLine(r.number, r.offset, r.line)
+			}
+			return
+		}
+		if r.err == errFiltered {
+			observe(name, r.number, r.line, nil)
+			if !*skipFiltered {
+				out.printUnknownLine(r.number, r.offset, r.line)
+			}
+			return
+		}
+		exitOnDetectError(r.err)
+		if r.results == nil {
+			observe(name, r.number, r.line, nil)
+			out.printUnknownLine(r.number, r.offset, r.line)
+			return
+		}
+		if smooth != nil {
+			r.results = smooth.apply(r.results)
+		}
+		observe(name, r.number, r.line, r.results)
+		out.printLineWithConfidenceValues(r.number, r.offset, r.line, r.results)
+	}
+
+	// readFile reads the text of a file, extracting it from HTML, PDF and
+	// Word documents.
+	limits := fileLimits{maxSize: *maxFileSize, headBytes: *headBytes}
+	readFile := func(path string) (string, error) {
+		return extractFile(path, *inputEncoding, limits)
+	}
+
+	if *syslogListen != "" {
+		var forward *syslogForwarder
+		if *syslogForward != "" {
+			forward, err = dialSyslog(*syslogForward)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: -syslog-forward: %v\n", err)
+				exit(1)
+			}
+			atExit(forward.close)
+		}
+		// Each message is classified like a line, numbered in the order of
+		// arrival.
+		out.flush = true
+		out.numberLines = true
+		work := func(r lineResult) lineResult {
+			r.results, r.err = classifyLine(r.line)
+			return r
+		}
+		emit := func(m syslogMsg, r lineResult) {
+			emitLine(m.source(), r)
+			if forward != nil {
+				record := sinkRecord("", 0, "", r.results, out.minScore(), out.labels)
+				score := ""
+				if record.Score != nil {
+					score = formatScore(*record.Score)
+				}
+				if err := forward.send(m.format(syslogElement(*record.Language, score))); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: -syslog-forward: %v\n", err)
+				}
+			}
+		}
+		limits := syslogConnLimits{idleTimeout: *syslogIdleTimeout, keepAlive: *syslogKeepAlive, maxConns: *syslogMaxConns}
+		if limits.keepAlive == 0 {
+			limits.keepAlive = -1
+		}
+		if err := listenSyslog(*syslogListen, limits, *workers, !*unordered, work, emit); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -syslog: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	if *natsSubject != "" {
+		if err := nats.subscribe(*natsSubject, *natsQueue); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -nats-subject: %v\n", err)
+			exit(1)
+		}
+		// Each message is classified like a line, numbered in the order of
+		// arrival, and requests are answered with its record.
+		out.flush = true
+		out.numberLines = true
+		work := func(r lineResult) lineResult {
+			r.results, r.err = classifyLine(r.line)
+			return r
+		}
+		emit := func(msg natsMsg, r lineResult) {
+			emitLine(msg.subject, r)
+			if msg.reply != "" {
+				payload, err := json.Marshal(sinkRecord(msg.subject, r.number, r.line, r.results, out.minScore(), out.labels))
+				if err != nil {
+					// The requester times out as for a lost reply.
+					fmt.Fprintf(os.Stderr, "warning: -nats-subject: %v\n", err)
+					return
+				}
+				if err := nats.publish(msg.reply, payload); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: -nats-subject: %v\n", err)
+				}
+			}
+		}
+		if err := nats.consume(*workers, !*unordered, work, emit); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -nats-subject: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	if zmqIn != nil {
+		// Each message is classified like a line, numbered in the order of
+		// arrival, and requests are answered with its record.
+		out.flush = true
+		out.numberLines = true
+		work := func(r lineResult) lineResult {
+			r.results, r.err = classifyLine(r.line)
+			return r
+		}
+		emit := func(msg zmqMsg, r lineResult) {
+			source := msg.peer.conn.RemoteAddr().String()
+			emitLine(source, r)
+			if zmqIn.kind == "REP" {
+				payload, err := json.Marshal(sinkRecord(source, r.number, r.line, r.results, out.minScore(), out.labels))
+				if err != nil {
+					// A REQ peer waits for its reply before it sends
+					// anything else, so it gets the error instead.
+					fmt.Fprintf(os.Stderr, "warning: -zmq: %v\n", err)
+					payload, _ = json.Marshal(map[string]string{"error": err.Error()})
+				}
+				if err := zmqIn.reply(msg, payload); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: -zmq: %v\n", err)
+				}
+			}
+		}
+		if err := zmqIn.consume(*workers, !*unordered, work, emit); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -zmq: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	if *watchDir != "" {
+		out.flush = true
+		classifyFile := func(path string) {
+			text, err := readFile(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+				return
+			}
+			text, ok := policy.check(text, path, 1)
+			if !ok {
+				return
+			}
+			out.source = text
+			results, err := detectText(text)
+			if err == errFiltered {
+				observe(path, 0, "", nil)
+				out.printUnknownNamed(path)
+				return
+			}
+			exitOnDetectError(err)
+			observe(path, 0, "", results)
+			out.printNamed(path, results)
+		}
+		if err := watchDirectory(*watchDir, classifyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	// From here on, an interrupt stops reading input, and the run ends once
+	// the results of the texts read before it are written.
+	stopOnInterrupt()
+	// endInterrupted ends an interrupted run after done lines, files or other
+	// units of input: it saves the checkpoint, so that the run can be
+	// resumed, and reports how far the run got.
+	endInterrupted := func(done int, unit string) {
+		if !stopping() {
+			return
+		}
+		if cp != nil {
+			if err := cp.save(out.w); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: saving checkpoint: %v\n", err)
+			} else {
+				fmt.Fprintf(os.Stderr, "checkpoint saved, run again with -checkpoint %s to resume\n", cp.path)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "interrupted after %d %s\n", done, unit)
+		exit(exitInterrupted)
+	}
+
+	if *filesMode {
+		processed := 0
+		produce := func(send func(string)) error {
+			next := send
+			send = func(path string) {
+				if !stopping() {
+					next(path)
+				}
+			}
+			// walk finds the files of the shard.
+			walk := func(send func(string)) error {
+				n := 0
+				return walkFiles(positionalArgs, func(path string) {
+					if n++; sh.keeps(n, path) {
+						send(path)
+					}
+				})
+			}
+			if cp == nil || cp.done == 0 {
+				return walk(send)
+			}
+			// Skip the files done before the checkpoint.
+			var n int64
+			return walk(func(path string) {
+				n++
+				if n == cp.done && path != cp.last {
+					fmt.Fprintf(os.Stderr, "warning: the files differ from those of the checkpoint, which ended with %s\n", cp.last)
+				}
+				if n > cp.done {
+					send(path)
+				}
+			})
+		}
+		work := func(path string) fileResult {
+			result := fileResult{path: path}
+			text, err := readFile(path)
+			if err != nil {
+				result.readErr = err
+				return result
+			}
+			// With -path-lang prior, the language the path names is weighed up.
+			weigh := func(results []langid.Confidence) []langid.Confidence { return results }
+			if *pathLang == "prior" {
+				if language := paths.language(path); language != "" {
+					weights := map[string]float64{language: 1 + *pathLangWeight}
+					weigh = func(results []langid.Confidence) []langid.Confidence {
+						return langid.ApplyPrior(results, weights)
+					}
+				}
+			}
+			// With -lang-manifest, only the languages allowed for the path
+			// are kept.
+			allowed := manifest.allowed(path)
+			if *perLine {
+				scanner := newLineScanner(strings.NewReader(text))
+				number, offset := 0, int64(0)
+				for scanner.Scan() {
+					number++
+					raw := scanner.Text()
+					r := lineResult{number: number, offset: offset, line: trimLineEnd(raw)}
+					r.results, r.err = classifyLine(r.line)
+					r.results = restrictResults(weigh(r.results), allowed)
+					result.lines = append(result.lines, r)
+					offset += int64(len(raw))
+				}
+				return result
+			}
+			if !utf8.ValidString(text) {
+				result.invalid = text
+			}
+			if text, ok := policy.clean(text); ok {
+				if out.textColumns() {
+					result.text = text
+				}
+				result.results, result.err = detectText(text)
+				result.results = weigh(result.results)
+				if allowed != nil {
+					result.detected = result.results
+					result.results = restrictResults(result.results, allowed)
+				}
+			} else {
+				result.err = errFiltered
+			}
+			return result
+		}
+		// checkPathLanguage reports a file detected in another language than
+		// the one its path names, for -path-lang check.
+		mismatches := 0
+		checker := langChecker{threshold: out.minScore()}
+		checkPathLanguage := func(result fileResult) {
+			language := paths.language(result.path)
+			if language == "" || checker.status(language, result.results) != "mismatch" {
+				return
+			}
+			mismatches++
+			fmt.Fprintf(os.Stderr, "%s: named for %s, detected as %s (%.2f)\n",
+				result.path, language, result.results[0].Code, result.results[0].Score)
+		}
+		// checkManifest reports a file detected in a language the manifest
+		// does not allow for it, for -lang-manifest.
+		disallowed := 0
+		checkManifest := func(result fileResult) {
+			detected := result.detected
+			if len(detected) == 0 || detected[0].Score <= 0 || detected[0].Score < out.minScore() {
+				return
+			}
+			allowed := manifest.allowed(result.path)
+			if slices.Contains(allowed, detected[0].Code) {
+				return
+			}
+			disallowed++
+			fmt.Fprintf(os.Stderr, "%s: allowed %s, detected as %s (%.2f)\n",
+				result.path, strings.Join(allowed, ","), detected[0].Code, detected[0].Score)
+		}
+		emit := func(result fileResult) {
+			processed++
+			switch {
+			case result.readErr != nil:
+				fmt.Fprintf(os.Stderr, "warning: %s: %v\n", result.path, result.readErr)
+			case result.err != nil && result.err != errFiltered:
+				exitOnDetectError(result.err)
+			case *perLine && *aggregate:
+				for _, r := range result.lines {
+					policy.check(r.line, result.path, r.number)
+					observe(result.path, r.number, r.line, r.results)
+				}
+				shares := out.withoutThreshold()
+				shares.printNamed(result.path, shareOfLines(result.lines, out.minScore()))
+			case *perLine:
+				out.file = result.path
+				if smooth != nil {
+					smooth = newSmoother(*smoothWindow, *smoothWeight)
+				}
+				for _, r := range result.lines {
+					emitLine(result.path, r)
+				}
+			default:
+				if result.invalid != "" {
+					if _, ok := policy.check(result.invalid, result.path, 1); !ok {
+						return
+					}
+				}
+				out.source = result.text
+				if result.err == errFiltered {
+					observe(result.path, 0, "", nil)
+					out.printUnknownNamed(result.path)
+					return
+				}
+				observe(result.path, 0, "", result.results)
+				out.printNamed(result.path, result.results)
+				if *pathLang == "check" {
+					checkPathLanguage(result)
+				}
+				checkManifest(result)
+			}
+		}
+		if cp != nil {
+			emitFile := emit
+			emit = func(result fileResult) {
+				emitFile(result)
+				cp.advance(0, result.path, out.w)
+			}
+		}
+		if err := parallelMap(*workers, !*unordered, produce, work, emit); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		endInterrupted(processed, "files")
+		if cp != nil {
+			cp.finish()
+		}
+		if mismatches > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %d files are not in the language their path names\n", mismatches, processed)
+		}
+		if disallowed > 0 {
+			fmt.Fprintf(os.Stderr, "%d of %d files are in a language their manifest does not allow\n", disallowed, processed)
+		}
+		if mismatches > 0 || disallowed > 0 {
+			exit(1)
+		}
+		return
+	}
+
+	if *each || *textsFrom != "" {
+		texts := positionalArgs
+		if *textsFrom != "" {
+			data, err := os.ReadFile(*textsFrom)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				exit(1)
+			}
+			texts = splitTexts(string(data))
+		}
+		// Each text is classified like a line, numbered by position.
+		out.numberLines = true
+		produce := func(send func(lineResult)) error {
+			for i, text := range texts {
+				if stopping() {
+					break
+				}
+				if !sh.keeps(i+1, text) {
+					continue
+				}
+				send(lineResult{number: i + 1, line: text})
+			}
+			return nil
+		}
+		work := func(r lineResult) lineResult {
+			r.results, r.err = classifyLine(r.line)
+			return r
+		}
+		processed := 0
+		parallelMap(*workers, !*unordered, produce, work, func(r lineResult) {
+			processed++
+			emitLine("", r)
+		})
+		endInterrupted(processed, "texts")
+		return
+	}
 
 	if len(positionalArgs) > 0 {
 		// Text supplied as positional arguments
 		text := strings.Join(positionalArgs, " ")
-		if *minLength > 0 && !longEnough(text, *minLength) {
-			fmt.Printf("unknown%s\n", *delimiter)
+		text, ok, err := records.check(text, int64(len(text)), "arguments")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		if !ok {
 			return
 		}
-		if *multi {
-			results := detector.DetectMultipleLanguagesOf(text)
-			printWithOffset(results, text, *delimiter)
+		text, ok = policy.check(text, "", 1)
+		if !ok {
+			return
+		}
+		out.source = text
+		if !classifiable(preprocess(text)) {
+			observe("", 0, text, nil)
+			out.printUnknown()
+			return
+		}
+		if spanMode {
+			spans, err := detectSpans(truncate(text))
+			exitOnDetectError(err)
+			out.printWithOffset(spans, text, 0)
 		} else {
-			results := detector.ComputeLanguageConfidenceValues(text)
-			printConfidenceValues(results, *delimiter, *confidenceVal, hasConfidence, *showAll)
+			results, err := traceDetect(traceCtx, preprocess(text), detector.ConfidenceValues)
+			exitOnDetectError(err)
+			observe("", 0, text, results)
+			out.printConfidenceValues(results)
 		}
 		return
 	}
 
-	// Read from stdin
-	if *perLine {
-		scanner := bufio.NewScanner(os.Stdin)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if *minLength > 0 && !longEnough(line, *minLength) {
-				fmt.Printf("unknown%s%s%s\n", *delimiter, *delimiter, line)
-				continue
+	// Read from stdin. A resumed run seeks past the lines done if stdin is
+	// a UTF-8 file, and reads over them otherwise.
+	firstLine, firstOffset, skip := 1, int64(0), int64(0)
+	if cp != nil && cp.done > 0 {
+		firstLine, firstOffset, skip = int(cp.done)+1, cp.offset, cp.done
+		if info, err := os.Stdin.Stat(); err == nil && info.Mode().IsRegular() && isUTF8Name(*inputEncoding) {
+			if _, err := os.Stdin.Seek(cp.offset, io.SeekStart); err == nil {
+				skip = 0
 			}
-			results := detector.ComputeLanguageConfidenceValues(line)
-			printLineWithConfidenceValues(line, results, *delimiter, *confidenceVal, hasConfidence, *showAll)
 		}
-		if err := scanner.Err(); err != nil {
+	}
+	input, encodingName, err := decodeInput(os.Stdin, *inputEncoding)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		exit(1)
+	}
+	if skip > 0 {
+		br := bufio.NewReader(input)
+		if err := skipLines(br, skip); err != nil && err != io.EOF {
 			fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
-			os.Exit(1)
+			exit(1)
+		}
+		input = br
+	}
+	if *showEncoding {
+		out.encoding = encodingName
+	}
+	if *csvInput {
+		br := bufio.NewReader(input)
+		if csvFormat.delimiter == 0 {
+			csvFormat.delimiter = sniffCSVDelimiter(br, csvFormat.quote)
+		}
+		reader := &csvReader{r: br, csvDialect: csvFormat}
+		writer := &csvWriter{w: out.w, csvDialect: csvFormat}
+		first, _, err := reader.read()
+		if err == io.EOF {
+			return
 		}
-	} else {
-		raw, err := io.ReadAll(os.Stdin)
 		if err != nil {
+			fmt.Fprintf(os.Stderr, "error reading stdin: line %d: %v\n", reader.line, err)
+			exit(1)
+		}
+		var header []string
+		if *csvHeader {
+			header = first
+		}
+		columns, err := newCSVColumns(header, len(first), *textField, *langField, *confField, *hintField)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -csv: %v\n", err)
+			exit(1)
+		}
+		if header != nil {
+			writer.write(columns.fill(header, *langField, *confField))
+		}
+		produce := func(send func(csvRow)) error {
+			// Rows are numbered for -shard without the header.
+			rows := 0
+			keep := func(fields []string) bool {
+				rows++
+				return sh.keeps(rows, strings.Join(fields, "\x00"))
+			}
+			if header == nil && keep(first) {
+				send(csvRow{line: 1, fields: first})
+			}
+			for {
+				fields, line, err := reader.read()
+				if err == io.EOF || stopping() {
+					return nil
+				}
+				if err != nil {
+					return fmt.Errorf("line %d: %v", line, err)
+				}
+				if keep(fields) {
+					send(csvRow{line: line, fields: fields})
+				}
+			}
+		}
+		work := func(r csvRow) csvRow {
+			if len(r.fields) != columns.width {
+				if *csvRagged != "pad" {
+					r.ragged = true
+					return r
+				}
+				r.fields = slices.Grow(r.fields, columns.width)[:columns.width]
+			}
+			r.results, r.err = classifyLine(r.fields[columns.text])
+			if r.err == nil && hint != nil {
+				r.results = hint.apply(r.results, r.fields[columns.hint])
+			}
+			return r
+		}
+		processed := 0
+		emit := func(r csvRow) {
+			processed++
+			if r.ragged {
+				switch *csvRagged {
+				case "error":
+					fmt.Fprintf(os.Stderr, "error: -csv: line %d: %d fields instead of %d\n", r.line, len(r.fields), columns.width)
+					exit(1)
+				case "skip":
+					fmt.Fprintf(os.Stderr, "warning: -csv: line %d: skipped a row of %d fields instead of %d\n", r.line, len(r.fields), columns.width)
+				case "pass":
+					writer.write(r.fields)
+					out.endResult()
+				}
+				return
+			}
+			if r.err != errFiltered {
+				exitOnDetectError(r.err)
+			}
+			observe("", r.line, r.fields[columns.text], r.results)
+			out.printCSV(writer, columns, r.fields, r.results)
+		}
+		if err := parallelMap(*workers, !*unordered, produce, work, emit); err != nil {
 			fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
-			os.Exit(1)
+			exit(1)
 		}
-		text := string(raw)
-		if *minLength > 0 && !longEnough(text, *minLength) {
+		endInterrupted(processed, "rows")
+		return
+	}
+	if *perLine {
+		processed := 0
+		emit := func(r lineResult) {
+			processed++
+			if passthrough != nil {
+				passthrough.WriteString(r.raw)
+				if *flush {
+					passthrough.Flush()
+				}
+			}
+			if !r.skipped {
+				emitLine("", r)
+			}
+			if cp != nil {
+				cp.advance(r.size, "", out.w)
+			}
+		}
+		var keep func(lineResult) bool
+		if sh.count > 1 {
+			keep = func(r lineResult) bool { return sh.keeps(r.number, r.line) }
+		}
+		if err := classifyLines(input, records, firstLine, firstOffset, *workers, !*unordered, keep, classifyLine, emit); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
+			exit(1)
+		}
+		endInterrupted(processed, "lines")
+		if cp != nil {
+			cp.finish()
+		}
+	} else {
+		// With -m or -tokens and -chunk-size, the last span of a chunk is
+		// held back as carry and split again together with the next chunk,
+		// so that spans are not cut at chunk boundaries, unless it is longer
+		// than a chunk. base is the input offset of carry.
+		stitch := spanMode && *chunkSize > 0
+		var carry string
+		base := 0
+
+		// classifyText classifies all of raw at once; firstLine is the
+		// number of its first line within the input.
+		classifyText := func(raw string, firstLine int) {
+			if passthrough != nil {
+				passthrough.WriteString(raw)
+			}
+			text, ok := policy.check(raw, "", firstLine)
+			if !ok {
+				base += len(raw)
+				return
+			}
+			if spanMode {
+				text = carry + text
+				classified := classifiable(preprocess(text))
+				var spans []langid.Span
+				if classified {
+					var err error
+					spans, err = detectSpans(truncate(text))
+					exitOnDetectError(err)
+				}
+				keep := len(text)
+				if stitch && len(spans) > 0 && len(text)-spans[len(spans)-1].Start <= *chunkSize {
+					keep = spans[len(spans)-1].Start
+					spans = spans[:len(spans)-1]
+				}
+				if classified && (len(spans) > 0 || keep == len(text)) {
+					out.printWithOffset(spans, text, base)
+				}
+				carry, base = text[keep:], base+keep
+				return
+			}
+			if !classifiable(preprocess(text)) {
+				return
+			}
+			out.source = text
+			results, err := traceDetect(traceCtx, preprocess(text), detector.ConfidenceValues)
+			exitOnDetectError(err)
+			observe("", 0, text, results)
+			out.printConfidenceValues(results)
+		}
+
+		if *idle > 0 || *chunkSize > 0 {
+			// Stream mode: results are wanted while the input continues.
+			out.flush = true
+			line, processed := 1, 0
+			err := streamChunks(input, *idle, *chunkSize, *chunkBoundary, func(chunk []byte) {
+				processed++
+				classifyText(string(chunk), line)
+				line += bytes.Count(chunk, []byte("\n"))
+				if passthrough != nil {
+					passthrough.Flush()
+				}
+			})
+			if err != nil && !stopping() {
+				fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
+				exit(1)
+			}
+			if carry != "" {
+				stitch = false
+				classifyText("", line)
+			}
+			endInterrupted(processed, "chunks")
 			return
 		}
-		if *multi {
-			results := detector.DetectMultipleLanguagesOf(text)
-			printWithOffset(results, text, *delimiter)
-		} else {
-			results := detector.ComputeLanguageConfidenceValues(text)
-			printConfidenceValues(results, *delimiter, *confidenceVal, hasConfidence, *showAll)
+
+		// With -tee, all of the input is passed through.
+		limit := *maxChars
+		if passthrough != nil {
+			limit = 0
+		}
+		// An interrupt ends the input, and what was read of it is
+		// classified.
+		raw, size, err := readText(input, limit, records.maxBytes)
+		if err != nil && !stopping() {
+			fmt.Fprintf(os.Stderr, "error reading stdin: %v\n", err)
+			exit(1)
+		}
+		raw, ok, err := records.check(raw, size, "input")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			exit(1)
+		}
+		if ok {
+			classifyText(raw, 1)
 		}
+		endInterrupted(len(raw), "bytes")
 	}
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// langManifest maps path patterns to the languages the files they match may
+// be in, for -lang-manifest.
+type langManifest struct {
+	// dir is the directory of the manifest, which the patterns are relative
+	// to.
+	dir   string
+	rules []manifestRule
+}
+
+// manifestRule is a line of a manifest.
+type manifestRule struct {
+	pattern   string
+	languages []string
+}
+
+// loadLangManifest reads a manifest of lines of the form
+//
+//	pattern code[,code...]
+//
+// where pattern is a glob in which "**" stands for any number of
+// directories, and a pattern naming a directory matches the files below it.
+// Blank lines and lines starting with # are ignored.
+func loadLangManifest(file string) (*langManifest, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	m := &langManifest{dir: dir}
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected a pattern and a list of languages", file, number)
+		}
+		rule := manifestRule{pattern: path.Clean(strings.TrimPrefix(filepath.ToSlash(fields[0]), "/"))}
+		for _, tag := range splitList(fields[1]) {
+			code := declaredLanguage(tag)
+			if code == "" {
+				return nil, fmt.Errorf("%s:%d: invalid language %q", file, number, tag)
+			}
+			rule.languages = append(rule.languages, code)
+		}
+		if len(rule.languages) == 0 {
+			return nil, fmt.Errorf("%s:%d: no languages", file, number)
+		}
+		m.rules = append(m.rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(m.rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", file)
+	}
+	return m, nil
+}
+
+// languages returns the codes of all languages the manifest names.
+func (m *langManifest) languages() []string {
+	var codes []string
+	for _, rule := range m.rules {
+		codes = append(codes, rule.languages...)
+	}
+	return codes
+}
+
+// allowed returns the languages the first rule matching file allows, or nil
+// if none matches or there is no manifest. Files below the directory of the
+// manifest are matched by their path relative to it, others by their path as
+// given.
+func (m *langManifest) allowed(file string) []string {
+	if m == nil {
+		return nil
+	}
+	name := filepath.ToSlash(file)
+	if !isURL(file) && !isObjectURI(file) {
+		if abs, err := filepath.Abs(file); err == nil {
+			if rel, err := filepath.Rel(m.dir, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				name = filepath.ToSlash(rel)
+			}
+		}
+	}
+	for _, rule := range m.rules {
+		if matchGlobOrParent(rule.pattern, name) {
+			return rule.languages
+		}
+	}
+	return nil
+}
+
+// restrictResults returns the confidence values of the allowed languages,
+// rescaled to sum to 1, as a detector restricted to them would report them.
+// All results are kept if allowed is nil.
+func restrictResults(results []langid.Confidence, allowed []string) []langid.Confidence {
+	if allowed == nil || len(results) == 0 {
+		return results
+	}
+	var restricted []langid.Confidence
+	total := 0.0
+	for _, result := range results {
+		if slices.Contains(allowed, result.Code) {
+			restricted = append(restricted, result)
+			total += result.Score
+		}
+	}
+	if total > 0 {
+		for i := range restricted {
+			restricted[i].Score /= total
+		}
+	}
+	return restricted
+}
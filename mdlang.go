@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// frontMatterExtensions are the extensions of the files the mdlang command
+// checks.
+var frontMatterExtensions = []string{".htm", ".html", ".markdown", ".md", ".mdown", ".mdx", ".mkd"}
+
+// splitFrontMatter splits a document into its front matter, YAML between
+// "---" lines or TOML between "+++" lines, and its content. format is "yaml"
+// or "toml", or "" if the document has no front matter.
+func splitFrontMatter(source string) (format, matter, content string) {
+	source = strings.TrimPrefix(source, "\ufeff")
+	for _, delimiter := range []struct{ line, format string }{{"---", "yaml"}, {"+++", "toml"}} {
+		first, rest, ok := strings.Cut(source, "\n")
+		if !ok || strings.TrimRight(first, " \r") != delimiter.line {
+			continue
+		}
+		for offset := 0; offset < len(rest); {
+			line, _, _ := strings.Cut(rest[offset:], "\n")
+			if strings.TrimRight(line, " \r") == delimiter.line || (delimiter.format == "yaml" && strings.TrimRight(line, " \r") == "...") {
+				return delimiter.format, rest[:offset], rest[min(offset+len(line)+1, len(rest)):]
+			}
+			offset += len(line) + 1
+		}
+	}
+	return "", "", source
+}
+
+// frontMatterField returns the value of a top-level string field of YAML or
+// TOML front matter, or "" if it is not set.
+func frontMatterField(format, matter, name string) string {
+	separator := ":"
+	if format == "toml" {
+		separator = "="
+	}
+	for _, line := range strings.Split(matter, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if format == "toml" && strings.HasPrefix(line, "[") {
+			// Fields after a table header belong to the table.
+			return ""
+		}
+		key, raw, ok := strings.Cut(strings.TrimSpace(stripComment(line)), separator)
+		if !ok || strings.Trim(strings.TrimSpace(key), `"'`) != name {
+			continue
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			return ""
+		}
+		value, err := parseConfigValue(raw)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(value)
+	}
+	return ""
+}
+
+var (
+	markdownFence     = regexp.MustCompile("(?ms)^ {0,3}(```|~~~).*?^ {0,3}(```|~~~)[ \t]*$")
+	markdownTemplate  = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{%.*?%\}`)
+	markdownCode      = regexp.MustCompile("`[^`\n]*`")
+	markdownLink      = regexp.MustCompile(`!?\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	markdownReference = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:.*$`)
+	markdownURL       = regexp.MustCompile(`<?https?://[^\s>)]+>?`)
+	markdownMarker    = regexp.MustCompile(`(?m)^[ \t]*(#{1,6}|>|[-*+]|\d+[.)])[ \t]+`)
+	markdownEmphasis  = regexp.MustCompile("[*_~|]+")
+)
+
+// markdownText returns the prose of Markdown content, without code, URLs,
+// Liquid and Hugo template tags, HTML tags and markup.
+func markdownText(source string) string {
+	text := markdownFence.ReplaceAllString(source, "\n")
+	text = markdownTemplate.ReplaceAllString(text, " ")
+	text = markdownCode.ReplaceAllString(text, " ")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownReference.ReplaceAllString(text, "")
+	text = markdownURL.ReplaceAllString(text, " ")
+	text = htmlSkipped.ReplaceAllString(text, " ")
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+	text = markdownMarker.ReplaceAllString(text, "")
+	return markdownEmphasis.ReplaceAllString(text, " ")
+}
+
+// runMDLang implements the mdlang command. It compares the
+// language the front matter of Markdown and HTML files (Jekyll, Hugo and
+// other static site generators) declares with the language of their
+// content, and reports the mismatches.
+func runMDLang(args []string) int {
+	fs := flag.NewFlagSet("mdlang", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0.5,
+		"Confidence threshold, content scoring below it is reported as 'unknown' rather than a mismatch (0.0-1.0)")
+	fields := fs.String("fields", "lang,language",
+		"Comma separated list of front matter fields declaring the language, the first one set is used")
+	defaultLang := fs.String("default-lang", "",
+		"Language of files whose front matter declares none, such as the site's default language; without it they are skipped")
+	minLength := fs.Int("min-length", 40,
+		"Minimum length in characters of the content of a file to check it")
+	all := fs.Bool("all", false,
+		"Report every file, not only mismatches")
+	junit := fs.String("junit", "",
+		"Write a JUnit XML report to this file, with a test case per check, for CI systems")
+	sarif := fs.String("sarif", "",
+		"Write the findings as a SARIF 2.1.0 log to this file, for code scanning tools")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Check the language declared in the front matter of Markdown and HTML files.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli mdlang [OPTIONS] PATH...\n\n")
+		fmt.Fprintf(os.Stderr, "Directories are searched recursively for files with the extensions:\n")
+		fmt.Fprintf(os.Stderr, "%s\n\n", strings.Join(frontMatterExtensions, " "))
+		fmt.Fprintf(os.Stderr, "Prints status (mismatch, and with -all ok, unknown, short and undeclared), path,\n")
+		fmt.Fprintf(os.Stderr, "field, declared language, detected language and score per file, then a summary\n")
+		fmt.Fprintf(os.Stderr, "on stderr. Exits with status 1 if any file has a mismatch.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *defaultLang != "" && declaredLanguage(*defaultLang) == "" {
+		fmt.Fprintf(os.Stderr, "error: invalid -default-lang %q\n", *defaultLang)
+		return 2
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+	checker := &langChecker{detector: detector, threshold: *threshold}
+	report := newJUnitReport(*junit, "mismatch")
+	findings := newSARIFReport(*sarif)
+
+	files := 0
+	statuses := make(map[string]int)
+	out := bufio.NewWriter(os.Stdout)
+	err = walkFiles(fs.Args(), func(path string) {
+		ext := strings.ToLower(filepath.Ext(path))
+		if !slices.Contains(frontMatterExtensions, ext) {
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			return
+		}
+		format, matter, content := splitFrontMatter(string(data))
+		check := langCheck{page: path, element: "-default-lang", declared: *defaultLang}
+		for _, field := range splitList(*fields) {
+			if value := frontMatterField(format, matter, field); value != "" {
+				check.element, check.declared = field, value
+				break
+			}
+		}
+		var text string
+		if ext == ".html" || ext == ".htm" {
+			text = htmlText(markdownTemplate.ReplaceAllString(content, " "))
+		} else {
+			text = markdownText(content)
+		}
+		switch {
+		case check.declared == "":
+			check.element, check.status = "-", "undeclared"
+		case utf8.RuneCountInString(strings.Join(strings.Fields(text), " ")) < *minLength:
+			check.status = "short"
+		default:
+			check = checker.check(path, check.element, check.declared, text)
+		}
+		files++
+		statuses[check.status]++
+		report.add(path, check)
+		findings.add(path, check)
+		if !*all && check.status != "mismatch" {
+			return
+		}
+		detected, score := "-", "-"
+		if len(check.results) > 0 && check.results[0].Score > 0 {
+			detected, score = check.results[0].Code, formatScore(check.results[0].Score)
+		}
+		declared := check.declared
+		if declared == "" {
+			declared = "-"
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\n", check.status, path, check.element, declared, detected, score)
+	})
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if err := report.write(*junit, "mdlang"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := findings.write(*sarif); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\n%d files\n", files)
+	fmt.Fprintf(os.Stderr, "status: %s\n", formatCounts(statuses, files))
+	if statuses["mismatch"] > 0 {
+		return 1
+	}
+	return 0
+}
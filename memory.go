@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// byteUnits are the suffixes of byte sizes, in binary multiples as in
+// GOMEMLIMIT.
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"TiB", 1 << 40}, {"GiB", 1 << 30}, {"MiB", 1 << 20}, {"KiB", 1 << 10},
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10},
+	{"B", 1},
+}
+
+// parseByteSize parses a size in bytes such as 512MiB, 2G or 1048576.
+func parseByteSize(value string) (int64, error) {
+	number, unit := strings.TrimSpace(value), int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(strings.ToUpper(number), strings.ToUpper(u.suffix)) {
+			number, unit = strings.TrimSpace(number[:len(number)-len(u.suffix)]), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n <= 0 || n*float64(unit) > float64(1<<62) {
+		return 0, fmt.Errorf("invalid size %q (expected a number of bytes with an optional unit such as KiB, MiB or GiB)", value)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// memoryBudget shares out the memory of -max-memory.
+type memoryBudget int64
+
+const (
+	// budgetCacheEntry is the memory assumed for an entry of the result
+	// cache, a text of a few kilobytes and its confidence values.
+	budgetCacheEntry = 4 << 10
+	// budgetPressure is the share of the budget at which caches are
+	// flushed.
+	budgetPressure = 0.8
+)
+
+// apply sets the memory limit of the Go runtime to the budget, like
+// GOMEMLIMIT, so that the garbage collector works harder as memory runs
+// short instead of the process being killed.
+func (b memoryBudget) apply() {
+	debug.SetMemoryLimit(int64(b))
+}
+
+// cacheSize returns the number of results of -cache that fit in a sixteenth
+// of the budget, or size if it is smaller.
+func (b memoryBudget) cacheSize(size int) int {
+	return int(min(int64(size), int64(b)/16/budgetCacheEntry))
+}
+
+// recordSize returns the size of records that fit in a sixteenth of the
+// budget, or size if it is smaller and not 0.
+func (b memoryBudget) recordSize(size int) int {
+	limit := int(int64(b) / 16)
+	if size > 0 && size < limit {
+		return size
+	}
+	return limit
+}
+
+// watch empties the caches of d whenever the heap grows beyond
+// budgetPressure of the budget, and returns the freed memory to the
+// operating system, so that the run degrades to classifying repeated texts
+// again instead of running out of memory.
+func (b memoryBudget) watch(d langid.Detector) {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	threshold := uint64(float64(b) * budgetPressure)
+	go func() {
+		warned := false
+		for range time.Tick(500 * time.Millisecond) {
+			metrics.Read(sample)
+			if sample[0].Value.Kind() != metrics.KindUint64 || sample[0].Value.Uint64() < threshold {
+				continue
+			}
+			// Without cached results to drop, the detector itself takes up
+			// the memory, and collecting it again would only slow down
+			// the run.
+			if langid.FlushCaches(d) == 0 {
+				continue
+			}
+			debug.FreeOSMemory()
+			if !warned {
+				fmt.Fprintf(os.Stderr, "warning: memory use close to -max-memory, flushed the result cache\n")
+				warned = true
+			}
+		}
+	}()
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// androidEscapes are the escape sequences of Android string resources.
+var androidEscapes = strings.NewReplacer(`\'`, `'`, `\"`, `"`, `\n`, "\n", `\t`, "\t", `\@`, "@", `\?`, "?", `\\`, `\`)
+
+// readAndroidResource returns the strings, string arrays and plurals of an
+// Android resource file (values-*/strings.xml), without those marked
+// translatable="false". Other XML files have no values.
+func readAndroidResource(data []byte) ([]resourceValue, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var values []resourceValue
+	// key and line are those of the value being read, text its content;
+	// name is the name of the array or plurals element around it.
+	var key, name string
+	var line, items, skipped int
+	var text strings.Builder
+	for depth := 0; ; {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			attributes := make(map[string]string)
+			for _, a := range t.Attr {
+				attributes[a.Name.Local] = a.Value
+			}
+			switch {
+			case depth == 1 && t.Name.Local != "resources":
+				return nil, nil
+			case depth == 2 && attributes["translatable"] == "false":
+				decoder.Skip()
+				depth--
+			case depth == 2 && t.Name.Local == "string":
+				key, line = attributes["name"], inputLine(decoder)
+				text.Reset()
+			case depth == 2 && (t.Name.Local == "string-array" || t.Name.Local == "plurals"):
+				name, items = attributes["name"], 0
+			case depth == 3 && t.Name.Local == "item" && name != "":
+				key, line = fmt.Sprintf("%s[%d]", name, items), inputLine(decoder)
+				if quantity := attributes["quantity"]; quantity != "" {
+					key = name + "." + quantity
+				}
+				items++
+				text.Reset()
+			case key != "" && t.Name.Local == "g":
+				// xliff:g marks placeholders and other text not to translate.
+				skipped++
+			}
+		case xml.EndElement:
+			depth--
+			switch {
+			case key != "" && t.Name.Local == "g":
+				skipped--
+			case depth == 1 && t.Name.Local == "string", depth == 2 && t.Name.Local == "item":
+				value := strings.TrimSpace(text.String())
+				if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+					value = value[1 : len(value)-1]
+				}
+				values = append(values, resourceValue{key: key, line: line, text: androidEscapes.Replace(value)})
+				key = ""
+			case depth == 1:
+				name = ""
+			}
+		case xml.CharData:
+			if key != "" && skipped == 0 {
+				text.Write(t)
+			}
+		}
+	}
+}
+
+// inputLine returns the line the decoder is at.
+func inputLine(decoder *xml.Decoder) int {
+	line, _ := decoder.InputPos()
+	return line
+}
+
+// readAppleStrings returns the values of an Apple .strings file, lines of
+// "key" = "value"; in UTF-8 or UTF-16.
+func readAppleStrings(data []byte) ([]resourceValue, error) {
+	source := decodeAppleStrings(data)
+	var values []resourceValue
+	line := 1
+	i := 0
+	// next returns the next string, word or punctuation character and the
+	// line it is on; ok is false for punctuation.
+	next := func() (string, int, bool, error) {
+		for i < len(source) {
+			switch {
+			case source[i] == '\n':
+				line++
+				i++
+			case source[i] == ' ' || source[i] == '\t' || source[i] == '\r':
+				i++
+			case strings.HasPrefix(source[i:], "/*"):
+				end := strings.Index(source[i+2:], "*/")
+				if end < 0 {
+					return "", line, false, errors.New("comment not closed")
+				}
+				line += strings.Count(source[i:i+2+end], "\n")
+				i += end + 4
+			case strings.HasPrefix(source[i:], "//"):
+				for i < len(source) && source[i] != '\n' {
+					i++
+				}
+			case source[i] == '"':
+				start := line
+				var value strings.Builder
+				for i++; i < len(source) && source[i] != '"'; i++ {
+					c := source[i]
+					if c == '\n' {
+						line++
+					}
+					if c != '\\' || i+1 >= len(source) {
+						value.WriteByte(c)
+						continue
+					}
+					i++
+					switch c = source[i]; c {
+					case 'n':
+						value.WriteByte('\n')
+					case 't':
+						value.WriteByte('\t')
+					case 'r':
+						value.WriteByte('\r')
+					case 'U', 'u':
+						if i+4 < len(source) {
+							if r, err := strconv.ParseUint(source[i+1:i+5], 16, 16); err == nil {
+								value.WriteRune(rune(r))
+								i += 4
+								continue
+							}
+						}
+						value.WriteByte(c)
+					default:
+						value.WriteByte(c)
+					}
+				}
+				if i >= len(source) {
+					return "", start, false, errors.New("string not closed")
+				}
+				i++
+				return value.String(), start, true, nil
+			case source[i] == '=' || source[i] == ';':
+				i++
+				return string(source[i-1]), line, false, nil
+			default:
+				start := i
+				for i < len(source) && !strings.ContainsRune(" \t\r\n=;\"/", rune(source[i])) {
+					i++
+				}
+				if i == start {
+					return "", line, false, fmt.Errorf("line %d: unexpected %q", line, source[i])
+				}
+				return source[start:i], line, true, nil
+			}
+		}
+		return "", line, false, io.EOF
+	}
+	for {
+		key, _, ok, err := next()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected a key", line)
+		}
+		separator, _, _, err := next()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: expected = or ;", line)
+		}
+		if separator == ";" {
+			continue
+		}
+		value, valueLine, ok, err := next()
+		if err != nil || separator != "=" || !ok {
+			return nil, fmt.Errorf("line %d: expected = and a value", line)
+		}
+		if end, _, _, err := next(); err != nil || end != ";" {
+			return nil, fmt.Errorf("line %d: expected ;", line)
+		}
+		values = append(values, resourceValue{key: key, line: valueLine, text: value})
+	}
+}
+
+// decodeAppleStrings decodes a .strings file, which Xcode writes in UTF-16
+// with a byte order mark, or in UTF-8.
+func decodeAppleStrings(data []byte) string {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xfe}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0xfe, 0xff}):
+		order = binary.BigEndian
+	default:
+		return strings.TrimPrefix(string(data), "\ufeff")
+	}
+	units := make([]uint16, (len(data)-2)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2+2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// stringsDictMetadata are the keys of .stringsdict files that hold no text.
+var stringsDictMetadata = map[string]bool{"NSStringFormatSpecTypeKey": true, "NSStringFormatValueTypeKey": true}
+
+// readStringsDict returns the strings of an Apple .stringsdict file, a
+// property list of plural rules, keyed by their path in its dictionaries
+// ("items.count.one").
+func readStringsDict(data []byte) ([]resourceValue, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var values []resourceValue
+	// path holds the keys of the dictionaries being read, key the last key
+	// read.
+	var path []string
+	key := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "dict":
+				if key != "" {
+					path = append(path, key)
+				}
+				key = ""
+			case "key", "string":
+				line := inputLine(decoder)
+				var s string
+				if err := decoder.DecodeElement(&s, &t); err != nil {
+					return nil, err
+				}
+				if t.Name.Local == "key" {
+					key = s
+					continue
+				}
+				if key != "" && !stringsDictMetadata[key] {
+					values = append(values, resourceValue{key: strings.Join(append(path, key), "."), line: line, text: s})
+				}
+				key = ""
+			}
+		case xml.EndElement:
+			if t.Name.Local == "dict" && len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		}
+	}
+}
+
+// platformLocale returns the locale an Android resource directory
+// ("values-de", "values-pt-rBR", "values-b+sr+Latn") or an Apple localization
+// directory ("de.lproj") is for, or "" if there is none.
+func platformLocale(dir string) string {
+	if name, ok := strings.CutSuffix(dir, ".lproj"); ok {
+		return name
+	}
+	qualifiers, ok := strings.CutPrefix(dir, "values-")
+	if !ok {
+		return ""
+	}
+	if tag, ok := strings.CutPrefix(qualifiers, "b+"); ok {
+		tag, _, _ = strings.Cut(tag, "-")
+		return strings.ReplaceAll(tag, "+", "-")
+	}
+	parts := strings.Split(qualifiers, "-")
+	if len(parts[0]) != 2 && len(parts[0]) != 3 {
+		return ""
+	}
+	if len(parts) > 1 && len(parts[1]) == 3 && parts[1][0] == 'r' {
+		return parts[0] + "-" + parts[1][1:]
+	}
+	return parts[0]
+}
+
+// platformSource returns the file with the source language resources a
+// localized Android or Apple resource file translates: the file of the same
+// name in values/, or in the lproj directory of sourceLang or else
+// Base.lproj, or "" if there is none.
+func platformSource(path, sourceLang string) string {
+	dir := filepath.Dir(path)
+	parent, name := filepath.Dir(dir), filepath.Base(path)
+	var candidates []string
+	switch {
+	case strings.HasPrefix(filepath.Base(dir), "values-"):
+		candidates = []string{filepath.Join(parent, "values", name)}
+	case strings.HasSuffix(dir, ".lproj"):
+		candidates = []string{filepath.Join(parent, sourceLang+".lproj", name), filepath.Join(parent, "Base.lproj", name)}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil && candidate != path {
+			return candidate
+		}
+	}
+	return ""
+}
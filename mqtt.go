@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// MQTT 3.1.1 control packet types, shifted into the high nibble of the
+// first byte.
+const (
+	mqttConnect    = 1 << 4
+	mqttConnack    = 2 << 4
+	mqttPublish    = 3 << 4
+	mqttPuback     = 4 << 4
+	mqttPubrec     = 5 << 4
+	mqttPubrel     = 6<<4 | 2
+	mqttPubcomp    = 7 << 4
+	mqttDisconnect = 14 << 4
+)
+
+// mqttSink publishes the results as json-v1 records to an MQTT broker, one
+// message per result on the topic of its language. It speaks just enough of
+// MQTT 3.1.1 to publish, waiting for the broker to acknowledge every message
+// of QoS 1 and 2.
+type mqttSink struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// topic is the topic template, with {lang} replaced by the language.
+	topic     string
+	qos       byte
+	threshold float64
+	labels    labelMap
+	packetID  uint16
+}
+
+// dialMQTT connects to the broker at rawURL, mqtt://host[:1883] or
+// mqtts://host[:8883], with the user name and password given in the URL.
+func dialMQTT(rawURL, topic string, qos int, threshold float64, labels labelMap) (*mqttSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if qos < 0 || qos > 2 {
+		return nil, fmt.Errorf("invalid QoS %d (0, 1 or 2)", qos)
+	}
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	switch u.Scheme {
+	case "mqtt", "tcp":
+		conn, err = dialer.Dial("tcp", hostPort(u, "1883"))
+	case "mqtts", "ssl", "tls":
+		conn, err = tls.DialWithDialer(dialer, "tcp", hostPort(u, "8883"), nil)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q (mqtt or mqtts)", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &mqttSink{conn: conn, r: bufio.NewReader(conn), topic: topic, qos: byte(qos), threshold: threshold, labels: labels}
+	if err := s.connect(u.User); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// connect opens a clean session without keep-alive, so that a quiet -watch
+// run is not disconnected.
+func (s *mqttSink) connect(user *url.Userinfo) error {
+	var body []byte
+	body = appendMQTTString(body, "MQTT")
+	flags := byte(0x02) // clean session
+	if user != nil {
+		flags |= 0x80
+		if _, ok := user.Password(); ok {
+			flags |= 0x40
+		}
+	}
+	body = append(body, 4, flags, 0, 0)
+	body = appendMQTTString(body, fmt.Sprintf("lingua-cli-%d", os.Getpid()))
+	if user != nil {
+		body = appendMQTTString(body, user.Username())
+		if password, ok := user.Password(); ok {
+			body = appendMQTTString(body, password)
+		}
+	}
+	if err := s.write(mqttConnect, body); err != nil {
+		return err
+	}
+	typ, reply, err := s.read()
+	if err != nil {
+		return err
+	}
+	if typ != mqttConnack || len(reply) != 2 {
+		return errors.New("unexpected reply to CONNECT")
+	}
+	if reply[1] != 0 {
+		return fmt.Errorf("connection refused (%s)", mqttConnackCodes[min(int(reply[1]), len(mqttConnackCodes)-1)])
+	}
+	return nil
+}
+
+var mqttConnackCodes = []string{"accepted", "unacceptable protocol version", "identifier rejected",
+	"server unavailable", "bad user name or password", "not authorized", "unknown reason"}
+
+// add publishes the record of a result, reporting failures as warnings.
+func (s *mqttSink) add(name string, number int, text string, results []langid.Confidence) {
+	if s == nil {
+		return
+	}
+	r := sinkRecord(name, number, text, results, s.threshold, s.labels)
+	payload, err := json.Marshal(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -mqtt: %v\n", err)
+		return
+	}
+	if err := s.publish(strings.ReplaceAll(s.topic, "{lang}", *r.Language), payload); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -mqtt: %v\n", err)
+	}
+}
+
+// publish sends a message and, for QoS 1 and 2, waits for its
+// acknowledgement.
+func (s *mqttSink) publish(topic string, payload []byte) error {
+	body := appendMQTTString(nil, topic)
+	if s.qos > 0 {
+		s.packetID++
+		if s.packetID == 0 {
+			s.packetID = 1
+		}
+		body = binary.BigEndian.AppendUint16(body, s.packetID)
+	}
+	if err := s.write(mqttPublish|s.qos<<1, append(body, payload...)); err != nil {
+		return err
+	}
+	switch s.qos {
+	case 1:
+		return s.expect(mqttPuback)
+	case 2:
+		if err := s.expect(mqttPubrec); err != nil {
+			return err
+		}
+		if err := s.write(mqttPubrel, binary.BigEndian.AppendUint16(nil, s.packetID)); err != nil {
+			return err
+		}
+		return s.expect(mqttPubcomp)
+	}
+	return nil
+}
+
+// expect reads the acknowledgement of the current packet.
+func (s *mqttSink) expect(want byte) error {
+	typ, body, err := s.read()
+	if err != nil {
+		return err
+	}
+	if typ != want || len(body) < 2 || binary.BigEndian.Uint16(body) != s.packetID {
+		return fmt.Errorf("unexpected packet 0x%02x from the broker", typ)
+	}
+	return nil
+}
+
+// close disconnects from the broker.
+func (s *mqttSink) close() {
+	s.write(mqttDisconnect, nil)
+	s.conn.Close()
+}
+
+// write sends a packet of the given type with its variable header and
+// payload in body.
+func (s *mqttSink) write(typ byte, body []byte) error {
+	packet := []byte{typ}
+	// The remaining length is encoded in 7 bits per byte, least
+	// significant first.
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		packet = append(packet, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := s.conn.Write(append(packet, body...))
+	return err
+}
+
+// read reads a packet, returning its type and flags and its body.
+func (s *mqttSink) read() (byte, []byte, error) {
+	typ, err := s.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, shift := 0, 0
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return 0, nil, errors.New("malformed packet length")
+		}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(s.r, body); err != nil {
+		return 0, nil, err
+	}
+	return typ, body, nil
+}
+
+func appendMQTTString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
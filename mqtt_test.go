@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMQTTRemainingLength(t *testing.T) {
+	tests := []struct {
+		n       int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x80, 0x80, 0x01}},
+		{2097151, []byte{0xff, 0xff, 0x7f}},
+		{2097152, []byte{0x80, 0x80, 0x80, 0x01}},
+	}
+	for _, test := range tests {
+		client, server := net.Pipe()
+		body := bytes.Repeat([]byte{'x'}, test.n)
+		go func() {
+			(&mqttSink{conn: client}).write(mqttPublish, body)
+			client.Close()
+		}()
+		packet, err := io.ReadAll(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		head := append([]byte{mqttPublish}, test.encoded...)
+		if !bytes.HasPrefix(packet, head) || len(packet) != len(head)+test.n {
+			t.Errorf("length %d: packet starts % x, %d bytes, want % x, %d bytes", test.n,
+				packet[:min(len(packet), 5)], len(packet), head, len(head)+test.n)
+			continue
+		}
+		typ, got, err := (&mqttSink{r: bufio.NewReader(bytes.NewReader(packet))}).read()
+		if err != nil || typ != mqttPublish || !bytes.Equal(got, body) {
+			t.Errorf("length %d: read 0x%02x, %d bytes, %v", test.n, typ, len(got), err)
+		}
+	}
+}
+
+func TestMQTTReadMalformedLength(t *testing.T) {
+	packet := []byte{mqttPublish, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if _, _, err := (&mqttSink{r: bufio.NewReader(bytes.NewReader(packet))}).read(); err == nil {
+		t.Error("read a remaining length of 5 bytes")
+	}
+}
+
+// mqttBroker is the broker side of a connection to an mqttSink.
+func mqttBroker(conn net.Conn) *mqttSink {
+	return &mqttSink{conn: conn, r: bufio.NewReader(conn)}
+}
+
+func TestMQTTConnect(t *testing.T) {
+	tests := []struct {
+		code byte
+		err  string
+	}{
+		{0, ""},
+		{5, "connection refused (not authorized)"},
+	}
+	for _, test := range tests {
+		client, server := net.Pipe()
+		s, broker := mqttBroker(client), mqttBroker(server)
+		done := make(chan error, 1)
+		go func() {
+			done <- s.connect(url.UserPassword("user", "secret"))
+		}()
+		typ, body, err := broker.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []byte("\x00\x04MQTT\x04\xc2\x00\x00")
+		want = appendMQTTString(want, fmt.Sprintf("lingua-cli-%d", os.Getpid()))
+		want = append(want, "\x00\x04user\x00\x06secret"...)
+		if typ != mqttConnect || !bytes.Equal(body, want) {
+			t.Errorf("CONNECT 0x%02x % x, want % x", typ, body, want)
+		}
+		broker.write(mqttConnack, []byte{0, test.code})
+		err = <-done
+		if got := fmt.Sprint(err); test.err == "" && err != nil || test.err != "" && got != test.err {
+			t.Errorf("CONNACK code %d: connect returned %v, want %q", test.code, err, test.err)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestMQTTPublish(t *testing.T) {
+	payload := []byte(`{"language":"en"}`)
+	for qos := byte(0); qos <= 2; qos++ {
+		client, server := net.Pipe()
+		s, broker := mqttBroker(client), mqttBroker(server)
+		s.qos = qos
+		done := make(chan error, 1)
+		go func() {
+			done <- s.publish("lang/en", payload)
+		}()
+		typ, body, err := broker.read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []byte("\x00\x07lang/en")
+		if qos > 0 {
+			want = append(want, 0x00, 0x01)
+		}
+		want = append(want, payload...)
+		if typ != mqttPublish|qos<<1 || !bytes.Equal(body, want) {
+			t.Errorf("QoS %d: PUBLISH 0x%02x % x, want 0x%02x % x", qos, typ, body, mqttPublish|qos<<1, want)
+		}
+		switch qos {
+		case 1:
+			broker.write(mqttPuback, []byte{0x00, 0x01})
+		case 2:
+			broker.write(mqttPubrec, []byte{0x00, 0x01})
+			typ, body, err := broker.read()
+			if err != nil || typ != mqttPubrel || !bytes.Equal(body, []byte{0x00, 0x01}) {
+				t.Errorf("QoS 2: PUBREL 0x%02x % x, %v", typ, body, err)
+			}
+			broker.write(mqttPubcomp, []byte{0x00, 0x01})
+		}
+		if err := <-done; err != nil {
+			t.Errorf("QoS %d: %v", qos, err)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestMQTTPublishWrongAcknowledgement(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	s, broker := mqttBroker(client), mqttBroker(server)
+	s.qos = 1
+	done := make(chan error, 1)
+	go func() {
+		done <- s.publish("t", nil)
+	}()
+	broker.read()
+	broker.write(mqttPuback, []byte{0x00, 0x02})
+	if err := <-done; err == nil || !strings.Contains(err.Error(), "unexpected packet") {
+		t.Errorf("PUBACK of another packet: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// natsConn is a connection to a NATS server. It speaks just enough of the
+// NATS client protocol to publish messages and to receive those of a
+// subscription.
+type natsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// mu serializes writes, as replies to PINGs are sent while results are
+	// published.
+	mu sync.Mutex
+	// pongs receives the PONGs of the server once keepAlive reads the
+	// connection.
+	pongs chan struct{}
+}
+
+// natsMsg is a message received on a subscription.
+type natsMsg struct {
+	subject, reply string
+	data           []byte
+}
+
+// dialNATS connects to the server at rawURL, nats://host[:4222] or
+// tls://host[:4222], authenticating with the user name and password or the
+// token given in the URL.
+func dialNATS(rawURL string) (*natsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("unsupported scheme %q (nats or tls)", u.Scheme)
+	}
+	conn, err := net.DialTimeout("tcp", hostPort(u, "4222"), 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{conn: conn, r: bufio.NewReader(conn)}
+	if err := c.handshake(u); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake reads the INFO of the server, upgrades the connection to TLS if
+// either side requires it, and authenticates, waiting for the PONG to the
+// first PING to know that the server accepted the connection.
+func (c *natsConn) handshake(u *url.URL) error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	payload, ok := strings.CutPrefix(line, "INFO ")
+	if !ok {
+		return fmt.Errorf("unexpected greeting %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(payload), &info); err != nil {
+		return fmt.Errorf("invalid INFO: %v", err)
+	}
+	if info.TLSRequired || u.Scheme == "tls" {
+		conn := tls.Client(c.conn, &tls.Config{ServerName: u.Hostname()})
+		if err := conn.Handshake(); err != nil {
+			return err
+		}
+		c.conn, c.r = conn, bufio.NewReader(conn)
+	}
+	options := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     "lingua-cli",
+		"lang":     "go",
+		"version":  version,
+		"protocol": 1,
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"], options["pass"] = u.User.Username(), password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	if err := c.write("CONNECT " + string(data) + "\r\nPING\r\n"); err != nil {
+		return err
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+		}
+	}
+}
+
+// subscribe subscribes to subject, in the queue group queue if it is set,
+// so that the messages are shared among the members of the group.
+func (c *natsConn) subscribe(subject, queue string) error {
+	if queue != "" {
+		return c.write(fmt.Sprintf("SUB %s %s 1\r\n", subject, queue))
+	}
+	return c.write(fmt.Sprintf("SUB %s 1\r\n", subject))
+}
+
+// publish sends a message to subject.
+func (c *natsConn) publish(subject string, data []byte) error {
+	return c.write(fmt.Sprintf("PUB %s %d\r\n%s\r\n", subject, len(data), data))
+}
+
+// next returns the next message of the subscription, answering the PINGs
+// of the server while it waits.
+func (c *natsConn) next() (natsMsg, error) {
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return natsMsg{}, err
+		}
+		verb, args, _ := strings.Cut(line, " ")
+		switch verb {
+		case "PING":
+			if err := c.write("PONG\r\n"); err != nil {
+				return natsMsg{}, err
+			}
+		case "-ERR":
+			return natsMsg{}, errors.New(strings.Trim(args, "'"))
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(args)
+			if len(fields) != 3 && len(fields) != 4 {
+				return natsMsg{}, fmt.Errorf("malformed MSG %q", line)
+			}
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || size < 0 {
+				return natsMsg{}, fmt.Errorf("malformed MSG %q", line)
+			}
+			msg := natsMsg{subject: fields[0], data: make([]byte, size+2)}
+			if len(fields) == 4 {
+				msg.reply = fields[2]
+			}
+			if _, err := io.ReadFull(c.r, msg.data); err != nil {
+				return natsMsg{}, err
+			}
+			msg.data = msg.data[:size]
+			return msg, nil
+		}
+	}
+}
+
+func (c *natsConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (c *natsConn) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := io.WriteString(c.conn, s)
+	return err
+}
+
+// keepAlive reads the connection of a publisher, which nothing else reads,
+// answering the PINGs of the server so that it does not drop the connection
+// as stale, and reporting its errors on stderr.
+func (c *natsConn) keepAlive() {
+	c.pongs = make(chan struct{}, 1)
+	go func() {
+		for {
+			line, err := c.readLine()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					fmt.Fprintf(os.Stderr, "warning: -nats: connection lost: %v\n", err)
+				}
+				return
+			}
+			verb, args, _ := strings.Cut(line, " ")
+			switch verb {
+			case "PING":
+				c.write("PONG\r\n")
+			case "PONG":
+				select {
+				case c.pongs <- struct{}{}:
+				default:
+				}
+			case "-ERR":
+				fmt.Fprintf(os.Stderr, "warning: -nats: %s\n", strings.Trim(args, "'"))
+			}
+		}
+	}()
+}
+
+// interrupt makes a waiting next return.
+func (c *natsConn) interrupt() {
+	c.conn.SetReadDeadline(time.Now())
+}
+
+// close waits for the server to process the messages sent so far, as the
+// answer to a PING shows, and closes the connection.
+func (c *natsConn) close() {
+	if c.pongs != nil {
+		if c.write("PING\r\n") == nil {
+			select {
+			case <-c.pongs:
+			case <-time.After(5 * time.Second):
+			}
+		}
+		c.conn.Close()
+		return
+	}
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if c.write("PING\r\n") == nil {
+		for {
+			line, err := c.readLine()
+			if err != nil || line == "PONG" {
+				break
+			}
+		}
+	}
+	c.conn.Close()
+}
+
+// consume passes the messages of the subscriptions to work, like lines in
+// parallel line mode, and the results to emit together with their message,
+// until the process is interrupted.
+func (c *natsConn) consume(workers int, ordered bool, work func(lineResult) lineResult, emit func(natsMsg, lineResult)) error {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	var mu sync.Mutex
+	stopped := false
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupted:
+			mu.Lock()
+			stopped = true
+			mu.Unlock()
+			c.interrupt()
+		case <-done:
+		}
+	}()
+
+	// messages holds the messages in flight by number, without their data.
+	messages := make(map[int]natsMsg)
+	produce := func(send func(lineResult)) error {
+		for number := 1; ; number++ {
+			msg, err := c.next()
+			mu.Lock()
+			if err != nil {
+				defer mu.Unlock()
+				if stopped {
+					return nil
+				}
+				return err
+			}
+			messages[number] = natsMsg{subject: msg.subject, reply: msg.reply}
+			mu.Unlock()
+			send(lineResult{number: number, line: string(msg.data)})
+		}
+	}
+	return parallelMap(workers, ordered, produce, work, func(r lineResult) {
+		mu.Lock()
+		msg := messages[r.number]
+		delete(messages, r.number)
+		mu.Unlock()
+		emit(msg, r)
+	})
+}
+
+// natsSink publishes the results as json-v1 records on a NATS subject per
+// language.
+type natsSink struct {
+	conn *natsConn
+	// subject is the subject template, with {lang} replaced by the
+	// language.
+	subject   string
+	threshold float64
+	labels    labelMap
+}
+
+// add publishes the record of a result, reporting failures as warnings.
+func (s *natsSink) add(name string, number int, text string, results []langid.Confidence) {
+	if s == nil {
+		return
+	}
+	r := sinkRecord(name, number, text, results, s.threshold, s.labels)
+	payload, err := json.Marshal(r)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -nats-publish: %v\n", err)
+		return
+	}
+	if err := s.conn.publish(strings.ReplaceAll(s.subject, "{lang}", *r.Language), payload); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -nats-publish: %v\n", err)
+	}
+}
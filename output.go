@@ -0,0 +1,724 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// printer writes detection results to stdout in the configured format.
+type printer struct {
+	w *outputWriter
+	// flush flushes w after every result rather than only when it is full.
+	flush     bool
+	delimiter string
+	// threshold suppresses results scoring below it when hasThreshold is set.
+	threshold    float64
+	hasThreshold bool
+	// all prints the entire distribution rather than just the winning score.
+	all bool
+	// With all, top limits the distribution to the best languages (0 for
+	// all of them), hideBelow leaves out languages scoring lower, and
+	// renormalize rescales the remaining scores to sum to 1.
+	top         int
+	hideBelow   float64
+	renormalize bool
+	labels      labelMap
+	// file, if set, is printed as the first column of per-line results.
+	file string
+	// numberLines prefixes per-line results with their input line number,
+	// and offsets with the byte offset of the line.
+	numberLines bool
+	offsets     bool
+	// encoding, if set, is printed as a column after the score.
+	encoding string
+	// entropy adds the normalized entropy of the distribution as a column
+	// after the score.
+	entropy bool
+	// margin adds the difference between the scores of the two best
+	// languages as a column after the score.
+	margin bool
+	// mixedScript adds a column marking texts that mix scripts, such as
+	// Latin and Cyrillic, with mixed-script.
+	mixedScript bool
+	// columns, if set, replaces the default columns of every output line.
+	columns []string
+	// buckets holds the descending -buckets cutoffs that sort results into
+	// confidence buckets. If bucketWriters is set, the rows of each bucket
+	// go to its writer instead of w.
+	buckets       []float64
+	bucketWriters map[string]*outputWriter
+	// source is the text whose results are printed outside line and
+	// multi-language mode, for the columns derived from the text, if
+	// textColumns reports that any are selected.
+	source string
+	// json prints a json-v1 record per result instead of delimited columns,
+	// or with ecs an Elastic Common Schema document, and fastText labels as
+	// fastText prints them.
+	json     bool
+	ecs      bool
+	fastText bool
+	// avro, if set, writes the json-v1 records into an Avro container file
+	// instead, protobuf as length-delimited protobuf messages and cbor as
+	// CBOR maps.
+	avro     *avroWriter
+	protobuf bool
+	cbor     bool
+	// is, if set, prints yes or no instead of the language, for whether the
+	// confidence of that language reaches isThreshold, with that
+	// confidence. rejected counts the texts decided no.
+	is          string
+	isThreshold float64
+	rejected    int
+	// held, if set, holds back the results printed to w, which then writes
+	// into it, for -group-by-lang and -sort.
+	held *resultBuffer
+	// order is the order of -sort, which also applies to the distributions
+	// of -a.
+	order resultOrder
+}
+
+// outputWriter buffers the output and optionally gzip-compresses it.
+type outputWriter struct {
+	*bufio.Writer
+	gz *gzip.Writer
+	// file is the output file, or nil for stdout and stderr.
+	file *os.File
+}
+
+// Flush writes out the buffered output, including pending compressed data.
+func (w *outputWriter) Flush() error {
+	_, span := tracer.Start(traceCtx, "write output")
+	err := w.Writer.Flush()
+	if err == nil && w.gz != nil {
+		err = w.gz.Flush()
+	}
+	endSpan(span, err)
+	return err
+}
+
+// size returns the number of bytes flushed to the output file so far, or 0
+// if the output is not a file.
+func (w *outputWriter) size() int64 {
+	if w.file == nil {
+		return 0
+	}
+	n, err := w.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// openOutput returns the writer for results: the file at path, stdout if
+// path is empty or stderr if it is "stderr", gzip-compressed if compress is
+// set. An existing file is truncated to its first keep bytes, to which the
+// output is appended. Everything is flushed and closed when the process
+// exits.
+func openOutput(path string, compress bool, keep int64) (*outputWriter, error) {
+	var dest io.Writer = os.Stdout
+	var file *os.File
+	if path == "stderr" {
+		dest = os.Stderr
+	} else if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o666)
+		if err != nil {
+			return nil, err
+		}
+		if err := f.Truncate(keep); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, err
+		}
+		atExit(func() {
+			if err := f.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing %s: %v\n", path, err)
+			}
+		})
+		dest, file = f, f
+	}
+	w := &outputWriter{file: file}
+	if compress {
+		w.gz = gzip.NewWriter(dest)
+		gz := w.gz
+		atExit(func() {
+			if err := gz.Close(); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+			}
+		})
+		dest = w.gz
+	}
+	w.Writer = bufio.NewWriter(dest)
+	atExit(func() {
+		_, span := tracer.Start(traceCtx, "write output")
+		err := w.Writer.Flush()
+		endSpan(span, err)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing output: %v\n", err)
+		}
+	})
+	return w, nil
+}
+
+// scoreFormat controls how formatScore prints scores, set by -precision and
+// -percent.
+var scoreFormat = struct {
+	// precision is the number of decimals, or -1 for the default format.
+	precision int
+	// percent prints scores as percentages.
+	percent bool
+}{precision: -1}
+
+// formatScore formats a confidence score. By default it matches the Rust
+// lingua-cli output: exactly 1.0 is printed as "1", all other values use 16
+// decimals. With a precision set, every score has that many decimals. The
+// format never depends on the locale.
+func formatScore(score float64) string {
+	if scoreFormat.percent {
+		precision := scoreFormat.precision
+		if precision < 0 {
+			precision = 2
+		}
+		return strconv.FormatFloat(100*score, 'f', precision, 64) + "%"
+	}
+	if scoreFormat.precision >= 0 {
+		return strconv.FormatFloat(score, 'f', scoreFormat.precision, 64)
+	}
+	if score == 1.0 {
+		return "1"
+	}
+	return strconv.FormatFloat(score, 'f', 16, 64)
+}
+
+// columnNames lists the columns accepted by -columns.
+var columnNames = []string{"file", "line", "source", "offset", "lang", "name", "score", "script", "direction", "entropy", "margin", "mixed", "encoding", "bucket", "start", "end", "text"}
+
+// sourceLocation returns the file:line location of a line of a file, as
+// editors and compilers print them, the file alone outside line mode, or ""
+// for input that is not a file.
+func sourceLocation(file string, line int) string {
+	if file == "" || line == 0 {
+		return file
+	}
+	return file + ":" + strconv.Itoa(line)
+}
+
+// parseColumns parses a comma-separated -columns list.
+func parseColumns(list string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !slices.Contains(columnNames, name) {
+			return nil, fmt.Errorf("unknown column %q (expected %s)", name, strings.Join(columnNames, ", "))
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// row holds the fields of one line of output.
+type row struct {
+	file string
+	// line is the input line number, or 0 outside line mode.
+	line int
+	// offset is the byte offset of the line in the input.
+	offset int64
+	lang   string
+	score  string
+	// results is the distribution the entropy and margin columns are
+	// computed from.
+	results    []langid.Confidence
+	start, end int
+	text       string
+	// source is the text the result is for if text does not hold it.
+	source string
+}
+
+// field returns the value of a column of r.
+func (p *printer) field(r row, column string) string {
+	switch column {
+	case "file":
+		return r.file
+	case "line":
+		if r.line > 0 {
+			return strconv.Itoa(r.line)
+		}
+	case "source":
+		return sourceLocation(r.file, r.line)
+	case "offset":
+		if r.line > 0 {
+			return strconv.FormatInt(r.offset, 10)
+		}
+	case "lang":
+		return r.lang
+	case "name":
+		if r.lang != "" {
+			return languageName(r.lang)
+		}
+	case "score":
+		return r.score
+	case "script":
+		if r.source != "" {
+			return langid.DominantScript(r.source)
+		}
+		return langid.DominantScript(r.text)
+	case "direction":
+		if r.source != "" {
+			return langid.Direction(r.source)
+		}
+		return langid.Direction(r.text)
+	case "entropy":
+		if len(r.results) > 0 {
+			return formatScore(langid.Entropy(r.results))
+		}
+	case "margin":
+		if len(r.results) > 0 {
+			return formatScore(langid.Margin(r.results))
+		}
+	case "mixed":
+		text := r.text
+		if r.source != "" {
+			text = r.source
+		}
+		if langid.MixedScripts(text, langid.MixedScriptShare) != nil {
+			return "mixed-script"
+		}
+	case "encoding":
+		return p.encoding
+	case "bucket":
+		return p.bucket(r)
+	case "start":
+		return strconv.Itoa(r.start)
+	case "end":
+		return strconv.Itoa(r.end)
+	case "text":
+		return r.text
+	}
+	return ""
+}
+
+// textColumns reports whether -columns or -mixed-script selects columns
+// derived from the classified text, which then has to be kept until it is
+// printed.
+func (p *printer) textColumns() bool {
+	if p.mixedScript {
+		return true
+	}
+	for _, column := range p.columns {
+		switch column {
+		case "script", "direction", "mixed":
+			return true
+		}
+	}
+	return false
+}
+
+// printRow prints r with the columns selected by -columns, or else with
+// the given default columns.
+func (p *printer) printRow(r row, defaults ...string) {
+	columns := p.columns
+	if columns == nil {
+		columns = defaults
+	}
+	w := p.w
+	if p.bucketWriters != nil {
+		w = p.bucketWriters[p.bucket(r)]
+	}
+	for i, column := range columns {
+		if i > 0 {
+			w.WriteString(p.delimiter)
+		}
+		w.WriteString(p.field(r, column))
+	}
+	w.WriteByte('\n')
+}
+
+// bucketNames returns the names of the confidence buckets for n cutoffs,
+// from the most to the least confident.
+func bucketNames(n int) []string {
+	if n == 1 {
+		return []string{"high", "low"}
+	}
+	return []string{"high", "medium", "low"}
+}
+
+// parseBuckets parses the comma-separated -buckets cutoffs, one or two
+// descending scores.
+func parseBuckets(list string) ([]float64, error) {
+	var cutoffs []float64
+	for _, field := range strings.Split(list, ",") {
+		cutoff, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || cutoff < 0 || cutoff > 1 {
+			return nil, fmt.Errorf("invalid -buckets cutoff %q", field)
+		}
+		if len(cutoffs) > 0 && cutoff >= cutoffs[len(cutoffs)-1] {
+			return nil, fmt.Errorf("-buckets cutoffs must be descending")
+		}
+		cutoffs = append(cutoffs, cutoff)
+	}
+	if len(cutoffs) > 2 {
+		return nil, fmt.Errorf("-buckets takes one or two cutoffs")
+	}
+	return cutoffs, nil
+}
+
+// bucket returns the confidence bucket of a row by the best score of its
+// text. Texts without results fall into the lowest bucket.
+func (p *printer) bucket(r row) string {
+	score := 0.0
+	if len(r.results) > 0 {
+		score = r.results[0].Score
+	}
+	names := bucketNames(len(p.buckets))
+	for i, cutoff := range p.buckets {
+		if score >= cutoff {
+			return names[i]
+		}
+	}
+	return names[len(names)-1]
+}
+
+// resultColumns returns the default columns of a result: language and
+// score, followed by bucket, entropy, margin, mixed and encoding if enabled.
+func (p *printer) resultColumns(leading ...string) []string {
+	columns := append(leading, "lang", "score")
+	if p.buckets != nil {
+		columns = append(columns, "bucket")
+	}
+	if p.entropy {
+		columns = append(columns, "entropy")
+	}
+	if p.margin {
+		columns = append(columns, "margin")
+	}
+	if p.mixedScript {
+		columns = append(columns, "mixed")
+	}
+	if p.encoding != "" {
+		columns = append(columns, "encoding")
+	}
+	return columns
+}
+
+// lineColumns returns the default columns of per-line results: the
+// file:line source, line number and offset if file, numberLines respectively
+// offsets are set, followed by the result columns if withResult is set.
+func (p *printer) lineColumns(withResult bool) []string {
+	var columns []string
+	if p.file != "" {
+		columns = append(columns, "source")
+	}
+	if p.numberLines {
+		columns = append(columns, "line")
+	}
+	if p.offsets {
+		columns = append(columns, "offset")
+	}
+	if withResult {
+		columns = p.resultColumns(columns...)
+	}
+	return append(columns, "text")
+}
+
+// printConfidenceValues prints language detection results to stdout.
+// If all is false, only the top result is printed.
+// If a confidence threshold is set, results below it are suppressed (printing "unknown" instead).
+func (p *printer) printConfidenceValues(results []langid.Confidence) {
+	results = p.decide(results)
+	p.startResult(results, 0)
+	results = p.shown(p.labels.apply(results))
+	if p.json {
+		p.printRecord(p.record(results))
+		return
+	}
+	if p.fastText {
+		p.printFastText(results, "")
+		return
+	}
+	found := false
+	for _, result := range p.ordered(results) {
+		score := result.Score
+		if !p.hasThreshold || score >= p.threshold {
+			found = true
+			p.printRow(row{lang: result.Code, score: formatScore(score), results: results, source: p.source}, p.resultColumns()...)
+		}
+		if !p.all {
+			break
+		}
+	}
+	if !found {
+		p.printUnknown()
+	}
+	p.endResult()
+}
+
+// shown returns the part of a distribution printed with -a, following top,
+// hideBelow and renormalize.
+func (p *printer) shown(results []langid.Confidence) []langid.Confidence {
+	if !p.all || (p.top == 0 && p.hideBelow == 0 && !p.renormalize) {
+		return results
+	}
+	var kept []langid.Confidence
+	total := 0.0
+	for _, result := range results {
+		if (p.top > 0 && len(kept) == p.top) || result.Score < p.hideBelow {
+			break
+		}
+		kept = append(kept, result)
+		total += result.Score
+	}
+	if p.renormalize && total > 0 {
+		for i := range kept {
+			kept[i].Score /= total
+		}
+	}
+	return kept
+}
+
+// decide replaces a distribution by the decision of -is: yes or no, with
+// the confidence of the language asked about. Without results, it returns
+// none, to be printed as unknown.
+func (p *printer) decide(results []langid.Confidence) []langid.Confidence {
+	if p.is == "" || len(results) == 0 {
+		return results
+	}
+	score := 0.0
+	for _, result := range results {
+		if result.Code == p.is {
+			score = result.Score
+			break
+		}
+	}
+	if score > 0 && score >= p.isThreshold {
+		return []langid.Confidence{{Code: "yes", Score: score}}
+	}
+	p.rejected++
+	return []langid.Confidence{{Code: "no", Score: score}}
+}
+
+// unknown returns the label of texts that could not be classified: no with
+// -is, which counts them as rejected.
+func (p *printer) unknown() string {
+	if p.is != "" {
+		p.rejected++
+		return "no"
+	}
+	return p.labels.label(langid.Unknown)
+}
+
+// ordered returns the results printed with -a in the order of -sort.
+func (p *printer) ordered(results []langid.Confidence) []langid.Confidence {
+	if !p.all {
+		return results
+	}
+	return p.order.distribution(results)
+}
+
+// printNamed prints the results for a named text such as a file, with the
+// name as the first column.
+func (p *printer) printNamed(name string, results []langid.Confidence) {
+	results = p.decide(results)
+	p.startResult(results, 0)
+	results = p.shown(p.labels.apply(results))
+	if p.json {
+		r := p.record(results)
+		r.File = name
+		p.printRecord(r)
+		return
+	}
+	if p.fastText {
+		p.printFastText(results, name)
+		return
+	}
+	found := false
+	for _, result := range p.ordered(results) {
+		if !p.hasThreshold || result.Score >= p.threshold {
+			found = true
+			p.printRow(row{file: name, lang: result.Code, score: formatScore(result.Score), results: results, source: p.source}, p.resultColumns("file")...)
+		}
+		if !p.all {
+			break
+		}
+	}
+	if !found {
+		p.printUnknownNamed(name)
+	}
+	p.endResult()
+}
+
+// printUnknownNamed prints the result for a named text that could not be classified.
+func (p *printer) printUnknownNamed(name string) {
+	p.startResult(nil, 0)
+	if p.json {
+		r := p.record(nil)
+		r.File = name
+		p.printRecord(r)
+		return
+	}
+	if p.fastText {
+		p.printFastText(nil, name)
+		return
+	}
+	p.printRow(row{file: name, lang: p.unknown(), source: p.source}, p.resultColumns("file")...)
+	p.endResult()
+}
+
+// printUnknown prints the result for a text that could not be classified.
+func (p *printer) printUnknown() {
+	p.startResult(nil, 0)
+	if p.json {
+		p.printRecord(p.record(nil))
+		return
+	}
+	if p.fastText {
+		p.printFastText(nil, "")
+		return
+	}
+	p.printRow(row{lang: p.unknown(), source: p.source}, p.resultColumns()...)
+	p.endResult()
+}
+
+// printLineWithConfidenceValues prints per-line detection results including the original line.
+func (p *printer) printLineWithConfidenceValues(number int, offset int64, line string, results []langid.Confidence) {
+	results = p.decide(results)
+	p.startResult(results, number)
+	results = p.shown(p.labels.apply(results))
+	if p.json {
+		r := p.record(results)
+		r.File, r.Line, r.Text = p.file, number, &line
+		p.printRecord(r)
+		return
+	}
+	if p.fastText {
+		p.printFastText(results, line)
+		return
+	}
+	printed := false
+	for _, result := range p.ordered(results) {
+		score := result.Score
+		if !p.hasThreshold || score >= p.threshold {
+			p.printRow(row{file: p.file, line: number, offset: offset, lang: result.Code, score: formatScore(score), results: results, text: line},
+				p.lineColumns(true)...)
+			printed = true
+		} else {
+			// Printed inline rather than by printUnknownLine, which would
+			// start a result of its own.
+			p.printRow(row{file: p.file, line: number, offset: offset, lang: p.unknown(), text: line}, p.lineColumns(true)...)
+			printed = true
+		}
+		if !p.all {
+			break
+		}
+	}
+	if !printed {
+		p.printRow(row{file: p.file, line: number, offset: offset, lang: p.unknown(), text: line}, p.lineColumns(true)...)
+	}
+	p.endResult()
+}
+
+// printUnknownLine prints the per-line result for a line that could not be classified.
+func (p *printer) printUnknownLine(number int, offset int64, line string) {
+	p.startResult(nil, number)
+	if p.json {
+		r := p.record(nil)
+		r.File, r.Line, r.Text = p.file, number, &line
+		p.printRecord(r)
+		return
+	}
+	if p.fastText {
+		p.printFastText(nil, line)
+		return
+	}
+	p.printRow(row{file: p.file, line: number, offset: offset, lang: p.unknown(), text: line}, p.lineColumns(true)...)
+	p.endResult()
+}
+
+// printRawLine prints an input line unchanged, without a result.
+func (p *printer) printRawLine(number int, offset int64, line string) {
+	p.startResult(nil, number)
+	if p.json {
+		p.printRecord(jsonRecord{File: p.file, Line: number, Text: &line})
+		return
+	}
+	if p.fastText {
+		p.w.WriteString(line)
+		p.w.WriteByte('\n')
+		p.endResult()
+		return
+	}
+	p.printRow(row{file: p.file, line: number, offset: offset, text: line}, p.lineColumns(false)...)
+	p.endResult()
+}
+
+// withoutThreshold returns a copy of the printer that prints all results.
+func (p *printer) withoutThreshold() *printer {
+	c := *p
+	c.hasThreshold = false
+	return &c
+}
+
+// minScore returns the confidence threshold, or 0 if none is set.
+func (p *printer) minScore() float64 {
+	if !p.hasThreshold {
+		return 0
+	}
+	return p.threshold
+}
+
+// printWithOffset prints multi-language detection results with byte offsets.
+// base is the offset of text within the input.
+func (p *printer) printWithOffset(spans []langid.Span, text string, base int) {
+	if p.json {
+		r := jsonRecord{Spans: []jsonSpan{}}
+		for _, span := range spans {
+			r.Spans = append(r.Spans, jsonSpan{base + span.Start, base + span.End, p.labels.label(span.Code), text[span.Start:span.End]})
+		}
+		p.printRecord(r)
+		return
+	}
+	for _, span := range spans {
+		p.printRow(row{start: base + span.Start, end: base + span.End, lang: p.labels.label(span.Code), text: text[span.Start:span.End]},
+			"start", "end", "lang", "text")
+	}
+	p.endResult()
+}
+
+// startResult records the best language and score of results, before they
+// are relabeled and filtered, and the line they are for, as those of the
+// result printed next, if results are held back.
+func (p *printer) startResult(results []langid.Confidence, line int) {
+	if p.held == nil {
+		return
+	}
+	results = p.labels.apply(results)
+	if len(results) > 0 && (!p.hasThreshold || results[0].Score >= p.threshold) {
+		p.held.start(results[0].Code, results[0].Score, line)
+		return
+	}
+	p.held.start("", 0, line)
+}
+
+// endResult flushes the output after a complete result if requested, or
+// holds the result back for -group-by-lang and -sort.
+func (p *printer) endResult() {
+	if p.held != nil {
+		p.held.end(p.w)
+		return
+	}
+	if p.flush {
+		p.w.Flush()
+		for _, w := range p.bucketWriters {
+			w.Flush()
+		}
+	}
+}
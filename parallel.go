@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// errFiltered is returned by a line classifier for lines it rejected without
+// running the detector, such as lines shorter than -M.
+var errFiltered = errors.New("line filtered")
+
+// lineResult is the classification of one input line.
+type lineResult struct {
+	number int
+	// offset is the byte offset of the line in the input.
+	offset int64
+	line   string
+	// raw is the line as read, including its line terminator.
+	raw string
+	// size is the length of the line in the input, which is longer than
+	// raw if the line was cut at the record limit.
+	size int64
+	// skipped is set for lines left out by keep or the record limit in
+	// classifyLines, which are not classified.
+	skipped bool
+	results []langid.Confidence
+	err     error
+}
+
+// setThreads applies -threads, limiting the Go runtime to n threads running
+// Go code at once if n is positive, and returns the number of workers of
+// -j 0: one per thread the runtime may use. That is GOMAXPROCS, which unlike
+// runtime.NumCPU can be lowered to the cores a container is granted.
+func setThreads(n int) int {
+	if n > 0 {
+		runtime.GOMAXPROCS(n)
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// parallelMap runs work on every job produced by produce, using workers
+// goroutines, and passes the results to emit on the calling goroutine. With
+// ordered set, results are emitted in the order of their jobs, holding back
+// those that finish early; otherwise they are emitted as soon as they are
+// ready. It returns the error returned by produce.
+func parallelMap[J, R any](workers int, ordered bool, produce func(send func(J)) error, work func(J) R, emit func(R)) error {
+	if workers <= 1 {
+		return produce(func(job J) { emit(work(job)) })
+	}
+
+	type numbered[T any] struct {
+		n     int
+		value T
+	}
+	jobs := make(chan numbered[J], workers*4)
+	done := make(chan numbered[R], workers*4)
+	var produceErr error
+	go func() {
+		defer close(jobs)
+		n := 0
+		produceErr = produce(func(job J) {
+			jobs <- numbered[J]{n, job}
+			n++
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				done <- numbered[R]{job.n, work(job.value)}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	pending := make(map[int]R)
+	next := 0
+	for result := range done {
+		if !ordered {
+			emit(result.value)
+			continue
+		}
+		pending[result.n] = result.value
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			emit(ready)
+			next++
+		}
+	}
+	return produceErr
+}
+
+// classifyLines reads lines from r, classifies them with workers goroutines
+// running classify, and passes the results to emit on the calling goroutine,
+// in input order if ordered is set. Lines are numbered from firstLine, and
+// their offsets counted from firstOffset. Lines for which keep, if not nil,
+// is false, and lines dropped by limit, are passed to emit unclassified,
+// marked as skipped. It returns the first read error, or the error of limit,
+// and stops reading, without an error, when the run is interrupted.
+func classifyLines(r io.Reader, limit recordLimit, firstLine int, firstOffset int64, workers int, ordered bool, keep func(lineResult) bool, classify func(line string) ([]langid.Confidence, error), emit func(lineResult)) error {
+	produce := func(send func(lineResult)) error {
+		reader := newLineReader(r, limit.maxBytes)
+		number, offset := firstLine-1, firstOffset
+		for reader.Scan() && !stopping() {
+			number++
+			raw := reader.Text()
+			line, ok, err := limit.check(trimLineEnd(raw), reader.Size(), fmt.Sprintf("line %d", number))
+			if err != nil {
+				return err
+			}
+			send(lineResult{number: number, offset: offset, line: line, raw: raw, size: reader.Size(), skipped: !ok})
+			offset += reader.Size()
+		}
+		if stopping() {
+			return nil
+		}
+		return reader.Err()
+	}
+	work := func(job lineResult) lineResult {
+		if job.skipped || keep != nil && !keep(job) {
+			job.skipped = true
+			return job
+		}
+		job.results, job.err = classify(job.line)
+		return job
+	}
+	return parallelMap(workers, ordered, produce, work, emit)
+}
+
+// newLineScanner returns a scanner splitting r into lines that keep their
+// line terminators, so they can be passed through unchanged.
+func newLineScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			return i + 1, data[:i+1], nil
+		}
+		if atEOF && len(data) > 0 {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	})
+	return scanner
+}
+
+// trimLineEnd removes the line terminator ("\n" or "\r\n") from a line.
+func trimLineEnd(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
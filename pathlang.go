@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pathLangModes lists the values accepted by -path-lang.
+var pathLangModes = []string{"prior", "check"}
+
+// javaLocale matches the locale suffix of Java resource bundles and similar
+// files, as in messages_ja or messages_pt_BR.
+var javaLocale = regexp.MustCompile(`_([a-z]{2,3}(?:_[A-Za-z]{2,4})?)$`)
+
+// pathLanguages finds the language a file path names, for -path-lang.
+type pathLanguages struct {
+	// rules replace the built-in conventions if set. The first rule that
+	// matches a path names its language with its "lang" group, or else its
+	// first group.
+	rules []*regexp.Regexp
+}
+
+// loadPathRules reads the rules of -path-lang-rules, one regular expression
+// per line; blank lines and lines starting with # are ignored.
+func loadPathRules(path string) ([]*regexp.Regexp, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []*regexp.Regexp
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+		}
+		if rule.NumSubexp() == 0 {
+			return nil, fmt.Errorf("%s:%d: the rule has no group capturing the language", path, number)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%s: no rules", path)
+	}
+	return rules, nil
+}
+
+// language returns the code of the language path names, comparable to
+// detected ones, or "" if it names none. Without rules, it recognizes the
+// conventions of localization files: a locale before the extension
+// (foo.de.md), a locale directory among the two closest ones (locales/fr/,
+// values-de/, de.lproj/) and the suffix of Java resource bundles
+// (messages_ja.properties).
+func (p pathLanguages) language(path string) string {
+	slashed := filepath.ToSlash(path)
+	if p.rules != nil {
+		for _, rule := range p.rules {
+			match := rule.FindStringSubmatch(slashed)
+			if match == nil {
+				continue
+			}
+			group := 1
+			if i := rule.SubexpIndex("lang"); i > 0 {
+				group = i
+			}
+			return declaredLanguage(match[group])
+		}
+		return ""
+	}
+	if locale := resourceLocale(path); locale != "" {
+		return declaredLanguage(locale)
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if match := javaLocale.FindStringSubmatch(base); match != nil {
+		return declaredLanguage(match[1])
+	}
+	return ""
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// poEntry is a message of a gettext catalog.
+type poEntry struct {
+	// line is the number of the line of the msgid.
+	line int
+	id   string
+	// plural is the msgid_plural, strs the msgstr, or the msgstr[N] of
+	// plural messages.
+	plural string
+	strs   []string
+	fuzzy  bool
+}
+
+// readPO reads the messages of a .po file, without obsolete ones. The
+// header, the message with an empty msgid, is the first if present.
+func readPO(path string) ([]poEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []poEntry
+	var entry poEntry
+	// target is the string continuation lines are appended to.
+	var target *string
+	started := false
+	flush := func() {
+		if started {
+			entries = append(entries, entry)
+		}
+		entry, target, started = poEntry{}, nil, false
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			flush()
+			continue
+		case strings.HasPrefix(line, "#"):
+			if len(entry.strs) > 0 {
+				flush()
+			}
+			if strings.HasPrefix(line, "#,") && strings.Contains(line, "fuzzy") {
+				entry.fuzzy = true
+			}
+			continue
+		case strings.HasPrefix(line, `"`):
+			value, err := strconv.Unquote(line)
+			if err != nil || target == nil {
+				return nil, fmt.Errorf("%s:%d: invalid string %s", path, number, line)
+			}
+			*target += value
+			continue
+		}
+		keyword, raw, _ := strings.Cut(line, " ")
+		value, err := strconv.Unquote(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid string %s", path, number, raw)
+		}
+		switch {
+		case keyword == "msgctxt":
+			if len(entry.strs) > 0 {
+				flush()
+			}
+			started, target = true, new(string)
+			*target = value
+		case keyword == "msgid":
+			if len(entry.strs) > 0 {
+				flush()
+			}
+			started, entry.line, entry.id = true, number, value
+			target = &entry.id
+		case keyword == "msgid_plural":
+			entry.plural = value
+			target = &entry.plural
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			entry.strs = append(entry.strs, value)
+			target = &entry.strs[len(entry.strs)-1]
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown keyword %q", path, number, keyword)
+		}
+	}
+	flush()
+	return entries, scanner.Err()
+}
+
+// poLanguage returns the language of a catalog: the Language field of its
+// header, or else its file name ("de.po") or the directory above
+// LC_MESSAGES ("de/LC_MESSAGES/app.po").
+func poLanguage(path string, entries []poEntry) string {
+	if len(entries) > 0 && entries[0].id == "" && len(entries[0].strs) > 0 {
+		for _, line := range strings.Split(entries[0].strs[0], "\n") {
+			if value, ok := strings.CutPrefix(line, "Language:"); ok && strings.TrimSpace(value) != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if dir := filepath.Dir(path); filepath.Base(dir) == "LC_MESSAGES" {
+		name = filepath.Base(filepath.Dir(dir))
+	}
+	if len(name) <= 6 && declaredLanguage(name) != "" {
+		return name
+	}
+	return ""
+}
+
+// poPlaceholder matches the parts of messages that are no text: printf,
+// Python and brace placeholders, markup, and the accelerator markers of menu
+// entries.
+var poPlaceholder = regexp.MustCompile(`%(\([^)]*\))?[-+ #0-9.*]*[a-zA-Z%]|\{[^{}]*\}|<[^>]*>|&[a-z]+;|[_&]\b`)
+
+// poText returns the text of a message, without placeholders and markup.
+func poText(s string) string {
+	return strings.Join(strings.Fields(poPlaceholder.ReplaceAllString(s, " ")), " ")
+}
+
+// runPOLang implements the polang command. It checks that the translations
+// of gettext catalogs are in the catalog's language and the messages in the
+// source language, and reports translations that are copies of their
+// message.
+func runPOLang(args []string) int {
+	fs := flag.NewFlagSet("polang", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0.5,
+		"Confidence threshold, texts scoring below it are reported as 'unknown' rather than mismatches (0.0-1.0)")
+	lang := fs.String("lang", "",
+		"Language of the translations (default: the Language header of each catalog, or its file name)")
+	sourceLang := fs.String("source-lang", "en",
+		"Language of the messages (msgid); empty to not check them")
+	minLength := fs.Int("min-length", 20,
+		"Minimum length in characters of a text, without placeholders, to check it")
+	fuzzy := fs.Bool("fuzzy", false,
+		"Check fuzzy translations too")
+	all := fs.Bool("all", false,
+		"Report every check, not only mismatches and copies")
+	junit := fs.String("junit", "",
+		"Write a JUnit XML report to this file, with a test case per check, for CI systems")
+	sarif := fs.String("sarif", "",
+		"Write the findings as a SARIF 2.1.0 log to this file, for code scanning tools")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Check the languages of the messages and translations of gettext catalogs.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli polang [OPTIONS] PATH...\n\n")
+		fmt.Fprintf(os.Stderr, "Directories are searched recursively for .po and .pot files. Prints status\n")
+		fmt.Fprintf(os.Stderr, "(mismatch, copied, and with -all ok, unknown and short), file:line, field,\n")
+		fmt.Fprintf(os.Stderr, "expected language, detected language, score and text per check, then a summary\n")
+		fmt.Fprintf(os.Stderr, "on stderr. Exits with status 1 if any check fails.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	for _, option := range []struct{ name, value string }{{"-lang", *lang}, {"-source-lang", *sourceLang}} {
+		if option.value != "" && declaredLanguage(option.value) == "" {
+			fmt.Fprintf(os.Stderr, "error: invalid %s %q\n", option.name, option.value)
+			return 2
+		}
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+	checker := &langChecker{detector: detector, threshold: *threshold}
+	junitReport := newJUnitReport(*junit, "mismatch", "copied")
+	findings := newSARIFReport(*sarif)
+
+	checks := 0
+	statuses := make(map[string]int)
+	out := bufio.NewWriter(os.Stdout)
+	report := func(file string, check langCheck, text string) {
+		checks++
+		statuses[check.status]++
+		junitReport.add(file, check)
+		findings.add(file, check)
+		if !*all && check.status != "mismatch" && check.status != "copied" {
+			return
+		}
+		detected, score := "-", "-"
+		if len(check.results) > 0 && check.results[0].Score > 0 {
+			detected, score = check.results[0].Code, formatScore(check.results[0].Score)
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", check.status, check.page, check.element, check.declared, detected, score, excerpt(text, 60))
+	}
+	// checkText checks the language of a message or translation.
+	checkText := func(file string, line int, field, tag, s string) {
+		where := fmt.Sprintf("%s:%d", file, line)
+		text := poText(s)
+		if utf8.RuneCountInString(text) < *minLength {
+			report(file, langCheck{status: "short", page: where, element: field, declared: tag, line: line}, s)
+			return
+		}
+		check := checker.check(where, field, tag, text)
+		check.line = line
+		report(file, check, s)
+	}
+
+	err = walkFiles(fs.Args(), func(path string) {
+		if ext := filepath.Ext(path); ext != ".po" && ext != ".pot" {
+			return
+		}
+		entries, err := readPO(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			return
+		}
+		target := *lang
+		if target == "" && filepath.Ext(path) == ".po" {
+			if target = poLanguage(path, entries); target == "" {
+				fmt.Fprintf(os.Stderr, "warning: %s: no Language header, translations not checked (see -lang)\n", path)
+			}
+		}
+		for _, entry := range entries {
+			if entry.id == "" {
+				continue
+			}
+			where := fmt.Sprintf("%s:%d", path, entry.line)
+			if *sourceLang != "" {
+				checkText(path, entry.line, "msgid", *sourceLang, entry.id)
+				if entry.plural != "" {
+					checkText(path, entry.line, "msgid_plural", *sourceLang, entry.plural)
+				}
+			}
+			if target == "" || (entry.fuzzy && !*fuzzy) {
+				continue
+			}
+			for i, str := range entry.strs {
+				if str == "" {
+					continue
+				}
+				field := "msgstr"
+				if entry.plural != "" {
+					field = fmt.Sprintf("msgstr[%d]", i)
+				}
+				copied := str == entry.id || str == entry.plural
+				if copied && declaredLanguage(target) != declaredLanguage(*sourceLang) && utf8.RuneCountInString(poText(str)) >= *minLength {
+					report(path, langCheck{status: "copied", page: where, element: field, declared: target, line: entry.line}, str)
+					continue
+				}
+				checkText(path, entry.line, field, target, str)
+			}
+		}
+	})
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	if err := junitReport.write(*junit, "polang"); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := findings.write(*sarif); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "\n%d checks\n", checks)
+	fmt.Fprintf(os.Stderr, "status: %s\n", formatCounts(statuses, checks))
+	if statuses["mismatch"] > 0 || statuses["copied"] > 0 {
+		return 1
+	}
+	return 0
+}
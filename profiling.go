@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// cleanups run before the process exits, also when it exits early through
+// exit. They are run in reverse order of registration.
+var cleanups []func()
+
+func atExit(f func()) {
+	cleanups = append(cleanups, f)
+}
+
+func runCleanups() {
+	for len(cleanups) > 0 {
+		f := cleanups[len(cleanups)-1]
+		cleanups = cleanups[:len(cleanups)-1]
+		f()
+	}
+}
+
+// exit runs the registered cleanups and terminates the process.
+func exit(code int) {
+	runCleanups()
+	os.Exit(code)
+}
+
+// startProfiling starts the CPU profile and execution trace requested on the
+// command line and arranges for them, and the heap profile, to be written
+// when the process exits. Empty paths disable the respective output.
+func startProfiling(cpuProfile, memProfile, traceFile string) error {
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		atExit(func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return err
+		}
+		atExit(func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+	if memProfile != "" {
+		// Create the file up front so a bad path fails before the run.
+		f, err := os.Create(memProfile)
+		if err != nil {
+			return err
+		}
+		atExit(func() {
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				fmt.Fprintf(os.Stderr, "error writing %s: %v\n", memProfile, err)
+			}
+			f.Close()
+		})
+	}
+	return nil
+}
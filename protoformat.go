@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protobuf wire types.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// printProtobuf prints r as a Result message of schema/result-v1.proto,
+// preceded by its length as a varint, for -format protobuf.
+func (p *printer) printProtobuf(r jsonRecord) {
+	message := appendProtoResult(nil, r)
+	p.w.Write(binary.AppendUvarint(nil, uint64(len(message))))
+	p.w.Write(message)
+	p.endResult()
+}
+
+// appendProtoResult appends r encoded as a Result message. Absent fields of
+// the json-v1 record are not set.
+func appendProtoResult(buf []byte, r jsonRecord) []byte {
+	if r.File != "" {
+		buf = appendProtoString(buf, 1, r.File)
+	}
+	if r.Line > 0 {
+		buf = appendProtoVarint(buf, 2, uint64(r.Line))
+	}
+	if r.Source != "" {
+		buf = appendProtoString(buf, 3, r.Source)
+	}
+	if r.Language != nil {
+		buf = appendProtoString(buf, 4, *r.Language)
+	}
+	if r.Score != nil {
+		buf = appendProtoDouble(buf, 5, *r.Score)
+	}
+	if r.Entropy != nil {
+		buf = appendProtoDouble(buf, 6, *r.Entropy)
+	}
+	if r.Margin != nil {
+		buf = appendProtoDouble(buf, 7, *r.Margin)
+	}
+	for _, script := range r.MixedScript {
+		buf = appendProtoString(buf, 8, script)
+	}
+	if r.Encoding != "" {
+		buf = appendProtoString(buf, 9, r.Encoding)
+	}
+	for _, c := range r.Confidences {
+		var message []byte
+		message = appendProtoString(message, 1, c.Language)
+		message = appendProtoDouble(message, 2, c.Score)
+		buf = appendProtoBytes(buf, 10, message)
+	}
+	for _, s := range r.Spans {
+		var message []byte
+		message = appendProtoVarint(message, 1, uint64(s.Start))
+		message = appendProtoVarint(message, 2, uint64(s.End))
+		message = appendProtoString(message, 3, s.Language)
+		message = appendProtoString(message, 4, s.Text)
+		buf = appendProtoBytes(buf, 11, message)
+	}
+	if r.Text != nil {
+		buf = appendProtoString(buf, 12, *r.Text)
+	}
+	return buf
+}
+
+func appendProtoTag(buf []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(field<<3|wireType))
+}
+
+func appendProtoVarint(buf []byte, field int, n uint64) []byte {
+	return binary.AppendUvarint(appendProtoTag(buf, field, protoVarint), n)
+}
+
+func appendProtoDouble(buf []byte, field int, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(appendProtoTag(buf, field, protoFixed64), math.Float64bits(f))
+}
+
+func appendProtoBytes(buf []byte, field int, b []byte) []byte {
+	buf = binary.AppendUvarint(appendProtoTag(buf, field, protoBytes), uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendProtoString(buf []byte, field int, s string) []byte {
+	buf = binary.AppendUvarint(appendProtoTag(buf, field, protoBytes), uint64(len(s)))
+	return append(buf, s...)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestProtoFields(t *testing.T) {
+	tests := []struct {
+		encoded, want []byte
+	}{
+		{appendProtoVarint(nil, 1, 150), []byte{0x08, 0x96, 0x01}},
+		{appendProtoString(nil, 2, "testing"), []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}},
+		{appendProtoBytes(nil, 3, []byte{0x08, 0x96, 0x01}), []byte{0x1a, 0x03, 0x08, 0x96, 0x01}},
+		{appendProtoDouble(nil, 5, 0.5), []byte{0x29, 0, 0, 0, 0, 0, 0, 0xe0, 0x3f}},
+		{appendProtoString(nil, 16, ""), []byte{0x82, 0x01, 0x00}},
+	}
+	for _, test := range tests {
+		if !bytes.Equal(test.encoded, test.want) {
+			t.Errorf("encoded % x, want % x", test.encoded, test.want)
+		}
+	}
+}
+
+func TestProtoResult(t *testing.T) {
+	for i, r := range avroTestRecords() {
+		got, err := decodeProtoResult(appendProtoResult(nil, r))
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		// Empty repeated fields are absent from the message.
+		if len(r.MixedScript) == 0 {
+			r.MixedScript = nil
+		}
+		if len(r.Confidences) == 0 {
+			r.Confidences = nil
+		}
+		if len(r.Spans) == 0 {
+			r.Spans = nil
+		}
+		if !reflect.DeepEqual(got, r) {
+			t.Errorf("record %d decoded as %+v, want %+v", i, got, r)
+		}
+	}
+}
+
+// protoField is a field of a protobuf message in the wire format.
+type protoField struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeProtoFields splits a message into its fields, which must be of the
+// wire types appendProtoResult writes.
+func decodeProtoFields(data []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("malformed tag")
+		}
+		data = data[n:]
+		field := protoField{number: int(tag >> 3)}
+		switch tag & 7 {
+		case protoVarint:
+			if field.varint, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("field %d: malformed varint", field.number)
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return nil, fmt.Errorf("field %d: truncated", field.number)
+			}
+			field.varint, data = binary.LittleEndian.Uint64(data), data[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, fmt.Errorf("field %d: truncated", field.number)
+			}
+			field.bytes, data = data[n:n+int(size)], data[n+int(size):]
+		default:
+			return nil, fmt.Errorf("field %d: wire type %d", field.number, tag&7)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// decodeProtoResult decodes a Result message of schema/result-v1.proto into
+// a json-v1 record.
+func decodeProtoResult(data []byte) (jsonRecord, error) {
+	fields, err := decodeProtoFields(data)
+	if err != nil {
+		return jsonRecord{}, err
+	}
+	var r jsonRecord
+	double := func(f protoField) *float64 {
+		v := math.Float64frombits(f.varint)
+		return &v
+	}
+	for _, f := range fields {
+		s := string(f.bytes)
+		switch f.number {
+		case 1:
+			r.File = s
+		case 2:
+			r.Line = int(f.varint)
+		case 3:
+			r.Source = s
+		case 4:
+			r.Language = &s
+		case 5:
+			r.Score = double(f)
+		case 6:
+			r.Entropy = double(f)
+		case 7:
+			r.Margin = double(f)
+		case 8:
+			r.MixedScript = append(r.MixedScript, s)
+		case 9:
+			r.Encoding = s
+		case 10:
+			confidence, err := decodeProtoFields(f.bytes)
+			if err != nil || len(confidence) != 2 || confidence[0].number != 1 || confidence[1].number != 2 {
+				return r, fmt.Errorf("malformed Confidence % x", f.bytes)
+			}
+			r.Confidences = append(r.Confidences, jsonConfidence{string(confidence[0].bytes), *double(confidence[1])})
+		case 11:
+			span, err := decodeProtoFields(f.bytes)
+			if err != nil || len(span) != 4 {
+				return r, fmt.Errorf("malformed Span % x", f.bytes)
+			}
+			r.Spans = append(r.Spans, jsonSpan{int(span[0].varint), int(span[1].varint), string(span[2].bytes), string(span[3].bytes)})
+		case 12:
+			r.Text = &s
+		default:
+			return r, fmt.Errorf("unknown field %d", f.number)
+		}
+	}
+	return r, nil
+}
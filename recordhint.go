@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// hintModes are the values of -hint-mode.
+var hintModes = []string{"prior", "restrict"}
+
+// recordHint applies the languages a record of -jsonl or -csv input
+// declares in its -hint-field, such as the UI locale of the user who wrote
+// it: as a prior, multiplying their scores by 1 plus weight to break near
+// ties, or as the only languages kept of those the detector scored. The
+// detector is built with decideLater, so that the hint applies before -d
+// and -max-entropy turn near-ties into unknown.
+type recordHint struct {
+	restrict bool
+	weight   float64
+	// decide applies -d and -max-entropy.
+	decide func([]langid.Confidence) []langid.Confidence
+}
+
+func newRecordHint(mode string, weight float64, decide func([]langid.Confidence) []langid.Confidence) (*recordHint, error) {
+	if !slices.Contains(hintModes, mode) {
+		return nil, fmt.Errorf("invalid -hint-mode value %q (expected %s)", mode, strings.Join(hintModes, ", "))
+	}
+	if weight < 0 {
+		return nil, fmt.Errorf("-hint-weight must not be negative")
+	}
+	return &recordHint{restrict: mode == "restrict", weight: weight, decide: decide}, nil
+}
+
+// apply returns results weighed or restricted by the languages of value, a
+// comma-separated list of language codes, BCP 47 tags or POSIX locales, and
+// then decided on. Results are returned unchanged if h is nil.
+func (h *recordHint) apply(results []langid.Confidence, value string) []langid.Confidence {
+	if h == nil {
+		return results
+	}
+	return h.decide(h.weigh(results, value))
+}
+
+// weigh returns results weighed or restricted by the languages of value,
+// unchanged if value declares no language.
+func (h *recordHint) weigh(results []langid.Confidence, value string) []langid.Confidence {
+	if len(results) == 0 {
+		return results
+	}
+	var codes []string
+	for _, tag := range splitList(value) {
+		if code := declaredLanguage(tag); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return results
+	}
+	if h.restrict {
+		return restrictResults(results, codes)
+	}
+	weights := make(map[string]float64, len(codes))
+	for _, code := range codes {
+		weights[code] = 1 + h.weight
+	}
+	return langid.ApplyPrior(results, weights)
+}
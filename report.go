@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+const (
+	// reportSamples is the number of example texts the report shows per
+	// language.
+	reportSamples = 5
+	// reportFlagged is the number of low-confidence texts the report lists.
+	reportFlagged = 100
+	// reportLowConfidence flags texts below this score in reports of runs
+	// without -c.
+	reportLowConfidence = 0.5
+	// reportTextLength is the number of characters of a text the report
+	// shows.
+	reportTextLength = 200
+)
+
+// sampler draws a uniform random sample of up to n items per language from
+// a stream by reservoir sampling.
+type sampler struct {
+	n     int
+	rng   *rand.Rand
+	seen  map[string]int
+	items map[string][]string
+}
+
+func newSampler(n int, seed int64) *sampler {
+	return &sampler{n: n, rng: rand.New(rand.NewSource(seed)), seen: make(map[string]int), items: make(map[string][]string)}
+}
+
+// add offers an item of the given language to the sample.
+func (s *sampler) add(lang, item string) {
+	s.seen[lang]++
+	if len(s.items[lang]) < s.n {
+		s.items[lang] = append(s.items[lang], item)
+	} else if i := s.rng.Intn(s.seen[lang]); i < s.n {
+		s.items[lang][i] = item
+	}
+}
+
+// corpusReport collects the results of a run for the -report HTML report
+// and the -samples listing.
+type corpusReport struct {
+	threshold float64
+	// minSampleScore keeps texts scoring below it out of the samples.
+	minSampleScore float64
+	started        time.Time
+	total          int
+	unknown        int
+	counts         map[string]int
+	scores         map[string]float64
+	// histogram counts the best scores in tenths.
+	histogram [10]int
+	samples   *sampler
+	flagged   []reportItem
+	// lowCount is the number of texts below the threshold.
+	lowCount int
+}
+
+type reportItem struct {
+	Where string
+	Lang  string
+	Score string
+	Text  string
+}
+
+func newCorpusReport(threshold float64) *corpusReport {
+	return &corpusReport{
+		threshold: threshold,
+		started:   time.Now(),
+		counts:    make(map[string]int),
+		scores:    make(map[string]float64),
+		samples:   newSampler(reportSamples, 1),
+	}
+}
+
+// add records the result of a text: a line of the input if number is set,
+// a file if name is set, or else a text. Texts that could not be classified
+// have no results; text is empty if it is not at hand.
+func (r *corpusReport) add(name string, number int, text string, results []langid.Confidence) {
+	if r == nil {
+		return
+	}
+	r.total++
+	where := name
+	if number > 0 {
+		where = fmt.Sprintf("line %d", number)
+		if name != "" {
+			where = fmt.Sprintf("%s:%d", name, number)
+		}
+	}
+	if len(results) == 0 || results[0].Score <= 0 {
+		r.unknown++
+		return
+	}
+	best := results[0]
+	r.counts[best.Code]++
+	r.scores[best.Code] += best.Score
+	r.histogram[min(int(best.Score*10), 9)]++
+	sample := truncateRunes(text, reportTextLength)
+	if sample == "" {
+		sample = where
+	}
+	if best.Score >= r.minSampleScore {
+		r.samples.add(best.Code, sample)
+	}
+	if best.Score < r.threshold {
+		r.lowCount++
+		if len(r.flagged) < reportFlagged {
+			r.flagged = append(r.flagged, reportItem{Where: where, Lang: best.Code, Score: fmt.Sprintf("%.2f", best.Score), Text: truncateRunes(text, reportTextLength)})
+		}
+	}
+}
+
+// languages returns the languages found, the most frequent first.
+func (r *corpusReport) languages() []string {
+	codes := make([]string, 0, len(r.counts))
+	for code := range r.counts {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if r.counts[codes[i]] != r.counts[codes[j]] {
+			return r.counts[codes[i]] > r.counts[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	return codes
+}
+
+// printSamples prints the sampled texts of every language, the most frequent
+// language first, one per line after their language.
+func (r *corpusReport) printSamples(p *printer) {
+	for _, code := range r.languages() {
+		for _, text := range r.samples.items[code] {
+			p.printRow(row{lang: p.labels.label(code), text: text}, "lang", "text")
+		}
+	}
+}
+
+// reportLanguage is the row of a language in the report.
+type reportLanguage struct {
+	Code, Name string
+	Count      int
+	Share      string
+	Percent    float64
+	MeanScore  string
+	Samples    []string
+}
+
+type reportBin struct {
+	Label   string
+	Count   int
+	Percent float64
+}
+
+// write writes the report as a self-contained HTML page to path.
+func (r *corpusReport) write(path string) error {
+	classified := r.total - r.unknown
+	var languages []reportLanguage
+	for _, code := range r.languages() {
+		n := r.counts[code]
+		languages = append(languages, reportLanguage{
+			Code:      code,
+			Name:      languageName(code),
+			Count:     n,
+			Share:     strconv.FormatFloat(100*float64(n)/float64(classified), 'f', 1, 64) + "%",
+			Percent:   100 * float64(n) / float64(classified),
+			MeanScore: fmt.Sprintf("%.2f", r.scores[code]/float64(n)),
+			Samples:   r.samples.items[code],
+		})
+	}
+	var bins []reportBin
+	peak := 1
+	for _, n := range r.histogram {
+		peak = max(peak, n)
+	}
+	for i, n := range r.histogram {
+		bins = append(bins, reportBin{
+			Label:   fmt.Sprintf("%.1f–%.1f", float64(i)/10, float64(i+1)/10),
+			Count:   n,
+			Percent: 100 * float64(n) / float64(peak),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := reportTemplate.Execute(&buf, map[string]any{
+		"Version":    version,
+		"Generated":  time.Now().Format(time.RFC1123),
+		"Duration":   time.Since(r.started).Round(time.Millisecond),
+		"Total":      r.total,
+		"Classified": classified,
+		"Unknown":    r.unknown,
+		"Languages":  languages,
+		"Bins":       bins,
+		"Threshold":  fmt.Sprintf("%.2f", r.threshold),
+		"LowCount":   r.lowCount,
+		"Flagged":    r.flagged,
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Language report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em auto; max-width: 60em; padding: 0 1em; color: #222; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { text-align: left; padding: .3em .6em; border-bottom: 1px solid #ddd; vertical-align: top; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+.bar { background: #4a7ebb; height: 1em; min-width: 1px; }
+.chart { width: 40%; }
+ul { margin: 0; padding-left: 1.2em; }
+.meta { color: #666; }
+</style>
+</head>
+<body>
+<h1>Language report</h1>
+<p class="meta">Generated by lingua-cli {{.Version}} on {{.Generated}} in {{.Duration}}.</p>
+<p>{{.Total}} texts: {{.Classified}} classified, {{.Unknown}} unknown.</p>
+
+<h2>Languages</h2>
+<table>
+<tr><th>Language</th><th>Texts</th><th>Share</th><th class="chart"></th><th>Mean score</th></tr>
+{{range .Languages}}<tr><td>{{.Name}} ({{.Code}})</td><td class="n">{{.Count}}</td><td class="n">{{.Share}}</td><td class="chart"><div class="bar" style="width: {{printf "%.1f" .Percent}}%"></div></td><td class="n">{{.MeanScore}}</td></tr>
+{{end}}</table>
+
+<h2>Confidence</h2>
+<table>
+<tr><th>Best score</th><th>Texts</th><th class="chart"></th></tr>
+{{range .Bins}}<tr><td>{{.Label}}</td><td class="n">{{.Count}}</td><td class="chart"><div class="bar" style="width: {{printf "%.1f" .Percent}}%"></div></td></tr>
+{{end}}</table>
+
+<h2>Samples</h2>
+<table>
+<tr><th>Language</th><th>Random texts</th></tr>
+{{range .Languages}}<tr><td>{{.Name}} ({{.Code}})</td><td><ul>{{range .Samples}}<li>{{.}}</li>{{end}}</ul></td></tr>
+{{end}}</table>
+
+<h2>Low confidence</h2>
+<p>Texts scoring below {{.Threshold}}: {{.LowCount}}{{if gt .LowCount (len .Flagged)}}, the first {{len .Flagged}} of which are listed{{end}}.</p>
+{{if .Flagged}}<table>
+<tr><th>Source</th><th>Language</th><th>Score</th><th>Text</th></tr>
+{{range .Flagged}}<tr><td>{{.Where}}</td><td>{{.Lang}}</td><td class="n">{{.Score}}</td><td>{{.Text}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
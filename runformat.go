@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// runDocument is the output of -format json-run: a single JSON document for
+// a whole run, with the json-v1 records of its results, to be kept as an
+// artifact of data pipeline runs.
+type runDocument struct {
+	Version   string            `json:"version"`
+	Command   []string          `json:"command"`
+	Input     runInput          `json:"input"`
+	Timing    runTiming         `json:"timing"`
+	Languages []runLanguage     `json:"languages"`
+	Results   []json.RawMessage `json:"results"`
+}
+
+type runInput struct {
+	// Mode is text, texts (-each, -texts-from), lines (-n) or files.
+	Mode    string   `json:"mode"`
+	Sources []string `json:"sources"`
+	Records int      `json:"records"`
+}
+
+type runTiming struct {
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	// LoadSeconds is the time taken to build the detector, part of
+	// Seconds.
+	LoadSeconds      float64 `json:"load_seconds"`
+	Seconds          float64 `json:"seconds"`
+	RecordsPerSecond float64 `json:"records_per_second"`
+}
+
+type runLanguage struct {
+	Language  string  `json:"language"`
+	Count     int     `json:"count"`
+	Share     float64 `json:"share"`
+	MeanScore float64 `json:"mean_score"`
+}
+
+// runMetadata describes a run for its runDocument.
+type runMetadata struct {
+	command  []string
+	mode     string
+	sources  []string
+	started  time.Time
+	loadTime time.Duration
+}
+
+// printRunDocument writes the held json-v1 records of a run to w as its
+// runDocument, with the share of each language among the records, the most
+// frequent first.
+func (b *resultBuffer) printRunDocument(w *outputWriter, run runMetadata) error {
+	finished := time.Now()
+	doc := runDocument{
+		Version: version,
+		Command: run.command,
+		Input:   runInput{Mode: run.mode, Sources: run.sources, Records: len(b.results)},
+		Timing: runTiming{
+			Started:     run.started,
+			Finished:    finished,
+			LoadSeconds: run.loadTime.Seconds(),
+			Seconds:     finished.Sub(run.started).Seconds(),
+		},
+		Languages: []runLanguage{},
+		Results:   []json.RawMessage{},
+	}
+	if elapsed := doc.Timing.Seconds - doc.Timing.LoadSeconds; elapsed > 0 {
+		doc.Timing.RecordsPerSecond = float64(len(b.results)) / elapsed
+	}
+	groups, languages := b.groups()
+	for _, lang := range languages {
+		language := runLanguage{Language: lang, Count: len(groups[lang])}
+		language.Share = float64(language.Count) / float64(len(b.results))
+		for _, r := range groups[lang] {
+			language.MeanScore += r.score / float64(language.Count)
+		}
+		doc.Languages = append(doc.Languages, language)
+	}
+	for _, r := range b.results {
+		doc.Results = append(doc.Results, json.RawMessage(r.data))
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	w.Write(data)
+	return w.WriteByte('\n')
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sarifRules are the rules of the findings of the checking commands, by the
+// status of the failed check.
+var sarifRules = []struct{ status, id, description string }{
+	{"mismatch", "wrong-language", "Text is in another language than the one declared"},
+	{"missing", "missing-lang", "Page declares no language"},
+	{"copied", "copied-translation", "Translation is identical to the source text"},
+}
+
+// sarifReport collects the failed checks of a checking command for -sarif,
+// in the Static Analysis Results Interchange Format 2.1.0, so that code
+// scanning tools show them alongside other findings.
+type sarifReport struct {
+	results []sarifResult
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	} `json:"driver"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// newSARIFReport returns a report, or nil if path is empty, on which add
+// and write do nothing.
+func newSARIFReport(path string) *sarifReport {
+	if path == "" {
+		return nil
+	}
+	return &sarifReport{}
+}
+
+// add records check of file as a finding if it failed.
+func (r *sarifReport) add(file string, check langCheck) {
+	if r == nil {
+		return
+	}
+	for _, rule := range sarifRules {
+		if rule.status != check.status {
+			continue
+		}
+		message := rule.description
+		if check.status == "mismatch" {
+			detected := "no language"
+			if len(check.results) > 0 && check.results[0].Score > 0 {
+				detected = fmt.Sprintf("%s (%s)", check.results[0].Code, formatScore(check.results[0].Score))
+			}
+			message = fmt.Sprintf("%s: declared %s, detected %s", check.element, check.declared, detected)
+		}
+		result := sarifResult{RuleID: rule.id, Level: "error", Message: sarifMessage{message}, Locations: make([]sarifLocation, 1)}
+		location := &result.Locations[0].PhysicalLocation
+		location.ArtifactLocation.URI = file
+		if !strings.Contains(file, "://") {
+			location.ArtifactLocation.URI = filepath.ToSlash(file)
+		}
+		if check.line > 0 {
+			location.Region = &sarifRegion{StartLine: check.line}
+		}
+		r.results = append(r.results, result)
+	}
+}
+
+// write writes the report to path.
+func (r *sarifReport) write(path string) error {
+	if r == nil {
+		return nil
+	}
+	run := sarifRun{Results: r.results}
+	if run.Results == nil {
+		run.Results = []sarifResult{}
+	}
+	driver := &run.Tool.Driver
+	driver.Name, driver.Version = "lingua-cli", version
+	driver.InformationURI = "https://github.com/rinodrops/lingua-cli-go"
+	for _, rule := range sarifRules {
+		driver.Rules = append(driver.Rules, sarifRule{rule.id, sarifMessage{rule.description}})
+	}
+	data, err := json.MarshalIndent(sarifLog{"2.1.0", "https://json.schemastore.org/sarif-2.1.0.json", []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+	"github.com/rinodrops/lingua-cli-go/langid/linguahttp"
+)
+
+// serveOptions are the options of the serve subcommand, from the command
+// line, the environment and the configuration file.
+type serveOptions struct {
+	fs                  *flag.FlagSet
+	cfg                 detectorConfig
+	listen              string
+	prior               bool
+	playground          bool
+	sessions            bool
+	sessionHeader       string
+	sessionDecay        float64
+	sessionTTL          time.Duration
+	maxSessions         int
+	responseCache       int
+	responseCacheTTL    time.Duration
+	poolsFile           string
+	corsOrigins         string
+	corsMaxAge          time.Duration
+	compressResponses   bool
+	auditLog            string
+	auditText           string
+	auditTextChars      int
+	auditUserHeader     string
+	pprofListen         string
+	configPath, profile string
+}
+
+// parseServeOptions reads the options of serve. They are read again on
+// SIGHUP with flag.ContinueOnError, so that a mistake in the configuration
+// file is reported without stopping the server.
+func parseServeOptions(args []string, handling flag.ErrorHandling) (*serveOptions, error) {
+	o := &serveOptions{fs: flag.NewFlagSet("serve", handling)}
+	fs := o.fs
+	o.cfg.register(fs)
+	fs.StringVar(&o.listen, "listen", "localhost:8080",
+		"Address to listen on: host:port, or unix:PATH of a socket.")
+	fs.BoolVar(&o.prior, "prior", false,
+		"Break near-ties on short texts in favour of the languages of the client, from a hint field or query parameter or the Accept-Language header.")
+	fs.BoolVar(&o.playground, "playground", false,
+		"Also serve a page at / for trying out detection in a browser.")
+	fs.BoolVar(&o.sessions, "sessions", false,
+		"Weigh the short texts of a session by the languages of its earlier texts. A session is named by a session query parameter or body field, or else by the -session-header header.")
+	fs.StringVar(&o.sessionHeader, "session-header", linguahttp.DefaultSessionHeader,
+		"Request header naming the session of -sessions, such as a chat or client key.")
+	fs.Float64Var(&o.sessionDecay, "session-decay", linguahttp.DefaultSessionDecay,
+		"Factor by which the earlier texts of a session count less with every new one (0.0-1.0).")
+	fs.DurationVar(&o.sessionTTL, "session-ttl", linguahttp.DefaultSessionTTL,
+		"How long a session is kept after its last text.")
+	fs.IntVar(&o.maxSessions, "max-sessions", linguahttp.DefaultMaxSessions,
+		"Number of sessions kept, the least recently used one being dropped for a new one.")
+	fs.IntVar(&o.responseCache, "response-cache", 0,
+		"Answer repeated requests from a cache of this many responses, keyed by a hash of the text, the pool, the all and spans parameters and the weights of -prior and -sessions, and add an X-Cache header (0 disables the cache).")
+	fs.DurationVar(&o.responseCacheTTL, "response-cache-ttl", linguahttp.DefaultCacheTTL,
+		"How long a response is kept by -response-cache.")
+	fs.StringVar(&o.poolsFile, "pools", "",
+		"File of named detector pools that requests select with a pool query parameter or body field, one per line: the name followed by the detection options in which the pool differs from the server, such as 'chat -l en,de,fr -q -c 0.5'. Pools share all other options but -cache-file.")
+	fs.StringVar(&o.corsOrigins, "cors-origins", "",
+		"Comma-separated origins allowed to call the server from browsers, such as https://tools.example.com, https://*.example.com for the subdomains of a site, or * for any origin.")
+	fs.DurationVar(&o.corsMaxAge, "cors-max-age", 0,
+		"How long browsers may cache the answer to a preflight request of -cors-origins (0 leaves it to them).")
+	fs.BoolVar(&o.compressResponses, "compress-responses", true,
+		"Compress responses of 1 KiB or more for clients accepting gzip or deflate. Compressed request bodies are accepted either way.")
+	fs.StringVar(&o.auditLog, "audit-log", "",
+		"Append a JSON line for every detection to this file: the time, the request ID, the remote address, the number of characters, the text as -audit-text says, and the language and score. The file is opened again on SIGHUP, for rotating it.")
+	fs.StringVar(&o.auditText, "audit-text", "hash",
+		"How -audit-log records texts: hash (their SHA-256), truncate (their first -audit-text-chars characters), full or none.")
+	fs.IntVar(&o.auditTextChars, "audit-text-chars", 64,
+		"Number of characters of a text -audit-text truncate records.")
+	fs.StringVar(&o.auditUserHeader, "audit-user-header", "",
+		"Request header naming the user in -audit-log, such as one set by an authenticating proxy.")
+	fs.StringVar(&o.pprofListen, "pprof-listen", "",
+		"Also serve the profiles of net/http/pprof at /debug/pprof/ on this address, such as localhost:6060, for capturing CPU and heap profiles of the running server. Keep it apart from -listen and away from the clients: profiles reveal the texts in memory and take CPU.")
+	fs.StringVar(&o.configPath, "config", "",
+		"Read default options from this configuration file instead of lingua-cli/config.toml in the user configuration directory (e.g. ~/.config), if it exists. Settings of options serve does not have are skipped.")
+	fs.StringVar(&o.profile, "profile", "",
+		"Apply the options of this named profile, a [profile.NAME] section of the configuration file.")
+	addLongFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Answer detection requests over HTTP.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli serve [OPTIONS]\n\n")
+		fmt.Fprintf(os.Stderr, "POST a JSON object with a \"text\" field, a form or plain text to /detect;\n")
+		fmt.Fprintf(os.Stderr, "add all=1 for the confidences of all languages and spans=1 for spans.\n")
+		fmt.Fprintf(os.Stderr, "Options can also be set by environment variables and the configuration file,\n")
+		fmt.Fprintf(os.Stderr, "as for detect mode. SIGHUP reads them again and swaps the detector.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		printFlags(os.Stderr, fs)
+	}
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := setFlagsFromEnv(fs); err != nil {
+		return nil, err
+	}
+	if err := applyConfig(fs, o.configPath, o.profile, true); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if o.sessionDecay < 0 || o.sessionDecay > 1 {
+		return nil, fmt.Errorf("-session-decay must lie in between 0.0 and 1.0")
+	}
+	if o.maxSessions < 0 || o.responseCache < 0 {
+		return nil, fmt.Errorf("-max-sessions and -response-cache must not be negative")
+	}
+	if !slices.Contains(auditTextModes, o.auditText) {
+		return nil, fmt.Errorf("invalid -audit-text value %q (expected %s)", o.auditText, strings.Join(auditTextModes, ", "))
+	}
+	if o.auditTextChars < 0 {
+		return nil, fmt.Errorf("-audit-text-chars must not be negative")
+	}
+	return o, nil
+}
+
+// runServe implements the serve subcommand. It answers detection requests
+// over HTTP with linguahttp.NewHandler at /detect, the server the client
+// subcommand and the remote backend talk to.
+func runServe(args []string) int {
+	opts, err := parseServeOptions(args, flag.ExitOnError)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 2
+	}
+	network, address := "tcp", opts.listen
+	if socket, ok := strings.CutPrefix(opts.listen, "unix:"); ok {
+		network, address = "unix", socket
+		// A socket left behind by a server that did not shut down
+		// cleanly would make listening fail.
+		if info, err := os.Stat(socket); err == nil && info.Mode().Type() == os.ModeSocket {
+			os.Remove(socket)
+		}
+	}
+	generation, err := opts.build((*detectorConfig).build)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	handler := &reloadableHandler{current: generation}
+	defer func() { handler.current.close() }()
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	server := &http.Server{Handler: linguahttp.WithRequestID(handler), ReadHeaderTimeout: 10 * time.Second}
+	fmt.Fprintf(os.Stderr, "serving detection requests on %s\n", opts.listen)
+	if opts.pprofListen != "" {
+		if err := servePprof(opts.pprofListen); err != nil {
+			fmt.Fprintf(os.Stderr, "error: -pprof-listen: %v\n", err)
+			return 1
+		}
+	}
+
+	// SIGHUP reads the options again and swaps the handler, while the
+	// requests in flight finish with the old one. Requests in flight are
+	// also answered before the server stops.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for sig := range signals {
+			if sig == syscall.SIGHUP {
+				handler.reload(args, opts.listen)
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+			return
+		}
+	}()
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	<-stopped
+	return 0
+}
+
+// servePprof serves the profiles of net/http/pprof on addr until the
+// process exits. The address is only read at startup, not on SIGHUP.
+func servePprof(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	fmt.Fprintf(os.Stderr, "serving profiles on %s/debug/pprof/\n", addr)
+	go http.Serve(listener, mux)
+	return nil
+}
+
+// serveGeneration is the handler built from one reading of the options of
+// serve, with the detectors it closes once its requests are answered.
+type serveGeneration struct {
+	opts      *serveOptions
+	handler   http.Handler
+	detectors []langid.Detector
+	audit     *auditLog
+	inflight  sync.WaitGroup
+	// ctx is canceled by retire when the generation is replaced, which
+	// closes its WebSocket connections.
+	ctx    context.Context
+	retire context.CancelFunc
+}
+
+// build creates the detectors and the handler the options configure. The
+// detector of the server is created last, by open, so that reload can hold
+// on to the cache file of the old one until nothing else can fail.
+func (o *serveOptions) build(open func(*detectorConfig) (langid.Detector, error)) (*serveGeneration, error) {
+	g := &serveGeneration{opts: o}
+	g.ctx, g.retire = context.WithCancel(context.Background())
+	config := linguahttp.Config{Prior: o.prior, DisableCompression: !o.compressResponses}
+	if o.sessions {
+		config.Sessions = &linguahttp.Sessions{Header: o.sessionHeader, Decay: o.sessionDecay, TTL: o.sessionTTL, MaxSessions: o.maxSessions}
+	}
+	if o.responseCache > 0 {
+		config.Cache = &linguahttp.Cache{Size: o.responseCache, TTL: o.responseCacheTTL}
+	}
+	if o.poolsFile != "" {
+		pools, err := loadPools(o.poolsFile, o.fs)
+		for _, pool := range pools {
+			g.detectors = append(g.detectors, pool.Detector)
+		}
+		if err != nil {
+			g.close()
+			return nil, err
+		}
+		config.Pools = pools
+	}
+	if origins := splitList(o.corsOrigins); origins != nil {
+		config.CORS = &linguahttp.CORS{AllowedOrigins: origins, MaxAge: o.corsMaxAge}
+	}
+	if o.auditLog != "" {
+		audit, err := openAuditLog(o.auditLog, o.auditText, o.auditTextChars, o.auditUserHeader)
+		if err != nil {
+			g.close()
+			return nil, err
+		}
+		g.audit = audit
+		config.Observe = audit.observe
+	}
+	detector, err := open(&o.cfg)
+	if err != nil {
+		g.close()
+		return nil, err
+	}
+	g.detectors = append(g.detectors, detector)
+	mux := http.NewServeMux()
+	mux.Handle("/detect", linguahttp.NewHandler(detector, config))
+	mux.Handle("/ws", g.untilRetired(linguahttp.NewWebSocketHandler(detector, config)))
+	if o.playground {
+		mux.Handle("/{$}", linguahttp.Playground("/detect"))
+	}
+	g.handler = mux
+	return g, nil
+}
+
+// close closes the detectors of g once its requests in flight are answered.
+func (g *serveGeneration) close() {
+	g.retire()
+	g.inflight.Wait()
+	for _, d := range g.detectors {
+		langid.Close(d)
+	}
+	if g.audit != nil {
+		g.audit.close()
+	}
+}
+
+// untilRetired ends the requests of next, such as WebSocket connections,
+// which last, when g is retired, for their clients to reconnect to the next
+// generation.
+func (g *serveGeneration) untilRetired(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		stop := context.AfterFunc(g.ctx, cancel)
+		defer stop()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// reloadableHandler serves requests with the current generation of the
+// handler of serve, which reload replaces.
+type reloadableHandler struct {
+	mu      sync.RWMutex
+	current *serveGeneration
+}
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	g := h.current
+	g.inflight.Add(1)
+	h.mu.RUnlock()
+	defer g.inflight.Done()
+	g.handler.ServeHTTP(w, r)
+}
+
+// reload reads the options of serve from args, the environment and the
+// configuration file again and swaps in a handler built from them, closing
+// the old one once its requests are answered. If they are invalid, the old
+// handler is kept. The address the server listens on can not change.
+func (h *reloadableHandler) reload(args []string, listen string) {
+	opts, err := parseServeOptions(args, flag.ContinueOnError)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: reload: %v, keeping the previous configuration\n", err)
+		return
+	}
+	if opts.listen != listen {
+		fmt.Fprintf(os.Stderr, "warning: reload: -listen can not change while serving, still listening on %s\n", listen)
+	}
+	h.mu.RLock()
+	old := h.current
+	h.mu.RUnlock()
+	open, locked := (*detectorConfig).build, false
+	if opts.cfg.cacheFile != "" && opts.cfg.cacheFile == old.opts.cfg.cacheFile {
+		// Only one detector at a time can hold the cache file, so the new
+		// options are checked without it first. Then new requests wait
+		// while the old generation finishes its requests and hands over
+		// the file, until the new one is swapped in.
+		open = func(cfg *detectorConfig) (langid.Detector, error) {
+			check := *cfg
+			check.cacheFile = ""
+			detector, err := check.build()
+			if err != nil {
+				return nil, err
+			}
+			langid.Close(detector)
+			h.mu.Lock()
+			locked = true
+			old.retire()
+			old.inflight.Wait()
+			langid.Close(old.detectors[len(old.detectors)-1])
+			old.detectors = old.detectors[:len(old.detectors)-1]
+			if detector, err = cfg.build(); err != nil {
+				fmt.Fprintf(os.Stderr, "error: reload: %v\n", err)
+				exit(1)
+			}
+			return detector, nil
+		}
+	}
+	g, err := opts.build(open)
+	if !locked {
+		h.mu.Lock()
+	}
+	defer h.mu.Unlock()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: reload: %v, keeping the previous configuration\n", err)
+		return
+	}
+	h.current = g
+	go old.close()
+	fmt.Fprintf(os.Stderr, "reloaded the configuration\n")
+}
+
+// loadPools builds the detector pools of the -pools file at path. Each line
+// names a pool and gives the detection options, and -c, in which it differs
+// from those of base, the flags of serve; it shares the others, except
+// -cache-file, whose database one detector holds at a time. The pools built
+// are returned with an error too, for the caller to close.
+func loadPools(path string, base *flag.FlagSet) (map[string]linguahttp.Pool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pools := make(map[string]linguahttp.Pool)
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := langid.SplitCommand(line)
+		if err != nil {
+			return pools, fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		name := fields[0]
+		if _, ok := pools[name]; ok {
+			return pools, fmt.Errorf("%s:%d: pool %q defined twice", path, number, name)
+		}
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		var cfg detectorConfig
+		cfg.register(fs)
+		threshold := fs.Float64("c", 0, "")
+		addLongFlags(fs)
+		if err := parseFlags(fs, fields[1:]); err != nil {
+			return pools, fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		if fs.NArg() != 0 {
+			return pools, fmt.Errorf("%s:%d: unexpected argument %q", path, number, fs.Arg(0))
+		}
+		given := make(map[string]bool)
+		fs.Visit(func(f *flag.Flag) {
+			given[canonicalFlag(f.Name)] = true
+		})
+		base.VisitAll(func(f *flag.Flag) {
+			if _, long := longFlags[f.Name]; !long && !given[f.Name] && f.Name != "cache-file" && fs.Lookup(f.Name) != nil {
+				fs.Set(f.Name, f.Value.String())
+			}
+		})
+		detector, err := cfg.build()
+		if err != nil {
+			return pools, fmt.Errorf("%s:%d: pool %s: %v", path, number, name, err)
+		}
+		pool := linguahttp.Pool{Detector: detector}
+		if given["c"] {
+			pool.Options = []langid.Option{langid.WithThreshold(*threshold)}
+		}
+		pools[name] = pool
+	}
+	return pools, scanner.Err()
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// shardModes lists the values accepted by -shard-by.
+var shardModes = []string{"index", "hash"}
+
+// shard is the part of the input one of several runs processes, so that a
+// corpus can be split deterministically among machines.
+type shard struct {
+	// index is the number of the shard, from 1 to count.
+	index, count int
+	// byHash assigns records to shards by a hash of their content instead
+	// of their position, so that equal records end up in the same shard
+	// and the assignment does not depend on the order of the input.
+	byHash bool
+}
+
+// parseShard parses the value of -shard, "i/n" for shard i of n.
+func parseShard(spec, mode string) (shard, error) {
+	i, n, ok := strings.Cut(spec, "/")
+	index, err1 := strconv.Atoi(strings.TrimSpace(i))
+	count, err2 := strconv.Atoi(strings.TrimSpace(n))
+	if !ok || err1 != nil || err2 != nil || count < 1 || index < 1 || index > count {
+		return shard{}, fmt.Errorf("invalid -shard value %q (expected i/n with 1 <= i <= n, e.g. 2/4)", spec)
+	}
+	return shard{index: index, count: count, byHash: mode == "hash"}, nil
+}
+
+// keeps reports whether the record at position number, from 1, with the
+// given content belongs to the shard.
+func (s shard) keeps(number int, record string) bool {
+	if s.count <= 1 {
+		return true
+	}
+	if s.byHash {
+		h := fnv.New64a()
+		h.Write([]byte(record))
+		return int(h.Sum64()%uint64(s.count)) == s.index-1
+	}
+	return (number-1)%s.count == s.index-1
+}
+
+// path returns the output file of the shard for path, with "-i-of-n"
+// before its extension ("results.tsv.gz" becomes "results-2-of-4.tsv.gz"),
+// so that the runs of all shards can write to the same directory.
+func (s shard) path(path string) string {
+	if path == "" || s.count <= 1 {
+		return path
+	}
+	dir, name := filepath.Split(path)
+	base, gz := strings.CutSuffix(name, ".gz")
+	ext := filepath.Ext(base)
+	suffix := fmt.Sprintf("-%d-of-%d", s.index, s.count)
+	name = strings.TrimSuffix(base, ext) + suffix + ext
+	if gz {
+		name += ".gz"
+	}
+	return dir + name
+}
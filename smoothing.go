@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// smoother blends each line's distribution with the average distribution of
+// the preceding lines, since chat logs and subtitles rarely switch language
+// from one line to the next and short lines on their own are noisy.
+type smoother struct {
+	window  int
+	weight  float64
+	history []map[string]float64
+}
+
+func newSmoother(window int, weight float64) *smoother {
+	return &smoother{window: window, weight: weight}
+}
+
+// apply returns the smoothed distribution for the next line and records the
+// line's own (unsmoothed) distribution as context for the following lines.
+// Lines without any signal neither get smoothed nor become context.
+func (s *smoother) apply(values []langid.Confidence) []langid.Confidence {
+	current := make(map[string]float64, len(values))
+	total := 0.0
+	for _, value := range values {
+		current[value.Code] += value.Score
+		total += value.Score
+	}
+	if total == 0 {
+		return values
+	}
+
+	smoothed := values
+	if len(s.history) > 0 {
+		context := make(map[string]float64)
+		for _, dist := range s.history {
+			for code, score := range dist {
+				context[code] += score / float64(len(s.history))
+			}
+		}
+		smoothed = make([]langid.Confidence, 0, len(current))
+		for code, score := range current {
+			smoothed = append(smoothed, langid.Confidence{
+				Code:  code,
+				Score: (1-s.weight)*score/total + s.weight*context[code],
+			})
+		}
+		sort.Slice(smoothed, func(i, j int) bool {
+			if smoothed[i].Score != smoothed[j].Score {
+				return smoothed[i].Score > smoothed[j].Score
+			}
+			return smoothed[i].Code < smoothed[j].Code
+		})
+	}
+
+	for code := range current {
+		current[code] /= total
+	}
+	s.history = append(s.history, current)
+	if len(s.history) > s.window {
+		s.history = s.history[1:]
+	}
+	return smoothed
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// sourceSyntax describes how comments and string literals are written in a
+// family of programming languages.
+type sourceSyntax struct {
+	lineComments  []string
+	blockComments [][2]string
+	// quotes lists string delimiters, longest first. Delimiters longer than
+	// one character, and the backtick, may span lines.
+	quotes []string
+}
+
+var (
+	cSyntax = &sourceSyntax{
+		lineComments:  []string{"//"},
+		blockComments: [][2]string{{"/*", "*/"}},
+		quotes:        []string{`"`, `'`, "`"},
+	}
+	hashSyntax = &sourceSyntax{
+		lineComments: []string{"#"},
+		quotes:       []string{`"""`, `'''`, `"`, `'`},
+	}
+	dashSyntax = &sourceSyntax{
+		lineComments:  []string{"--"},
+		blockComments: [][2]string{{"/*", "*/"}, {"{-", "-}"}, {"--[[", "]]"}},
+		quotes:        []string{`"`, `'`},
+	}
+	markupSyntax = &sourceSyntax{
+		blockComments: [][2]string{{"<!--", "-->"}},
+	}
+)
+
+// sourceSyntaxes maps file extensions to the syntax of their language.
+var sourceSyntaxes = map[string]*sourceSyntax{
+	".c": cSyntax, ".h": cSyntax, ".cc": cSyntax, ".cpp": cSyntax, ".cxx": cSyntax,
+	".hpp": cSyntax, ".hh": cSyntax, ".cs": cSyntax, ".java": cSyntax, ".kt": cSyntax,
+	".kts": cSyntax, ".scala": cSyntax, ".go": cSyntax, ".rs": cSyntax, ".swift": cSyntax,
+	".js": cSyntax, ".jsx": cSyntax, ".mjs": cSyntax, ".ts": cSyntax, ".tsx": cSyntax,
+	".php": cSyntax, ".dart": cSyntax, ".m": cSyntax, ".css": cSyntax, ".scss": cSyntax,
+	".py": hashSyntax, ".rb": hashSyntax, ".sh": hashSyntax, ".bash": hashSyntax,
+	".zsh": hashSyntax, ".pl": hashSyntax, ".pm": hashSyntax, ".r": hashSyntax,
+	".yaml": hashSyntax, ".yml": hashSyntax, ".toml": hashSyntax, ".ex": hashSyntax,
+	".exs": hashSyntax, ".nim": hashSyntax, ".ps1": hashSyntax,
+	".sql": dashSyntax, ".lua": dashSyntax, ".hs": dashSyntax,
+	".html": markupSyntax, ".htm": markupSyntax, ".xml": markupSyntax, ".svg": markupSyntax,
+}
+
+// sourceFragment is a comment or string literal extracted from source code.
+type sourceFragment struct {
+	line int
+	kind string // "comment" or "string"
+	text string
+}
+
+// extractFragments returns the comments and string literals of src in order
+// of appearance. Consecutive line comments are merged into one fragment, as
+// they usually continue the same sentence.
+func extractFragments(src string, syntax *sourceSyntax) []sourceFragment {
+	var fragments []sourceFragment
+	line := 1
+	// The fragment and line of the previous line comment, for merging.
+	lastComment, lastCommentLine := -1, 0
+	for i := 0; i < len(src); {
+		if end, ok := syntax.blockComment(src, i); ok {
+			fragments = append(fragments, sourceFragment{line, "comment", cleanComment(src[i:end.text])})
+			line += strings.Count(src[i:end.next], "\n")
+			i = end.next
+			continue
+		}
+		if marker, ok := hasPrefixAny(src[i:], syntax.lineComments); ok {
+			stop := strings.IndexByte(src[i:], '\n')
+			if stop < 0 {
+				stop = len(src) - i
+			}
+			text := cleanComment(src[i+len(marker) : i+stop])
+			if lastComment >= 0 && lastComment == len(fragments)-1 && lastCommentLine == line-1 {
+				fragments[lastComment].text += " " + text
+			} else {
+				fragments = append(fragments, sourceFragment{line, "comment", text})
+				lastComment = len(fragments) - 1
+			}
+			lastCommentLine = line
+			i += stop
+			continue
+		}
+		if quote, ok := hasPrefixAny(src[i:], syntax.quotes); ok {
+			start := i + len(quote)
+			end, next := scanString(src, start, quote)
+			fragments = append(fragments, sourceFragment{line, "string", src[start:end]})
+			line += strings.Count(src[i:next], "\n")
+			i = next
+			continue
+		}
+		if src[i] == '\n' {
+			line++
+		}
+		i++
+	}
+	return fragments
+}
+
+type blockEnd struct {
+	text int // end of the comment text
+	next int // position after the closing marker
+}
+
+// blockComment reports whether a block comment starts at i, and where it ends.
+func (s *sourceSyntax) blockComment(src string, i int) (blockEnd, bool) {
+	for _, markers := range s.blockComments {
+		if !strings.HasPrefix(src[i:], markers[0]) {
+			continue
+		}
+		start := i + len(markers[0])
+		stop := strings.Index(src[start:], markers[1])
+		if stop < 0 {
+			return blockEnd{len(src), len(src)}, true
+		}
+		return blockEnd{start + stop, start + stop + len(markers[1])}, true
+	}
+	return blockEnd{}, false
+}
+
+func hasPrefixAny(s string, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// scanString finds the end of a string literal whose content starts at
+// start. It returns the end of the content and the position after the
+// closing quote. Single-character quotes other than the backtick end at the
+// line end if they are not closed.
+func scanString(src string, start int, quote string) (int, int) {
+	multiline := len(quote) > 1 || quote == "`"
+	for i := start; i < len(src); i++ {
+		switch {
+		case src[i] == '\\' && quote != "`":
+			i++
+		case strings.HasPrefix(src[i:], quote):
+			return i, i + len(quote)
+		case src[i] == '\n' && !multiline:
+			return i, i
+		}
+	}
+	return len(src), len(src)
+}
+
+// cleanComment strips the decoration of comment lines, such as the leading
+// asterisks of block comments, and joins them into one line.
+func cleanComment(text string) string {
+	var words []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimLeft(strings.TrimSpace(line), "*/#!-")
+		words = append(words, strings.Fields(line)...)
+	}
+	return strings.Join(words, " ")
+}
+
+// runSource implements the source subcommand. It extracts the comments and
+// string literals of source files and classifies their natural language.
+func runSource(args []string) int {
+	flags := flag.NewFlagSet("source", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(flags)
+	minLength := flags.Int("M", 10,
+		"Minimum number of letters of a fragment to classify it, shorter ones are skipped")
+	threshold := flags.Float64("c", 0,
+		"Confidence threshold, fragments scoring below it are reported as 'unknown' (0.0-1.0)")
+	ignore := flags.String("ignore", "",
+		"Comma separated list of languages whose fragments are not printed, e.g. 'en' to find non-English comments")
+	commentsOnly := flags.Bool("comments-only", false,
+		"Classify comments only, not string literals")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Classify the natural language of comments and string literals in source code.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli source [OPTIONS] PATH...\n\n")
+		fmt.Fprintf(os.Stderr, "Directories are searched recursively for files with known extensions:\n")
+		fmt.Fprintf(os.Stderr, "%s\n\n", strings.Join(sourceExtensions(), " "))
+		fmt.Fprintf(os.Stderr, "Prints path:line, fragment kind, language, score and the fragment.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		flags.PrintDefaults()
+	}
+	parseFlags(flags, args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	ignored := make(map[string]bool)
+	for _, code := range splitList(*ignore) {
+		ignored[strings.ToLower(code)] = true
+	}
+
+	classifyFile := func(path string, syntax *sourceSyntax) error {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, fragment := range extractFragments(string(src), syntax) {
+			if *commentsOnly && fragment.kind != "comment" {
+				continue
+			}
+			text := strings.Join(strings.Fields(fragment.text), " ")
+			if !longEnough(text, *minLength) {
+				continue
+			}
+			results, err := detector.ConfidenceValues(text)
+			if err != nil {
+				return err
+			}
+			code, score := langid.Unknown, ""
+			if len(results) > 0 && results[0].Score > 0 && results[0].Score >= *threshold {
+				code, score = results[0].Code, formatScore(results[0].Score)
+			}
+			if ignored[code] {
+				continue
+			}
+			fmt.Printf("%s:%d\t%s\t%s\t%s\t%s\n", path, fragment.line, fragment.kind, code, score, text)
+		}
+		return nil
+	}
+
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if path != root && strings.HasPrefix(entry.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			syntax, ok := sourceSyntaxes[strings.ToLower(filepath.Ext(path))]
+			if !ok {
+				if path == root {
+					fmt.Fprintf(os.Stderr, "warning: %s: unknown source file type, skipped\n", path)
+				}
+				return nil
+			}
+			return classifyFile(path, syntax)
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// sourceExtensions returns the recognized source file extensions, sorted.
+func sourceExtensions() []string {
+	var extensions []string
+	for ext := range sourceSyntaxes {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	lingua "github.com/pemistahl/lingua-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// detectParams are the parameters of the detect method. Unset options fall
+// back to those of the configure method, then to the command line flags.
+// The configure method takes the same parameters without text.
+type detectParams struct {
+	Text      string   `json:"text"`
+	Languages []string `json:"languages,omitempty"`
+	Threshold *float64 `json:"threshold,omitempty"`
+	All       *bool    `json:"all,omitempty"`
+	Multi     *bool    `json:"multi,omitempty"`
+}
+
+type detectResult struct {
+	// Language is the best language, or "unknown".
+	Language    string           `json:"language"`
+	Score       float64          `json:"score"`
+	Confidences []confidenceJSON `json:"confidences,omitempty"`
+	Spans       []spanJSON       `json:"spans,omitempty"`
+}
+
+type confidenceJSON struct {
+	Language string  `json:"language"`
+	Score    float64 `json:"score"`
+}
+
+type spanJSON struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Language string `json:"language"`
+	Text     string `json:"text"`
+}
+
+// rpcServer answers JSON-RPC requests read from stdin on stdout.
+type rpcServer struct {
+	cfg       detectorConfig
+	threshold float64
+	// preprocess and classifiable apply the text filters of the command line.
+	preprocess   func(string) string
+	classifiable func(string) bool
+	// maxMessage is the size of the largest message read (0 for no limit).
+	maxMessage int
+
+	// defaults are the options set by the configure method. They only
+	// change while no request is in progress.
+	defaults detectParams
+
+	mu sync.Mutex
+	// detectors holds the detectors built so far, by language list.
+	detectors map[string]langid.Detector
+}
+
+// runStdio serves JSON-RPC 2.0 over stdin and stdout until stdin ends or a
+// shutdown request arrives. Messages are either one JSON object per line or
+// framed by Content-Length headers as in the Language Server Protocol;
+// responses use the framing of their request. Up to workers requests are
+// processed at a time, so responses may arrive out of order. A configure
+// request waits for the requests before it, so that they keep the options
+// they were sent with.
+func runStdio(s *rpcServer, detector langid.Detector, workers int) error {
+	s.detectors = map[string]langid.Detector{"": detector}
+	defer func() {
+		for key, d := range s.detectors {
+			if key != "" {
+				langid.Close(d)
+			}
+		}
+	}()
+
+	in := newLineReader(os.Stdin, s.maxMessage)
+	out := bufio.NewWriter(os.Stdout)
+	var writeMu sync.Mutex
+	write := func(response rpcResponse, framed bool) {
+		data, err := json.Marshal(response)
+		if err != nil {
+			data, _ = json.Marshal(rpcResponse{JSONRPC: "2.0", ID: response.ID,
+				Error: &rpcError{rpcInternalError, err.Error()}})
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if framed {
+			fmt.Fprintf(out, "Content-Length: %d\r\n\r\n", len(data))
+			out.Write(data)
+		} else {
+			out.Write(data)
+			out.WriteByte('\n')
+		}
+		out.Flush()
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		data, framed, err := readMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err == errMessageTooLarge {
+			write(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{rpcInvalidRequest, fmt.Sprintf("message longer than %d bytes", s.maxMessage)}}, framed)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(strings.TrimSpace(string(data))) == 0 {
+			continue
+		}
+		var request rpcRequest
+		if err := json.Unmarshal(data, &request); err != nil {
+			write(rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"),
+				Error: &rpcError{rpcParseError, err.Error()}}, framed)
+			continue
+		}
+		if request.Method == "shutdown" {
+			wg.Wait()
+			if request.ID != nil {
+				write(rpcResponse{JSONRPC: "2.0", ID: request.ID, Result: json.RawMessage("null")}, framed)
+			}
+			return nil
+		}
+		if request.Method == "configure" {
+			wg.Wait()
+			rpcErr := s.configure(traceCtx, request.Params)
+			if request.ID != nil {
+				response := rpcResponse{JSONRPC: "2.0", ID: request.ID, Error: rpcErr}
+				if rpcErr == nil {
+					response.Result = json.RawMessage("null")
+				}
+				write(response, framed)
+			}
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-slots; wg.Done() }()
+			result, rpcErr := s.handle(traceCtx, request)
+			// Requests without id are notifications and get no response.
+			if request.ID == nil {
+				return
+			}
+			response := rpcResponse{JSONRPC: "2.0", ID: request.ID, Result: result, Error: rpcErr}
+			if rpcErr == nil && result == nil {
+				response.Result = json.RawMessage("null")
+			}
+			write(response, framed)
+		}()
+	}
+}
+
+// errMessageTooLarge is returned by readMessage for messages longer than
+// the limit of its reader, which are skipped.
+var errMessageTooLarge = errors.New("message too large")
+
+// readMessage reads the next message, either a line of JSON or a block of
+// headers with Content-Length followed by that many bytes. framed reports
+// the latter.
+func readMessage(in *lineReader) ([]byte, bool, error) {
+	if !in.Scan() {
+		if in.Err() != nil {
+			return nil, false, in.Err()
+		}
+		return nil, false, io.EOF
+	}
+	line := in.Text()
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || !strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+		if in.Size() > int64(len(line)) {
+			return nil, false, errMessageTooLarge
+		}
+		return []byte(line), false, nil
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || length < 0 {
+		return nil, false, fmt.Errorf("invalid Content-Length header: %q", strings.TrimSpace(value))
+	}
+	// Skip further headers up to the empty line ending them.
+	for {
+		if !in.Scan() {
+			if in.Err() != nil {
+				return nil, true, in.Err()
+			}
+			return nil, true, io.EOF
+		}
+		if strings.TrimSpace(in.Text()) == "" {
+			break
+		}
+	}
+	if in.maxBytes > 0 && length > in.maxBytes {
+		if _, err := io.CopyN(io.Discard, in.r, int64(length)); err != nil {
+			return nil, true, err
+		}
+		return nil, true, errMessageTooLarge
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(in.r, data); err != nil {
+		return nil, true, err
+	}
+	return data, true, nil
+}
+
+// handle runs one request in a span.
+func (s *rpcServer) handle(ctx context.Context, request rpcRequest) (result any, rpcErr *rpcError) {
+	ctx, span := tracer.Start(ctx, "rpc "+request.Method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("rpc.system", "jsonrpc"), attribute.String("rpc.method", request.Method)))
+	defer func() {
+		if rpcErr != nil {
+			span.SetStatus(codes.Error, rpcErr.Message)
+		}
+		span.End()
+	}()
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		return nil, &rpcError{rpcInvalidRequest, "not a JSON-RPC 2.0 request"}
+	}
+	switch request.Method {
+	case "detect":
+		var params detectParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+		result, err := s.detect(ctx, params)
+		if err != nil {
+			return nil, &rpcError{rpcInternalError, err.Error()}
+		}
+		return result, nil
+	case "languages":
+		var codes []string
+		for _, lang := range lingua.AllLanguages() {
+			codes = append(codes, strings.ToLower(lang.IsoCode639_1().String()))
+		}
+		sort.Strings(codes)
+		return codes, nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, fmt.Sprintf("unknown method %q", request.Method)}
+}
+
+// configure replaces the default options of the detect method by params,
+// or resets them to the command line flags if params are empty. It builds
+// the detector of the languages right away, so that unknown codes are
+// reported to the configure request rather than to every detect request.
+func (s *rpcServer) configure(ctx context.Context, params json.RawMessage) *rpcError {
+	var defaults detectParams
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &defaults); err != nil {
+			return &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	if defaults.Text != "" {
+		return &rpcError{rpcInvalidParams, "configure takes no text"}
+	}
+	if _, err := s.detectorFor(ctx, defaults.Languages); err != nil {
+		return &rpcError{rpcInvalidParams, err.Error()}
+	}
+	s.defaults = defaults
+	return nil
+}
+
+// detectorFor returns the detector for the given languages, building and
+// keeping it on first use. No languages means those of the command line.
+func (s *rpcServer) detectorFor(ctx context.Context, languages []string) (langid.Detector, error) {
+	codes := make([]string, len(languages))
+	for i, code := range languages {
+		codes[i] = strings.ToLower(strings.TrimSpace(code))
+	}
+	sort.Strings(codes)
+	key := strings.Join(codes, ",")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if d, ok := s.detectors[key]; ok {
+		return d, nil
+	}
+	cfg := s.cfg
+	cfg.languages = key
+	d, err := traceBuild(ctx, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	s.detectors[key] = d
+	return d, nil
+}
+
+func (s *rpcServer) detect(ctx context.Context, params detectParams) (*detectResult, error) {
+	languages := params.Languages
+	if languages == nil {
+		languages = s.defaults.Languages
+	}
+	detector, err := s.detectorFor(ctx, languages)
+	if err != nil {
+		return nil, err
+	}
+	threshold := s.threshold
+	if s.defaults.Threshold != nil {
+		threshold = *s.defaults.Threshold
+	}
+	if params.Threshold != nil {
+		threshold = *params.Threshold
+	}
+	all, multi := s.defaults.All, s.defaults.Multi
+	if params.All != nil {
+		all = params.All
+	}
+	if params.Multi != nil {
+		multi = params.Multi
+	}
+	result := &detectResult{Language: langid.Unknown}
+	text := s.preprocess(params.Text)
+	if !s.classifiable(text) {
+		return result, nil
+	}
+
+	if multi != nil && *multi {
+		md, ok := langid.AsMulti(detector)
+		if !ok {
+			return nil, fmt.Errorf("the %s backend does not support multi", s.cfg.backend)
+		}
+		spans, err := traceSpans(ctx, text, md.DetectMultiple)
+		if err != nil {
+			return nil, err
+		}
+		result.Spans = []spanJSON{}
+		for _, span := range spans {
+			result.Spans = append(result.Spans, spanJSON{span.Start, span.End, span.Code, text[span.Start:span.End]})
+		}
+	}
+
+	values, err := traceDetect(ctx, text, detector.ConfidenceValues)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) > 0 && values[0].Score > 0 && values[0].Score >= threshold {
+		result.Language, result.Score = values[0].Code, values[0].Score
+	}
+	if all != nil && *all {
+		result.Confidences = []confidenceJSON{}
+		for _, value := range values {
+			result.Confidences = append(result.Confidences, confidenceJSON{value.Code, value.Score})
+		}
+	}
+	return result, nil
+}
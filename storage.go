@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// objectStore reads objects from a cloud storage service. Objects are named
+// by URIs of the form scheme://container/key, where the container is a
+// bucket or, for Azure, a blob container.
+type objectStore interface {
+	// exists reports whether the object exists.
+	exists(ctx context.Context, container, key string) (bool, error)
+	// list passes the keys of the objects below prefix to send.
+	list(ctx context.Context, container, prefix string, send func(key string)) error
+	// open starts the download of an object, returning its body and size.
+	// Closing the body early stops the download.
+	open(ctx context.Context, container, key string) (io.ReadCloser, int64, error)
+}
+
+// objectStores maps URI schemes to their stores.
+var objectStores = map[string]objectStore{
+	"s3": s3Store{},
+	"gs": gcsStore{},
+	"az": azureStore{},
+}
+
+// parseObjectURI splits a URI naming a cloud storage object or prefix into
+// its store, container and key. ok is false for other paths.
+func parseObjectURI(uri string) (store objectStore, container, key string, ok bool) {
+	scheme, rest, found := strings.Cut(uri, "://")
+	if !found {
+		return nil, "", "", false
+	}
+	store, ok = objectStores[scheme]
+	container, key, _ = strings.Cut(rest, "/")
+	return store, container, key, ok
+}
+
+// isObjectURI reports whether path names a cloud storage object or prefix.
+func isObjectURI(path string) bool {
+	_, _, _, ok := parseObjectURI(path)
+	return ok
+}
+
+// walkObjects passes the objects named by uri to send: the object itself if
+// it exists, or else all objects below it as a prefix.
+func walkObjects(uri string, send func(string)) error {
+	store, container, key, _ := parseObjectURI(uri)
+	if container == "" {
+		return fmt.Errorf("%s: missing bucket or container name", uri)
+	}
+	ctx := context.Background()
+	if key != "" && !strings.HasSuffix(key, "/") {
+		exists, err := store.exists(ctx, container, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", uri, err)
+		}
+		if exists {
+			send(uri)
+			return nil
+		}
+		key += "/"
+	}
+
+	scheme, _, _ := strings.Cut(uri, "://")
+	found := false
+	err := store.list(ctx, container, key, func(name string) {
+		// Keys ending in a slash are folder markers.
+		if strings.HasSuffix(name, "/") {
+			return
+		}
+		found = true
+		send(scheme + "://" + container + "/" + name)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", uri, err)
+	}
+	if !found {
+		return fmt.Errorf("%s: no such object or prefix", uri)
+	}
+	return nil
+}
+
+// openObject starts the download of the object named by uri.
+func openObject(uri string) (io.ReadCloser, int64, error) {
+	store, container, key, _ := parseObjectURI(uri)
+	return store.open(context.Background(), container, key)
+}
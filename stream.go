@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+// chunkBoundaries lists the values accepted by -chunk-boundary.
+var chunkBoundaries = []string{"char", "word", "sentence"}
+
+// streamChunks reads r until EOF and passes the input to emit in chunks: when
+// no new input arrived for idle, when maxSize bytes have accumulated, and at
+// EOF. A zero idle or maxSize disables that trigger. Chunks of maxSize are cut
+// at the given boundary where possible (see boundaryCut), and no chunk ends
+// inside a UTF-8 sequence unless the input does.
+func streamChunks(r io.Reader, idle time.Duration, maxSize int, boundary string, emit func([]byte)) error {
+	type read struct {
+		data []byte
+		err  error
+	}
+	reads := make(chan read)
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				reads <- read{data: append([]byte(nil), buf[:n]...)}
+			}
+			if err != nil {
+				reads <- read{err: err}
+				return
+			}
+		}
+	}()
+
+	var pending []byte
+	var timeout <-chan time.Time
+	var timer *time.Timer
+	for {
+		select {
+		case rd := <-reads:
+			if rd.err != nil {
+				if len(pending) > 0 {
+					emit(pending)
+				}
+				if rd.err == io.EOF {
+					return nil
+				}
+				return rd.err
+			}
+			pending = append(pending, rd.data...)
+			for maxSize > 0 && len(pending) >= maxSize {
+				cut := boundaryCut(pending, maxSize, boundary)
+				emit(pending[:cut])
+				pending = pending[cut:]
+			}
+			if idle > 0 {
+				if timer == nil {
+					timer = time.NewTimer(idle)
+				} else {
+					timer.Reset(idle)
+				}
+				timeout = timer.C
+			}
+		case <-timeout:
+			timeout = nil
+			// Hold back a character whose bytes have not all arrived yet.
+			if n := completeRunes(pending); n > 0 {
+				emit(pending[:n])
+				pending = append([]byte(nil), pending[n:]...)
+			}
+		}
+	}
+}
+
+// sentenceEnd matches the end of a sentence: a full stop, question or
+// exclamation mark with the closing quotes and brackets after it, followed by
+// whitespace, an ideographic one, or a blank line.
+var sentenceEnd = regexp.MustCompile(`[.!?…]["'”’»)\]]*\s+|[。！？]["'”’」』）]*|\n\s*\n`)
+
+// boundaryCut returns where to cut a chunk of at most size bytes off data:
+// after the last sentence end in its second half for the "sentence"
+// boundary, after the last whitespace for "word", and at the last character
+// boundary for "char", each falling back to the next if there is none.
+func boundaryCut(data []byte, size int, boundary string) int {
+	switch boundary {
+	case "sentence":
+		if ends := sentenceEnd.FindAllIndex(data[size/2:size], -1); len(ends) > 0 {
+			return size/2 + ends[len(ends)-1][1]
+		}
+	case "char":
+		return runeCut(data, size)
+	}
+	return chunkCut(data, size)
+}
+
+// chunkCut returns where to cut a chunk of at most size bytes off data: after
+// the last whitespace in its second half, or else at the last character
+// boundary.
+func chunkCut(data []byte, size int) int {
+	if i := bytes.LastIndexAny(data[size/2:size], " \t\r\n"); i >= 0 {
+		return size/2 + i + 1
+	}
+	return runeCut(data, size)
+}
+
+// runeCut returns the last character boundary in the first size bytes of
+// data, or size if data is not UTF-8 there.
+func runeCut(data []byte, size int) int {
+	if size >= len(data) {
+		return len(data)
+	}
+	for cut := size; cut > 0 && size-cut < utf8.UTFMax; cut-- {
+		if utf8.RuneStart(data[cut]) {
+			return cut
+		}
+	}
+	return size
+}
+
+// completeRunes returns the length of data without a trailing incomplete
+// UTF-8 sequence.
+func completeRunes(data []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		start := len(data) - i
+		if utf8.RuneStart(data[start]) {
+			if !utf8.FullRune(data[start:]) {
+				return start
+			}
+			break
+		}
+	}
+	return len(data)
+}
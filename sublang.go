@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// subtitleCue is a cue of a subtitle file.
+type subtitleCue struct {
+	// number is the position of the cue in the file, from 1, and line the
+	// number of the line of its timing.
+	number     int
+	line       int
+	start, end string
+	text       string
+}
+
+var (
+	subtitleTiming   = regexp.MustCompile(`^\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{2}[,.]\d{1,3})`)
+	subtitleLanguage = regexp.MustCompile(`^(?i)language:\s*(\S+)`)
+	subtitleMarkup   = regexp.MustCompile(`<[^>]*>|\{[^}]*\}|[♪♫#]+`)
+)
+
+// subtitleExtensions are the extensions of the subtitle files sublang reads.
+var subtitleExtensions = []string{".srt", ".vtt", ".ass", ".ssa"}
+
+// readSubtitles returns the cues of SubRip, WebVTT or SubStation Alpha
+// source, and the language its header declares, if any.
+func readSubtitles(source string, ass bool) ([]subtitleCue, string) {
+	source = strings.TrimPrefix(source, "\ufeff")
+	lines := strings.Split(strings.ReplaceAll(source, "\r\n", "\n"), "\n")
+	var cues []subtitleCue
+	lang := ""
+	if ass {
+		// Dialogue lines hold the fields named by the Format line of the
+		// [Events] section, the text last, as it may contain commas.
+		format := []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+		for i, line := range lines {
+			name, value, _ := strings.Cut(line, ":")
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "language":
+				if lang == "" && len(cues) == 0 {
+					lang = strings.TrimSpace(value)
+				}
+			case "format":
+				format = splitList(strings.ToLower(strings.ReplaceAll(value, " ", "")))
+			case "dialogue":
+				fields := strings.SplitN(value, ",", len(format))
+				if len(fields) != len(format) {
+					continue
+				}
+				cue := subtitleCue{number: len(cues) + 1, line: i + 1}
+				for j, field := range format {
+					switch field {
+					case "start":
+						cue.start = strings.TrimSpace(fields[j])
+					case "end":
+						cue.end = strings.TrimSpace(fields[j])
+					case "text":
+						cue.text = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(fields[j])
+					}
+				}
+				cues = append(cues, cue)
+			}
+		}
+	} else {
+		// Cues are blocks of lines, separated by blank lines, starting with
+		// a timing line after an optional number or identifier; blocks
+		// without one are headers, notes and styles.
+		var cue *subtitleCue
+		for i, line := range lines {
+			switch {
+			case strings.TrimSpace(line) == "":
+				cue = nil
+			case cue != nil:
+				cue.text += line + "\n"
+			case subtitleTiming.MatchString(line):
+				m := subtitleTiming.FindStringSubmatch(line)
+				cues = append(cues, subtitleCue{number: len(cues) + 1, line: i + 1, start: m[1], end: m[2]})
+				cue = &cues[len(cues)-1]
+			case len(cues) == 0 && lang == "":
+				if m := subtitleLanguage.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+					lang = m[1]
+				}
+			}
+		}
+	}
+	for i := range cues {
+		cues[i].text = subtitleText(cues[i].text)
+	}
+	return cues, lang
+}
+
+// subtitleText returns the text of a cue without markup, music notes and
+// the dashes starting the lines of a dialogue, on one line.
+func subtitleText(s string) string {
+	s = html.UnescapeString(subtitleMarkup.ReplaceAllString(s, " "))
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimLeft(strings.TrimSpace(line), "-–— "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(strings.Fields(strings.Join(lines, " ")), " ")
+}
+
+// subtitleFileLanguage returns the language a subtitle file is named for,
+// the last dotted part of its name naming one ("ep01.de.srt",
+// "movie.pt-BR.forced.vtt", "film.ger.srt"), or "".
+func subtitleFileLanguage(path string) string {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		part := parts[i]
+		switch strings.ToLower(part) {
+		case "forced", "sdh", "cc", "hi", "default", "full":
+			continue
+		}
+		if (localeName.MatchString(part) || len(part) == 3) && declaredLanguage(part) != "" {
+			return part
+		}
+		return ""
+	}
+	return ""
+}
+
+// subtitleStretch is a run of cues in another language than the declared
+// one, with the cues between them whose language is not known.
+type subtitleStretch struct {
+	first, last subtitleCue
+	// cues is the number of cues detected in another language.
+	cues    int
+	results []langid.Confidence
+}
+
+// runSubLang implements the sublang command. It checks that the cues of
+// subtitle files are in the declared language, and reports stretches of
+// cues in another language, such as dialogue left untranslated.
+func runSubLang(args []string) int {
+	fs := flag.NewFlagSet("sublang", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0.3,
+		"Confidence threshold, cues and stretches scoring below it are not counted as another language (0.0-1.0)")
+	lang := fs.String("lang", "",
+		"Language of the subtitles (default: the language in each file name or header, or else the language of most cues)")
+	minLength := fs.Int("min-length", 15,
+		"Minimum length in characters of the text of a cue to check it")
+	minCues := fs.Int("min-cues", 2,
+		"Minimum number of cues in another language to report a stretch")
+	encoding := fs.String("encoding", "auto",
+		"Character encoding of the files; 'auto' detects it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Report the language consistency of subtitle files.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli sublang [OPTIONS] PATH...\n\n")
+		fmt.Fprintf(os.Stderr, "Directories are searched recursively for .srt, .vtt, .ass and .ssa files. Prints\n")
+		fmt.Fprintf(os.Stderr, "for each file its language, the number of cues, the share of the checked cues\n")
+		fmt.Fprintf(os.Stderr, "in its language and the stretches of cues in other languages, then a summary on\n")
+		fmt.Fprintf(os.Stderr, "stderr. Exits with status 1 if any file has such stretches.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *lang != "" && declaredLanguage(*lang) == "" {
+		fmt.Fprintf(os.Stderr, "error: invalid -lang %q\n", *lang)
+		return 2
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+	checker := &langChecker{detector: detector, threshold: *threshold}
+
+	files, inconsistent := 0, 0
+	out := bufio.NewWriter(os.Stdout)
+	err = walkFiles(fs.Args(), func(path string) {
+		ext := strings.ToLower(filepath.Ext(path))
+		if !slices.Contains(subtitleExtensions, ext) {
+			return
+		}
+		f, _, err := openFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+			return
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		var source string
+		if err == nil {
+			source, err = extractPlain(data, *encoding)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+			return
+		}
+		cues, header := readSubtitles(source, ext == ".ass" || ext == ".ssa")
+		files++
+
+		// Cues are detected before the language is known, as it may be the
+		// language of most of them.
+		checks := make([]langCheck, len(cues))
+		languages := make(map[string]int)
+		for i, cue := range cues {
+			where := fmt.Sprintf("%s:%d", path, cue.line)
+			if utf8.RuneCountInString(cue.text) < *minLength {
+				checks[i] = langCheck{status: "short", page: where}
+				continue
+			}
+			checks[i] = checker.check(where, "cue", "", cue.text)
+			if checks[i].status != "unknown" {
+				languages[checks[i].results[0].Code]++
+			}
+		}
+		declared, origin := *lang, ""
+		switch {
+		case declared != "":
+		case subtitleFileLanguage(path) != "":
+			declared = subtitleFileLanguage(path)
+		case header != "" && declaredLanguage(header) != "":
+			declared = header
+		default:
+			for code, n := range languages {
+				if declared == "" || n > languages[declared] || (n == languages[declared] && code < declared) {
+					declared = code
+				}
+			}
+			origin = " (most cues)"
+		}
+		if declared == "" {
+			fmt.Fprintf(out, "%s: no language, %d cues\n", path, len(cues))
+			return
+		}
+
+		counts := make(map[string]int)
+		var stretches []subtitleStretch
+		var stretch *subtitleStretch
+		// end closes the stretch being read, keeping it if enough of its
+		// cues are in another language and so is its text as a whole.
+		end := func() {
+			if stretch == nil {
+				return
+			}
+			if stretch.cues >= *minCues {
+				var text []string
+				for _, cue := range cues[stretch.first.number-1 : stretch.last.number] {
+					text = append(text, cue.text)
+				}
+				check := checker.check(path, "stretch", declared, strings.Join(text, " "))
+				if check.status == "mismatch" {
+					stretch.results = check.results
+					stretches = append(stretches, *stretch)
+				}
+			}
+			stretch = nil
+		}
+		for i, cue := range cues {
+			status := checks[i].status
+			if status != "short" {
+				status = checker.status(declared, checks[i].results)
+			}
+			counts[status]++
+			switch {
+			case status == "ok":
+				end()
+			case status == "mismatch" && stretch == nil:
+				stretch = &subtitleStretch{first: cue, last: cue, cues: 1}
+			case status == "mismatch":
+				stretch.last = cue
+				stretch.cues++
+			}
+		}
+		end()
+
+		share := "-"
+		if checked := counts["ok"] + counts["mismatch"]; checked > 0 {
+			share = fmt.Sprintf("%.1f%%", 100*float64(counts["ok"])/float64(checked))
+		}
+		fmt.Fprintf(out, "%s: %s%s, %d cues, %d checked, %s in %s, %d stretches in other languages\n",
+			path, declared, origin, len(cues), counts["ok"]+counts["mismatch"], share, declaredLanguage(declared), len(stretches))
+		for _, s := range stretches {
+			text := make([]string, 0, s.last.number-s.first.number+1)
+			for _, cue := range cues[s.first.number-1 : s.last.number] {
+				text = append(text, cue.text)
+			}
+			fmt.Fprintf(out, "  cues %d-%d (line %d, %s --> %s): %s %s %s\n", s.first.number, s.last.number, s.first.line,
+				s.first.start, s.last.end, s.results[0].Code, formatScore(s.results[0].Score), excerpt(strings.Join(text, " "), 60))
+		}
+		if len(stretches) > 0 {
+			inconsistent++
+		}
+	})
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d files, %d with stretches in other languages\n", files, inconsistent)
+	if inconsistent > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// syslogSDID is the ID of the structured data element -syslog-forward adds
+// to messages, under the enterprise number RFC 5424 reserves for examples.
+const syslogSDID = "lingua@32473"
+
+// syslogMsg is a syslog message in either the RFC 5424 or the traditional
+// BSD (RFC 3164) format. Absent fields are empty, except priority, which is
+// -1.
+type syslogMsg struct {
+	priority                                    int
+	timestamp, hostname, appName, procID, msgID string
+	// structured is the structured data of an RFC 5424 message, its
+	// bracketed elements.
+	structured string
+	text       string
+}
+
+// parseSyslog parses a syslog message. Text without a priority is taken as
+// the message itself.
+func parseSyslog(line string) syslogMsg {
+	m := syslogMsg{priority: -1}
+	line = strings.TrimRight(line, "\r\n\x00")
+	rest, ok := strings.CutPrefix(line, "<")
+	end := strings.IndexByte(rest, '>')
+	if !ok || end < 1 || end > 3 {
+		m.text = line
+		return m
+	}
+	priority, err := strconv.Atoi(rest[:end])
+	if err != nil || priority > 191 {
+		m.text = line
+		return m
+	}
+	m.priority = priority
+	rest = rest[end+1:]
+	if version, after, ok := strings.Cut(rest, " "); ok && version == "1" {
+		parseSyslog5424(&m, after)
+	} else {
+		parseSyslog3164(&m, rest)
+	}
+	return m
+}
+
+// parseSyslog5424 parses the header after the version, the structured data
+// and the message of an RFC 5424 message.
+func parseSyslog5424(m *syslogMsg, rest string) {
+	fields := []*string{&m.timestamp, &m.hostname, &m.appName, &m.procID, &m.msgID}
+	for _, field := range fields {
+		value, after, _ := strings.Cut(rest, " ")
+		if value != "-" {
+			*field = value
+		}
+		rest = after
+	}
+	if strings.HasPrefix(rest, "-") {
+		rest = rest[1:]
+	} else {
+		// Elements are bracketed; values are quoted, with '"', '\' and ']'
+		// escaped by a backslash.
+		i, depth, quoted := 0, 0, false
+	scan:
+		for ; i < len(rest); i++ {
+			switch c := rest[i]; {
+			case c == '\\' && quoted:
+				i++
+			case c == '"':
+				quoted = !quoted
+			case c == '[' && !quoted:
+				depth++
+			case c == ']' && !quoted:
+				depth--
+			case c == ' ' && depth == 0:
+				break scan
+			}
+		}
+		m.structured, rest = rest[:i], rest[i:]
+	}
+	m.text = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\ufeff")
+}
+
+// parseSyslog3164 parses the timestamp, host name, tag and message of a BSD
+// message, such as "Oct 11 22:14:15 mymachine su[42]: 'su root' failed".
+func parseSyslog3164(m *syslogMsg, rest string) {
+	if len(rest) > 16 && rest[15] == ' ' {
+		if _, err := time.Parse(time.Stamp, rest[:15]); err == nil {
+			m.timestamp = rest[:15]
+			rest = rest[16:]
+			if host, after, ok := strings.Cut(rest, " "); ok {
+				m.hostname, rest = host, after
+			}
+		}
+	}
+	// The tag ends at the first character that can not be part of it.
+	if i := strings.IndexAny(rest, ":[ "); i > 0 && i <= 48 {
+		tag := rest[:i]
+		after := rest[i:]
+		if strings.HasPrefix(after, "[") {
+			if pid, more, ok := strings.Cut(after[1:], "]"); ok {
+				m.procID, after = pid, more
+			}
+		}
+		if strings.HasPrefix(after, ":") {
+			m.appName = tag
+			rest = strings.TrimPrefix(after[1:], " ")
+		}
+	}
+	m.text = rest
+}
+
+// source names the sender of the message, its host and application.
+func (m syslogMsg) source() string {
+	switch {
+	case m.hostname != "" && m.appName != "":
+		return m.hostname + "/" + m.appName
+	case m.hostname != "":
+		return m.hostname
+	}
+	return m.appName
+}
+
+// format returns m in the RFC 5424 format, with element added to its
+// structured data if it is set.
+func (m syslogMsg) format(element string) string {
+	nilValue := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+	priority := m.priority
+	if priority < 0 {
+		priority = 13 // user.notice
+	}
+	timestamp := m.timestamp
+	if t, err := time.Parse(time.Stamp, timestamp); err == nil {
+		// BSD timestamps lack the year and the time zone.
+		now := time.Now()
+		timestamp = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local).Format(time.RFC3339)
+	}
+	structured := m.structured + element
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s %s", priority, nilValue(timestamp), nilValue(m.hostname),
+		nilValue(m.appName), nilValue(m.procID), nilValue(m.msgID), nilValue(structured))
+	if m.text != "" {
+		b.WriteString(" " + m.text)
+	}
+	return b.String()
+}
+
+// syslogElement returns the structured data element -syslog-forward adds
+// for a language and score; score is empty for unknown languages.
+func syslogElement(language, score string) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	element := fmt.Sprintf(`[%s lang="%s"`, syslogSDID, escape.Replace(language))
+	if score != "" {
+		element += fmt.Sprintf(` score="%s"`, score)
+	}
+	return element + "]"
+}
+
+// syslogForwarder sends messages to a syslog server, framed by octet
+// counting over TCP (RFC 6587).
+type syslogForwarder struct {
+	conn   net.Conn
+	stream bool
+}
+
+// dialSyslog connects to the server at rawURL, udp://host[:514] or
+// tcp://host[:601].
+func dialSyslog(rawURL string) (*syslogForwarder, error) {
+	network, address, err := syslogAddress(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout(network, address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogForwarder{conn: conn, stream: network == "tcp"}, nil
+}
+
+// syslogAddress splits a udp:// or tcp:// URL into network and address,
+// with the default port of the network.
+func syslogAddress(rawURL string) (network, address string, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	switch u.Scheme {
+	case "udp":
+		return "udp", hostPort(u, "514"), nil
+	case "tcp":
+		return "tcp", hostPort(u, "601"), nil
+	}
+	return "", "", fmt.Errorf("unsupported scheme %q (udp or tcp)", u.Scheme)
+}
+
+func (f *syslogForwarder) send(message string) error {
+	if f.stream {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	_, err := io.WriteString(f.conn, message)
+	return err
+}
+
+func (f *syslogForwarder) close() {
+	f.conn.Close()
+}
+
+// syslogConnLimits bound the TCP connections of -syslog clients, so that
+// connections clients leak do not accumulate until file descriptors run out.
+type syslogConnLimits struct {
+	// idleTimeout closes connections that send nothing for this long (0 for
+	// no limit).
+	idleTimeout time.Duration
+	// keepAlive is the period of TCP keepalive probes, which detect peers
+	// that went away without closing their connection (negative for none).
+	keepAlive time.Duration
+	// maxConns is the number of connections beyond which new ones are
+	// closed at once (0 for no limit).
+	maxConns int
+}
+
+// idleConn is a connection whose reads fail once it has been idle for
+// timeout.
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c idleConn) Read(b []byte) (int, error) {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(b)
+}
+
+// listenSyslog receives syslog messages at rawURL, udp://[host]:port or
+// tcp://[host]:port, one per datagram over UDP and framed by octet counting
+// or by newlines over TCP, with the connections bounded by limits. It passes
+// the messages to work, like lines in parallel line mode, and the results to
+// emit together with their message, until the process is interrupted.
+func listenSyslog(rawURL string, limits syslogConnLimits, workers int, ordered bool, work func(lineResult) lineResult, emit func(syslogMsg, lineResult)) error {
+	network, address, err := syslogAddress(rawURL)
+	if err != nil {
+		return err
+	}
+	messages := make(chan string, 64)
+	// stop closes the listener and the open connections, ending messages.
+	var stop func()
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", address)
+		if err != nil {
+			return err
+		}
+		stop = func() { conn.Close() }
+		go func() {
+			buf := make([]byte, 64*1024)
+			for {
+				n, _, err := conn.ReadFrom(buf)
+				if err != nil {
+					close(messages)
+					return
+				}
+				messages <- string(buf[:n])
+			}
+		}()
+	} else {
+		lc := net.ListenConfig{KeepAlive: limits.keepAlive}
+		listener, err := lc.Listen(context.Background(), "tcp", address)
+		if err != nil {
+			return err
+		}
+		var mu sync.Mutex
+		open := make(map[net.Conn]bool)
+		stop = func() {
+			listener.Close()
+			mu.Lock()
+			defer mu.Unlock()
+			for conn := range open {
+				conn.Close()
+			}
+		}
+		go func() {
+			var wg sync.WaitGroup
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					break
+				}
+				mu.Lock()
+				full := limits.maxConns > 0 && len(open) >= limits.maxConns
+				if !full {
+					open[conn] = true
+				}
+				mu.Unlock()
+				if full {
+					fmt.Fprintf(os.Stderr, "warning: -syslog: %s: %d connections open already (see -syslog-max-conns), closed\n", conn.RemoteAddr(), limits.maxConns)
+					conn.Close()
+					continue
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					readSyslogStream(conn, limits.idleTimeout, messages)
+					mu.Lock()
+					delete(open, conn)
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+			close(messages)
+		}()
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupted:
+		case <-done:
+		}
+		stop()
+	}()
+
+	// parsed holds the messages in flight by number, without their text.
+	var mu sync.Mutex
+	parsed := make(map[int]syslogMsg)
+	produce := func(send func(lineResult)) error {
+		number := 0
+		for raw := range messages {
+			number++
+			m := parseSyslog(raw)
+			text := m.text
+			m.text = ""
+			mu.Lock()
+			parsed[number] = m
+			mu.Unlock()
+			send(lineResult{number: number, line: text})
+		}
+		return nil
+	}
+	return parallelMap(workers, ordered, produce, work, func(r lineResult) {
+		mu.Lock()
+		m := parsed[r.number]
+		delete(parsed, r.number)
+		mu.Unlock()
+		m.text = r.line
+		emit(m, r)
+	})
+}
+
+// readSyslogStream reads the messages of a TCP connection until it is
+// closed, or has been idle for idleTimeout if that is set. Messages starting
+// with a length are framed by octet counting, others end at a newline.
+func readSyslogStream(conn net.Conn, idleTimeout time.Duration, messages chan<- string) {
+	defer conn.Close()
+	var r *bufio.Reader
+	if idleTimeout > 0 {
+		r = bufio.NewReader(idleConn{conn, idleTimeout})
+	} else {
+		r = bufio.NewReader(conn)
+	}
+	for {
+		first, err := r.Peek(1)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			fmt.Fprintf(os.Stderr, "warning: -syslog: %s: idle for %v, closed\n", conn.RemoteAddr(), idleTimeout)
+		}
+		if err != nil {
+			return
+		}
+		if first[0] >= '1' && first[0] <= '9' {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+			if err != nil || n > 1<<20 {
+				fmt.Fprintf(os.Stderr, "warning: -syslog: %s: invalid frame length\n", conn.RemoteAddr())
+				return
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			messages <- string(buf)
+			continue
+		}
+		line, err := r.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			messages <- line
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				fmt.Fprintf(os.Stderr, "warning: -syslog: %v\n", err)
+			}
+			return
+		}
+	}
+}
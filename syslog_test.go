@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+)
+
+func TestParseSyslog(t *testing.T) {
+	tests := []struct {
+		line string
+		want syslogMsg
+	}{
+		{
+			"<34>1 2003-10-11T22:14:15.003Z mymachine.example.com su - ID47 - \ufeff'su root' failed for lonvick on /dev/pts/8",
+			syslogMsg{priority: 34, timestamp: "2003-10-11T22:14:15.003Z", hostname: "mymachine.example.com",
+				appName: "su", msgID: "ID47", text: "'su root' failed for lonvick on /dev/pts/8"},
+		},
+		{
+			`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"][x@1 v="a\]b \"c\""] An application event`,
+			syslogMsg{priority: 165, timestamp: "2003-10-11T22:14:15.003Z", hostname: "mymachine.example.com",
+				appName: "evntslog", msgID: "ID47", structured: `[exampleSDID@32473 iut="3" eventSource="Application"][x@1 v="a\]b \"c\""]`,
+				text: "An application event"},
+		},
+		{
+			"<165>1 - - - - - -",
+			syslogMsg{priority: 165},
+		},
+		{
+			"<34>Oct 11 22:14:15 mymachine su[42]: 'su root' failed for lonvick on /dev/pts/8\r\n",
+			syslogMsg{priority: 34, timestamp: "Oct 11 22:14:15", hostname: "mymachine", appName: "su", procID: "42",
+				text: "'su root' failed for lonvick on /dev/pts/8"},
+		},
+		{
+			"<13>no tag here",
+			syslogMsg{priority: 13, text: "no tag here"},
+		},
+		{
+			"<192>1 - - - - - - out of range",
+			syslogMsg{priority: -1, text: "<192>1 - - - - - - out of range"},
+		},
+		{
+			"plain text",
+			syslogMsg{priority: -1, text: "plain text"},
+		},
+	}
+	for _, test := range tests {
+		if got := parseSyslog(test.line); got != test.want {
+			t.Errorf("parseSyslog(%q) = %+v, want %+v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestSyslogFormat(t *testing.T) {
+	tests := []struct {
+		line, element, want string
+	}{
+		{
+			`<165>1 2003-10-11T22:14:15.003Z host app 7 ID47 [a@1 b="c"] Bonjour à tous`,
+			syslogElement("fr", "0.97"),
+			`<165>1 2003-10-11T22:14:15.003Z host app 7 ID47 [a@1 b="c"][lingua@32473 lang="fr" score="0.97"] Bonjour à tous`,
+		},
+		{
+			`<165>1 2003-10-11T22:14:15.003Z host app - - - Hello`,
+			syslogElement("unknown", ""),
+			`<165>1 2003-10-11T22:14:15.003Z host app - - [lingua@32473 lang="unknown"] Hello`,
+		},
+		{
+			`<165>1 2003-10-11T22:14:15.003Z host app - - -`,
+			"",
+			`<165>1 2003-10-11T22:14:15.003Z host app - - -`,
+		},
+		{
+			"just text",
+			syslogElement(`a"]\`, ""),
+			`<13>1 - - - - - [lingua@32473 lang="a\"\]\\"] just text`,
+		},
+	}
+	for _, test := range tests {
+		if got := parseSyslog(test.line).format(test.element); got != test.want {
+			t.Errorf("format of %q with %q:\n got %s\nwant %s", test.line, test.element, got, test.want)
+		}
+	}
+}
+
+func TestReadSyslogStream(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		io.WriteString(client, "11 hello world21 <13>1 - - - - - - two")
+		io.WriteString(client, "<13>newline framed\r\n\n")
+		io.WriteString(client, "14 with\nnewline\n!last without newline")
+		client.Close()
+	}()
+	messages := make(chan string, 10)
+	readSyslogStream(server, 0, messages)
+	close(messages)
+	want := []string{"hello world", "<13>1 - - - - - - two", "<13>newline framed", "with\nnewline\n!", "last without newline"}
+	var got []string
+	for message := range messages {
+		got = append(got, message)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("read %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d is %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSyslogForwarderFraming(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		client, server := net.Pipe()
+		f := &syslogForwarder{conn: client, stream: stream}
+		go func() {
+			f.send("<13>1 - - - - - - hi")
+			f.close()
+		}()
+		data, _ := io.ReadAll(server)
+		want := "<13>1 - - - - - - hi"
+		if stream {
+			want = "20 " + want
+		}
+		if string(data) != want {
+			t.Errorf("stream %v: sent %q, want %q", stream, data, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// tracer creates the OpenTelemetry spans of lingua-cli. Unless -otel sets
+// up an exporter, they are discarded at next to no cost.
+var tracer = otel.Tracer("github.com/rinodrops/lingua-cli-go")
+
+// traceCtx holds the span covering the whole run, the parent of the spans
+// of the run.
+var traceCtx = context.Background()
+
+// startTracing exports spans over OTLP/HTTP, configured by the standard
+// OTEL_EXPORTER_OTLP_* environment variables (by default to a collector on
+// localhost:4318), and starts the span of the run. The spans are sent when
+// the process exits.
+func startTracing() error {
+	ctx := context.Background()
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return fmt.Errorf("-otel: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the defaults.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "lingua-cli"),
+			attribute.String("service.version", version)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return fmt.Errorf("-otel: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+
+	var span trace.Span
+	traceCtx, span = tracer.Start(ctx, "lingua-cli")
+	atExit(func() {
+		span.End()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: exporting traces: %v\n", err)
+		}
+	})
+	return nil
+}
+
+// endSpan ends span, recording err if it is not nil.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceBuild builds the detector configured by cfg in a span.
+func traceBuild(ctx context.Context, cfg *detectorConfig) (langid.Detector, error) {
+	_, span := tracer.Start(ctx, "build detector", trace.WithAttributes(
+		attribute.String("lingua.backend", cfg.backend),
+		attribute.StringSlice("lingua.languages", splitList(cfg.languages))))
+	detector, err := cfg.build()
+	endSpan(span, err)
+	return detector, err
+}
+
+// traceDetect classifies text with detect in a span recording the size of
+// the text and the best language.
+func traceDetect(ctx context.Context, text string, detect func(string) ([]langid.Confidence, error)) ([]langid.Confidence, error) {
+	_, span := tracer.Start(ctx, "detect", trace.WithAttributes(attribute.Int("lingua.text.bytes", len(text))))
+	results, err := detect(text)
+	if len(results) > 0 {
+		span.SetAttributes(
+			attribute.String("lingua.language", results[0].Code),
+			attribute.Float64("lingua.score", results[0].Score))
+	}
+	endSpan(span, err)
+	return results, err
+}
+
+// traceSpans splits text with detect in a span recording the size of the
+// text and the number of spans.
+func traceSpans(ctx context.Context, text string, detect func(string) ([]langid.Span, error)) ([]langid.Span, error) {
+	_, span := tracer.Start(ctx, "detect spans", trace.WithAttributes(attribute.Int("lingua.text.bytes", len(text))))
+	spans, err := detect(text)
+	span.SetAttributes(attribute.Int("lingua.spans", len(spans)))
+	endSpan(span, err)
+	return spans, err
+}
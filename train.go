@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// runTrain implements the train subcommand, which builds a character n-gram
+// profile for a language from a monolingual corpus (one sentence or paragraph
+// per line). Profiles are loaded in detect mode with -profiles.
+func runTrain(args []string) int {
+	fs := flag.NewFlagSet("train", flag.ExitOnError)
+	code := fs.String("code", "",
+		"Language code the profile is trained for, e.g. an ISO 639-3 code such as 'sco' (required)")
+	output := fs.String("o", "",
+		"Output file for the profile (default: <code>.profile.json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Build a custom language profile from a monolingual corpus.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli train -code CODE [-o FILE] [CORPUS]...\n\n")
+		fmt.Fprintf(os.Stderr, "Reads the corpus from stdin when no files are given.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if *code == "" {
+		fmt.Fprintf(os.Stderr, "error: train requires -code\n")
+		return 2
+	}
+	if *output == "" {
+		*output = strings.ToLower(*code) + ".profile.json"
+	}
+
+	corpus := io.Reader(os.Stdin)
+	if fs.NArg() > 0 {
+		var readers []io.Reader
+		for _, path := range fs.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				return 1
+			}
+			defer f.Close()
+			// Keep the last line of one file from running into the next.
+			readers = append(readers, f, strings.NewReader("\n"))
+		}
+		corpus = io.MultiReader(readers...)
+	}
+
+	profile, err := langid.Train(*code, corpus)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	if err := profile.Save(f); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "error writing %s: %v\n", *output, err)
+		return 1
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing %s: %v\n", *output, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "profile for %q written to %s\n", profile.Code, *output)
+	return 0
+}
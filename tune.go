@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// prediction is the detector's verdict on one labeled text.
+type prediction struct {
+	code    string
+	score   float64
+	margin  float64
+	correct bool
+}
+
+// thresholdChoice is the loosest threshold reaching the target precision.
+type thresholdChoice struct {
+	value     float64
+	precision float64
+	// coverage is the share of texts of the language accepted and correct.
+	coverage float64
+	ok       bool
+}
+
+// runTune implements the tune subcommand. It sweeps the -c and -d thresholds
+// over the detector's results on a labeled development set and recommends the
+// loosest values that still reach the target precision.
+func runTune(args []string) int {
+	fs := flag.NewFlagSet("tune", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	target := fs.Float64("precision", 0.95,
+		"Target precision of accepted results (0.0-1.0)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Recommend -c and -d thresholds reaching a target precision on a labeled development set.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli tune [OPTIONS] DEVFILE\n\n")
+		fmt.Fprintf(os.Stderr, "DEVFILE holds one label<TAB>text pair per line ('-' reads stdin).\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	if *target <= 0 || *target > 1 {
+		fmt.Fprintf(os.Stderr, "error: -precision must lie in between 0.0 and 1.0\n")
+		return 2
+	}
+	if cfg.minRelDist != 0 {
+		fmt.Fprintf(os.Stderr, "error: tune sweeps -d itself and can not be combined with it\n")
+		return 2
+	}
+	entries, err := readLabeled(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	predictions := make([]prediction, len(entries))
+	support := make(map[string]int)
+	for i, entry := range entries {
+		results, err := detector.ConfidenceValues(entry.text)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return 1
+		}
+		p := prediction{code: langid.Unknown}
+		if len(results) > 0 && results[0].Score > 0 {
+			p = prediction{
+				code:    results[0].Code,
+				score:   results[0].Score,
+				margin:  langid.Margin(results),
+				correct: results[0].Code == entry.label,
+			}
+		}
+		predictions[i] = p
+		support[entry.label]++
+	}
+
+	var languages []string
+	for code := range support {
+		languages = append(languages, code)
+	}
+	sort.Strings(languages)
+
+	fmt.Printf("target precision %.2f on %d texts\n\n", *target, len(entries))
+	fmt.Printf("language\ttexts\t-c\tprecision\tcoverage\t-d\tprecision\tcoverage\n")
+	printRow := func(name string, n int, selected []prediction) {
+		c := sweepThreshold(selected, n, *target, func(p prediction) float64 { return p.score })
+		d := sweepThreshold(selected, n, *target, func(p prediction) float64 { return p.margin })
+		fmt.Printf("%s\t%d\t%s\t%s\n", name, n, c, d)
+	}
+	for _, code := range languages {
+		var selected []prediction
+		for _, p := range predictions {
+			if p.code == code {
+				selected = append(selected, p)
+			}
+		}
+		printRow(code, support[code], selected)
+	}
+	printRow("(all)", len(entries), predictions)
+	return 0
+}
+
+// sweepThreshold finds the lowest threshold on key for which the predictions
+// at or above it reach the target precision. total is the number of texts the
+// coverage is relative to.
+func sweepThreshold(predictions []prediction, total int, target float64, key func(prediction) float64) thresholdChoice {
+	var candidates []prediction
+	for _, p := range predictions {
+		if p.code != langid.Unknown {
+			candidates = append(candidates, p)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return key(candidates[i]) > key(candidates[j]) })
+
+	best := thresholdChoice{}
+	hits := 0
+	for i, p := range candidates {
+		if p.correct {
+			hits++
+		}
+		// Only cut between distinct values: equal keys are accepted together.
+		if i+1 < len(candidates) && key(candidates[i+1]) == key(p) {
+			continue
+		}
+		precision := float64(hits) / float64(i+1)
+		if precision >= target {
+			best = thresholdChoice{
+				value:     key(p),
+				precision: precision,
+				coverage:  float64(hits) / float64(total),
+				ok:        true,
+			}
+		}
+	}
+	return best
+}
+
+func (t thresholdChoice) String() string {
+	if !t.ok {
+		return "-\t-\t-"
+	}
+	return fmt.Sprintf("%.4f\t%.4f\t%.4f", t.value, t.precision, t.coverage)
+}
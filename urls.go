@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// maxSitemapDepth limits how deeply sitemap indexes may nest.
+const maxSitemapDepth = 3
+
+// pageResult is the outcome of classifying one URL.
+type pageResult struct {
+	url     string
+	status  string // HTTP status code, or "error"
+	results []langid.Confidence
+	err     error
+}
+
+// runURLs implements the urls subcommand. It fetches every page of a list of
+// URLs or a sitemap, classifies it, and reports each URL with its HTTP
+// status and language, followed by a summary.
+func runURLs(args []string) int {
+	fs := flag.NewFlagSet("urls", flag.ExitOnError)
+	var cfg detectorConfig
+	cfg.register(fs)
+	threshold := fs.Float64("c", 0,
+		"Confidence threshold, pages scoring below it are reported as 'unknown' (0.0-1.0)")
+	workers := fs.Int("j", 8,
+		"Number of pages to fetch and classify in parallel")
+	timeout := fs.Duration("http-timeout", 30*time.Second,
+		"Time limit for fetching a page, including its content")
+	retries := fs.Int("http-retries", 2,
+		"How often to retry fetching a page after a network error, HTTP 429 or 5xx")
+	encoding := fs.String("encoding", "auto",
+		"Character encoding of pages that do not declare one; 'auto' detects it")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Classify the language of web pages.\n\n")
+		fmt.Fprintf(os.Stderr, "Usage: lingua-cli urls [OPTIONS] LIST|SITEMAP...\n\n")
+		fmt.Fprintf(os.Stderr, "Each argument is a file with one URL per line ('-' reads stdin), or a sitemap\n")
+		fmt.Fprintf(os.Stderr, "(file or URL, possibly gzipped or a sitemap index). Prints URL, HTTP status,\n")
+		fmt.Fprintf(os.Stderr, "language and score per page, then a summary on stderr.\n\n")
+		fmt.Fprintf(os.Stderr, "Options:\n")
+		fs.PrintDefaults()
+	}
+	parseFlags(fs, args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	if *retries < 0 {
+		fmt.Fprintf(os.Stderr, "error: -http-retries must not be negative\n")
+		return 2
+	}
+	fetcher.client.Timeout = *timeout
+	fetcher.retries = *retries
+	detector, err := cfg.build()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+	defer langid.Close(detector)
+
+	produce := func(send func(string)) error {
+		for _, arg := range fs.Args() {
+			if err := readURLList(arg, send, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	work := func(url string) pageResult {
+		page := pageResult{url: url, status: "error"}
+		resp, err := fetcher.fetch(url)
+		var statusErr *httpStatusError
+		if errors.As(err, &statusErr) {
+			page.status = fmt.Sprint(statusErr.code)
+		}
+		if err != nil {
+			page.err = err
+			return page
+		}
+		defer resp.Body.Close()
+		page.status = fmt.Sprint(resp.StatusCode)
+		text, err := extractReader(url, resp.Body, resp.ContentLength, *encoding, fileLimits{})
+		if err != nil {
+			page.err = err
+			return page
+		}
+		page.results, page.err = detector.ConfidenceValues(strings.Join(strings.Fields(text), " "))
+		return page
+	}
+
+	languages := make(map[string]int)
+	statuses := make(map[string]int)
+	pages := 0
+	out := bufio.NewWriter(os.Stdout)
+	emit := func(page pageResult) {
+		pages++
+		statuses[page.status]++
+		code, score := langid.Unknown, ""
+		if len(page.results) > 0 && page.results[0].Score > 0 && page.results[0].Score >= *threshold {
+			code, score = page.results[0].Code, formatScore(page.results[0].Score)
+		}
+		if page.err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", page.url, page.err)
+			code = "-"
+		} else {
+			languages[code]++
+		}
+		fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", page.url, page.status, code, score)
+	}
+	err = parallelMap(*workers, true, produce, work, emit)
+	out.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "\n%d pages\n", pages)
+	fmt.Fprintf(os.Stderr, "status:   %s\n", formatCounts(statuses, pages))
+	fmt.Fprintf(os.Stderr, "language: %s\n", formatCounts(languages, pages))
+	return 0
+}
+
+// formatCounts lists counts in descending order, with their share of total.
+func formatCounts(counts map[string]int, total int) string {
+	var keys []string
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s %d (%.1f%%)", key, counts[key], 100*float64(counts[key])/float64(total)))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// readURLList passes the URLs listed in source to send. source is a file
+// or URL holding either a sitemap or one URL per line; sitemap indexes are
+// followed up to maxSitemapDepth levels.
+func readURLList(source string, send func(string), depth int) error {
+	var data []byte
+	var err error
+	switch {
+	case source == "-":
+		data, err = io.ReadAll(os.Stdin)
+	case isURL(source):
+		var resp io.ReadCloser
+		if resp, _, err = fetcher.open(source); err == nil {
+			data, err = io.ReadAll(resp)
+			resp.Close()
+		}
+	default:
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", source, err)
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		if data, err = io.ReadAll(r); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	}
+
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				send(line)
+			}
+		}
+		return nil
+	}
+
+	// A sitemap lists pages in <urlset><url><loc>, a sitemap index lists
+	// further sitemaps in <sitemapindex><sitemap><loc>.
+	var sitemap struct {
+		XMLName  xml.Name
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal(data, &sitemap); err != nil {
+		return fmt.Errorf("%s: invalid sitemap: %w", source, err)
+	}
+	for _, url := range sitemap.URLs {
+		send(strings.TrimSpace(url))
+	}
+	for _, nested := range sitemap.Sitemaps {
+		if depth >= maxSitemapDepth {
+			return fmt.Errorf("%s: sitemap indexes nested too deeply", source)
+		}
+		if err := readURLList(strings.TrimSpace(nested), send, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
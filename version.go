@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/pemistahl/lingua-go"
+)
+
+// buildDate is the time the binary was built, set by the Makefile with
+// -ldflags "-X main.buildDate=...".
+var buildDate = ""
+
+// printVersion prints the version of lingua-cli and what it was built from,
+// for telling apart binaries whose results differ: the version of lingua-go,
+// the commit and the Go release, and the number of languages lingua detects.
+func printVersion(w io.Writer) {
+	fmt.Fprintf(w, "lingua-cli %s\n", version)
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(w, "  %-12s %s\n", name, value)
+		}
+	}
+	linguaVersion, commit, commitDate := "", "", ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/pemistahl/lingua-go" {
+				linguaVersion = dep.Version
+				if dep.Replace != nil {
+					linguaVersion += " => " + dep.Replace.Path + " " + dep.Replace.Version
+				}
+			}
+		}
+		modified := false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				commit = setting.Value
+			case "vcs.time":
+				commitDate = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if commit != "" && modified {
+			commit += " (modified)"
+		}
+	}
+	row("lingua-go", linguaVersion)
+	row("commit", commit)
+	row("commit date", commitDate)
+	row("build date", buildDate)
+	row("go", fmt.Sprintf("%s %s/%s", runtime.Version(), runtime.GOOS, runtime.GOARCH))
+	row("languages", fmt.Sprint(len(lingua.AllLanguages())))
+}
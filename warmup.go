@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// warmupSamples are texts in the scripts lingua-go tells languages apart
+// in by their models, which it loads on the first text that needs them.
+var warmupSamples = []string{
+	"The quick brown fox jumps over the lazy dog near the river bank.",
+	"Съешь же ещё этих мягких французских булок да выпей чаю.",
+	"نص تجريبي لتحميل نماذج اللغات التي تكتب بالحروف العربية.",
+	"यह देवनागरी लिपि में लिखी गई भाषाओं के मॉडल लोड करने के लिए है।",
+	"这是一个用于加载汉字语言模型的示例句子。",
+	"Ελληνικά, 日本語のテキスト, 한국어 문장, עברית, ภาษาไทย.",
+}
+
+// warmUp classifies texts with d, or the built-in samples if there are
+// none, so that the backend loads its models before the first real text
+// rather than while answering it. Every text is also classified repeated
+// beyond 120 characters, from where lingua-go uses other n-gram models.
+// The caches of d are skipped, so that they keep only real texts.
+func warmUp(d langid.Detector, texts []string) error {
+	if len(texts) == 0 {
+		texts = warmupSamples
+	}
+	d = langid.Uncached(d)
+	for _, text := range texts {
+		long := text
+		for len([]rune(long)) <= 120 {
+			long += " " + text
+		}
+		for _, text := range []string{text, long} {
+			if _, err := d.ConfidenceValues(text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readWarmupFile returns the non-empty lines of the -warmup-file file.
+func readWarmupFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var texts []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if text := strings.TrimSpace(scanner.Text()); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return texts, nil
+}
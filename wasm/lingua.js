@@ -0,0 +1,32 @@
+// JavaScript API of lingua.wasm. Load wasm_exec.js from the Go distribution
+// first, which defines the Go class running the module:
+//
+//   const lingua = await loadLingua("lingua.wasm");
+//   lingua.detect("Hallo Welt", { languages: ["de", "nl"] });
+//   // => { language: "de", score: 0.93 }
+//
+// Options are languages (ISO 639-1 codes, default all) and lowAccuracy, and
+// for detect also threshold (results scoring below it are "unknown") and
+// all (add the full distribution as confidences). Errors are thrown.
+
+export async function loadLingua(url = "lingua.wasm") {
+  const go = new Go();
+  const response = fetch(url);
+  const { instance } = WebAssembly.instantiateStreaming
+    ? await WebAssembly.instantiateStreaming(response, go.importObject)
+    : await WebAssembly.instantiate(await (await response).arrayBuffer(), go.importObject);
+  go.run(instance);
+
+  const api = globalThis.lingua;
+  const check = (result) => {
+    if (result && result.error) {
+      throw new Error(result.error);
+    }
+    return result;
+  };
+  return {
+    detect: (text, options = {}) => check(api.detect(text, options)),
+    detectMulti: (text, options = {}) => check(api.detectMulti(text, options)).spans,
+    languages: () => api.languages(),
+  };
+}
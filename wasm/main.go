@@ -0,0 +1,165 @@
+//go:build js && wasm
+
+// Command wasm builds the lingua detector as a WebAssembly module for
+// browsers and other JavaScript hosts. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o lingua.wasm ./wasm
+//
+// and load it with lingua.js, which wraps the functions the module
+// registers as globalThis.lingua.
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"syscall/js"
+
+	lingua "github.com/pemistahl/lingua-go"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+var (
+	mu sync.Mutex
+	// detectors holds the detectors built so far, by configuration.
+	detectors = map[string]langid.Detector{}
+)
+
+// options are the settings a caller may pass with each text.
+type options struct {
+	languages   []string
+	lowAccuracy bool
+	threshold   float64
+	all         bool
+}
+
+func parseOptions(v js.Value) options {
+	var opts options
+	if v.Type() != js.TypeObject {
+		return opts
+	}
+	if languages := v.Get("languages"); languages.Type() == js.TypeObject {
+		for i := 0; i < languages.Length(); i++ {
+			opts.languages = append(opts.languages, strings.ToLower(languages.Index(i).String()))
+		}
+		sort.Strings(opts.languages)
+	}
+	if low := v.Get("lowAccuracy"); low.Type() == js.TypeBoolean {
+		opts.lowAccuracy = low.Bool()
+	}
+	if threshold := v.Get("threshold"); threshold.Type() == js.TypeNumber {
+		opts.threshold = threshold.Float()
+	}
+	if all := v.Get("all"); all.Type() == js.TypeBoolean {
+		opts.all = all.Bool()
+	}
+	return opts
+}
+
+// detectorFor returns the detector for opts, building it on first use.
+func detectorFor(opts options) (langid.Detector, error) {
+	key := strings.Join(opts.languages, ",")
+	if opts.lowAccuracy {
+		key += ";q"
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if d, ok := detectors[key]; ok {
+		return d, nil
+	}
+	d, err := langid.New("lingua", langid.Options{Languages: opts.languages, LowAccuracy: opts.lowAccuracy})
+	if err != nil {
+		return nil, err
+	}
+	detectors[key] = d
+	return d, nil
+}
+
+func failure(err error) any {
+	return map[string]any{"error": err.Error()}
+}
+
+// detect(text, options) returns {language, score}, with the full
+// distribution as confidences if options.all is set.
+func detect(_ js.Value, args []js.Value) any {
+	if len(args) == 0 {
+		return map[string]any{"error": "missing text"}
+	}
+	var opts options
+	if len(args) > 1 {
+		opts = parseOptions(args[1])
+	}
+	d, err := detectorFor(opts)
+	if err != nil {
+		return failure(err)
+	}
+	values, err := d.ConfidenceValues(args[0].String())
+	if err != nil {
+		return failure(err)
+	}
+	result := map[string]any{"language": langid.Unknown, "score": 0.0}
+	if len(values) > 0 && values[0].Score > 0 && values[0].Score >= opts.threshold {
+		result["language"], result["score"] = values[0].Code, values[0].Score
+	}
+	if opts.all {
+		confidences := make([]any, len(values))
+		for i, value := range values {
+			confidences[i] = map[string]any{"language": value.Code, "score": value.Score}
+		}
+		result["confidences"] = confidences
+	}
+	return result
+}
+
+// detectMulti(text, options) returns the single-language sections of a
+// mixed text as [{start, end, language, text}], with UTF-8 byte offsets.
+func detectMulti(_ js.Value, args []js.Value) any {
+	if len(args) == 0 {
+		return map[string]any{"error": "missing text"}
+	}
+	var opts options
+	if len(args) > 1 {
+		opts = parseOptions(args[1])
+	}
+	d, err := detectorFor(opts)
+	if err != nil {
+		return failure(err)
+	}
+	md, _ := langid.AsMulti(d)
+	text := args[0].String()
+	spans, err := md.DetectMultiple(text)
+	if err != nil {
+		return failure(err)
+	}
+	result := make([]any, len(spans))
+	for i, span := range spans {
+		result[i] = map[string]any{
+			"start": span.Start, "end": span.End, "language": span.Code, "text": text[span.Start:span.End],
+		}
+	}
+	return map[string]any{"spans": result}
+}
+
+// languages() returns the supported ISO 639-1 codes.
+func languages(js.Value, []js.Value) any {
+	var codes []string
+	for _, lang := range lingua.AllLanguages() {
+		codes = append(codes, langid.LinguaCode(lang))
+	}
+	sort.Strings(codes)
+	result := make([]any, len(codes))
+	for i, code := range codes {
+		result[i] = code
+	}
+	return result
+}
+
+func main() {
+	js.Global().Set("lingua", js.ValueOf(map[string]any{
+		"detect":      js.FuncOf(detect),
+		"detectMulti": js.FuncOf(detectMulti),
+		"languages":   js.FuncOf(languages),
+	}))
+	select {}
+}
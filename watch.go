@@ -0,0 +1,70 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long a file must stay unchanged before it is classified,
+// so that files still being written are not classified half-way.
+const watchSettle = 500 * time.Millisecond
+
+// watchDirectory calls classify for every file created or modified in dir
+// once it has settled, until the process is interrupted. Hidden files are
+// ignored.
+func watchDirectory(dir string, classify func(path string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		return err
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+
+	settled := make(chan string)
+	timers := make(map[string]*time.Timer)
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if strings.HasPrefix(filepath.Base(event.Name), ".") {
+				continue
+			}
+			if timer, ok := timers[event.Name]; ok {
+				timer.Reset(watchSettle)
+				continue
+			}
+			path := event.Name
+			timers[path] = time.AfterFunc(watchSettle, func() { settled <- path })
+		case path := <-settled:
+			delete(timers, path)
+			if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+			classify(path)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-interrupted:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// webhookInterval is how long results wait for their batch to fill before
+// they are sent anyway, so that slow streams and -watch notify in time.
+const webhookInterval = 5 * time.Second
+
+// webhookSink posts the results to the -webhook URL as JSON arrays of
+// json-v1 records, batch records at a time.
+type webhookSink struct {
+	url       string
+	batch     int
+	threshold float64
+	labels    labelMap
+	fetcher   *urlFetcher
+
+	mu      sync.Mutex
+	records []jsonRecord
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+func newWebhookSink(url string, batch, retries int, threshold float64, labels labelMap) *webhookSink {
+	s := &webhookSink{
+		url:       url,
+		batch:     batch,
+		threshold: threshold,
+		labels:    labels,
+		fetcher:   &urlFetcher{client: &http.Client{Timeout: 30 * time.Second}, retries: retries, backoff: time.Second},
+		done:      make(chan struct{}),
+	}
+	s.stopped.Add(1)
+	go func() {
+		defer s.stopped.Done()
+		ticker := time.NewTicker(webhookInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.flush()
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// add queues the record of a result for the next batch.
+func (s *webhookSink) add(name string, number int, text string, results []langid.Confidence) {
+	if s == nil {
+		return
+	}
+	r := sinkRecord(name, number, text, results, s.threshold, s.labels)
+	s.mu.Lock()
+	s.records = append(s.records, r)
+	full := len(s.records) >= s.batch
+	s.mu.Unlock()
+	if full {
+		s.flush()
+	}
+}
+
+// flush posts the queued records. A batch that still fails after the
+// retries is dropped with a warning rather than stopping the run.
+func (s *webhookSink) flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.records) == 0 {
+		return
+	}
+	body, err := json.Marshal(s.records)
+	n := len(s.records)
+	s.records = s.records[:0]
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -webhook: dropping %d results: %v\n", n, err)
+		return
+	}
+	resp, err := s.fetcher.do(func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -webhook: dropping %d results: %v\n", n, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// close sends the remaining records and stops the timer.
+func (s *webhookSink) close() {
+	close(s.done)
+	s.stopped.Wait()
+	s.flush()
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rinodrops/lingua-cli-go/langid"
+)
+
+// zmqTypes lists the values accepted by -zmq-type.
+var zmqTypes = []string{"pull", "rep"}
+
+// zmqPeerTypes maps the socket types lingua-cli opens to the types of the
+// peers they exchange messages with.
+var zmqPeerTypes = map[string]string{"PULL": "PUSH", "PUSH": "PULL", "REP": "REQ"}
+
+// ZMTP frame flags.
+const (
+	zmqMore    = 0x01
+	zmqLong    = 0x02
+	zmqCommand = 0x04
+)
+
+// zmqMaxCommand is the size beyond which command frames, such as the READY
+// of a handshake, are refused and their connection closed, whatever
+// -max-record-bytes allows for messages, as peers send them before they
+// are known.
+const zmqMaxCommand = 64 << 10
+
+// zmqSocket is a ZeroMQ socket of one type, speaking just enough of ZMTP 3.1
+// with the NULL mechanism to exchange messages with libzmq peers: PULL and
+// REP sockets receive, PUSH sockets send. A socket either connects to a
+// single peer, reconnecting when the connection is lost, or binds and
+// accepts any number of them.
+type zmqSocket struct {
+	kind     string
+	address  string
+	listener net.Listener
+	// maxMessage is the size beyond which the connections of peers sending
+	// larger messages are closed (0 for no limit).
+	maxMessage int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	// peers are the open connections, and next the one PUSH sends the next
+	// message to.
+	peers []*zmqPeer
+	next  int
+
+	incoming chan zmqMsg
+	done     chan struct{}
+	closed   bool
+}
+
+// zmqPeer is a connection to a peer.
+type zmqPeer struct {
+	conn net.Conn
+	r    *bufio.Reader
+	// mu serializes writes, as PONGs are sent while results are.
+	mu sync.Mutex
+	// gone is closed once the connection is dropped.
+	gone chan struct{}
+}
+
+// zmqMsg is a message received, with the peer and, for REP, the routing
+// envelope to answer it with.
+type zmqMsg struct {
+	peer     *zmqPeer
+	envelope [][]byte
+	data     []byte
+}
+
+// zmqEndpoint returns the address of an endpoint, tcp://host:port to
+// connect to or tcp://*:port to bind, and whether to bind.
+func zmqEndpoint(endpoint string) (string, bool, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false, err
+	}
+	if u.Scheme != "tcp" {
+		return "", false, fmt.Errorf("unsupported scheme %q (tcp)", u.Scheme)
+	}
+	if u.Port() == "" {
+		return "", false, fmt.Errorf("%s: no port", endpoint)
+	}
+	if u.Hostname() == "*" || u.Hostname() == "" {
+		return ":" + u.Port(), true, nil
+	}
+	return u.Host, false, nil
+}
+
+// openZMQ opens a socket of kind, PULL, PUSH or REP, at endpoint.
+func openZMQ(endpoint, kind string, maxMessage int) (*zmqSocket, error) {
+	address, bind, err := zmqEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	s := &zmqSocket{
+		kind:       kind,
+		address:    address,
+		maxMessage: maxMessage,
+		incoming:   make(chan zmqMsg, 64),
+		done:       make(chan struct{}),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	if bind {
+		if s.listener, err = net.Listen("tcp", address); err != nil {
+			return nil, err
+		}
+		go s.accept()
+		return s, nil
+	}
+	peer, err := s.dial()
+	if err != nil {
+		return nil, err
+	}
+	go s.reconnect(peer)
+	return s, nil
+}
+
+// accept adds the peers connecting to a bound socket.
+func (s *zmqSocket) accept() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			if _, err := s.add(conn); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: ZeroMQ: %s: %v\n", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// dial connects a connecting socket to its peer.
+func (s *zmqSocket) dial() (*zmqPeer, error) {
+	conn, err := net.DialTimeout("tcp", s.address, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return s.add(conn)
+}
+
+// reconnect connects a connecting socket again whenever it loses its peer,
+// until the socket is closed.
+func (s *zmqSocket) reconnect(peer *zmqPeer) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-peer.gone:
+		}
+		fmt.Fprintf(os.Stderr, "warning: ZeroMQ: lost the connection to %s, reconnecting\n", s.address)
+		for {
+			var err error
+			if peer, err = s.dial(); err == nil {
+				break
+			}
+			select {
+			case <-s.done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// add performs the handshake on a new connection and adds its peer, reading
+// its messages.
+func (s *zmqSocket) add(conn net.Conn) (*zmqPeer, error) {
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	peer := &zmqPeer{conn: conn, r: bufio.NewReader(conn), gone: make(chan struct{})}
+	if err := peer.handshake(s.kind); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		conn.Close()
+		return nil, net.ErrClosed
+	}
+	s.peers = append(s.peers, peer)
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	go s.read(peer)
+	return peer, nil
+}
+
+// drop closes the connection of a peer and forgets it.
+func (s *zmqSocket) drop(peer *zmqPeer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, p := range s.peers {
+		if p == peer {
+			s.peers = append(s.peers[:i], s.peers[i+1:]...)
+			peer.conn.Close()
+			close(peer.gone)
+			return
+		}
+	}
+}
+
+// read passes the messages of a peer to incoming until its connection is
+// lost, which for PUSH, whose peers send nothing, it only watches for.
+func (s *zmqSocket) read(peer *zmqPeer) {
+	defer s.drop(peer)
+	for {
+		frames, err := peer.readMessage(s.maxMessage)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				fmt.Fprintf(os.Stderr, "warning: ZeroMQ: %s: %v\n", peer.conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if s.kind == "PUSH" {
+			continue
+		}
+		msg := zmqMsg{peer: peer}
+		if s.kind == "REP" {
+			// The envelope of a request ends with an empty delimiter frame.
+			i := 0
+			for i < len(frames) && len(frames[i]) > 0 {
+				i++
+			}
+			if i == len(frames) {
+				fmt.Fprintf(os.Stderr, "warning: ZeroMQ: %s: request without envelope delimiter\n", peer.conn.RemoteAddr())
+				continue
+			}
+			msg.envelope, frames = frames[:i+1], frames[i+1:]
+		}
+		// The parts of multipart messages are classified as one text.
+		msg.data = bytes.Join(frames, nil)
+		select {
+		case s.incoming <- msg:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// receive returns the next message received from any peer, or an error
+// once the socket is closed.
+func (s *zmqSocket) receive() (zmqMsg, error) {
+	select {
+	case msg := <-s.incoming:
+		return msg, nil
+	case <-s.done:
+		return zmqMsg{}, net.ErrClosed
+	}
+}
+
+// send sends a message to the peers of a PUSH socket in turn, waiting for a
+// peer to connect if there is none.
+func (s *zmqSocket) send(data []byte) error {
+	for {
+		s.mu.Lock()
+		for len(s.peers) == 0 && !s.closed {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return net.ErrClosed
+		}
+		s.next %= len(s.peers)
+		peer := s.peers[s.next]
+		s.next++
+		s.mu.Unlock()
+		if err := peer.writeMessage([][]byte{data}); err == nil {
+			return nil
+		}
+		// The message goes to another peer, or to the same one once it
+		// reconnects.
+		s.drop(peer)
+	}
+}
+
+// reply answers a request received by a REP socket.
+func (s *zmqSocket) reply(msg zmqMsg, data []byte) error {
+	frames := append(append([][]byte(nil), msg.envelope...), data)
+	return msg.peer.writeMessage(frames)
+}
+
+// close closes the socket and the connections of its peers.
+func (s *zmqSocket) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.done)
+	s.cond.Broadcast()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, peer := range s.peers {
+		peer.conn.Close()
+	}
+}
+
+// handshake exchanges the greetings of ZMTP 3.1 and the READY commands of
+// the NULL mechanism, and checks that the peer is of a matching type.
+func (p *zmqPeer) handshake(kind string) error {
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xff, 0x7f
+	greeting[10], greeting[11] = 3, 1
+	copy(greeting[12:32], "NULL")
+	if _, err := p.conn.Write(greeting); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(p.r, greeting); err != nil {
+		return err
+	}
+	if greeting[0] != 0xff || greeting[9] != 0x7f || greeting[10] < 3 {
+		return errors.New("not a ZMTP 3 peer")
+	}
+	if mechanism := string(bytes.TrimRight(greeting[12:32], "\x00")); mechanism != "NULL" {
+		return fmt.Errorf("unsupported security mechanism %s (NULL)", mechanism)
+	}
+	ready := zmqCommandBody("READY")
+	ready = zmqProperty(ready, "Socket-Type", kind)
+	if err := p.writeFrame(zmqCommand, ready); err != nil {
+		return err
+	}
+	// Whatever the peer sends first must be a command.
+	flags, body, err := p.readFrame(zmqMaxCommand)
+	if err != nil {
+		return err
+	}
+	name, properties, ok := zmqParseCommand(body)
+	if flags&zmqCommand == 0 || !ok || name != "READY" {
+		return errors.New("expected a READY command")
+	}
+	if peerType := properties["Socket-Type"]; peerType != zmqPeerTypes[kind] {
+		return fmt.Errorf("%s socket can not talk to a %s socket", kind, peerType)
+	}
+	return nil
+}
+
+// zmqCommandBody returns the body of a command without data.
+func zmqCommandBody(name string) []byte {
+	return append([]byte{byte(len(name))}, name...)
+}
+
+// zmqProperty appends a metadata property to the body of a command.
+func zmqProperty(body []byte, name, value string) []byte {
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(value)))
+	return append(body, value...)
+}
+
+// zmqParseCommand returns the name of a command and, for READY, its
+// metadata properties.
+func zmqParseCommand(body []byte) (string, map[string]string, bool) {
+	if len(body) == 0 || len(body) < 1+int(body[0]) {
+		return "", nil, false
+	}
+	name, rest := string(body[1:1+body[0]]), body[1+body[0]:]
+	properties := make(map[string]string)
+	if name != "READY" {
+		return name, properties, true
+	}
+	for len(rest) > 0 {
+		n := int(rest[0])
+		if len(rest) < 1+n+4 {
+			return "", nil, false
+		}
+		key := string(rest[1 : 1+n])
+		size := binary.BigEndian.Uint32(rest[1+n:])
+		rest = rest[1+n+4:]
+		if uint64(len(rest)) < uint64(size) {
+			return "", nil, false
+		}
+		properties[key], rest = string(rest[:size]), rest[size:]
+	}
+	return name, properties, true
+}
+
+// readFrame reads a frame, refusing message frames larger than maxSize
+// unless it is 0, and command frames larger than zmqMaxCommand.
+func (p *zmqPeer) readFrame(maxSize int) (byte, []byte, error) {
+	flags, err := p.r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmqLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(p.r, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		n, err := p.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(n)
+	}
+	if flags&zmqCommand != 0 && size > zmqMaxCommand {
+		return 0, nil, fmt.Errorf("command of %d bytes is too large", size)
+	}
+	if maxSize > 0 && size > uint64(maxSize) || size > 1<<31 {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large (see -max-record-bytes)", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// readMessage reads the frames of the next message, answering the PINGs of
+// heartbeats in between.
+func (p *zmqPeer) readMessage(maxSize int) ([][]byte, error) {
+	var frames [][]byte
+	size := 0
+	for {
+		flags, body, err := p.readFrame(maxSize)
+		if err != nil {
+			return nil, err
+		}
+		if flags&zmqCommand != 0 {
+			name, _, ok := zmqParseCommand(body)
+			if ok && name == "PING" && len(body) >= 7 {
+				// PING carries a TTL of 2 bytes followed by the context
+				// PONG echoes.
+				pong := append(zmqCommandBody("PONG"), body[7:]...)
+				if err := p.writeFrame(zmqCommand, pong); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if size += len(body); maxSize > 0 && size > maxSize {
+			return nil, fmt.Errorf("message of more than %d bytes (see -max-record-bytes)", maxSize)
+		}
+		frames = append(frames, body)
+		if flags&zmqMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// writeFrame writes a single frame.
+func (p *zmqPeer) writeFrame(flags byte, body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.conn.Write(appendZMQFrame(nil, flags, body))
+	return err
+}
+
+// writeMessage writes a message of one or more frames.
+func (p *zmqPeer) writeMessage(frames [][]byte) error {
+	var buf []byte
+	for i, frame := range frames {
+		flags := byte(0)
+		if i < len(frames)-1 {
+			flags = zmqMore
+		}
+		buf = appendZMQFrame(buf, flags, frame)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err := p.conn.Write(buf)
+	return err
+}
+
+func appendZMQFrame(buf []byte, flags byte, body []byte) []byte {
+	if len(body) > 255 {
+		buf = append(buf, flags|zmqLong)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(len(body)))
+	} else {
+		buf = append(buf, flags, byte(len(body)))
+	}
+	return append(buf, body...)
+}
+
+// consume passes the messages received to work, like lines in parallel line
+// mode, and the results to emit together with their message, until the
+// process is interrupted.
+func (s *zmqSocket) consume(workers int, ordered bool, work func(lineResult) lineResult, emit func(zmqMsg, lineResult)) error {
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-interrupted:
+			s.close()
+		case <-stop:
+		}
+	}()
+
+	// messages holds the messages in flight by number, without their data.
+	var mu sync.Mutex
+	messages := make(map[int]zmqMsg)
+	produce := func(send func(lineResult)) error {
+		for number := 1; ; number++ {
+			msg, err := s.receive()
+			if err != nil {
+				return nil
+			}
+			data := msg.data
+			msg.data = nil
+			mu.Lock()
+			messages[number] = msg
+			mu.Unlock()
+			send(lineResult{number: number, line: string(data)})
+		}
+	}
+	return parallelMap(workers, ordered, produce, work, func(r lineResult) {
+		mu.Lock()
+		msg := messages[r.number]
+		delete(messages, r.number)
+		mu.Unlock()
+		emit(msg, r)
+	})
+}
+
+// zmqSink sends the results as json-v1 records on a ZeroMQ PUSH socket.
+type zmqSink struct {
+	socket    *zmqSocket
+	threshold float64
+	labels    labelMap
+}
+
+// add sends the record of a result, reporting failures as warnings.
+func (s *zmqSink) add(name string, number int, text string, results []langid.Confidence) {
+	if s == nil {
+		return
+	}
+	payload, err := json.Marshal(sinkRecord(name, number, text, results, s.threshold, s.labels))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -zmq-push: %v\n", err)
+		return
+	}
+	if err := s.socket.send(payload); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: -zmq-push: %v\n", err)
+	}
+}
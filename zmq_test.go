@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZMQFrame(t *testing.T) {
+	long := bytes.Repeat([]byte{'x'}, 256)
+	tests := []struct {
+		flags   byte
+		body    []byte
+		encoded []byte
+	}{
+		{0, []byte("hi"), []byte{0x00, 0x02, 'h', 'i'}},
+		{zmqMore, nil, []byte{0x01, 0x00}},
+		{zmqCommand, bytes.Repeat([]byte{'x'}, 255), append([]byte{0x04, 0xff}, bytes.Repeat([]byte{'x'}, 255)...)},
+		{0, long, append([]byte{0x02, 0, 0, 0, 0, 0, 0, 0x01, 0x00}, long...)},
+		{zmqMore, long, append([]byte{0x03, 0, 0, 0, 0, 0, 0, 0x01, 0x00}, long...)},
+	}
+	for _, test := range tests {
+		encoded := appendZMQFrame(nil, test.flags, test.body)
+		if !bytes.Equal(encoded, test.encoded) {
+			t.Errorf("frame 0x%02x of %d bytes encoded as % x, want % x", test.flags, len(test.body),
+				encoded[:min(len(encoded), 9)], test.encoded[:min(len(test.encoded), 9)])
+		}
+		p := &zmqPeer{r: bufio.NewReader(bytes.NewReader(encoded))}
+		flags, body, err := p.readFrame(0)
+		if err != nil || flags&^zmqLong != test.flags || !bytes.Equal(body, test.body) {
+			t.Errorf("frame 0x%02x of %d bytes read as 0x%02x of %d bytes, %v", test.flags, len(test.body), flags, len(body), err)
+		}
+	}
+}
+
+func TestZMQReadFrameLimits(t *testing.T) {
+	tests := []struct {
+		encoded []byte
+		maxSize int
+		err     string
+	}{
+		{[]byte{0x00, 0x0a}, 5, "frame of 10 bytes is too large (see -max-record-bytes)"},
+		{[]byte{0x06, 0, 0, 0, 0, 0, 0x01, 0x00, 0x01}, 0, "command of 65537 bytes is too large"},
+		{[]byte{0x02, 0, 0, 0, 0x01, 0, 0, 0, 0}, 0, "frame of 4294967296 bytes is too large (see -max-record-bytes)"},
+		{[]byte{0x00, 0x02, 'h'}, 0, "unexpected EOF"},
+	}
+	for _, test := range tests {
+		p := &zmqPeer{r: bufio.NewReader(bytes.NewReader(test.encoded))}
+		if _, _, err := p.readFrame(test.maxSize); err == nil || err.Error() != test.err {
+			t.Errorf("readFrame(% x) = %v, want %s", test.encoded, err, test.err)
+		}
+	}
+}
+
+func TestZMQCommand(t *testing.T) {
+	ready := zmqProperty(zmqCommandBody("READY"), "Socket-Type", "PULL")
+	want := []byte("\x05READY\x0bSocket-Type\x00\x00\x00\x04PULL")
+	if !bytes.Equal(ready, want) {
+		t.Errorf("READY encoded as % x, want % x", ready, want)
+	}
+	name, properties, ok := zmqParseCommand(ready)
+	if !ok || name != "READY" || len(properties) != 1 || properties["Socket-Type"] != "PULL" {
+		t.Errorf("READY parsed as %q %v %v", name, properties, ok)
+	}
+	if name, _, ok := zmqParseCommand([]byte("\x04PING\x00\x0a")); !ok || name != "PING" {
+		t.Errorf("PING parsed as %q %v", name, ok)
+	}
+	for _, malformed := range []string{
+		"",
+		"\x06READY",
+		"\x05READY\x0bSocket-Type\x00\x00",
+		"\x05READY\x0bSocket-Type\x00\x00\x00\x05PULL",
+	} {
+		if _, _, ok := zmqParseCommand([]byte(malformed)); ok {
+			t.Errorf("parsed malformed command %q", malformed)
+		}
+	}
+}
+
+func TestZMQReadMessage(t *testing.T) {
+	var input []byte
+	input = appendZMQFrame(input, zmqMore, []byte("Bonjour "))
+	input = appendZMQFrame(input, zmqCommand, []byte("\x04PING\x00\x0actx"))
+	input = appendZMQFrame(input, 0, []byte("à tous"))
+	client, server := net.Pipe()
+	defer client.Close()
+	pong := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 10)
+		io.ReadFull(server, buf)
+		pong <- buf
+	}()
+	p := &zmqPeer{conn: client, r: bufio.NewReader(bytes.NewReader(input))}
+	frames, err := p.readMessage(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 || string(frames[0]) != "Bonjour " || string(frames[1]) != "à tous" {
+		t.Errorf("read frames %q", frames)
+	}
+	if got, want := <-pong, []byte("\x04\x08\x04PONGctx"); !bytes.Equal(got, want) {
+		t.Errorf("answered PING with % x, want % x", got, want)
+	}
+
+	input = appendZMQFrame(appendZMQFrame(nil, zmqMore, []byte("Bonjour ")), 0, []byte("à tous"))
+	p = &zmqPeer{r: bufio.NewReader(bytes.NewReader(input))}
+	if _, err := p.readMessage(10); err == nil || !strings.Contains(err.Error(), "message of more than 10 bytes") {
+		t.Errorf("readMessage of a 15-byte message with a limit of 10: %v", err)
+	}
+}
+
+// zmqConnPair returns the two ends of a TCP connection.
+func zmqConnPair(t *testing.T) (net.Conn, net.Conn) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	a, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestZMQGreeting(t *testing.T) {
+	a, b := zmqConnPair(t)
+	defer a.Close()
+	defer b.Close()
+	go (&zmqPeer{conn: a, r: bufio.NewReader(a)}).handshake("PULL")
+	greeting := make([]byte, 64)
+	if _, err := io.ReadFull(b, greeting); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 64)
+	copy(want, "\xff\x00\x00\x00\x00\x00\x00\x00\x00\x7f\x03\x01NULL")
+	if !bytes.Equal(greeting, want) {
+		t.Errorf("greeting % x, want % x", greeting, want)
+	}
+}
+
+func TestZMQHandshake(t *testing.T) {
+	tests := []struct {
+		kind, peer string
+		ok         bool
+	}{
+		{"PULL", "PUSH", true},
+		{"PUSH", "PULL", true},
+		{"PULL", "PULL", false},
+	}
+	for _, test := range tests {
+		a, b := zmqConnPair(t)
+		peerErr := make(chan error, 1)
+		go func() {
+			peerErr <- (&zmqPeer{conn: b, r: bufio.NewReader(b)}).handshake(test.peer)
+		}()
+		err := (&zmqPeer{conn: a, r: bufio.NewReader(a)}).handshake(test.kind)
+		if test.ok && err != nil || !test.ok && err == nil {
+			t.Errorf("%s with %s: %v", test.kind, test.peer, err)
+		}
+		a.Close()
+		b.Close()
+		<-peerErr
+	}
+}
+
+func TestZMQPushPull(t *testing.T) {
+	pull, err := openZMQ("tcp://*:0", "PULL", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pull.close()
+	_, port, _ := net.SplitHostPort(pull.listener.Addr().String())
+	push, err := openZMQ("tcp://127.0.0.1:"+port, "PUSH", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer push.close()
+	for _, text := range []string{"Bonjour à tous", strings.Repeat("long ", 100)} {
+		if err := push.send([]byte(text)); err != nil {
+			t.Fatal(err)
+		}
+		received := make(chan zmqMsg, 1)
+		go func() {
+			msg, _ := pull.receive()
+			received <- msg
+		}()
+		select {
+		case msg := <-received:
+			if string(msg.data) != text {
+				t.Errorf("received %q, want %q", msg.data, text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("no message received")
+		}
+	}
+}